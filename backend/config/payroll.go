@@ -0,0 +1,329 @@
+package config
+
+import (
+	"crypto/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // ZONA_WAKTU must load in images without a zoneinfo database
+)
+
+// BPJSConfig holds BPJS contribution rates (as fractions) and wage ceilings
+type BPJSConfig struct {
+	KesehatanPerusahaan float64
+	KesehatanKaryawan   float64
+	KesehatanBatasUpah  float64
+	JHTPerusahaan       float64
+	JHTKaryawan         float64
+	JKKKelasRisiko      int
+	JKMPerusahaan       float64
+	JPPerusahaan        float64
+	JPKaryawan          float64
+	JPBatasUpah         float64
+}
+
+// jkkTarif maps JKK risk classes (kelompok tingkat risiko) to employer rates
+var jkkTarif = map[int]float64{
+	1: 0.0024,
+	2: 0.0054,
+	3: 0.0089,
+	4: 0.0127,
+	5: 0.0174,
+}
+
+// GetBPJSConfig returns BPJS configuration from environment variables or defaults
+func GetBPJSConfig() *BPJSConfig {
+	return &BPJSConfig{
+		KesehatanPerusahaan: getEnvFloat("BPJS_KES_PERUSAHAAN", 0.04),
+		KesehatanKaryawan:   getEnvFloat("BPJS_KES_KARYAWAN", 0.01),
+		KesehatanBatasUpah:  getEnvFloat("BPJS_KES_BATAS_UPAH", 12000000),
+		JHTPerusahaan:       getEnvFloat("BPJS_JHT_PERUSAHAAN", 0.037),
+		JHTKaryawan:         getEnvFloat("BPJS_JHT_KARYAWAN", 0.02),
+		JKKKelasRisiko:      getEnvInt("BPJS_JKK_KELAS_RISIKO", 1),
+		JKMPerusahaan:       getEnvFloat("BPJS_JKM_PERUSAHAAN", 0.003),
+		JPPerusahaan:        getEnvFloat("BPJS_JP_PERUSAHAAN", 0.02),
+		JPKaryawan:          getEnvFloat("BPJS_JP_KARYAWAN", 0.01),
+		JPBatasUpah:         getEnvFloat("BPJS_JP_BATAS_UPAH", 10547400),
+	}
+}
+
+// JKKPerusahaan returns the employer JKK rate for the configured risk class
+func (c *BPJSConfig) JKKPerusahaan() float64 {
+	if tarif, ok := jkkTarif[c.JKKKelasRisiko]; ok {
+		return tarif
+	}
+	return jkkTarif[1]
+}
+
+// VariansConfig holds the default thresholds for flagging period-over-period changes
+type VariansConfig struct {
+	AmbangPersen  float64
+	AmbangNominal float64
+}
+
+// GetVariansConfig returns variance report thresholds from environment variables or defaults
+func GetVariansConfig() *VariansConfig {
+	return &VariansConfig{
+		AmbangPersen:  getEnvFloat("VARIANS_AMBANG_PERSEN", 10),
+		AmbangNominal: getEnvFloat("VARIANS_AMBANG_NOMINAL", 0),
+	}
+}
+
+// ProrataConfig holds the default method for pro-rating the salary of karyawan who join
+// or leave during a period: "kalender" (calendar days) or "hari_kerja" (working days of the kalender kerja)
+type ProrataConfig struct {
+	Metode string
+}
+
+// GetProrataConfig returns pro-rating configuration from environment variables or defaults
+func GetProrataConfig() *ProrataConfig {
+	return &ProrataConfig{
+		Metode: getEnv("PRORATA_METODE", "kalender"),
+	}
+}
+
+// MasaKerjaConfig holds the service-length rules for THR, gaji ke-13 and bonus runs:
+// karyawan with less than MinimalBulan full months of service get nothing, those with at
+// least BulanPenuh months get the full amount and those in between get masa kerja / BulanPenuh
+type MasaKerjaConfig struct {
+	MinimalBulan int
+	BulanPenuh   int
+}
+
+// GetMasaKerjaConfig returns service-length rules from environment variables or defaults
+func GetMasaKerjaConfig() *MasaKerjaConfig {
+	return &MasaKerjaConfig{
+		MinimalBulan: getEnvInt("MASA_KERJA_MINIMAL_BULAN", 1),
+		BulanPenuh:   getEnvInt("MASA_KERJA_BULAN_PENUH", 12),
+	}
+}
+
+// AbsensiGajiConfig holds the default attendance rules applied by generate-batch. An
+// amount of 0 turns its rule off: TunjanganMakanPerHadir replaces the flat tunjangan makan
+// with a rate per hadir day, PotonganPerAlpha is deducted per alpha day and
+// DendaPerTerlambat per day late according to the jadwal kerja.
+type AbsensiGajiConfig struct {
+	TunjanganMakanPerHadir float64
+	PotonganPerAlpha       float64
+	DendaPerTerlambat      float64
+}
+
+// GetAbsensiGajiConfig returns attendance rules from environment variables or defaults
+func GetAbsensiGajiConfig() *AbsensiGajiConfig {
+	return &AbsensiGajiConfig{
+		TunjanganMakanPerHadir: getEnvFloat("ABSENSI_MAKAN_PER_HADIR", 0),
+		PotonganPerAlpha:       getEnvFloat("ABSENSI_POTONGAN_PER_ALPHA", 0),
+		DendaPerTerlambat:      getEnvFloat("ABSENSI_DENDA_TERLAMBAT", 0),
+	}
+}
+
+// AutoAlphaConfig holds the schedule of the job proposing alpha for working days without
+// any absensi. When Aktif, it runs daily at Jam for the days since its last proposal, at
+// most HariMundur days back, up to yesterday.
+type AutoAlphaConfig struct {
+	Aktif      bool
+	Jam        string
+	HariMundur int
+}
+
+// GetAutoAlphaConfig returns the auto-alpha schedule from environment variables or defaults
+func GetAutoAlphaConfig() *AutoAlphaConfig {
+	return &AutoAlphaConfig{
+		Aktif:      getEnvBool("AUTO_ALPHA_AKTIF", false),
+		Jam:        getEnv("AUTO_ALPHA_JAM", "01:00"),
+		HariMundur: getEnvInt("AUTO_ALPHA_HARI_MUNDUR", 7),
+	}
+}
+
+// CutiConfig holds the leave entitlements. HakTahunan and HakAlasanPenting are working
+// days per year; up to MaksCarryOver unused days of cuti tahunan carry over to the next
+// year. MaksMelahirkan limits one cuti melahirkan in calendar days. Attachments such as
+// the surat dokter of cuti sakit are stored under LampiranDir.
+type CutiConfig struct {
+	HakTahunan       int
+	HakAlasanPenting int
+	MaksCarryOver    int
+	MaksMelahirkan   int
+	LampiranDir      string
+}
+
+// GetCutiConfig returns the leave entitlements from environment variables or defaults
+func GetCutiConfig() *CutiConfig {
+	return &CutiConfig{
+		HakTahunan:       getEnvInt("CUTI_HAK_TAHUNAN", 12),
+		HakAlasanPenting: getEnvInt("CUTI_HAK_ALASAN_PENTING", 5),
+		MaksCarryOver:    getEnvInt("CUTI_MAKS_CARRY_OVER", 6),
+		MaksMelahirkan:   getEnvInt("CUTI_MAKS_MELAHIRKAN", 90),
+		LampiranDir:      getEnv("LAMPIRAN_DIR", "uploads"),
+	}
+}
+
+// PresensiConfig holds the rules of self-service clock-in and clock-out. Times are taken
+// in ZonaWaktu. When KantorLatitude and KantorLongitude are set, a reported location must
+// be within RadiusMeter of the kantor desa; WajibLokasi rejects attempts without one.
+// QR tokens of the kiosk are signed with QRSecret and valid for QRMasaBerlaku.
+type PresensiConfig struct {
+	ZonaWaktu       *time.Location
+	KantorLatitude  float64
+	KantorLongitude float64
+	RadiusMeter     float64
+	WajibLokasi     bool
+	QRSecret        []byte
+	QRMasaBerlaku   time.Duration
+}
+
+// AdaGeofence reports whether the kantor desa location is configured
+func (c *PresensiConfig) AdaGeofence() bool {
+	return c.KantorLatitude != 0 || c.KantorLongitude != 0
+}
+
+// GetPresensiConfig returns the clock-in rules from environment variables or defaults
+func GetPresensiConfig() *PresensiConfig {
+	zona, err := time.LoadLocation(getEnv("ZONA_WAKTU", "Asia/Jakarta"))
+	if err != nil {
+		zona = time.Local
+	}
+	return &PresensiConfig{
+		ZonaWaktu:       zona,
+		KantorLatitude:  getEnvFloat("KANTOR_LATITUDE", 0),
+		KantorLongitude: getEnvFloat("KANTOR_LONGITUDE", 0),
+		RadiusMeter:     getEnvFloat("KANTOR_RADIUS_METER", 100),
+		WajibLokasi:     getEnvBool("PRESENSI_WAJIB_LOKASI", false),
+		QRSecret:        qrSecret(),
+		QRMasaBerlaku:   time.Duration(getEnvInt("QR_MASA_BERLAKU_DETIK", 15)) * time.Second,
+	}
+}
+
+// TingkatLembur is one tier of the overtime multipliers: the next Jam hours of a day's
+// overtime are paid at Pengali times the hourly rate. A Jam of 0 covers the remaining hours.
+type TingkatLembur struct {
+	Jam     float64
+	Pengali float64
+}
+
+// LemburConfig holds the overtime multipliers by kind of day, written as tiers of
+// "hours:multiplier" with "*" for the remaining hours, e.g. "1:1.5,*:2". The hari libur
+// entries whose jenis is in JenisLiburResmi are public holidays; other days off under the
+// kalender kerja are rest days. A karyawan's overtime is capped at MaksJamMingguan hours
+// per week (Senin to Minggu) and MaksJamBulanan per month, 0 for no cap; BlokirBatas
+// rejects overtime beyond a cap or the desa budget instead of only warning.
+// WajibSuratPerintah requires new overtime to be reported against an approved surat
+// perintah lembur; it is off by default so existing overtime entry keeps working.
+type LemburConfig struct {
+	HariKerja          []TingkatLembur
+	HariIstirahat      []TingkatLembur
+	HariLibur          []TingkatLembur
+	JenisLiburResmi    []string
+	MaksJamMingguan    float64
+	MaksJamBulanan     float64
+	BlokirBatas        bool
+	WajibSuratPerintah bool
+}
+
+// GetLemburConfig returns the overtime rules from environment variables or defaults: the
+// multipliers of Kepmenakertrans 102/2004 for a five-day week and the weekly cap of
+// PP 35/2021
+func GetLemburConfig() *LemburConfig {
+	return &LemburConfig{
+		HariKerja:          getEnvTingkatLembur("LEMBUR_PENGALI_HARI_KERJA", "1:1.5,*:2"),
+		HariIstirahat:      getEnvTingkatLembur("LEMBUR_PENGALI_HARI_ISTIRAHAT", "8:2,1:3,*:4"),
+		HariLibur:          getEnvTingkatLembur("LEMBUR_PENGALI_HARI_LIBUR", "8:2,1:3,*:4"),
+		JenisLiburResmi:    strings.Split(getEnv("LEMBUR_JENIS_LIBUR_RESMI", "nasional"), ","),
+		MaksJamMingguan:    getEnvFloat("LEMBUR_MAKS_JAM_MINGGUAN", 18),
+		MaksJamBulanan:     getEnvFloat("LEMBUR_MAKS_JAM_BULANAN", 72),
+		BlokirBatas:        getEnvBool("LEMBUR_BLOKIR_BATAS", true),
+		WajibSuratPerintah: getEnvBool("LEMBUR_WAJIB_SURAT_PERINTAH", false),
+	}
+}
+
+// getEnvTingkatLembur reads overtime tiers from key, falling back to defaultValue when
+// the variable is unset or invalid
+func getEnvTingkatLembur(key, defaultValue string) []TingkatLembur {
+	if tingkat, ok := parseTingkatLembur(os.Getenv(key)); ok {
+		return tingkat
+	}
+	tingkat, _ := parseTingkatLembur(defaultValue)
+	return tingkat
+}
+
+// parseTingkatLembur parses tiers such as "1:1.5,*:2". Only the last tier may cover the
+// remaining hours.
+func parseTingkatLembur(value string) ([]TingkatLembur, bool) {
+	if strings.TrimSpace(value) == "" {
+		return nil, false
+	}
+	var tingkat []TingkatLembur
+	bagian := strings.Split(value, ",")
+	for i, b := range bagian {
+		jam, pengali, ok := strings.Cut(strings.TrimSpace(b), ":")
+		if !ok {
+			return nil, false
+		}
+		t := TingkatLembur{}
+		if strings.TrimSpace(jam) != "*" {
+			j, err := strconv.ParseFloat(strings.TrimSpace(jam), 64)
+			if err != nil || j <= 0 {
+				return nil, false
+			}
+			t.Jam = j
+		} else if i != len(bagian)-1 {
+			return nil, false
+		}
+		p, err := strconv.ParseFloat(strings.TrimSpace(pengali), 64)
+		if err != nil || p <= 0 {
+			return nil, false
+		}
+		t.Pengali = p
+		tingkat = append(tingkat, t)
+	}
+	return tingkat, true
+}
+
+var (
+	qrSecretAcak     []byte
+	qrSecretAcakOnce sync.Once
+)
+
+// qrSecret returns QR_SECRET, or a random secret kept for the life of the process when it
+// is not set; kiosk tokens then stop working on restart, which their lifetime allows
+func qrSecret() []byte {
+	if secret := os.Getenv("QR_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	qrSecretAcakOnce.Do(func() {
+		qrSecretAcak = make([]byte, 32)
+		if _, err := rand.Read(qrSecretAcak); err != nil {
+			panic("failed to generate QR secret: " + err.Error())
+		}
+	})
+	return qrSecretAcak
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
@@ -2,31 +2,60 @@ package handlers
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"pemdes-payroll/backend/config"
 	"pemdes-payroll/backend/models"
 	"pemdes-payroll/backend/repositories"
 	"pemdes-payroll/backend/services"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type AbsensiHandler struct {
-	absensiRepo   repositories.AbsensiRepository
-	karyawanRepo  repositories.KaryawanRepository
-	exportService *services.ExportService
+	absensiRepo    repositories.AbsensiRepository
+	karyawanRepo   repositories.KaryawanRepository
+	payrollRunRepo repositories.PayrollRunRepository
+	gajiRepo       repositories.GajiRepository
+	koreksiRepo    repositories.KoreksiGajiRepository
+	komponenRepo   repositories.KomponenGajiRepository
+	hariLiburRepo  repositories.HariLiburRepository
+	exportService  *services.ExportService
+	absensiGajiSvc *services.AbsensiGajiService
+	komponenSvc    *services.KomponenService
 }
 
 // NewAbsensiHandler creates a new Absensi handler
-func NewAbsensiHandler(absensiRepo repositories.AbsensiRepository, karyawanRepo repositories.KaryawanRepository) *AbsensiHandler {
+func NewAbsensiHandler(absensiRepo repositories.AbsensiRepository, karyawanRepo repositories.KaryawanRepository, payrollRunRepo repositories.PayrollRunRepository, gajiRepo repositories.GajiRepository, koreksiRepo repositories.KoreksiGajiRepository, komponenRepo repositories.KomponenGajiRepository, hariLiburRepo repositories.HariLiburRepository) *AbsensiHandler {
 	return &AbsensiHandler{
-		absensiRepo:   absensiRepo,
-		karyawanRepo:  karyawanRepo,
-		exportService: services.NewExportService(),
+		absensiRepo:    absensiRepo,
+		karyawanRepo:   karyawanRepo,
+		payrollRunRepo: payrollRunRepo,
+		gajiRepo:       gajiRepo,
+		koreksiRepo:    koreksiRepo,
+		komponenRepo:   komponenRepo,
+		hariLiburRepo:  hariLiburRepo,
+		exportService:  services.NewExportService(),
+		absensiGajiSvc: services.NewAbsensiGajiService(config.GetAbsensiGajiConfig()),
+		komponenSvc:    services.NewKomponenService(),
 	}
 }
 
+// validateJamAbsensi checks that jam masuk and jam keluar, when given, are times of day
+func validateJamAbsensi(jamMasuk, jamKeluar string) string {
+	if _, ok := models.ParseJam(jamMasuk); jamMasuk != "" && !ok {
+		return "Invalid jam_masuk format. Use HH:MM"
+	}
+	if _, ok := models.ParseJam(jamKeluar); jamKeluar != "" && !ok {
+		return "Invalid jam_keluar format. Use HH:MM"
+	}
+	return ""
+}
+
 // CreateAbsensi handles POST /api/absensi
 func (h *AbsensiHandler) CreateAbsensi(c *fiber.Ctx) error {
 	var req struct {
@@ -44,6 +73,12 @@ func (h *AbsensiHandler) CreateAbsensi(c *fiber.Ctx) error {
 		})
 	}
 
+	if isPeranKaryawan(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Karyawan record their own absensi through clock-in",
+		})
+	}
+
 	// Validation
 	if req.KaryawanID == 0 {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
@@ -64,6 +99,16 @@ func (h *AbsensiHandler) CreateAbsensi(c *fiber.Ctx) error {
 		})
 	}
 
+	if msg := validateJamAbsensi(req.JamMasuk, req.JamKeluar); msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	if ok, err := cekPeriodeTerbuka(c, h.payrollRunRepo, req.KaryawanID, tanggal); !ok {
+		return err
+	}
+
 	absensi := models.Absensi{
 		KaryawanID: req.KaryawanID,
 		Tanggal:    tanggal,
@@ -192,7 +237,8 @@ func (h *AbsensiHandler) GetRekapAbsensi(c *fiber.Ctx) error {
 	return c.JSON(rekap)
 }
 
-// UpdateAbsensi handles PUT /api/absensi/:id
+// UpdateAbsensi handles PUT /api/absensi/:id - changes to the times or status are kept
+// in the row's riwayat
 func (h *AbsensiHandler) UpdateAbsensi(c *fiber.Ctx) error {
 	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
 	if err != nil {
@@ -201,6 +247,12 @@ func (h *AbsensiHandler) UpdateAbsensi(c *fiber.Ctx) error {
 		})
 	}
 
+	if isPeranKaryawan(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Karyawan cannot change absensi",
+		})
+	}
+
 	var req struct {
 		JamMasuk   string         `json:"jam_masuk"`
 		JamKeluar  string         `json:"jam_keluar"`
@@ -214,6 +266,36 @@ func (h *AbsensiHandler) UpdateAbsensi(c *fiber.Ctx) error {
 		})
 	}
 
+	if msg := validateJamAbsensi(req.JamMasuk, req.JamKeluar); msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	existing, err := h.absensiRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Absensi not found",
+		})
+	}
+	if ok, err := cekBelumDigaji(c, existing.GajiID, "Absensi", "POST /api/absensi/:id/koreksi-gaji"); !ok {
+		return err
+	}
+	if ok, err := cekPeriodeTerbuka(c, h.payrollRunRepo, existing.KaryawanID, existing.Tanggal); !ok {
+		return err
+	}
+	if existing.PengajuanCutiID != nil {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Absensi belongs to an approved cuti. Cancel the cuti instead",
+		})
+	}
+	if existing.Sumber != models.SumberAbsensiManual &&
+		((req.JamMasuk != "" && req.JamMasuk != existing.JamMasuk) || (req.JamKeluar != "" && req.JamKeluar != existing.JamKeluar)) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Times recorded by clock-in cannot be edited",
+		})
+	}
+
 	absensi := models.Absensi{
 		JamMasuk:   req.JamMasuk,
 		JamKeluar:  req.JamKeluar,
@@ -221,7 +303,7 @@ func (h *AbsensiHandler) UpdateAbsensi(c *fiber.Ctx) error {
 		Keterangan: req.Keterangan,
 	}
 
-	if err := h.absensiRepo.Update(uint(id), &absensi); err != nil {
+	if err := h.absensiRepo.UpdateDenganRiwayat(uint(id), &absensi, userIDFromCtx(c), req.Keterangan); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update absensi",
 		})
@@ -241,6 +323,30 @@ func (h *AbsensiHandler) DeleteAbsensi(c *fiber.Ctx) error {
 		})
 	}
 
+	if isPeranKaryawan(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Karyawan cannot change absensi",
+		})
+	}
+
+	existing, err := h.absensiRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Absensi not found",
+		})
+	}
+	if ok, err := cekBelumDigaji(c, existing.GajiID, "Absensi", "POST /api/absensi/:id/koreksi-gaji"); !ok {
+		return err
+	}
+	if ok, err := cekPeriodeTerbuka(c, h.payrollRunRepo, existing.KaryawanID, existing.Tanggal); !ok {
+		return err
+	}
+	if existing.PengajuanCutiID != nil {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Absensi belongs to an approved cuti. Cancel the cuti instead",
+		})
+	}
+
 	if err := h.absensiRepo.Delete(uint(id)); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete absensi",
@@ -252,6 +358,275 @@ func (h *AbsensiHandler) DeleteAbsensi(c *fiber.Ctx) error {
 	})
 }
 
+// koreksiGajiAbsensiRequest is the corrected day of a koreksi gaji for paid attendance
+type koreksiGajiAbsensiRequest struct {
+	KaryawanID uint                 `json:"karyawan_id"`
+	Tanggal    string               `json:"tanggal"`
+	JamMasuk   string               `json:"jam_masuk"`
+	JamKeluar  string               `json:"jam_keluar"`
+	Status     models.AbsensiStatus `json:"status"`
+	Hapus      bool                 `json:"hapus"`
+	Alasan     string               `json:"alasan"`
+}
+
+// validate checks the corrected times and status and returns an error message, or "" if
+// valid
+func (req *koreksiGajiAbsensiRequest) validate() string {
+	if msg := validateJamAbsensi(req.JamMasuk, req.JamKeluar); msg != "" {
+		return msg
+	}
+	switch req.Status {
+	case "", models.AbsensiHadir, models.AbsensiIzin, models.AbsensiSakit, models.AbsensiAlpha:
+	default:
+		return "Invalid status. Use 'hadir', 'izin', 'sakit' or 'alpha'"
+	}
+	req.Alasan = strings.TrimSpace(req.Alasan)
+	if req.Alasan == "" {
+		return "Alasan is required"
+	}
+	return ""
+}
+
+// KoreksiGajiAbsensi handles POST /api/absensi/:id/koreksi-gaji - corrects a day already
+// paid in a slip gaji. The row stays as it was paid; the difference the corrected times or
+// status make to the slip becomes a koreksi gaji carried into the karyawan's next slip.
+// With hapus=true the day is counted as not recorded. A day has at most one pending
+// koreksi, which can be deleted to submit it again; once it is carried into a slip a
+// further koreksi only adds what differs from it.
+func (h *AbsensiHandler) KoreksiGajiAbsensi(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	if isPeranKaryawan(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Karyawan cannot change absensi",
+		})
+	}
+
+	var req koreksiGajiAbsensiRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	existing, err := h.absensiRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Absensi not found",
+		})
+	}
+	if existing.GajiID == nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Absensi has not been paid in a slip gaji. Update it directly instead",
+		})
+	}
+	gaji, err := h.gajiRepo.GetByID(*existing.GajiID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch gaji",
+		})
+	}
+
+	var baru *models.Absensi
+	if !req.Hapus {
+		koreksi := *existing
+		if req.JamMasuk != "" {
+			koreksi.JamMasuk = req.JamMasuk
+		}
+		if req.JamKeluar != "" {
+			koreksi.JamKeluar = req.JamKeluar
+		}
+		if req.Status != "" {
+			koreksi.Status = req.Status
+		}
+		baru = &koreksi
+	}
+	return h.buatKoreksiGajiAbsensi(c, gaji, existing.KaryawanID, existing.Tanggal, existing, baru, req.Alasan)
+}
+
+// KoreksiGajiHariAbsensi handles POST /api/absensi/koreksi-gaji - corrects a day without
+// a paid absensi row in a period already paid in a slip gaji, e.g. a hadir day that was
+// never recorded. The difference becomes a koreksi gaji as with KoreksiGajiAbsensi.
+func (h *AbsensiHandler) KoreksiGajiHariAbsensi(c *fiber.Ctx) error {
+	if isPeranKaryawan(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Karyawan cannot change absensi",
+		})
+	}
+
+	var req koreksiGajiAbsensiRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if req.KaryawanID == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Karyawan ID is required",
+		})
+	}
+	tanggal, err := time.Parse("2006-01-02", req.Tanggal)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tanggal format. Use YYYY-MM-DD",
+		})
+	}
+	if req.Status == "" || req.Hapus {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Status of the day is required",
+		})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	terkunci, err := h.payrollRunRepo.IsPeriodeTerkunci(req.KaryawanID, tanggal)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check payroll run",
+		})
+	}
+	if !terkunci {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Payroll for this period has not been approved. Record the absensi directly instead",
+		})
+	}
+	gaji, err := h.gajiRepo.GetByKaryawanAndPeriod(int(req.KaryawanID), int(tanggal.Month()), tanggal.Year())
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Gaji not found",
+		})
+	}
+
+	hari, err := h.absensiRepo.GetByKaryawanID(req.KaryawanID, tanggal, tanggal)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch absensi",
+		})
+	}
+	for _, a := range hari {
+		if a.GajiID != nil {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{
+				"error":      "Absensi of this day was paid. Submit the koreksi through /api/absensi/:id/koreksi-gaji instead",
+				"absensi_id": a.ID,
+			})
+		}
+	}
+
+	baru := &models.Absensi{
+		KaryawanID: req.KaryawanID,
+		Tanggal:    tanggal,
+		JamMasuk:   req.JamMasuk,
+		JamKeluar:  req.JamKeluar,
+		Status:     req.Status,
+	}
+	return h.buatKoreksiGajiAbsensi(c, gaji, req.KaryawanID, tanggal, nil, baru, req.Alasan)
+}
+
+// buatKoreksiGajiAbsensi creates the koreksi gaji for one day of a paid slip: semula is
+// the row the slip counted that day, nil when it counted none, and baru the corrected
+// day, nil when the day is counted as not recorded. The difference is what the corrected
+// day changes in the attendance rules and the per hadir komponen gaji at the slip's rates,
+// less what earlier koreksi of the day already carried.
+func (h *AbsensiHandler) buatKoreksiGajiAbsensi(c *fiber.Ctx, gaji *models.Gaji, karyawanID uint, tanggal time.Time, semula, baru *models.Absensi, alasan string) error {
+	sebelumnya, err := h.koreksiRepo.GetByTanggalAbsensi(karyawanID, tanggal)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch koreksi gaji",
+		})
+	}
+	// What the koreksi already carried into a slip changed for the day
+	diterapkan := 0.0
+	for _, k := range sebelumnya {
+		if k.Status == models.KoreksiPending {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{
+				"error": "Absensi of this day already has a pending koreksi gaji. Delete it to submit another",
+			})
+		}
+		if k.Jenis == models.JenisPotongan {
+			diterapkan -= k.Jumlah
+		} else {
+			diterapkan += k.Jumlah
+		}
+	}
+
+	komponenList, err := h.komponenRepo.GetAll()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch komponen gaji",
+		})
+	}
+
+	hari := tanggal.Format("02/01/2006")
+	keadaanSemula := "tidak tercatat"
+	if semula != nil {
+		keadaanSemula = fmt.Sprintf("%s %s-%s", semula.Status, semula.JamMasuk, semula.JamKeluar)
+	}
+	perubahan := "Absensi " + hari + " " + keadaanSemula + " dihapus"
+	if baru != nil {
+		kalender, err := h.hariLiburRepo.GetKalender(baru.Tanggal, baru.Tanggal)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch kalender kerja",
+			})
+		}
+		kalender.Hitung(baru)
+		perubahan = fmt.Sprintf("Absensi %s %s menjadi %s %s-%s", hari, keadaanSemula, baru.Status, baru.JamMasuk, baru.JamKeluar)
+	}
+
+	// The slip keeps the figures it was paid with; only the corrected day changes them
+	rekapDibayar := h.absensiGajiSvc.RekapGaji(gaji)
+	rekapDikoreksi := h.absensiGajiSvc.GantiHari(rekapDibayar, semula, baru)
+	selisih := h.absensiGajiSvc.NilaiAbsensi(gaji, rekapDikoreksi) - h.absensiGajiSvc.NilaiAbsensi(gaji, rekapDibayar) +
+		h.komponenSvc.SelisihHadir(gaji, komponenList, rekapDibayar.Hadir, rekapDikoreksi.Hadir) - diterapkan
+	selisih = math.Round(selisih*100) / 100
+	if selisih == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "The correction does not change the slip's amounts",
+		})
+	}
+	jenis := models.JenisPendapatan
+	if selisih < 0 {
+		jenis = models.JenisPotongan
+	}
+
+	koreksiGaji := models.KoreksiGaji{
+		GajiID:         gaji.ID,
+		KaryawanID:     karyawanID,
+		PeriodeBulan:   gaji.PeriodeBulan,
+		PeriodeTahun:   gaji.PeriodeTahun,
+		Nama:           "Koreksi Absensi " + hari,
+		Jenis:          jenis,
+		Jumlah:         math.Abs(selisih),
+		Alasan:         perubahan + ": " + alasan,
+		Status:         models.KoreksiPending,
+		DibuatOleh:     userIDFromCtx(c),
+		TanggalAbsensi: &tanggal,
+	}
+	if semula != nil {
+		koreksiGaji.AbsensiID = &semula.ID
+	}
+	if err := h.koreksiRepo.Create(&koreksiGaji); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create koreksi gaji",
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(koreksiGaji)
+}
+
 // ExportAbsensiExcel handles GET /api/absensi/export/excel
 func (h *AbsensiHandler) ExportAbsensiExcel(c *fiber.Ctx) error {
 	// Get all absensi
@@ -336,3 +711,81 @@ func (h *AbsensiHandler) ExportAbsensiPDF(c *fiber.Ctx) error {
 
 	return c.Send(data)
 }
+
+// GetLaporanKeterlambatan handles GET /api/absensi/keterlambatan?bulan=&tahun= - summarizes
+// each karyawan's late arrivals, early departures and working hours in a month, the
+// latest first
+func (h *AbsensiHandler) GetLaporanKeterlambatan(c *fiber.Ctx) error {
+	bulan, _ := strconv.Atoi(c.Query("bulan", strconv.Itoa(int(time.Now().Month()))))
+	tahun, _ := strconv.Atoi(c.Query("tahun", strconv.Itoa(time.Now().Year())))
+
+	if bulan < 1 || bulan > 12 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid bulan parameter",
+		})
+	}
+
+	startDate := time.Date(tahun, time.Month(bulan), 1, 0, 0, 0, 0, time.UTC)
+	endDate := startDate.AddDate(0, 1, -1)
+	absensiList, err := h.absensiRepo.GetByDateRange(startDate, endDate)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch absensi",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"periode":       map[string]int{"bulan": bulan, "tahun": tahun},
+		"keterlambatan": rekapKeterlambatan(absensiList),
+	})
+}
+
+// rekapKeterlambatan sums the minutes late, minutes left early and working hours of the
+// absensi per karyawan, ordered by minutes late
+func rekapKeterlambatan(absensiList []models.Absensi) []models.LaporanKeterlambatan {
+	laporan := []models.LaporanKeterlambatan{}
+	indeks := make(map[uint]int)
+	menitKerja := make(map[uint]int)
+
+	for _, a := range absensiList {
+		i, ok := indeks[a.KaryawanID]
+		if !ok {
+			l := models.LaporanKeterlambatan{
+				KaryawanID:   a.KaryawanID,
+				NIK:          a.Karyawan.NIK,
+				NamaKaryawan: a.Karyawan.Nama,
+			}
+			if a.Karyawan.Jabatan != nil {
+				l.NamaJabatan = a.Karyawan.Jabatan.NamaJabatan
+			}
+			laporan = append(laporan, l)
+			i = len(laporan) - 1
+			indeks[a.KaryawanID] = i
+		}
+
+		l := &laporan[i]
+		if a.Status == models.AbsensiHadir {
+			l.HariHadir++
+		}
+		if a.MenitTerlambat > 0 {
+			l.HariTerlambat++
+			l.MenitTerlambat += a.MenitTerlambat
+		}
+		if a.MenitPulangCepat > 0 {
+			l.HariPulangCepat++
+			l.MenitPulangCepat += a.MenitPulangCepat
+		}
+		menitKerja[a.KaryawanID] += a.MenitKerja
+	}
+
+	for i := range laporan {
+		laporan[i].JamKerja = math.Round(float64(menitKerja[laporan[i].KaryawanID])/60*100) / 100
+	}
+	sort.SliceStable(laporan, func(i, j int) bool {
+		if laporan[i].MenitTerlambat != laporan[j].MenitTerlambat {
+			return laporan[i].MenitTerlambat > laporan[j].MenitTerlambat
+		}
+		return laporan[i].NamaKaryawan < laporan[j].NamaKaryawan
+	})
+	return laporan
+}
@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"pemdes-payroll/backend/config"
+	"pemdes-payroll/backend/models"
+	"pemdes-payroll/backend/repositories"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// maksHariAutoAlpha limits the date range of one auto-alpha proposal
+const maksHariAutoAlpha = 92
+
+type AutoAlphaHandler struct {
+	usulanRepo     repositories.UsulanAlphaRepository
+	absensiRepo    repositories.AbsensiRepository
+	karyawanRepo   repositories.KaryawanRepository
+	payrollRunRepo repositories.PayrollRunRepository
+	cfg            *config.AutoAlphaConfig
+}
+
+// NewAutoAlphaHandler creates a new handler for auto-alpha proposals
+func NewAutoAlphaHandler(usulanRepo repositories.UsulanAlphaRepository, absensiRepo repositories.AbsensiRepository, karyawanRepo repositories.KaryawanRepository, payrollRunRepo repositories.PayrollRunRepository) *AutoAlphaHandler {
+	return &AutoAlphaHandler{
+		usulanRepo:     usulanRepo,
+		absensiRepo:    absensiRepo,
+		karyawanRepo:   karyawanRepo,
+		payrollRunRepo: payrollRunRepo,
+		cfg:            config.GetAutoAlphaConfig(),
+	}
+}
+
+// AutoAlphaRequest represents the range to propose alpha for. Working days follow the
+// jadwal kerja and the hari libur calendar; TanggalDikecualikan leaves out further dates.
+type AutoAlphaRequest struct {
+	Mulai               string   `json:"mulai"`
+	Selesai             string   `json:"selesai"`
+	TanggalDikecualikan []string `json:"tanggal_dikecualikan"`
+
+	mulai, selesai time.Time
+	dikecualikan   map[string]bool
+}
+
+// validate parses the range and excluded dates and returns an error message, or "" if valid
+func (req *AutoAlphaRequest) validate() string {
+	var err error
+	if req.mulai, err = time.Parse("2006-01-02", req.Mulai); err != nil {
+		return "Invalid mulai format. Use YYYY-MM-DD"
+	}
+	if req.selesai, err = time.Parse("2006-01-02", req.Selesai); err != nil {
+		return "Invalid selesai format. Use YYYY-MM-DD"
+	}
+	if req.selesai.Before(req.mulai) {
+		return "Selesai must not be before mulai"
+	}
+	if req.selesai.Sub(req.mulai) >= maksHariAutoAlpha*24*time.Hour {
+		return "Range must not exceed " + strconv.Itoa(maksHariAutoAlpha) + " days"
+	}
+	if !req.mulai.Before(hariIni()) {
+		return "Mulai must be before today"
+	}
+
+	req.dikecualikan = make(map[string]bool)
+	for _, t := range req.TanggalDikecualikan {
+		tanggal, err := time.Parse("2006-01-02", strings.TrimSpace(t))
+		if err != nil {
+			return "Invalid tanggal_dikecualikan " + t + ". Use YYYY-MM-DD"
+		}
+		req.dikecualikan[tanggal.Format("2006-01-02")] = true
+	}
+	return ""
+}
+
+// buatUsulan walks every karyawan employed in the range and proposes alpha for each
+// working day without an absensi row that is not excluded. It returns nil when there
+// is nothing to propose.
+func (h *AutoAlphaHandler) buatUsulan(req *AutoAlphaRequest, sumber string, dibuatOleh *uint) (*models.UsulanAlpha, error) {
+	karyawanList, err := h.karyawanRepo.GetUntukPeriode(req.mulai, req.selesai)
+	if err != nil {
+		return nil, err
+	}
+
+	var detail []models.DetailUsulanAlpha
+	for _, k := range karyawanList {
+		tanggal, err := h.absensiRepo.GetHariTanpaAbsensi(k.ID, req.mulai, req.selesai)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tanggal {
+			if req.dikecualikan[t.Format("2006-01-02")] {
+				continue
+			}
+			detail = append(detail, models.DetailUsulanAlpha{
+				KaryawanID: k.ID,
+				Tanggal:    t,
+				Status:     models.DetailAlphaUsulan,
+			})
+		}
+	}
+	if len(detail) == 0 {
+		return nil, nil
+	}
+
+	dikecualikan := make([]string, 0, len(req.dikecualikan))
+	for t := range req.dikecualikan {
+		dikecualikan = append(dikecualikan, t)
+	}
+	sort.Strings(dikecualikan)
+
+	usulan := models.UsulanAlpha{
+		Mulai:        req.mulai,
+		Selesai:      req.selesai,
+		Dikecualikan: strings.Join(dikecualikan, ","),
+		Sumber:       sumber,
+		Status:       models.UsulanAlphaDraft,
+		DibuatOleh:   dibuatOleh,
+		Detail:       detail,
+	}
+	if err := h.usulanRepo.Create(&usulan); err != nil {
+		return nil, err
+	}
+	return h.usulanRepo.GetByID(usulan.ID)
+}
+
+// CreateUsulanAlpha handles POST /api/absensi/auto-alpha - proposes alpha for the working
+// days without absensi in a range. Nothing is written to absensi until the proposal is
+// reviewed and applied.
+func (h *AutoAlphaHandler) CreateUsulanAlpha(c *fiber.Ctx) error {
+	var req AutoAlphaRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	usulan, err := h.buatUsulan(&req, "manual", userIDFromCtx(c))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create usulan alpha",
+		})
+	}
+	if usulan == nil {
+		return c.JSON(fiber.Map{
+			"message": "Every working day in this range has an absensi row",
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"usulan":    usulan,
+		"ringkasan": usulan.Ringkasan(),
+	})
+}
+
+// GetAllUsulanAlpha handles GET /api/absensi/auto-alpha?status=
+func (h *AutoAlphaHandler) GetAllUsulanAlpha(c *fiber.Ctx) error {
+	usulan, err := h.usulanRepo.GetAll(c.Query("status"))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch usulan alpha",
+		})
+	}
+
+	return c.JSON(usulan)
+}
+
+// GetUsulanAlphaByID handles GET /api/absensi/auto-alpha/:id - returns the proposed days
+// and their summary per karyawan
+func (h *AutoAlphaHandler) GetUsulanAlphaByID(c *fiber.Ctx) error {
+	usulan, err := h.usulanDariParam(c)
+	if err != nil {
+		return err
+	}
+	if usulan == nil {
+		return nil
+	}
+
+	return c.JSON(fiber.Map{
+		"usulan":    usulan,
+		"ringkasan": usulan.Ringkasan(),
+	})
+}
+
+// TerapkanUsulanAlpha handles POST /api/absensi/auto-alpha/:id/terapkan - creates the
+// alpha absensi of a draft proposal. Details listed in kecualikan_detail_ids are left
+// out, and days in a period whose payroll has been approved are skipped.
+func (h *AutoAlphaHandler) TerapkanUsulanAlpha(c *fiber.Ctx) error {
+	var req struct {
+		KecualikanDetailIDs []uint `json:"kecualikan_detail_ids"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	usulan, err := h.usulanDariParam(c)
+	if err != nil || usulan == nil {
+		return err
+	}
+	if usulan.Status != models.UsulanAlphaDraft {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Usulan alpha is already " + string(usulan.Status),
+		})
+	}
+
+	kecualikan := make(map[uint]bool)
+	for _, id := range req.KecualikanDetailIDs {
+		kecualikan[id] = true
+	}
+
+	for i := range usulan.Detail {
+		d := &usulan.Detail[i]
+		if kecualikan[d.ID] {
+			d.Status = models.DetailAlphaDikecualikan
+			continue
+		}
+		terkunci, err := h.payrollRunRepo.IsPeriodeTerkunci(d.KaryawanID, d.Tanggal)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check payroll run",
+			})
+		}
+		if terkunci {
+			d.Status = models.DetailAlphaDilewati
+			d.Keterangan = "Payroll for this period has been approved"
+		}
+	}
+
+	if err := h.usulanRepo.Terapkan(usulan, userIDFromCtx(c)); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to apply usulan alpha",
+		})
+	}
+
+	jumlah := make(map[models.DetailAlphaStatus]int)
+	for _, d := range usulan.Detail {
+		jumlah[d.Status]++
+	}
+
+	return c.JSON(fiber.Map{
+		"message":      "Usulan alpha applied",
+		"dibuat":       jumlah[models.DetailAlphaDibuat],
+		"dilewati":     jumlah[models.DetailAlphaDilewati],
+		"dikecualikan": jumlah[models.DetailAlphaDikecualikan],
+		"usulan":       usulan,
+	})
+}
+
+// BatalkanUsulanAlpha handles POST /api/absensi/auto-alpha/:id/batal - discards a draft
+// proposal
+func (h *AutoAlphaHandler) BatalkanUsulanAlpha(c *fiber.Ctx) error {
+	usulan, err := h.usulanDariParam(c)
+	if err != nil || usulan == nil {
+		return err
+	}
+	if usulan.Status != models.UsulanAlphaDraft {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Usulan alpha is already " + string(usulan.Status),
+		})
+	}
+
+	if err := h.usulanRepo.UpdateStatus(usulan.ID, models.UsulanAlphaDibatalkan); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update usulan alpha",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Usulan alpha cancelled",
+	})
+}
+
+// usulanDariParam loads the proposal of the :id parameter. When it cannot, it writes the
+// error response and returns nil.
+func (h *AutoAlphaHandler) usulanDariParam(c *fiber.Ctx) (*models.UsulanAlpha, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	usulan, err := h.usulanRepo.GetByID(uint(id))
+	if err != nil {
+		return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Usulan alpha not found",
+		})
+	}
+	return usulan, nil
+}
+
+// MulaiJadwal starts the daily auto-alpha job when AUTO_ALPHA_AKTIF is set
+func (h *AutoAlphaHandler) MulaiJadwal() {
+	if !h.cfg.Aktif {
+		return
+	}
+	menit, ok := models.ParseJam(h.cfg.Jam)
+	if !ok {
+		log.Printf("Warning: Invalid AUTO_ALPHA_JAM %q, auto-alpha job not started", h.cfg.Jam)
+		return
+	}
+	log.Printf("Auto-alpha job scheduled daily at %s", h.cfg.Jam)
+	go h.jalankanJadwal(time.Duration(menit) * time.Minute)
+}
+
+// jalankanJadwal proposes alpha every day at the given time after midnight for the days
+// since the previous scheduled proposal, up to yesterday. Proposals are left as draft
+// for review.
+func (h *AutoAlphaHandler) jalankanJadwal(jam time.Duration) {
+	for {
+		now := time.Now()
+		berikutnya := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Add(jam)
+		if !berikutnya.After(now) {
+			berikutnya = berikutnya.AddDate(0, 0, 1)
+		}
+		time.Sleep(time.Until(berikutnya))
+
+		kemarin := hariIni().AddDate(0, 0, -1)
+		mulai := kemarin.AddDate(0, 0, 1-h.cfg.HariMundur)
+		terakhir, err := h.usulanRepo.GetTerakhirTerjadwal()
+		if err != nil && err != gorm.ErrRecordNotFound {
+			log.Printf("Warning: Auto-alpha job failed to fetch previous usulan: %v", err)
+			continue
+		}
+		if terakhir != nil && terakhir.Selesai.AddDate(0, 0, 1).After(mulai) {
+			mulai = terakhir.Selesai.AddDate(0, 0, 1)
+		}
+		if mulai.After(kemarin) {
+			continue
+		}
+
+		req := AutoAlphaRequest{mulai: mulai, selesai: kemarin, dikecualikan: map[string]bool{}}
+		usulan, err := h.buatUsulan(&req, "jadwal", nil)
+		if err != nil {
+			log.Printf("Warning: Auto-alpha job failed: %v", err)
+			continue
+		}
+		if usulan == nil {
+			log.Printf("Auto-alpha job: no missing absensi from %s to %s", mulai.Format("2006-01-02"), kemarin.Format("2006-01-02"))
+			continue
+		}
+		log.Printf("Auto-alpha job: usulan %d proposes %d days for review", usulan.ID, len(usulan.Detail))
+	}
+}
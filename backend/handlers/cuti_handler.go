@@ -0,0 +1,565 @@
+package handlers
+
+import (
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"pemdes-payroll/backend/config"
+	"pemdes-payroll/backend/middleware"
+	"pemdes-payroll/backend/models"
+	"pemdes-payroll/backend/repositories"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// maksUkuranLampiran limits the size of an uploaded attachment
+const maksUkuranLampiran = 5 << 20
+
+type CutiHandler struct {
+	cutiRepo       repositories.CutiRepository
+	karyawanRepo   repositories.KaryawanRepository
+	userRepo       repositories.UserRepository
+	hariLiburRepo  repositories.HariLiburRepository
+	payrollRunRepo repositories.PayrollRunRepository
+	cfg            *config.CutiConfig
+}
+
+// NewCutiHandler creates a new handler for leave requests
+func NewCutiHandler(cutiRepo repositories.CutiRepository, karyawanRepo repositories.KaryawanRepository, userRepo repositories.UserRepository, hariLiburRepo repositories.HariLiburRepository, payrollRunRepo repositories.PayrollRunRepository) *CutiHandler {
+	return &CutiHandler{
+		cutiRepo:       cutiRepo,
+		karyawanRepo:   karyawanRepo,
+		userRepo:       userRepo,
+		hariLiburRepo:  hariLiburRepo,
+		payrollRunRepo: payrollRunRepo,
+		cfg:            config.GetCutiConfig(),
+	}
+}
+
+// CutiRequest represents the leave request, sent as JSON or as a multipart form with
+// the attachment in "lampiran". KaryawanID is ignored for users with the karyawan role,
+// who request leave for themselves.
+type CutiRequest struct {
+	KaryawanID uint             `json:"karyawan_id" form:"karyawan_id"`
+	Jenis      models.JenisCuti `json:"jenis" form:"jenis"`
+	Mulai      string           `json:"mulai" form:"mulai"`
+	Selesai    string           `json:"selesai" form:"selesai"`
+	Alasan     string           `json:"alasan" form:"alasan"`
+
+	mulai, selesai time.Time
+}
+
+// validate checks the leave type and dates and returns an error message, or "" if valid
+func (req *CutiRequest) validate(cfg *config.CutiConfig) string {
+	if !req.Jenis.IsValid() {
+		return "Invalid jenis. Use 'tahunan', 'sakit', 'melahirkan' or 'alasan_penting'"
+	}
+	var err error
+	if req.mulai, err = time.Parse("2006-01-02", req.Mulai); err != nil {
+		return "Invalid mulai format. Use YYYY-MM-DD"
+	}
+	if req.selesai, err = time.Parse("2006-01-02", req.Selesai); err != nil {
+		return "Invalid selesai format. Use YYYY-MM-DD"
+	}
+	if req.selesai.Before(req.mulai) {
+		return "Selesai must not be before mulai"
+	}
+	if req.mulai.Year() != req.selesai.Year() {
+		return "Cuti must not span two years. Submit one request per year"
+	}
+	if req.Jenis == models.CutiMelahirkan && int(req.selesai.Sub(req.mulai).Hours()/24)+1 > cfg.MaksMelahirkan {
+		return fmt.Sprintf("Cuti melahirkan must not exceed %d days", cfg.MaksMelahirkan)
+	}
+	if req.Jenis != models.CutiSakit && strings.TrimSpace(req.Alasan) == "" {
+		return "Alasan is required"
+	}
+	return ""
+}
+
+// hak returns the yearly entitlement of a leave type taken from a balance
+func (h *CutiHandler) hak(jenis models.JenisCuti) int {
+	if jenis == models.CutiAlasanPenting {
+		return h.cfg.HakAlasanPenting
+	}
+	return h.cfg.HakTahunan
+}
+
+// saldo returns the karyawan's balance of jenis in tahun
+func (h *CutiHandler) saldo(karyawanID uint, tahun int, jenis models.JenisCuti) (*models.SaldoCuti, error) {
+	return h.cutiRepo.GetSaldo(karyawanID, tahun, jenis, h.hak(jenis), h.cfg.MaksCarryOver)
+}
+
+// karyawanPengguna returns the karyawan linked to the logged-in user, or nil when the
+// user is not linked to one
+func karyawanPengguna(c *fiber.Ctx, userRepo repositories.UserRepository) (*uint, error) {
+	userID := userIDFromCtx(c)
+	if userID == nil {
+		return nil, nil
+	}
+	user, err := userRepo.GetByID(*userID)
+	if err != nil {
+		return nil, err
+	}
+	return user.KaryawanID, nil
+}
+
+// isPeranKaryawan reports whether the logged-in user has the karyawan role
+func isPeranKaryawan(c *fiber.Ctx) bool {
+	claims, ok := c.Locals("user").(*middleware.Claims)
+	return !ok || claims == nil || claims.Role == string(models.UserRoleKaryawan)
+}
+
+// isPimpinanDesa reports whether the logged-in user is the Sekdes or the Kades
+func isPimpinanDesa(c *fiber.Ctx) bool {
+	claims, ok := c.Locals("user").(*middleware.Claims)
+	return ok && claims != nil && (claims.Role == string(models.UserRoleSekdes) || claims.Role == string(models.UserRoleKades))
+}
+
+// simpanLampiran stores an uploaded attachment under the lampiran directory in subdir and
+// returns its path relative to that directory. Only PDF and image files are accepted.
+func simpanLampiran(c *fiber.Ctx, file *multipart.FileHeader, dir, subdir string) (string, string) {
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	switch ext {
+	case ".pdf", ".jpg", ".jpeg", ".png":
+	default:
+		return "", "Unsupported lampiran type. Upload a .pdf, .jpg or .png file"
+	}
+	if file.Size > maksUkuranLampiran {
+		return "", "Lampiran must not exceed 5 MB"
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, subdir), 0o755); err != nil {
+		return "", "Failed to store lampiran"
+	}
+	nama := filepath.Join(subdir, strconv.FormatInt(time.Now().UnixNano(), 10)+ext)
+	if err := c.SaveFile(file, filepath.Join(dir, nama)); err != nil {
+		return "", "Failed to store lampiran"
+	}
+	return nama, ""
+}
+
+// CreatePengajuanCuti handles POST /api/cuti - submits a leave request for approval by
+// the Sekdes or Kades. Cuti sakit needs the surat dokter as lampiran.
+func (h *CutiHandler) CreatePengajuanCuti(c *fiber.Ctx) error {
+	var req CutiRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if msg := req.validate(h.cfg); msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	if isPeranKaryawan(c) {
+		karyawanID, err := karyawanPengguna(c, h.userRepo)
+		if err != nil || karyawanID == nil {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{
+				"error": "User is not linked to a karyawan",
+			})
+		}
+		req.KaryawanID = *karyawanID
+	}
+	if req.KaryawanID == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Karyawan ID is required",
+		})
+	}
+	if _, err := h.karyawanRepo.GetByID(req.KaryawanID); err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Karyawan not found",
+		})
+	}
+
+	fileHeader, err := c.FormFile("lampiran")
+	if err != nil && req.Jenis == models.CutiSakit {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cuti sakit requires the surat dokter as lampiran",
+		})
+	}
+
+	bentrok, err := h.cutiRepo.GetBentrok(req.KaryawanID, req.mulai, req.selesai, 0)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch cuti",
+		})
+	}
+	if bentrok != nil {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": fmt.Sprintf("Overlaps cuti %s from %s to %s", bentrok.Jenis, bentrok.Mulai.Format("2006-01-02"), bentrok.Selesai.Format("2006-01-02")),
+		})
+	}
+
+	kalender, err := h.hariLiburRepo.GetKalender(req.mulai, req.selesai)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch kalender kerja",
+		})
+	}
+	jumlahHari := len(kalender.HariKerja(req.mulai, req.selesai))
+	if jumlahHari == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "There are no working days in this range",
+		})
+	}
+
+	if req.Jenis.BerKuota() {
+		saldo, err := h.saldo(req.KaryawanID, req.mulai.Year(), req.Jenis)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch saldo cuti",
+			})
+		}
+		if saldo.Sisa < jumlahHari {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{
+				"error": fmt.Sprintf("Saldo cuti %s is %d days, requested %d", req.Jenis, saldo.Sisa, jumlahHari),
+			})
+		}
+	}
+
+	pengajuan := models.PengajuanCuti{
+		KaryawanID:   req.KaryawanID,
+		Jenis:        req.Jenis,
+		Mulai:        req.mulai,
+		Selesai:      req.selesai,
+		JumlahHari:   jumlahHari,
+		Alasan:       strings.TrimSpace(req.Alasan),
+		Status:       models.CutiDiajukan,
+		DiajukanOleh: userIDFromCtx(c),
+	}
+	if fileHeader != nil {
+		lampiran, msg := simpanLampiran(c, fileHeader, h.cfg.LampiranDir, "cuti")
+		if msg != "" {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": msg,
+			})
+		}
+		pengajuan.Lampiran = lampiran
+	}
+
+	if err := h.cutiRepo.CreatePengajuan(&pengajuan); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create pengajuan cuti",
+		})
+	}
+
+	result, _ := h.cutiRepo.GetPengajuanByID(pengajuan.ID)
+	return c.Status(http.StatusCreated).JSON(result)
+}
+
+// GetAllPengajuanCuti handles GET /api/cuti?status=&karyawan_id=&tahun= - users with the
+// karyawan role only see their own requests
+func (h *CutiHandler) GetAllPengajuanCuti(c *fiber.Ctx) error {
+	karyawanID, _ := strconv.ParseUint(c.Query("karyawan_id", "0"), 10, 32)
+	tahun, _ := strconv.Atoi(c.Query("tahun", "0"))
+
+	if isPeranKaryawan(c) {
+		id, err := karyawanPengguna(c, h.userRepo)
+		if err != nil || id == nil {
+			return c.JSON([]models.PengajuanCuti{})
+		}
+		karyawanID = uint64(*id)
+	}
+
+	pengajuan, err := h.cutiRepo.GetAllPengajuan(c.Query("status"), uint(karyawanID), tahun)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch pengajuan cuti",
+		})
+	}
+
+	return c.JSON(pengajuan)
+}
+
+// GetSaldoCuti handles GET /api/cuti/saldo?karyawan_id=&tahun= - returns the balances of
+// the leave types with a yearly entitlement
+func (h *CutiHandler) GetSaldoCuti(c *fiber.Ctx) error {
+	karyawanID, _ := strconv.ParseUint(c.Query("karyawan_id", "0"), 10, 32)
+	tahun, err := strconv.Atoi(c.Query("tahun", strconv.Itoa(time.Now().Year())))
+	if err != nil || tahun < 2000 || tahun > 2100 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tahun parameter",
+		})
+	}
+
+	if isPeranKaryawan(c) {
+		id, err := karyawanPengguna(c, h.userRepo)
+		if err != nil || id == nil {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{
+				"error": "User is not linked to a karyawan",
+			})
+		}
+		karyawanID = uint64(*id)
+	}
+	if karyawanID == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Karyawan ID is required",
+		})
+	}
+
+	saldo := []models.SaldoCuti{}
+	for _, jenis := range []models.JenisCuti{models.CutiTahunan, models.CutiAlasanPenting} {
+		s, err := h.saldo(uint(karyawanID), tahun, jenis)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch saldo cuti",
+			})
+		}
+		saldo = append(saldo, *s)
+	}
+
+	return c.JSON(saldo)
+}
+
+// pengajuanDariParam loads the request of the :id parameter, checking that users with the
+// karyawan role only reach their own. When it cannot, it writes the error response and
+// returns nil.
+func (h *CutiHandler) pengajuanDariParam(c *fiber.Ctx) (*models.PengajuanCuti, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	pengajuan, err := h.cutiRepo.GetPengajuanByID(uint(id))
+	if err != nil {
+		return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Pengajuan cuti not found",
+		})
+	}
+
+	if isPeranKaryawan(c) {
+		karyawanID, err := karyawanPengguna(c, h.userRepo)
+		if err != nil || karyawanID == nil || *karyawanID != pengajuan.KaryawanID {
+			return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Pengajuan cuti not found",
+			})
+		}
+	}
+	return pengajuan, nil
+}
+
+// GetPengajuanCutiByID handles GET /api/cuti/:id
+func (h *CutiHandler) GetPengajuanCutiByID(c *fiber.Ctx) error {
+	pengajuan, err := h.pengajuanDariParam(c)
+	if err != nil || pengajuan == nil {
+		return err
+	}
+	return c.JSON(pengajuan)
+}
+
+// GetLampiranCuti handles GET /api/cuti/:id/lampiran - downloads the attachment
+func (h *CutiHandler) GetLampiranCuti(c *fiber.Ctx) error {
+	pengajuan, err := h.pengajuanDariParam(c)
+	if err != nil || pengajuan == nil {
+		return err
+	}
+	if pengajuan.Lampiran == "" {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Pengajuan cuti has no lampiran",
+		})
+	}
+	return c.Download(filepath.Join(h.cfg.LampiranDir, pengajuan.Lampiran))
+}
+
+// cekRentangTerbuka runs cekPeriodeTerbuka for each month from mulai up to selesai
+func cekRentangTerbuka(c *fiber.Ctx, repo repositories.PayrollRunRepository, karyawanID uint, mulai, selesai time.Time) (bool, error) {
+	for t := time.Date(mulai.Year(), mulai.Month(), 1, 0, 0, 0, 0, time.UTC); !t.After(selesai); t = t.AddDate(0, 1, 0) {
+		if ok, err := cekPeriodeTerbuka(c, repo, karyawanID, t); !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// keputusan reads the optional catatan of an approval decision
+func keputusan(c *fiber.Ctx) (string, bool) {
+	var req struct {
+		Catatan string `json:"catatan"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return "", false
+		}
+	}
+	return strings.TrimSpace(req.Catatan), true
+}
+
+// cekPemutus checks that the logged-in user is the Sekdes or Kades and is not deciding
+// their own request about objek, e.g. "cuti". When not, it writes the error response and
+// returns false.
+func cekPemutus(c *fiber.Ctx, userRepo repositories.UserRepository, diajukanOleh *uint, karyawanID uint, objek string) (bool, error) {
+	if !isPimpinanDesa(c) {
+		return false, c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the Sekdes or Kepala Desa can decide on " + objek,
+		})
+	}
+	userID := userIDFromCtx(c)
+	karyawanUser, err := karyawanPengguna(c, userRepo)
+	if err != nil {
+		return false, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch user",
+		})
+	}
+	if (diajukanOleh != nil && userID != nil && *diajukanOleh == *userID) ||
+		(karyawanUser != nil && *karyawanUser == karyawanID) {
+		return false, c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "You cannot decide on your own " + objek,
+		})
+	}
+	return true, nil
+}
+
+// SetujuiPengajuanCuti handles POST /api/cuti/:id/setujui - Sekdes or Kades only. Records
+// the leave days as izin, or sakit for cuti sakit, replacing alpha rows, and takes them
+// from the balance.
+func (h *CutiHandler) SetujuiPengajuanCuti(c *fiber.Ctx) error {
+	catatan, ok := keputusan(c)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	pengajuan, err := h.pengajuanDariParam(c)
+	if err != nil || pengajuan == nil {
+		return err
+	}
+	if ok, err := cekPemutus(c, h.userRepo, pengajuan.DiajukanOleh, pengajuan.KaryawanID, "cuti"); !ok {
+		return err
+	}
+	if pengajuan.Status != models.CutiDiajukan {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Pengajuan cuti is already " + string(pengajuan.Status),
+		})
+	}
+
+	// The calendar may have changed since the request was submitted
+	kalender, err := h.hariLiburRepo.GetKalender(pengajuan.Mulai, pengajuan.Selesai)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch kalender kerja",
+		})
+	}
+	tanggal := kalender.HariKerja(pengajuan.Mulai, pengajuan.Selesai)
+
+	if ok, err := cekRentangTerbuka(c, h.payrollRunRepo, pengajuan.KaryawanID, pengajuan.Mulai, pengajuan.Selesai); !ok {
+		return err
+	}
+
+	if pengajuan.Jenis.BerKuota() {
+		// Opens the balance of each year the leave falls in if this is its first cuti
+		for tahun := pengajuan.Mulai.Year(); tahun <= pengajuan.Selesai.Year(); tahun++ {
+			if _, err := h.saldo(pengajuan.KaryawanID, tahun, pengajuan.Jenis); err != nil {
+				return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to fetch saldo cuti",
+				})
+			}
+		}
+	}
+
+	err = h.cutiRepo.Setujui(pengajuan, tanggal, userIDFromCtx(c), catatan)
+	switch {
+	case err == repositories.ErrSaldoCutiKurang:
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": fmt.Sprintf("Saldo cuti %s does not cover %d days", pengajuan.Jenis, len(tanggal)),
+		})
+	case err == repositories.ErrAbsensiBentrok:
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Absensi is already recorded on a day of this cuti. Correct it first",
+		})
+	case err != nil:
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to approve pengajuan cuti",
+		})
+	}
+
+	return c.JSON(pengajuan)
+}
+
+// TolakPengajuanCuti handles POST /api/cuti/:id/tolak - Sekdes or Kades only
+func (h *CutiHandler) TolakPengajuanCuti(c *fiber.Ctx) error {
+	catatan, ok := keputusan(c)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if catatan == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Catatan is required to reject a cuti",
+		})
+	}
+
+	pengajuan, err := h.pengajuanDariParam(c)
+	if err != nil || pengajuan == nil {
+		return err
+	}
+	if ok, err := cekPemutus(c, h.userRepo, pengajuan.DiajukanOleh, pengajuan.KaryawanID, "cuti"); !ok {
+		return err
+	}
+	if pengajuan.Status != models.CutiDiajukan {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Pengajuan cuti is already " + string(pengajuan.Status),
+		})
+	}
+
+	if err := h.cutiRepo.Putuskan(pengajuan, models.CutiDitolak, userIDFromCtx(c), catatan); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reject pengajuan cuti",
+		})
+	}
+
+	return c.JSON(pengajuan)
+}
+
+// BatalkanPengajuanCuti handles POST /api/cuti/:id/batal - withdraws a pending request,
+// or lets the Sekdes or Kades cancel an approved one, putting back the absensi rows it
+// replaced and returning the days to the balance
+func (h *CutiHandler) BatalkanPengajuanCuti(c *fiber.Ctx) error {
+	catatan, ok := keputusan(c)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	pengajuan, err := h.pengajuanDariParam(c)
+	if err != nil || pengajuan == nil {
+		return err
+	}
+
+	switch pengajuan.Status {
+	case models.CutiDiajukan:
+	case models.CutiDisetujui:
+		if !isPimpinanDesa(c) {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{
+				"error": "Only the Sekdes or Kepala Desa can cancel an approved cuti",
+			})
+		}
+		if ok, err := cekRentangTerbuka(c, h.payrollRunRepo, pengajuan.KaryawanID, pengajuan.Mulai, pengajuan.Selesai); !ok {
+			return err
+		}
+	default:
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Pengajuan cuti is already " + string(pengajuan.Status),
+		})
+	}
+
+	if err := h.cutiRepo.Batalkan(pengajuan, userIDFromCtx(c), catatan); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to cancel pengajuan cuti",
+		})
+	}
+
+	return c.JSON(pengajuan)
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"pemdes-payroll/backend/models"
+	"pemdes-payroll/backend/repositories"
+	"pemdes-payroll/backend/services"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type FingerprintHandler struct {
+	absensiRepo    repositories.AbsensiRepository
+	karyawanRepo   repositories.KaryawanRepository
+	payrollRunRepo repositories.PayrollRunRepository
+	fingerprintSvc *services.FingerprintService
+}
+
+// NewFingerprintHandler creates a new handler for fingerprint terminal imports
+func NewFingerprintHandler(absensiRepo repositories.AbsensiRepository, karyawanRepo repositories.KaryawanRepository, payrollRunRepo repositories.PayrollRunRepository) *FingerprintHandler {
+	return &FingerprintHandler{
+		absensiRepo:    absensiRepo,
+		karyawanRepo:   karyawanRepo,
+		payrollRunRepo: payrollRunRepo,
+		fingerprintSvc: services.NewFingerprintService(),
+	}
+}
+
+// ImportFingerprint handles POST /api/absensi/import/fingerprint - imports the attendance
+// log of the fingerprint terminal uploaded as "file" (.csv, .txt or .dat). Punches are
+// matched to karyawan by their PIN and each day's punches become jam masuk and jam
+// keluar. With the form value simulasi=true the report is returned without saving.
+func (h *FingerprintHandler) ImportFingerprint(c *fiber.Ctx) error {
+	if isPeranKaryawan(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Karyawan cannot import absensi",
+		})
+	}
+	simulasi, _ := strconv.ParseBool(c.FormValue("simulasi", "false"))
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "File is required",
+		})
+	}
+	switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+	case ".csv", ".txt", ".dat":
+	default:
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unsupported file type. Upload a .csv, .txt or .dat file",
+		})
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to read file",
+		})
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to read file",
+		})
+	}
+
+	punch, gagal := h.fingerprintSvc.ParseLog(data)
+	if len(punch) == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "No punches found in the file",
+			"gagal": gagal,
+		})
+	}
+
+	karyawan, err := h.karyawanRepo.GetDenganPinFingerprint()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch karyawan",
+		})
+	}
+	perPIN := make(map[string]models.Karyawan, len(karyawan))
+	for _, k := range karyawan {
+		perPIN[*k.PinFingerprint] = k
+	}
+
+	rekap := h.fingerprintSvc.Rekap(punch)
+	terkunci := make(map[string]bool)
+	for i := range rekap {
+		r := &rekap[i]
+		k, ok := perPIN[r.PIN]
+		if !ok {
+			r.Hasil = models.FingerprintPinTidakDikenal
+			r.Keterangan = "No karyawan has this PIN"
+			continue
+		}
+		r.KaryawanID = k.ID
+		r.NamaKaryawan = k.Nama
+
+		kunci := fmt.Sprintf("%d-%s", k.ID, r.Tanggal.Format("2006-01"))
+		sudah, ada := terkunci[kunci]
+		if !ada {
+			sudah, err = h.payrollRunRepo.IsPeriodeTerkunci(k.ID, r.Tanggal)
+			if err != nil {
+				return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to check payroll run",
+				})
+			}
+			terkunci[kunci] = sudah
+		}
+		if sudah {
+			r.Hasil = models.FingerprintKonflik
+			r.Keterangan = "Payroll for this period has been approved"
+		}
+	}
+
+	if err := h.absensiRepo.ImportFingerprint(rekap, simulasi); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to import fingerprint log",
+		})
+	}
+
+	jumlah := make(map[models.HasilFingerprint]int)
+	for _, r := range rekap {
+		jumlah[r.Hasil]++
+	}
+	message := "Import completed"
+	if simulasi {
+		message = "Simulation completed. Nothing was saved"
+	}
+	return c.JSON(fiber.Map{
+		"message":           message,
+		"simulasi":          simulasi,
+		"jumlah_punch":      len(punch),
+		"dibuat":            jumlah[models.FingerprintDibuat],
+		"diperbarui":        jumlah[models.FingerprintDiperbarui],
+		"tidak_berubah":     jumlah[models.FingerprintTidakBerubah],
+		"konflik":           jumlah[models.FingerprintKonflik],
+		"pin_tidak_dikenal": jumlah[models.FingerprintPinTidakDikenal],
+		"detail":            rekap,
+		"gagal":             gagal,
+	})
+}
@@ -1,31 +1,314 @@
 package handlers
 
 import (
+	"fmt"
+	"math"
 	"net/http"
 	"pemdes-payroll/backend/config"
 	"pemdes-payroll/backend/middleware"
 	"pemdes-payroll/backend/models"
 	"pemdes-payroll/backend/repositories"
+	"pemdes-payroll/backend/services"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type GajiHandler struct {
-	gajiRepo     repositories.GajiRepository
-	karyawanRepo repositories.KaryawanRepository
-	lemburRepo   repositories.LemburRepository
+	gajiRepo       repositories.GajiRepository
+	karyawanRepo   repositories.KaryawanRepository
+	lemburRepo     repositories.LemburRepository
+	absensiRepo    repositories.AbsensiRepository
+	komponenRepo   repositories.KomponenGajiRepository
+	payrollRunRepo repositories.PayrollRunRepository
+	koreksiRepo    repositories.KoreksiGajiRepository
+	tarifRepo      repositories.TarifJabatanRepository
+	rapelRepo      repositories.RapelRepository
+	kasbonRepo     repositories.KasbonRepository
+	hariLiburRepo  repositories.HariLiburRepository
+	pph21Svc       *services.PPh21Service
+	bpjsSvc        *services.BPJSService
+	komponenSvc    *services.KomponenService
+	analisisSvc    *services.AnalisisGajiService
+	prorataSvc     *services.ProrataService
+	prorataCfg     *config.ProrataConfig
+	absensiGajiSvc *services.AbsensiGajiService
 }
 
 // NewGajiHandler creates a new Gaji handler
-func NewGajiHandler(gajiRepo repositories.GajiRepository, karyawanRepo repositories.KaryawanRepository, lemburRepo repositories.LemburRepository) *GajiHandler {
+func NewGajiHandler(
+	gajiRepo repositories.GajiRepository,
+	karyawanRepo repositories.KaryawanRepository,
+	lemburRepo repositories.LemburRepository,
+	absensiRepo repositories.AbsensiRepository,
+	komponenRepo repositories.KomponenGajiRepository,
+	payrollRunRepo repositories.PayrollRunRepository,
+	koreksiRepo repositories.KoreksiGajiRepository,
+	tarifRepo repositories.TarifJabatanRepository,
+	rapelRepo repositories.RapelRepository,
+	kasbonRepo repositories.KasbonRepository,
+	hariLiburRepo repositories.HariLiburRepository,
+) *GajiHandler {
 	return &GajiHandler{
-		gajiRepo:     gajiRepo,
-		karyawanRepo: karyawanRepo,
-		lemburRepo:   lemburRepo,
+		gajiRepo:       gajiRepo,
+		karyawanRepo:   karyawanRepo,
+		lemburRepo:     lemburRepo,
+		absensiRepo:    absensiRepo,
+		komponenRepo:   komponenRepo,
+		payrollRunRepo: payrollRunRepo,
+		koreksiRepo:    koreksiRepo,
+		tarifRepo:      tarifRepo,
+		rapelRepo:      rapelRepo,
+		kasbonRepo:     kasbonRepo,
+		hariLiburRepo:  hariLiburRepo,
+		pph21Svc:       services.NewPPh21Service(),
+		bpjsSvc:        services.NewBPJSService(config.GetBPJSConfig()),
+		komponenSvc:    services.NewKomponenService(),
+		analisisSvc:    services.NewAnalisisGajiService(),
+		prorataSvc:     services.NewProrataService(),
+		prorataCfg:     config.GetProrataConfig(),
+		absensiGajiSvc: services.NewAbsensiGajiService(config.GetAbsensiGajiConfig()),
 	}
 }
 
+// denganTx returns a copy of the handler whose repositories work inside tx, so that
+// several slips can be written as one change
+func (h *GajiHandler) denganTx(tx *gorm.DB) *GajiHandler {
+	salinan := *h
+	salinan.gajiRepo = repositories.NewGajiRepository(tx)
+	salinan.karyawanRepo = repositories.NewKaryawanRepository(tx)
+	salinan.lemburRepo = repositories.NewLemburRepository(tx)
+	salinan.absensiRepo = repositories.NewAbsensiRepository(tx)
+	salinan.komponenRepo = repositories.NewKomponenGajiRepository(tx)
+	salinan.payrollRunRepo = repositories.NewPayrollRunRepository(tx)
+	salinan.koreksiRepo = repositories.NewKoreksiGajiRepository(tx)
+	salinan.tarifRepo = repositories.NewTarifJabatanRepository(tx)
+	salinan.rapelRepo = repositories.NewRapelRepository(tx)
+	salinan.kasbonRepo = repositories.NewKasbonRepository(tx)
+	salinan.hariLiburRepo = repositories.NewHariLiburRepository(tx)
+	return &salinan
+}
+
+// hitungGaji builds the line items of a gaji from its fixed columns, the attendance of
+// its period, the configured komponen gaji, pending koreksi, rapel paid in its period and
+// kasbon installments due, adds BPJS contributions and PPh 21 withholding, and computes
+// the total
+func (h *GajiHandler) hitungGaji(gaji *models.Gaji, karyawan *models.Karyawan, komponenList []models.KomponenGaji) error {
+	// The slip keeps the jabatan it was generated under, so later recalculations and
+	// rapel use that jabatan even after a promotion or transfer
+	if gaji.JabatanID == nil {
+		gaji.JabatanID = karyawan.JabatanID
+	}
+
+	// Attendance is counted again on every calculation so that corrected absensi are
+	// picked up with the rates recorded on the slip
+	awal := time.Date(gaji.PeriodeTahun, time.Month(gaji.PeriodeBulan), 1, 0, 0, 0, 0, time.UTC)
+	absensiList, err := h.absensiRepo.GetByKaryawanID(karyawan.ID, awal, awal.AddDate(0, 1, -1))
+	if err != nil {
+		return err
+	}
+	h.absensiGajiSvc.Terapkan(gaji, h.absensiGajiSvc.Rekap(absensiList))
+	gaji.AbsensiIDs = nil
+	for _, a := range absensiList {
+		gaji.AbsensiIDs = append(gaji.AbsensiIDs, a.ID)
+	}
+
+	gaji.SetStandardItems()
+	h.absensiGajiSvc.TambahItem(gaji)
+	if err := h.lemburGaji(gaji); err != nil {
+		return err
+	}
+
+	h.komponenSvc.HitungKomponen(gaji, komponenList, services.KomponenInput{
+		KaryawanID:  karyawan.ID,
+		JabatanID:   gaji.JabatanID,
+		GajiPokok:   gaji.GajiPokok,
+		JumlahHadir: gaji.HariHadir,
+	})
+
+	koreksiList, err := h.koreksiRepo.GetUntukGaji(karyawan.ID, gaji.ID)
+	if err != nil {
+		return err
+	}
+	for _, k := range koreksiList {
+		gaji.Items = append(gaji.Items, k.ToItem())
+	}
+
+	rapelList, err := h.rapelRepo.GetItemUntukGaji(karyawan.ID, gaji.PeriodeBulan, gaji.PeriodeTahun)
+	if err != nil {
+		return err
+	}
+	for _, r := range rapelList {
+		gaji.Items = append(gaji.Items, r.ToItem())
+	}
+
+	angsuranList, err := h.kasbonRepo.GetAngsuranUntukGaji(karyawan.ID, gaji.PeriodeBulan, gaji.PeriodeTahun, gaji.ID)
+	if err != nil {
+		return err
+	}
+	for _, a := range angsuranList {
+		gaji.Items = append(gaji.Items, a.ToItem())
+	}
+
+	h.bpjsSvc.HitungIuran(gaji)
+	gaji.CalculateBruto()
+
+	if gaji.PPh21, err = h.hitungPPh21(gaji, karyawan); err != nil {
+		return err
+	}
+	gaji.AddItem(models.KodePPh21, "PPh 21", models.JenisPotongan, gaji.PPh21)
+
+	gaji.CalculateTotal()
+	return nil
+}
+
+// lemburGaji records the approved overtime of the period as the source of the slip's
+// lembur column when that column pays exactly that overtime, and describes their
+// multiplier tiers on the lembur line. A lembur amount entered by hand has no source.
+func (h *GajiHandler) lemburGaji(gaji *models.Gaji) error {
+	gaji.LemburIDs = nil
+	if gaji.Lembur == 0 {
+		return nil
+	}
+	lemburList, err := h.lemburRepo.GetByKaryawanAndPeriod(int(gaji.KaryawanID), gaji.PeriodeBulan, gaji.PeriodeTahun)
+	if err != nil {
+		return err
+	}
+
+	var ids []uint
+	var rincian []models.RincianLembur
+	total := 0.0
+	// The list is newest first; the tiers are described oldest first
+	for i := len(lemburList) - 1; i >= 0; i-- {
+		l := lemburList[i]
+		if l.Status != "disetujui" {
+			continue
+		}
+		ids = append(ids, l.ID)
+		rincian = append(rincian, l.Rincian...)
+		total += l.TotalNominal
+	}
+	if math.Abs(total-gaji.Lembur) >= 0.01 {
+		return nil
+	}
+	gaji.LemburIDs = ids
+
+	if len(rincian) == 0 {
+		return nil
+	}
+	for i := range gaji.Items {
+		if gaji.Items[i].Kode == models.KodeLembur {
+			gaji.Items[i].Keterangan = models.KeteranganRincianLembur(rincian)
+		}
+	}
+	return nil
+}
+
+// hitungGajiRapel builds a separate rapel slip from rapel differences
+func (h *GajiHandler) hitungGajiRapel(gaji *models.Gaji, karyawan *models.Karyawan, detail []models.RapelDetail) error {
+	gaji.Items = nil
+	for _, d := range detail {
+		gaji.Items = append(gaji.Items, d.ToItem())
+	}
+	gaji.CalculateBruto()
+
+	var err error
+	if gaji.PPh21, err = h.hitungPPh21(gaji, karyawan); err != nil {
+		return err
+	}
+	gaji.AddItem(models.KodePPh21, "PPh 21", models.JenisPotongan, gaji.PPh21)
+
+	gaji.CalculateTotal()
+	return nil
+}
+
+// hitungPPh21 computes the PPh 21 to withhold on gaji. The karyawan's slips of other
+// jenis in the same period count towards the month's income: the tax is computed on the
+// month's total and the tax already withheld on the other slips is deducted, so regular,
+// rapel, THR, gaji ke-13 and bonus slips are taxed as one month whichever is computed
+// first. Irregular slips never return tax.
+func (h *GajiHandler) hitungPPh21(gaji *models.Gaji, karyawan *models.Karyawan) (float64, error) {
+	jenis := gaji.Jenis
+	if jenis == "" {
+		jenis = models.JenisGajiReguler
+	}
+
+	slipList, err := h.gajiRepo.GetSlipPeriode(karyawan.ID, gaji.PeriodeBulan, gaji.PeriodeTahun)
+	if err != nil {
+		return 0, err
+	}
+	bruto := gaji.PenghasilanBruto
+	iuranPensiun := gaji.IuranPensiun()
+	pph21Lain := 0.0
+	for _, g := range slipList {
+		// One slip per jenis per period, so the same jenis is gaji itself
+		if g.Jenis == jenis {
+			continue
+		}
+		bruto += g.PenghasilanBruto
+		iuranPensiun += g.IuranPensiun()
+		pph21Lain += g.PPh21
+	}
+
+	brutoSebelumnya, iuranSebelumnya, pph21Sebelumnya, bulanSebelumnya, err := h.gajiRepo.GetAkumulasiPPh21(gaji.KaryawanID, gaji.PeriodeBulan, gaji.PeriodeTahun)
+	if err != nil {
+		return 0, err
+	}
+
+	pph21 := h.pph21Svc.HitungPPh21(services.PPh21Input{
+		StatusPTKP:      karyawan.StatusPTKP,
+		Bulan:           gaji.PeriodeBulan,
+		Bruto:           bruto,
+		IuranPensiun:    iuranPensiun,
+		BrutoSebelumnya: brutoSebelumnya,
+		IuranSebelumnya: iuranSebelumnya,
+		PPh21Sebelumnya: pph21Sebelumnya,
+		BulanSebelumnya: bulanSebelumnya,
+	}) - pph21Lain
+	if jenis.IsTidakTeratur() {
+		pph21 = math.Max(pph21, 0)
+	}
+	return pph21, nil
+}
+
+// hitungUlangGaji recalculates a saved regular slip from its fixed columns, e.g. after
+// rapel lines for its period were added or removed
+func (h *GajiHandler) hitungUlangGaji(gaji *models.Gaji) error {
+	karyawan, err := h.karyawanRepo.GetByID(gaji.KaryawanID)
+	if err != nil {
+		return err
+	}
+	komponenList, err := h.komponenRepo.GetAktif()
+	if err != nil {
+		return err
+	}
+
+	if err := h.hitungGaji(gaji, karyawan, komponenList); err != nil {
+		return err
+	}
+	if err := h.gajiRepo.Update(gaji.ID, gaji); err != nil {
+		return err
+	}
+	return h.terapkanItem(gaji)
+}
+
+// terapkanItem records the koreksi and kasbon installments carried by a saved slip and
+// the lembur and absensi it was computed from
+func (h *GajiHandler) terapkanItem(gaji *models.Gaji) error {
+	if err := h.koreksiRepo.SetDiterapkan(gaji.ID, gaji.KoreksiIDs()); err != nil {
+		return err
+	}
+	if err := h.kasbonRepo.SetDipotong(gaji.ID, gaji.AngsuranKasbonIDs()); err != nil {
+		return err
+	}
+	if err := h.lemburRepo.SetDihitung(gaji.ID, gaji.LemburIDs); err != nil {
+		return err
+	}
+	return h.absensiRepo.SetDihitung(gaji.ID, gaji.AbsensiIDs)
+}
+
 // CreateGaji handles POST /api/gaji
 func (h *GajiHandler) CreateGaji(c *fiber.Ctx) error {
 	var req struct {
@@ -79,6 +362,18 @@ func (h *GajiHandler) CreateGaji(c *fiber.Ctx) error {
 		})
 	}
 
+	run, err := h.payrollRunRepo.FindOrCreate(req.PeriodeBulan, req.PeriodeTahun, models.JenisGajiReguler, userIDFromCtx(c))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch payroll run",
+		})
+	}
+	if !run.IsEditable() {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Payroll run for this period is already " + string(run.Status),
+		})
+	}
+
 	// Calculate lembur from approved overtime records if not provided
 	lemburAmount := req.Lembur
 	if lemburAmount == 0 {
@@ -96,9 +391,21 @@ func (h *GajiHandler) CreateGaji(c *fiber.Ctx) error {
 		Lembur:             lemburAmount,
 		Potongan:           req.Potongan,
 		Status:             models.GajiStatusPending,
+		PayrollRunID:       &run.ID,
 	}
 
-	gaji.CalculateTotal()
+	komponenList, err := h.komponenRepo.GetAktif()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch komponen gaji",
+		})
+	}
+
+	if err := h.hitungGaji(&gaji, karyawan, komponenList); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to calculate gaji",
+		})
+	}
 
 	if err := h.gajiRepo.Create(&gaji); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
@@ -106,6 +413,12 @@ func (h *GajiHandler) CreateGaji(c *fiber.Ctx) error {
 		})
 	}
 
+	if err := h.terapkanItem(&gaji); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to apply koreksi gaji and kasbon",
+		})
+	}
+
 	// Fetch with Karyawan data
 	result, _ := h.gajiRepo.GetByID(gaji.ID)
 	result.Karyawan = *karyawan
@@ -125,7 +438,8 @@ func (h *GajiHandler) GetAllGaji(c *fiber.Ctx) error {
 	return c.JSON(gaji)
 }
 
-// GetGajiByPeriod handles GET /api/gaji/period?bulan=&tahun=
+// GetGajiByPeriod handles GET /api/gaji/period?bulan=&tahun=&jenis= - jenis optionally
+// limits the slips to those paid in the payroll run of that jenis
 func (h *GajiHandler) GetGajiByPeriod(c *fiber.Ctx) error {
 	bulan, _ := strconv.Atoi(c.Query("bulan", "0"))
 	tahun, _ := strconv.Atoi(c.Query("tahun", "0"))
@@ -141,16 +455,37 @@ func (h *GajiHandler) GetGajiByPeriod(c *fiber.Ctx) error {
 		})
 	}
 
+	jenis := models.JenisGaji(c.Query("jenis"))
+	if jenis != "" && !jenis.IsJenisRun() {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid jenis parameter",
+		})
+	}
+
 	gaji, err := h.gajiRepo.GetByPeriod(bulan, tahun)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch gaji",
 		})
 	}
+	if jenis != "" {
+		gaji = filterJenisRun(gaji, jenis)
+	}
 
 	return c.JSON(gaji)
 }
 
+// filterJenisRun keeps the slips paid in payroll runs of the given jenis
+func filterJenisRun(gajiList []models.Gaji, jenis models.JenisGaji) []models.Gaji {
+	hasil := []models.Gaji{}
+	for _, g := range gajiList {
+		if g.Jenis.JenisRun() == jenis {
+			hasil = append(hasil, g)
+		}
+	}
+	return hasil
+}
+
 // GetGajiByID handles GET /api/gaji/:id
 func (h *GajiHandler) GetGajiByID(c *fiber.Ctx) error {
 	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
@@ -199,16 +534,15 @@ func (h *GajiHandler) UpdateGaji(c *fiber.Ctx) error {
 	}
 
 	var req struct {
-		KaryawanID         uint              `json:"karyawan_id"`
-		PeriodeBulan       int               `json:"periode_bulan"`
-		PeriodeTahun       int               `json:"periode_tahun"`
-		GajiPokok          float64           `json:"gaji_pokok"`
-		TunjanganJabatan   float64           `json:"tunjangan_jabatan"`
-		TunjanganTransport float64           `json:"tunjangan_transport"`
-		TunjanganMakan     float64           `json:"tunjangan_makan"`
-		Lembur             float64           `json:"lembur"`
-		Potongan           float64           `json:"potongan"`
-		Status             models.GajiStatus `json:"status"`
+		KaryawanID         uint    `json:"karyawan_id"`
+		PeriodeBulan       int     `json:"periode_bulan"`
+		PeriodeTahun       int     `json:"periode_tahun"`
+		GajiPokok          float64 `json:"gaji_pokok"`
+		TunjanganJabatan   float64 `json:"tunjangan_jabatan"`
+		TunjanganTransport float64 `json:"tunjangan_transport"`
+		TunjanganMakan     float64 `json:"tunjangan_makan"`
+		Lembur             float64 `json:"lembur"`
+		Potongan           float64 `json:"potongan"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -217,7 +551,30 @@ func (h *GajiHandler) UpdateGaji(c *fiber.Ctx) error {
 		})
 	}
 
+	existing, err := h.gajiRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Gaji not found",
+		})
+	}
+	if !existing.IsEditable() {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Gaji can no longer be changed. Submit a koreksi instead",
+		})
+	}
+	if existing.Jenis == models.JenisGajiRapel {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Rapel slips are changed by cancelling their rapel",
+		})
+	}
+	if existing.Jenis.IsTidakTeratur() {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": existing.Jenis.Label() + " slips cannot be changed. Delete and generate them again instead",
+		})
+	}
+
 	gaji := models.Gaji{
+		ID:                 existing.ID,
 		KaryawanID:         req.KaryawanID,
 		PeriodeBulan:       req.PeriodeBulan,
 		PeriodeTahun:       req.PeriodeTahun,
@@ -227,10 +584,64 @@ func (h *GajiHandler) UpdateGaji(c *fiber.Ctx) error {
 		TunjanganMakan:     req.TunjanganMakan,
 		Lembur:             req.Lembur,
 		Potongan:           req.Potongan,
-		Status:             req.Status,
+		Status:             existing.Status,
+		PayrollRunID:       existing.PayrollRunID,
+		FaktorProrata:      existing.FaktorProrata,
+		HariDibayar:        existing.HariDibayar,
+		HariPeriode:        existing.HariPeriode,
+		MetodeProrata:      existing.MetodeProrata,
+		MakanPerHadir:      existing.MakanPerHadir,
+		PotonganPerAlpha:   existing.PotonganPerAlpha,
+		DendaPerTerlambat:  existing.DendaPerTerlambat,
+	}
+	if gaji.KaryawanID == 0 {
+		gaji.KaryawanID = existing.KaryawanID
+	}
+	if gaji.KaryawanID == existing.KaryawanID {
+		gaji.JabatanID = existing.JabatanID
+	}
+	if gaji.PeriodeBulan == 0 {
+		gaji.PeriodeBulan = existing.PeriodeBulan
+	}
+	if gaji.PeriodeTahun == 0 {
+		gaji.PeriodeTahun = existing.PeriodeTahun
 	}
 
-	gaji.CalculateTotal()
+	// Moving the slip to another period moves it to that period's run
+	if gaji.PeriodeBulan != existing.PeriodeBulan || gaji.PeriodeTahun != existing.PeriodeTahun {
+		run, err := h.payrollRunRepo.FindOrCreate(gaji.PeriodeBulan, gaji.PeriodeTahun, models.JenisGajiReguler, userIDFromCtx(c))
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch payroll run",
+			})
+		}
+		if !run.IsEditable() {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{
+				"error": "Payroll run for this period is already " + string(run.Status),
+			})
+		}
+		gaji.PayrollRunID = &run.ID
+	}
+
+	karyawan, err := h.karyawanRepo.GetByID(gaji.KaryawanID)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Karyawan not found",
+		})
+	}
+
+	komponenList, err := h.komponenRepo.GetAktif()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch komponen gaji",
+		})
+	}
+
+	if err := h.hitungGaji(&gaji, karyawan, komponenList); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to calculate gaji",
+		})
+	}
 
 	if err := h.gajiRepo.Update(uint(id), &gaji); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
@@ -238,6 +649,12 @@ func (h *GajiHandler) UpdateGaji(c *fiber.Ctx) error {
 		})
 	}
 
+	if err := h.terapkanItem(&gaji); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to apply koreksi gaji and kasbon",
+		})
+	}
+
 	// Get updated data
 	updated, _ := h.gajiRepo.GetByID(uint(id))
 	return c.JSON(updated)
@@ -252,12 +669,52 @@ func (h *GajiHandler) DeleteGaji(c *fiber.Ctx) error {
 		})
 	}
 
+	existing, err := h.gajiRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Gaji not found",
+		})
+	}
+	if !existing.IsEditable() {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Gaji can no longer be deleted. Submit a koreksi instead",
+		})
+	}
+	if existing.Jenis == models.JenisGajiRapel {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Rapel slips are deleted by cancelling their rapel",
+		})
+	}
+
 	if err := h.gajiRepo.Delete(uint(id)); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete gaji",
 		})
 	}
 
+	// Corrections and kasbon installments carried by the deleted slip go back to pending,
+	// and its lembur and absensi are no longer counted
+	if err := h.koreksiRepo.SetDiterapkan(uint(id), nil); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to release koreksi gaji",
+		})
+	}
+	if err := h.kasbonRepo.SetDipotong(uint(id), nil); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to release kasbon installments",
+		})
+	}
+	if err := h.lemburRepo.SetDihitung(uint(id), nil); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to release lembur",
+		})
+	}
+	if err := h.absensiRepo.SetDihitung(uint(id), nil); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to release absensi",
+		})
+	}
+
 	return c.Status(http.StatusOK).JSON(fiber.Map{
 		"message": "Gaji deleted successfully",
 	})
@@ -288,6 +745,18 @@ func (h *GajiHandler) UpdateGajiStatus(c *fiber.Ctx) error {
 		})
 	}
 
+	gaji, err := h.gajiRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Gaji not found",
+		})
+	}
+	if gaji.PayrollRun != nil && gaji.PayrollRun.Status != models.PayrollRunApproved {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Payment status can only be changed while the payroll run is approved",
+		})
+	}
+
 	if err := h.gajiRepo.UpdateStatus(uint(id), req.Status); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update status",
@@ -299,14 +768,108 @@ func (h *GajiHandler) UpdateGajiStatus(c *fiber.Ctx) error {
 	})
 }
 
+// GenerateBatchRequest represents a generate-batch or batch preview request. The
+// attendance rates override the configured ones when set; 0 turns a rule off.
+type GenerateBatchRequest struct {
+	PeriodeBulan       int                  `json:"periode_bulan"`
+	PeriodeTahun       int                  `json:"periode_tahun"`
+	TunjanganTransport float64              `json:"tunjangan_transport"`
+	TunjanganMakan     float64              `json:"tunjangan_makan"`
+	MetodeProrata      models.MetodeProrata `json:"metode_prorata"`
+	MakanPerHadir      *float64             `json:"makan_per_hadir"`
+	PotonganPerAlpha   *float64             `json:"potongan_per_alpha"`
+	DendaPerTerlambat  *float64             `json:"denda_per_terlambat"`
+}
+
+// validate checks the request and returns an error message, or "" if valid
+func (req *GenerateBatchRequest) validate() string {
+	if req.PeriodeBulan < 1 || req.PeriodeBulan > 12 {
+		return "Periode bulan must be between 1 and 12"
+	}
+	if req.PeriodeTahun < 2000 || req.PeriodeTahun > 2100 {
+		return "Invalid periode tahun"
+	}
+	if req.MetodeProrata != "" && !req.MetodeProrata.IsValid() {
+		return "Invalid metode prorata. Use 'kalender' or 'hari_kerja'"
+	}
+	for _, tarif := range []*float64{req.MakanPerHadir, req.PotonganPerAlpha, req.DendaPerTerlambat} {
+		if tarif != nil && *tarif < 0 {
+			return "Attendance rates must not be negative"
+		}
+	}
+	return ""
+}
+
+// periode returns the first and last day of the requested period
+func (req *GenerateBatchRequest) periode() (time.Time, time.Time) {
+	awal := time.Date(req.PeriodeTahun, time.Month(req.PeriodeBulan), 1, 0, 0, 0, 0, time.UTC)
+	return awal, awal.AddDate(0, 1, -1)
+}
+
+// buildGajiBatch computes the slip GenerateBatch creates for one karyawan, taking gaji
+// pokok and tunjangan jabatan from the jabatan rate valid at the start of the period
+// and lembur from approved overtime. The fixed monthly amounts are pro-rated when the
+// karyawan joins or leaves during the period, and the attendance rules are applied.
+func (h *GajiHandler) buildGajiBatch(k *models.Karyawan, req *GenerateBatchRequest, komponenList []models.KomponenGaji) (*models.Gaji, error) {
+	gajiPokok := 0.0
+	tunjanganJabatan := 0.0
+	awalPeriode, akhirPeriode := req.periode()
+
+	if k.JabatanID != nil {
+		tarif, err := h.tarifRepo.GetBerlaku(*k.JabatanID, awalPeriode)
+		if err != nil {
+			return nil, err
+		}
+		gajiPokok = tarif.GajiPokok
+		tunjanganJabatan = tarif.TunjanganJabatan
+	}
+
+	// Get total lembur for this employee for the period (only approved)
+	_, totalLemburNominal, _ := h.lemburRepo.GetTotalLemburByPeriod(int(k.ID), req.PeriodeBulan, req.PeriodeTahun)
+
+	metode := req.MetodeProrata
+	if metode == "" {
+		metode = models.MetodeProrata(h.prorataCfg.Metode)
+	}
+	var kalender *models.KalenderKerja
+	if metode == models.ProrataHariKerja {
+		var err error
+		if kalender, err = h.hariLiburRepo.GetKalender(awalPeriode, akhirPeriode); err != nil {
+			return nil, err
+		}
+	}
+	prorata := h.prorataSvc.Hitung(req.PeriodeBulan, req.PeriodeTahun, k.TanggalBergabung, k.TanggalBerhenti, metode, kalender)
+	aturan := h.absensiGajiSvc.Aturan(req.MakanPerHadir, req.PotonganPerAlpha, req.DendaPerTerlambat)
+
+	gaji := models.Gaji{
+		KaryawanID:         k.ID,
+		PeriodeBulan:       req.PeriodeBulan,
+		PeriodeTahun:       req.PeriodeTahun,
+		GajiPokok:          prorata.Terapkan(gajiPokok),
+		TunjanganJabatan:   prorata.Terapkan(tunjanganJabatan),
+		TunjanganTransport: prorata.Terapkan(req.TunjanganTransport),
+		TunjanganMakan:     prorata.Terapkan(req.TunjanganMakan),
+		Lembur:             totalLemburNominal,
+		Potongan:           0,
+		Status:             models.GajiStatusPending,
+		FaktorProrata:      prorata.Faktor,
+		HariDibayar:        prorata.HariDibayar,
+		HariPeriode:        prorata.HariPeriode,
+		MetodeProrata:      string(prorata.Metode),
+		MakanPerHadir:      aturan.MakanPerHadir,
+		PotonganPerAlpha:   aturan.PotonganPerAlpha,
+		DendaPerTerlambat:  aturan.DendaPerTerlambat,
+	}
+
+	if err := h.hitungGaji(&gaji, k, komponenList); err != nil {
+		return nil, err
+	}
+	return &gaji, nil
+}
+
 // GenerateBatch handles POST /api/gaji/generate-batch
 func (h *GajiHandler) GenerateBatch(c *fiber.Ctx) error {
-	var req struct {
-		PeriodeBulan       int     `json:"periode_bulan"`
-		PeriodeTahun       int     `json:"periode_tahun"`
-		TunjanganTransport float64 `json:"tunjangan_transport"`
-		TunjanganMakan     float64 `json:"tunjangan_makan"`
-	}
+	var req GenerateBatchRequest
 
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
@@ -315,25 +878,39 @@ func (h *GajiHandler) GenerateBatch(c *fiber.Ctx) error {
 	}
 
 	// Validation
-	if req.PeriodeBulan < 1 || req.PeriodeBulan > 12 {
+	if msg := req.validate(); msg != "" {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-			"error": "Periode bulan must be between 1 and 12",
+			"error": msg,
 		})
 	}
-	if req.PeriodeTahun < 2000 || req.PeriodeTahun > 2100 {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid periode tahun",
+
+	run, err := h.payrollRunRepo.FindOrCreate(req.PeriodeBulan, req.PeriodeTahun, models.JenisGajiReguler, userIDFromCtx(c))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch payroll run",
+		})
+	}
+	if !run.IsEditable() {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Payroll run for this period is already " + string(run.Status),
 		})
 	}
 
-	// Get all active employees
-	karyawanList, err := h.karyawanRepo.GetByStatus(models.StatusAktif)
+	// Get the employees in service during the period, including joiners and leavers
+	karyawanList, err := h.karyawanRepo.GetUntukPeriode(req.periode())
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch karyawan",
 		})
 	}
 
+	komponenList, err := h.komponenRepo.GetAktif()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch komponen gaji",
+		})
+	}
+
 	var gajiList []models.Gaji
 	var skipped []string
 	var created int
@@ -346,32 +923,14 @@ func (h *GajiHandler) GenerateBatch(c *fiber.Ctx) error {
 			continue
 		}
 
-		gajiPokok := 0.0
-		tunjanganJabatan := 0.0
-
-		if k.Jabatan != nil {
-			gajiPokok = k.Jabatan.GajiPokok
-			tunjanganJabatan = k.Jabatan.TunjanganJabatan
-		}
-
-		// Get total lembur for this employee for the period (only approved)
-		_, totalLemburNominal, _ := h.lemburRepo.GetTotalLemburByPeriod(int(k.ID), req.PeriodeBulan, req.PeriodeTahun)
-
-		gaji := models.Gaji{
-			KaryawanID:         k.ID,
-			PeriodeBulan:       req.PeriodeBulan,
-			PeriodeTahun:       req.PeriodeTahun,
-			GajiPokok:          gajiPokok,
-			TunjanganJabatan:   tunjanganJabatan,
-			TunjanganTransport: req.TunjanganTransport,
-			TunjanganMakan:     req.TunjanganMakan,
-			Lembur:             totalLemburNominal,
-			Potongan:           0,
-			Status:             models.GajiStatusPending,
+		gaji, err := h.buildGajiBatch(&k, &req, komponenList)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to calculate gaji for " + k.Nama,
+			})
 		}
-
-		gaji.CalculateTotal()
-		gajiList = append(gajiList, gaji)
+		gaji.PayrollRunID = &run.ID
+		gajiList = append(gajiList, *gaji)
 		created++
 	}
 
@@ -381,16 +940,240 @@ func (h *GajiHandler) GenerateBatch(c *fiber.Ctx) error {
 				"error": "Failed to create gaji batch",
 			})
 		}
+
+		for i := range gajiList {
+			g := &gajiList[i]
+			if len(g.KoreksiIDs()) == 0 && len(g.AngsuranKasbonIDs()) == 0 && len(g.LemburIDs) == 0 && len(g.AbsensiIDs) == 0 {
+				continue
+			}
+			if err := h.terapkanItem(g); err != nil {
+				return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to apply koreksi gaji, kasbon, lembur and absensi",
+				})
+			}
+		}
 	}
 
 	return c.Status(http.StatusCreated).JSON(fiber.Map{
-		"message": "Batch generation completed",
-		"created": created,
-		"skipped": skipped,
-		"periode": map[string]int{"bulan": req.PeriodeBulan, "tahun": req.PeriodeTahun},
+		"message":        "Batch generation completed",
+		"payroll_run_id": run.ID,
+		"created":        created,
+		"skipped":        skipped,
+		"periode":        map[string]int{"bulan": req.PeriodeBulan, "tahun": req.PeriodeTahun},
+	})
+}
+
+// PreviewBatch handles POST /api/gaji/generate-batch/preview - computes the slips
+// GenerateBatch would create, with warnings, totals per jabatan and the change against
+// the previous period, without writing anything
+func (h *GajiHandler) PreviewBatch(c *fiber.Ctx) error {
+	var req GenerateBatchRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Validation
+	if msg := req.validate(); msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	peringatan := []models.PeringatanGaji{}
+
+	if run, err := h.payrollRunRepo.GetByPeriod(req.PeriodeBulan, req.PeriodeTahun, models.JenisGajiReguler); err == nil && !run.IsEditable() {
+		peringatan = append(peringatan, models.PeringatanGaji{
+			Pesan: "Payroll run for this period is already " + string(run.Status) + " so generate-batch will be rejected",
+		})
+	}
+
+	karyawanList, err := h.karyawanRepo.GetUntukPeriode(req.periode())
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch karyawan",
+		})
+	}
+
+	komponenList, err := h.komponenRepo.GetAktif()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch komponen gaji",
+		})
+	}
+
+	existingList, err := h.gajiRepo.GetByPeriod(req.PeriodeBulan, req.PeriodeTahun)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch gaji",
+		})
+	}
+	existingList = filterJenisRun(existingList, models.JenisGajiReguler)
+	sudahAda := make(map[uint]bool, len(existingList))
+	for _, g := range existingList {
+		if g.Jenis == models.JenisGajiReguler {
+			sudahAda[g.KaryawanID] = true
+		}
+	}
+
+	gajiList := []models.Gaji{}
+	var skipped []string
+	totalGaji := 0.0
+
+	for _, k := range karyawanList {
+		if sudahAda[k.ID] {
+			skipped = append(skipped, k.Nama)
+			continue
+		}
+
+		gaji, err := h.buildGajiBatch(&k, &req, komponenList)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to calculate gaji for " + k.Nama,
+			})
+		}
+		gaji.Karyawan = k
+
+		pesan, err := h.periksaGaji(gaji, &k)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check gaji for " + k.Nama,
+			})
+		}
+		for _, p := range pesan {
+			peringatan = append(peringatan, models.PeringatanGaji{KaryawanID: k.ID, NamaKaryawan: k.Nama, Pesan: p})
+		}
+
+		gajiList = append(gajiList, *gaji)
+		totalGaji += gaji.TotalGaji
+	}
+
+	bulanSebelumnya, tahunSebelumnya := req.PeriodeBulan-1, req.PeriodeTahun
+	if bulanSebelumnya == 0 {
+		bulanSebelumnya, tahunSebelumnya = 12, req.PeriodeTahun-1
+	}
+	sebelumnya, err := h.gajiRepo.GetByPeriod(bulanSebelumnya, tahunSebelumnya)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch gaji of previous period",
+		})
+	}
+	sebelumnya = filterJenisRun(sebelumnya, models.JenisGajiReguler)
+
+	// Compare the whole period: the slips to be created plus those that already exist
+	periodeIni := make([]models.Gaji, 0, len(gajiList)+len(existingList))
+	periodeIni = append(periodeIni, gajiList...)
+	periodeIni = append(periodeIni, existingList...)
+
+	return c.JSON(fiber.Map{
+		"message":            "Batch preview completed",
+		"created":            len(gajiList),
+		"skipped":            skipped,
+		"periode":            map[string]int{"bulan": req.PeriodeBulan, "tahun": req.PeriodeTahun},
+		"periode_sebelumnya": map[string]int{"bulan": bulanSebelumnya, "tahun": tahunSebelumnya},
+		"gaji":               gajiList,
+		"total_gaji":         totalGaji,
+		"total_per_jabatan":  h.analisisSvc.TotalPerJabatan(gajiList),
+		"peringatan":         peringatan,
+		"selisih_sebelumnya": h.analisisSvc.Bandingkan(sebelumnya, periodeIni),
 	})
 }
 
+// sumberBerubah returns why a saved regular slip no longer matches the absensi and lembur
+// it was computed from, or "" when it still does. Absensi counts differently or has rows
+// the slip did not count; the overtime the lembur column was computed from no longer
+// totals it. Overtime approved after the slip was computed is not on the slip, so it
+// is neither checked nor linked.
+func (h *GajiHandler) sumberBerubah(gaji *models.Gaji) (string, error) {
+	awal := time.Date(gaji.PeriodeTahun, time.Month(gaji.PeriodeBulan), 1, 0, 0, 0, 0, time.UTC)
+	absensiList, err := h.absensiRepo.GetByKaryawanID(gaji.KaryawanID, awal, awal.AddDate(0, 1, -1))
+	if err != nil {
+		return "", err
+	}
+	tercatat := services.RekapAbsensi{
+		Hadir:          gaji.HariHadir,
+		Izin:           gaji.HariIzin,
+		Sakit:          gaji.HariSakit,
+		Alpha:          gaji.HariAlpha,
+		Terlambat:      gaji.HariTerlambat,
+		MenitTerlambat: gaji.MenitTerlambat,
+	}
+	if h.absensiGajiSvc.Rekap(absensiList) != tercatat {
+		return "Absensi changed after the slip was computed", nil
+	}
+	for _, a := range absensiList {
+		if a.DihitungGajiID == nil || *a.DihitungGajiID != gaji.ID {
+			return "Absensi changed after the slip was computed", nil
+		}
+	}
+
+	lemburList, err := h.lemburRepo.GetByKaryawanAndPeriod(int(gaji.KaryawanID), gaji.PeriodeBulan, gaji.PeriodeTahun)
+	if err != nil {
+		return "", err
+	}
+	dihitung := false
+	total := 0.0
+	for _, l := range lemburList {
+		if l.DihitungGajiID != nil && *l.DihitungGajiID == gaji.ID {
+			dihitung = true
+			if l.Status == "disetujui" {
+				total += l.TotalNominal
+			}
+		}
+	}
+	if dihitung && math.Abs(total-gaji.Lembur) >= 0.01 {
+		return "Lembur changed after the slip was computed", nil
+	}
+	return "", nil
+}
+
+// periksaGaji returns warnings about a computed slip that finance should check
+// before the batch is generated
+func (h *GajiHandler) periksaGaji(gaji *models.Gaji, k *models.Karyawan) ([]string, error) {
+	var pesan []string
+
+	if k.Jabatan == nil {
+		pesan = append(pesan, "Karyawan has no jabatan so gaji_pokok = 0")
+	} else if gaji.GajiPokok == 0 {
+		pesan = append(pesan, "Jabatan "+k.Jabatan.NamaJabatan+" has gaji_pokok = 0 for this period")
+	}
+
+	if ket := gaji.KeteranganProrata(); ket != "" {
+		pesan = append(pesan, ket+" because of tanggal bergabung or tanggal berhenti")
+	}
+
+	lemburList, err := h.lemburRepo.GetByKaryawanAndPeriod(int(k.ID), gaji.PeriodeBulan, gaji.PeriodeTahun)
+	if err != nil {
+		return nil, err
+	}
+	pending := 0
+	for _, l := range lemburList {
+		if l.Status == "pending" {
+			pending++
+		}
+	}
+	if pending > 0 {
+		pesan = append(pesan, fmt.Sprintf("%d lembur still pending approval and not included", pending))
+	}
+
+	if gaji.HariHadir == 0 {
+		pesan = append(pesan, "No hadir absensi recorded in this period")
+	}
+	if gaji.MakanPerHadir > 0 && gaji.HariHadir == 0 {
+		pesan = append(pesan, "Tunjangan makan is paid per hadir day so it is 0")
+	}
+
+	if gaji.TotalGaji < 0 {
+		pesan = append(pesan, "Total gaji is negative")
+	} else if gaji.TotalGaji == 0 {
+		pesan = append(pesan, "Total gaji is 0")
+	}
+
+	return pesan, nil
+}
+
 // GetSlipGaji handles GET /api/gaji/slip/:id - Get salary slip by ID (for karyawan portal)
 func (h *GajiHandler) GetSlipGaji(c *fiber.Ctx) error {
 	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
@@ -447,9 +1230,9 @@ func (h *GajiHandler) GetMySlipGaji(c *fiber.Ctx) error {
 	// If user is linked to karyawan
 	if userData.KaryawanID != nil {
 		if bulan > 0 && tahun > 0 {
-			slipData, err := h.gajiRepo.GetByKaryawanAndPeriod(int(*userData.KaryawanID), bulan, tahun)
-			if err == nil && slipData != nil {
-				gajiList = []models.Gaji{*slipData}
+			slipData, err := h.gajiRepo.GetSlipPeriode(*userData.KaryawanID, bulan, tahun)
+			if err == nil {
+				gajiList = slipData
 			}
 		} else {
 			slipData, err := h.gajiRepo.GetByKaryawanID(*userData.KaryawanID)
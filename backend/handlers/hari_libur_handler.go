@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"pemdes-payroll/backend/models"
+	"pemdes-payroll/backend/repositories"
+	"pemdes-payroll/backend/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type HariLiburHandler struct {
+	hariLiburRepo repositories.HariLiburRepository
+	absensiRepo   repositories.AbsensiRepository
+	kalenderSvc   *services.KalenderService
+}
+
+// NewHariLiburHandler creates a new handler for the working calendar
+func NewHariLiburHandler(hariLiburRepo repositories.HariLiburRepository, absensiRepo repositories.AbsensiRepository) *HariLiburHandler {
+	return &HariLiburHandler{
+		hariLiburRepo: hariLiburRepo,
+		absensiRepo:   absensiRepo,
+		kalenderSvc:   services.NewKalenderService(),
+	}
+}
+
+// GetAllHariLibur handles GET /api/hari-libur?tahun= - lists the calendar entries of a year
+func (h *HariLiburHandler) GetAllHariLibur(c *fiber.Ctx) error {
+	tahun, err := strconv.Atoi(c.Query("tahun", strconv.Itoa(time.Now().Year())))
+	if err != nil || tahun < 2000 || tahun > 2100 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tahun parameter",
+		})
+	}
+
+	awal := time.Date(tahun, time.January, 1, 0, 0, 0, 0, time.UTC)
+	libur, err := h.hariLiburRepo.GetByRange(awal, awal.AddDate(1, 0, -1))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch hari libur",
+		})
+	}
+	return c.JSON(libur)
+}
+
+// CreateHariLibur handles POST /api/hari-libur - adds a holiday of the desa, or another
+// calendar entry when jenis is given
+func (h *HariLiburHandler) CreateHariLibur(c *fiber.Ctx) error {
+	var req struct {
+		Tanggal string                `json:"tanggal"`
+		Nama    string                `json:"nama"`
+		Jenis   models.JenisHariLibur `json:"jenis"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	tanggal, err := time.Parse("2006-01-02", req.Tanggal)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tanggal format. Use YYYY-MM-DD",
+		})
+	}
+	if strings.TrimSpace(req.Nama) == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Nama is required",
+		})
+	}
+	if req.Jenis == "" {
+		req.Jenis = models.LiburDesa
+	}
+	if !req.Jenis.IsValid() {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid jenis. Use 'nasional', 'cuti_bersama', 'desa' or 'kerja_pengganti'",
+		})
+	}
+
+	existing, err := h.hariLiburRepo.GetByRange(tanggal, tanggal)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch hari libur",
+		})
+	}
+	if len(existing) > 0 {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Tanggal is already in the calendar as " + existing[0].Nama,
+		})
+	}
+
+	libur := models.HariLibur{
+		Tanggal:    tanggal,
+		Nama:       strings.TrimSpace(req.Nama),
+		Jenis:      req.Jenis,
+		Sumber:     "manual",
+		DibuatOleh: userIDFromCtx(c),
+	}
+	if err := h.hariLiburRepo.Create(&libur); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create hari libur",
+		})
+	}
+	return c.Status(http.StatusCreated).JSON(libur)
+}
+
+// ImportHariLibur handles POST /api/hari-libur/import - imports calendar entries from an
+// ICS or CSV file uploaded as "file". Entries get the form value jenis (default
+// nasional) unless named cuti bersama or, in a CSV, given a jenis of their own.
+func (h *HariLiburHandler) ImportHariLibur(c *fiber.Ctx) error {
+	jenis := models.JenisHariLibur(c.FormValue("jenis", string(models.LiburNasional)))
+	if !jenis.IsValid() {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid jenis. Use 'nasional', 'cuti_bersama', 'desa' or 'kerja_pengganti'",
+		})
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "File is required",
+		})
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to read file",
+		})
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to read file",
+		})
+	}
+
+	var libur []models.HariLibur
+	var gagal []string
+	switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+	case ".ics", ".ical":
+		libur, gagal = h.kalenderSvc.ParseICS(data, jenis)
+	case ".csv", ".txt":
+		libur, gagal = h.kalenderSvc.ParseCSV(data, jenis)
+	default:
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unsupported file type. Upload an .ics or .csv file",
+		})
+	}
+
+	dibuatOleh := userIDFromCtx(c)
+	for i := range libur {
+		libur[i].DibuatOleh = dibuatOleh
+	}
+
+	dibuat, diperbarui, err := h.hariLiburRepo.Import(libur)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to import hari libur",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":    "Import completed",
+		"dibuat":     dibuat,
+		"diperbarui": diperbarui,
+		"gagal":      gagal,
+	})
+}
+
+// DeleteHariLibur handles DELETE /api/hari-libur/:id
+func (h *HariLiburHandler) DeleteHariLibur(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	if _, err := h.hariLiburRepo.GetByID(uint(id)); err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Hari libur not found",
+		})
+	}
+
+	if err := h.hariLiburRepo.Delete(uint(id)); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete hari libur",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Hari libur deleted successfully",
+	})
+}
+
+// periodeKalender reads the period of a calendar query: mulai and selesai as YYYY-MM-DD,
+// or the month given by bulan and tahun, by default the current one
+func periodeKalender(c *fiber.Ctx) (time.Time, time.Time, string) {
+	if c.Query("mulai") != "" || c.Query("selesai") != "" {
+		mulai, err := time.Parse("2006-01-02", c.Query("mulai"))
+		if err != nil {
+			return mulai, mulai, "Invalid mulai format. Use YYYY-MM-DD"
+		}
+		selesai, err := time.Parse("2006-01-02", c.Query("selesai"))
+		if err != nil {
+			return mulai, mulai, "Invalid selesai format. Use YYYY-MM-DD"
+		}
+		if selesai.Before(mulai) {
+			return mulai, selesai, "Selesai must not be before mulai"
+		}
+		if selesai.Sub(mulai) > 366*24*time.Hour {
+			return mulai, selesai, "Period must not exceed one year"
+		}
+		return mulai, selesai, ""
+	}
+
+	bulan, _ := strconv.Atoi(c.Query("bulan", strconv.Itoa(int(time.Now().Month()))))
+	tahun, _ := strconv.Atoi(c.Query("tahun", strconv.Itoa(time.Now().Year())))
+	if bulan < 1 || bulan > 12 {
+		return time.Time{}, time.Time{}, "Invalid bulan parameter"
+	}
+	awal := time.Date(tahun, time.Month(bulan), 1, 0, 0, 0, 0, time.UTC)
+	return awal, awal.AddDate(0, 1, -1), ""
+}
+
+// GetHariKerja handles GET /api/kalender/hari-kerja?bulan=&tahun= (or mulai=&selesai=)
+// - returns the working days and days off of a period. With karyawan_id it also lists the
+// working days the karyawan has no absensi row for.
+func (h *HariLiburHandler) GetHariKerja(c *fiber.Ctx) error {
+	mulai, selesai, msg := periodeKalender(c)
+	if msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	kalender, err := h.hariLiburRepo.GetKalender(mulai, selesai)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch kalender kerja",
+		})
+	}
+
+	hariKerja := []string{}
+	hariLibur := []fiber.Map{}
+	for d := mulai; !d.After(selesai); d = d.AddDate(0, 0, 1) {
+		tanggal := d.Format("2006-01-02")
+		if kalender.IsHariKerja(d) {
+			hariKerja = append(hariKerja, tanggal)
+			continue
+		}
+		keterangan := "Libur " + models.NamaHari[d.Weekday()]
+		if l, ok := kalender.Libur[tanggal]; ok {
+			keterangan = l.Nama
+		}
+		hariLibur = append(hariLibur, fiber.Map{"tanggal": tanggal, "keterangan": keterangan})
+	}
+
+	response := fiber.Map{
+		"mulai":        mulai.Format("2006-01-02"),
+		"selesai":      selesai.Format("2006-01-02"),
+		"jumlah_hari":  int(selesai.Sub(mulai).Hours()/24) + 1,
+		"jumlah_kerja": len(hariKerja),
+		"jumlah_libur": len(hariLibur),
+		"hari_kerja":   hariKerja,
+		"hari_libur":   hariLibur,
+	}
+
+	if c.Query("karyawan_id") != "" {
+		karyawanID, err := strconv.ParseUint(c.Query("karyawan_id"), 10, 32)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid karyawan_id",
+			})
+		}
+		tanpaAbsensi, err := h.absensiRepo.GetHariTanpaAbsensi(uint(karyawanID), mulai, selesai)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch absensi",
+			})
+		}
+		tanggal := make([]string, 0, len(tanpaAbsensi))
+		for _, d := range tanpaAbsensi {
+			tanggal = append(tanggal, d.Format("2006-01-02"))
+		}
+		response["tanpa_absensi"] = tanggal
+	}
+
+	return c.JSON(response)
+}
@@ -5,22 +5,61 @@ import (
 	"pemdes-payroll/backend/models"
 	"pemdes-payroll/backend/repositories"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type JabatanHandler struct {
-	repo repositories.JabatanRepository
+	repo      repositories.JabatanRepository
+	tarifRepo repositories.TarifJabatanRepository
 }
 
 // NewJabatanHandler creates a new Jabatan handler
-func NewJabatanHandler(repo repositories.JabatanRepository) *JabatanHandler {
-	return &JabatanHandler{repo: repo}
+func NewJabatanHandler(repo repositories.JabatanRepository, tarifRepo repositories.TarifJabatanRepository) *JabatanHandler {
+	return &JabatanHandler{repo: repo, tarifRepo: tarifRepo}
+}
+
+// JabatanRequest represents create/update jabatan request. The rates apply from
+// berlaku_mulai (YYYY-MM-DD, default today); earlier periods keep their old rates.
+type JabatanRequest struct {
+	NamaJabatan       string  `json:"nama_jabatan"`
+	GajiPokok         float64 `json:"gaji_pokok"`
+	TunjanganJabatan  float64 `json:"tunjangan_jabatan"`
+	TarifLemburPerJam float64 `json:"tarif_lembur_per_jam"`
+	BerlakuMulai      string  `json:"berlaku_mulai"`
+	Keterangan        string  `json:"keterangan"`
+}
+
+// tarif returns the rate in the request, or an error message if berlaku_mulai is invalid
+func (req *JabatanRequest) tarif() (*models.TarifJabatan, string) {
+	berlaku := hariIni()
+	if req.BerlakuMulai != "" {
+		parsed, err := time.Parse("2006-01-02", req.BerlakuMulai)
+		if err != nil {
+			return nil, "Invalid berlaku_mulai format. Use YYYY-MM-DD"
+		}
+		berlaku = parsed
+	}
+
+	return &models.TarifJabatan{
+		BerlakuMulai:      berlaku,
+		GajiPokok:         req.GajiPokok,
+		TunjanganJabatan:  req.TunjanganJabatan,
+		TarifLemburPerJam: req.TarifLemburPerJam,
+		Keterangan:        req.Keterangan,
+	}, ""
+}
+
+// hariIni returns today's date at midnight UTC, matching how date columns are parsed
+func hariIni() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
 }
 
 // CreateJabatan handles POST /api/jabatan
 func (h *JabatanHandler) CreateJabatan(c *fiber.Ctx) error {
-	var req models.Jabatan
+	var req JabatanRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid request body",
@@ -39,13 +78,34 @@ func (h *JabatanHandler) CreateJabatan(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.repo.Create(&req); err != nil {
+	tarif, msg := req.tarif()
+	if msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	jabatan := models.Jabatan{
+		NamaJabatan:       req.NamaJabatan,
+		GajiPokok:         req.GajiPokok,
+		TunjanganJabatan:  req.TunjanganJabatan,
+		TarifLemburPerJam: req.TarifLemburPerJam,
+	}
+
+	if err := h.repo.Create(&jabatan); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create jabatan",
 		})
 	}
 
-	return c.Status(http.StatusCreated).JSON(req)
+	tarif.JabatanID = jabatan.ID
+	if err := h.tarifRepo.Create(tarif); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create tarif jabatan",
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(jabatan)
 }
 
 // GetAllJabatan handles GET /api/jabatan
@@ -88,7 +148,7 @@ func (h *JabatanHandler) UpdateJabatan(c *fiber.Ctx) error {
 		})
 	}
 
-	var req models.Jabatan
+	var req JabatanRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid request body",
@@ -107,7 +167,33 @@ func (h *JabatanHandler) UpdateJabatan(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.repo.Update(uint(id), &req); err != nil {
+	tarif, msg := req.tarif()
+	if msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	if _, err := h.repo.GetByID(uint(id)); err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Jabatan not found",
+		})
+	}
+
+	// Changed rates are recorded from berlaku_mulai instead of overwriting the old ones
+	tarif.JabatanID = uint(id)
+	if err := h.simpanTarif(tarif); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save tarif jabatan",
+		})
+	}
+
+	if err := h.repo.Update(uint(id), &models.Jabatan{NamaJabatan: req.NamaJabatan}); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update jabatan",
+		})
+	}
+	if err := h.tarifRepo.SyncJabatan(uint(id)); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update jabatan",
 		})
@@ -137,3 +223,144 @@ func (h *JabatanHandler) DeleteJabatan(c *fiber.Ctx) error {
 		"message": "Jabatan deleted successfully",
 	})
 }
+
+// simpanTarif records a rate from its berlaku_mulai when it differs from the rate already
+// valid on that date. A rate on the same date is corrected in place.
+func (h *JabatanHandler) simpanTarif(tarif *models.TarifJabatan) error {
+	berlaku, err := h.tarifRepo.GetBerlaku(tarif.JabatanID, tarif.BerlakuMulai)
+	if err != nil {
+		return err
+	}
+	if tarif.SamaDengan(berlaku) {
+		return nil
+	}
+
+	if existing, err := h.tarifRepo.GetByTanggal(tarif.JabatanID, tarif.BerlakuMulai); err == nil {
+		return h.tarifRepo.Update(existing.ID, tarif)
+	}
+	return h.tarifRepo.Create(tarif)
+}
+
+// GetTarifJabatan handles GET /api/jabatan/:id/tarif - rate history, newest first
+func (h *JabatanHandler) GetTarifJabatan(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	tarif, err := h.tarifRepo.GetByJabatanID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch tarif jabatan",
+		})
+	}
+
+	return c.JSON(tarif)
+}
+
+// CreateTarifJabatan handles POST /api/jabatan/:id/tarif - add a rate, e.g. a raise from a
+// new Perbup entered ahead of its effective date
+func (h *JabatanHandler) CreateTarifJabatan(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	var req JabatanRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Validation
+	if req.BerlakuMulai == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Berlaku mulai is required",
+		})
+	}
+	if req.GajiPokok <= 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Gaji pokok must be greater than 0",
+		})
+	}
+	tarif, msg := req.tarif()
+	if msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	if _, err := h.repo.GetByID(uint(id)); err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Jabatan not found",
+		})
+	}
+	if _, err := h.tarifRepo.GetByTanggal(uint(id), tarif.BerlakuMulai); err == nil {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Tarif jabatan already exists for this date",
+		})
+	}
+
+	tarif.JabatanID = uint(id)
+	if err := h.tarifRepo.Create(tarif); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create tarif jabatan",
+		})
+	}
+	if err := h.tarifRepo.SyncJabatan(uint(id)); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update jabatan",
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(tarif)
+}
+
+// DeleteTarifJabatan handles DELETE /api/jabatan/:id/tarif/:tarif_id - only rates that
+// have not taken effect yet can be deleted
+func (h *JabatanHandler) DeleteTarifJabatan(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+	tarifID, err := strconv.ParseUint(c.Params("tarif_id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tarif ID",
+		})
+	}
+
+	tarif, err := h.tarifRepo.GetByID(uint(tarifID))
+	if err != nil || tarif.JabatanID != uint(id) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Tarif jabatan not found",
+		})
+	}
+	if !tarif.BerlakuMulai.After(hariIni()) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Tarif jabatan already in effect cannot be deleted",
+		})
+	}
+
+	if err := h.tarifRepo.Delete(tarif.ID); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete tarif jabatan",
+		})
+	}
+	if err := h.tarifRepo.SyncJabatan(uint(id)); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update jabatan",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Tarif jabatan deleted successfully",
+	})
+}
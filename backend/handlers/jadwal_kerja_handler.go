@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"pemdes-payroll/backend/models"
+	"pemdes-payroll/backend/repositories"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type JadwalKerjaHandler struct {
+	jadwalRepo repositories.JadwalKerjaRepository
+}
+
+// NewJadwalKerjaHandler creates a new JadwalKerja handler
+func NewJadwalKerjaHandler(jadwalRepo repositories.JadwalKerjaRepository) *JadwalKerjaHandler {
+	return &JadwalKerjaHandler{jadwalRepo: jadwalRepo}
+}
+
+// jadwalResponse is a weekday schedule with the name of the day
+type jadwalResponse struct {
+	models.JadwalKerja
+	NamaHari string `json:"nama_hari"`
+}
+
+// validateJadwal checks a weekday schedule and returns an error message, or "" if valid
+func validateJadwal(j *models.JadwalKerja) string {
+	if j.Hari < 0 || j.Hari > 6 {
+		return "Hari must be between 0 (Minggu) and 6 (Sabtu)"
+	}
+	hari := j.NamaHari()
+	if j.ToleransiMenit < 0 {
+		return "Toleransi menit of " + hari + " must not be negative"
+	}
+	if j.Libur {
+		return ""
+	}
+
+	masuk, ok := models.ParseJam(j.JamMasuk)
+	if !ok {
+		return "Invalid jam_masuk of " + hari + ". Use HH:MM"
+	}
+	keluar, ok := models.ParseJam(j.JamKeluar)
+	if !ok {
+		return "Invalid jam_keluar of " + hari + ". Use HH:MM"
+	}
+	if keluar <= masuk {
+		return "Jam keluar of " + hari + " must be after jam masuk"
+	}
+
+	if j.IstirahatMulai == "" && j.IstirahatSelesai == "" {
+		return ""
+	}
+	mulai, okMulai := models.ParseJam(j.IstirahatMulai)
+	selesai, okSelesai := models.ParseJam(j.IstirahatSelesai)
+	if !okMulai || !okSelesai {
+		return "Invalid istirahat of " + hari + ". Give both istirahat_mulai and istirahat_selesai as HH:MM"
+	}
+	if selesai <= mulai || mulai < masuk || selesai > keluar {
+		return "Istirahat of " + hari + " must lie within the working hours"
+	}
+	return ""
+}
+
+// GetJadwalKerja handles GET /api/jadwal-kerja - returns the working schedule of each
+// weekday, with the default schedule for days not configured
+func (h *JadwalKerjaHandler) GetJadwalKerja(c *fiber.Ctx) error {
+	jadwal, err := h.jadwalRepo.GetMingguan()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch jadwal kerja",
+		})
+	}
+
+	response := make([]jadwalResponse, 0, 7)
+	for hari := 0; hari < 7; hari++ {
+		j := jadwal[time.Weekday(hari)]
+		response = append(response, jadwalResponse{JadwalKerja: j, NamaHari: j.NamaHari()})
+	}
+	return c.JSON(response)
+}
+
+// UpdateJadwalKerja handles PUT /api/jadwal-kerja - replaces the schedule of the weekdays
+// given. Minutes late and left early of all absensi are computed from the new schedule.
+func (h *JadwalKerjaHandler) UpdateJadwalKerja(c *fiber.Ctx) error {
+	var req []models.JadwalKerja
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if len(req) == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one hari is required",
+		})
+	}
+
+	dilihat := make(map[int]bool, len(req))
+	for i := range req {
+		j := &req[i]
+		j.ID = 0
+		if msg := validateJadwal(j); msg != "" {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": msg,
+			})
+		}
+		if dilihat[j.Hari] {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Hari %s is given more than once", j.NamaHari()),
+			})
+		}
+		dilihat[j.Hari] = true
+
+		if j.Libur {
+			j.JamMasuk, j.JamKeluar, j.IstirahatMulai, j.IstirahatSelesai = "", "", "", ""
+		}
+	}
+
+	if err := h.jadwalRepo.Simpan(req); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save jadwal kerja",
+		})
+	}
+
+	return h.GetJadwalKerja(c)
+}
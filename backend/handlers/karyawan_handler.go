@@ -5,9 +5,11 @@ import (
 	"pemdes-payroll/backend/models"
 	"pemdes-payroll/backend/repositories"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type KaryawanHandler struct {
@@ -29,7 +31,10 @@ func (h *KaryawanHandler) CreateKaryawan(c *fiber.Ctx) error {
 		Alamat          string     `json:"alamat"`
 		JabatanID       *uint      `json:"jabatan_id"`
 		TanggalBergabung *string   `json:"tanggal_bergabung"`
+		TanggalBerhenti *string    `json:"tanggal_berhenti"`
+		StatusPTKP      models.StatusPTKP `json:"status_ptkp"`
 		Status          models.KaryawanStatus `json:"status"`
+		PinFingerprint  *string    `json:"pin_fingerprint"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -49,20 +54,29 @@ func (h *KaryawanHandler) CreateKaryawan(c *fiber.Ctx) error {
 			"error": "Nama is required",
 		})
 	}
+	if req.StatusPTKP != "" && !req.StatusPTKP.IsValid() {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid status PTKP. Use TK/0-TK/3 or K/0-K/3",
+		})
+	}
 
 	karyawan := models.Karyawan{
-		NIK:       req.NIK,
-		Nama:      req.Nama,
-		Email:     req.Email,
-		Telepon:   req.Telepon,
-		Alamat:    req.Alamat,
-		JabatanID: req.JabatanID,
-		Status:    req.Status,
+		NIK:        req.NIK,
+		Nama:       req.Nama,
+		Email:      req.Email,
+		Telepon:    req.Telepon,
+		Alamat:     req.Alamat,
+		JabatanID:  req.JabatanID,
+		StatusPTKP: req.StatusPTKP,
+		Status:     req.Status,
 	}
 
 	if karyawan.Status == "" {
 		karyawan.Status = models.StatusAktif
 	}
+	if karyawan.StatusPTKP == "" {
+		karyawan.StatusPTKP = models.PTKPTK0
+	}
 
 	if req.TanggalBergabung != nil && *req.TanggalBergabung != "" {
 		parsedDate, err := time.Parse("2006-01-02", *req.TanggalBergabung)
@@ -71,6 +85,22 @@ func (h *KaryawanHandler) CreateKaryawan(c *fiber.Ctx) error {
 		}
 	}
 
+	tanggalBerhenti, msg := parseTanggalBerhenti(req.TanggalBerhenti, karyawan.TanggalBergabung)
+	if msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	karyawan.TanggalBerhenti = tanggalBerhenti
+
+	pin, status, msg := h.cekPinFingerprint(req.PinFingerprint, 0)
+	if msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	karyawan.PinFingerprint = pin
+
 	if err := h.repo.Create(&karyawan); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create karyawan",
@@ -143,7 +173,10 @@ func (h *KaryawanHandler) UpdateKaryawan(c *fiber.Ctx) error {
 		Alamat          string     `json:"alamat"`
 		JabatanID       *uint      `json:"jabatan_id"`
 		TanggalBergabung *string   `json:"tanggal_bergabung"`
+		TanggalBerhenti *string    `json:"tanggal_berhenti"`
+		StatusPTKP      models.StatusPTKP `json:"status_ptkp"`
 		Status          models.KaryawanStatus `json:"status"`
+		PinFingerprint  *string    `json:"pin_fingerprint"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -163,15 +196,21 @@ func (h *KaryawanHandler) UpdateKaryawan(c *fiber.Ctx) error {
 			"error": "Nama is required",
 		})
 	}
+	if req.StatusPTKP != "" && !req.StatusPTKP.IsValid() {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid status PTKP. Use TK/0-TK/3 or K/0-K/3",
+		})
+	}
 
 	karyawan := models.Karyawan{
-		NIK:       req.NIK,
-		Nama:      req.Nama,
-		Email:     req.Email,
-		Telepon:   req.Telepon,
-		Alamat:    req.Alamat,
-		JabatanID: req.JabatanID,
-		Status:    req.Status,
+		NIK:        req.NIK,
+		Nama:       req.Nama,
+		Email:      req.Email,
+		Telepon:    req.Telepon,
+		Alamat:     req.Alamat,
+		JabatanID:  req.JabatanID,
+		StatusPTKP: req.StatusPTKP,
+		Status:     req.Status,
 	}
 
 	if req.TanggalBergabung != nil && *req.TanggalBergabung != "" {
@@ -181,12 +220,44 @@ func (h *KaryawanHandler) UpdateKaryawan(c *fiber.Ctx) error {
 		}
 	}
 
+	tanggalBerhenti, msg := parseTanggalBerhenti(req.TanggalBerhenti, karyawan.TanggalBergabung)
+	if msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	pin, status, msg := h.cekPinFingerprint(req.PinFingerprint, uint(id))
+	if msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
 	if err := h.repo.Update(uint(id), &karyawan); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update karyawan",
 		})
 	}
 
+	// An empty tanggal_berhenti clears the end of service; leaving it out keeps it
+	if req.TanggalBerhenti != nil {
+		if err := h.repo.SetTanggalBerhenti(uint(id), tanggalBerhenti); err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update karyawan",
+			})
+		}
+	}
+
+	// An empty pin_fingerprint unenrolls the karyawan; leaving it out keeps it
+	if req.PinFingerprint != nil {
+		if err := h.repo.SetPinFingerprint(uint(id), pin); err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update karyawan",
+			})
+		}
+	}
+
 	// Get updated data
 	updated, _ := h.repo.GetByIDWithJabatan(uint(id))
 	return c.JSON(updated)
@@ -230,3 +301,39 @@ func (h *KaryawanHandler) GetActiveKaryawan(c *fiber.Ctx) error {
 
 	return c.JSON(karyawan)
 }
+
+// parseTanggalBerhenti parses the end-of-service date, which may not be before the
+// join date. It returns an error message, or "" if valid.
+func parseTanggalBerhenti(value *string, bergabung *time.Time) (*time.Time, string) {
+	if value == nil || *value == "" {
+		return nil, ""
+	}
+	tanggal, err := time.Parse("2006-01-02", *value)
+	if err != nil {
+		return nil, "Invalid tanggal berhenti format. Use YYYY-MM-DD"
+	}
+	if bergabung != nil && tanggal.Before(*bergabung) {
+		return nil, "Tanggal berhenti cannot be before tanggal bergabung"
+	}
+	return &tanggal, ""
+}
+
+// cekPinFingerprint trims the fingerprint terminal PIN and checks that no other karyawan
+// uses it. It returns nil for an empty PIN, or the HTTP status and an error message.
+func (h *KaryawanHandler) cekPinFingerprint(value *string, id uint) (*string, int, string) {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return nil, 0, ""
+	}
+	pin := strings.TrimSpace(*value)
+	if len(pin) > 20 {
+		return nil, http.StatusBadRequest, "PIN fingerprint must not exceed 20 characters"
+	}
+	existing, err := h.repo.GetByPinFingerprint(pin)
+	if err == nil && existing.ID != id {
+		return nil, http.StatusConflict, "PIN fingerprint is already used by " + existing.Nama
+	}
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, http.StatusInternalServerError, "Failed to check PIN fingerprint"
+	}
+	return &pin, 0, ""
+}
@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"net/http"
+	"pemdes-payroll/backend/models"
+	"pemdes-payroll/backend/repositories"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type KasbonHandler struct {
+	kasbonRepo   repositories.KasbonRepository
+	karyawanRepo repositories.KaryawanRepository
+}
+
+// NewKasbonHandler creates a new Kasbon handler
+func NewKasbonHandler(kasbonRepo repositories.KasbonRepository, karyawanRepo repositories.KaryawanRepository) *KasbonHandler {
+	return &KasbonHandler{kasbonRepo: kasbonRepo, karyawanRepo: karyawanRepo}
+}
+
+// KasbonRequest represents the create kasbon request
+type KasbonRequest struct {
+	KaryawanID    uint    `json:"karyawan_id"`
+	Tanggal       string  `json:"tanggal"`
+	Pokok         float64 `json:"pokok"`
+	JumlahCicilan int     `json:"jumlah_cicilan"`
+	MulaiBulan    int     `json:"mulai_bulan"`
+	MulaiTahun    int     `json:"mulai_tahun"`
+	Keterangan    string  `json:"keterangan"`
+}
+
+// validate checks the request and returns an error message, or "" if valid
+func (req *KasbonRequest) validate() string {
+	if req.KaryawanID == 0 {
+		return "Karyawan ID is required"
+	}
+	if req.Pokok <= 0 {
+		return "Pokok must be greater than 0"
+	}
+	if req.JumlahCicilan < 1 {
+		return "Jumlah cicilan must be at least 1"
+	}
+	if req.Pokok < float64(req.JumlahCicilan) {
+		return "Pokok must be at least Rp 1 per cicilan"
+	}
+	if req.MulaiBulan < 1 || req.MulaiBulan > 12 {
+		return "Mulai bulan must be between 1 and 12"
+	}
+	if req.MulaiTahun < 2000 || req.MulaiTahun > 2100 {
+		return "Invalid mulai tahun"
+	}
+	return ""
+}
+
+// CreateKasbon handles POST /api/kasbon - records a kasbon and its installment schedule
+func (h *KasbonHandler) CreateKasbon(c *fiber.Ctx) error {
+	if isPeranKaryawan(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Karyawan cannot manage kasbon",
+		})
+	}
+
+	var req KasbonRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if msg := req.validate(); msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	tanggal := hariIni()
+	if req.Tanggal != "" {
+		var err error
+		tanggal, err = time.Parse("2006-01-02", req.Tanggal)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid tanggal format. Use YYYY-MM-DD",
+			})
+		}
+	}
+
+	if _, err := h.karyawanRepo.GetByID(req.KaryawanID); err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Karyawan not found",
+		})
+	}
+
+	kasbon := models.Kasbon{
+		KaryawanID:    req.KaryawanID,
+		Tanggal:       tanggal,
+		Pokok:         req.Pokok,
+		JumlahCicilan: req.JumlahCicilan,
+		MulaiBulan:    req.MulaiBulan,
+		MulaiTahun:    req.MulaiTahun,
+		Keterangan:    strings.TrimSpace(req.Keterangan),
+		Status:        models.KasbonAktif,
+		DibuatOleh:    userIDFromCtx(c),
+	}
+	kasbon.BuatJadwal()
+
+	if err := h.kasbonRepo.Create(&kasbon); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create kasbon",
+		})
+	}
+
+	result, _ := h.kasbonRepo.GetByID(kasbon.ID)
+	return c.Status(http.StatusCreated).JSON(result)
+}
+
+// GetAllKasbon handles GET /api/kasbon?status=&karyawan_id=
+func (h *KasbonHandler) GetAllKasbon(c *fiber.Ctx) error {
+	status := c.Query("status")
+	if status != "" && status != string(models.KasbonAktif) && status != string(models.KasbonLunas) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid status. Use 'aktif' or 'lunas'",
+		})
+	}
+	karyawanID, _ := strconv.ParseUint(c.Query("karyawan_id", "0"), 10, 32)
+
+	kasbon, err := h.kasbonRepo.GetAll(status, uint(karyawanID))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch kasbon",
+		})
+	}
+
+	return c.JSON(kasbon)
+}
+
+// GetKasbonByID handles GET /api/kasbon/:id
+func (h *KasbonHandler) GetKasbonByID(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	kasbon, err := h.kasbonRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Kasbon not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"kasbon": kasbon,
+		"saldo":  kasbon.Saldo(),
+	})
+}
+
+// GetSaldoKasbon handles GET /api/kasbon/saldo?karyawan_id=&semua= - the outstanding
+// balance of every active kasbon, or of all kasbon when semua=true
+func (h *KasbonHandler) GetSaldoKasbon(c *fiber.Ctx) error {
+	status := string(models.KasbonAktif)
+	if c.Query("semua") == "true" {
+		status = ""
+	}
+	karyawanID, _ := strconv.ParseUint(c.Query("karyawan_id", "0"), 10, 32)
+
+	kasbonList, err := h.kasbonRepo.GetAll(status, uint(karyawanID))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch kasbon",
+		})
+	}
+
+	data := []models.SaldoKasbon{}
+	totalPokok, totalTerbayar, totalSisa := 0.0, 0.0, 0.0
+	for i := range kasbonList {
+		saldo := kasbonList[i].Saldo()
+		data = append(data, saldo)
+		totalPokok += saldo.Pokok
+		totalTerbayar += saldo.Terbayar
+		totalSisa += saldo.Sisa
+	}
+
+	return c.JSON(fiber.Map{
+		"data":           data,
+		"total_pokok":    totalPokok,
+		"total_terbayar": totalTerbayar,
+		"total_sisa":     totalSisa,
+	})
+}
+
+// LewatiAngsuran handles POST /api/kasbon/:id/angsuran/:angsuran_id/lewati - skips a
+// scheduled installment and adds one at the end of the schedule
+func (h *KasbonHandler) LewatiAngsuran(c *fiber.Ctx) error {
+	if isPeranKaryawan(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Karyawan cannot manage kasbon",
+		})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+	angsuranID, err := strconv.ParseUint(c.Params("angsuran_id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid angsuran ID",
+		})
+	}
+
+	var req struct {
+		Alasan string `json:"alasan"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	req.Alasan = strings.TrimSpace(req.Alasan)
+	if req.Alasan == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Alasan is required",
+		})
+	}
+
+	angsuran, err := h.kasbonRepo.GetAngsuranByID(uint(angsuranID))
+	if err != nil || angsuran.KasbonID != uint(id) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Angsuran not found",
+		})
+	}
+	if angsuran.Status != models.AngsuranTerjadwal {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Only scheduled installments can be skipped. Delete or recalculate the slip that deducted it first",
+		})
+	}
+
+	if err := h.kasbonRepo.Lewati(angsuran, req.Alasan); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to skip angsuran",
+		})
+	}
+
+	result, _ := h.kasbonRepo.GetByID(uint(id))
+	return c.JSON(result)
+}
+
+// LunasiKasbon handles POST /api/kasbon/:id/lunasi - settles a kasbon early. With
+// metode "tunai" the remaining installments are repaid in cash; with metode "gaji" they
+// are combined into one installment deducted from the slip of the given period.
+func (h *KasbonHandler) LunasiKasbon(c *fiber.Ctx) error {
+	if isPeranKaryawan(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Karyawan cannot manage kasbon",
+		})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	var req struct {
+		Metode       string `json:"metode"`
+		PeriodeBulan int    `json:"periode_bulan"`
+		PeriodeTahun int    `json:"periode_tahun"`
+		Keterangan   string `json:"keterangan"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	kasbon, err := h.kasbonRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Kasbon not found",
+		})
+	}
+	if kasbon.Status == models.KasbonLunas {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Kasbon is already lunas",
+		})
+	}
+
+	switch req.Metode {
+	case "tunai":
+		if req.Keterangan == "" {
+			req.Keterangan = "Pelunasan tunai"
+		}
+		err = h.kasbonRepo.Lunasi(kasbon.ID, req.Keterangan)
+	case "gaji":
+		if req.PeriodeBulan < 1 || req.PeriodeBulan > 12 {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Periode bulan must be between 1 and 12",
+			})
+		}
+		if req.PeriodeTahun < 2000 || req.PeriodeTahun > 2100 {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid periode tahun",
+			})
+		}
+		if req.Keterangan == "" {
+			req.Keterangan = "Pelunasan dipercepat"
+		}
+		err = h.kasbonRepo.PercepatPelunasan(kasbon.ID, req.PeriodeBulan, req.PeriodeTahun, req.Keterangan)
+	default:
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid metode. Use 'tunai' or 'gaji'",
+		})
+	}
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to settle kasbon",
+		})
+	}
+
+	result, _ := h.kasbonRepo.GetByID(kasbon.ID)
+	return c.JSON(result)
+}
+
+// DeleteKasbon handles DELETE /api/kasbon/:id - only kasbon with nothing deducted or repaid
+func (h *KasbonHandler) DeleteKasbon(c *fiber.Ctx) error {
+	if isPeranKaryawan(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Karyawan cannot manage kasbon",
+		})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	kasbon, err := h.kasbonRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Kasbon not found",
+		})
+	}
+	for _, a := range kasbon.Angsuran {
+		if a.Status == models.AngsuranDipotong || a.Status == models.AngsuranDilunasi {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{
+				"error": "Kasbon already has installments paid and cannot be deleted",
+			})
+		}
+	}
+
+	if err := h.kasbonRepo.Delete(uint(id)); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete kasbon",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Kasbon deleted successfully",
+	})
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"net/http"
+	"pemdes-payroll/backend/models"
+	"pemdes-payroll/backend/repositories"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type KomponenGajiHandler struct {
+	repo repositories.KomponenGajiRepository
+}
+
+// NewKomponenGajiHandler creates a new KomponenGaji handler
+func NewKomponenGajiHandler(repo repositories.KomponenGajiRepository) *KomponenGajiHandler {
+	return &KomponenGajiHandler{repo: repo}
+}
+
+// KomponenGajiRequest represents create/update komponen gaji request
+type KomponenGajiRequest struct {
+	Kode         string                    `json:"kode"`
+	Nama         string                    `json:"nama"`
+	Jenis        models.JenisKomponen      `json:"jenis"`
+	TipeFormula  models.TipeFormula        `json:"tipe_formula"`
+	Nilai        float64                   `json:"nilai"`
+	BerlakuSemua *bool                     `json:"berlaku_semua"`
+	Aktif        *bool                     `json:"aktif"`
+	Urutan       int                       `json:"urutan"`
+	NilaiJabatan []models.KomponenJabatan  `json:"nilai_jabatan"`
+	Karyawan     []models.KomponenKaryawan `json:"karyawan"`
+}
+
+// validate checks the request and returns an error message, or "" if valid
+func (req *KomponenGajiRequest) validate() string {
+	req.Kode = strings.ToUpper(strings.TrimSpace(req.Kode))
+	if req.Kode == "" {
+		return "Kode is required"
+	}
+	if models.IsKodeSistem(req.Kode) {
+		return "Kode " + req.Kode + " is reserved for system components"
+	}
+	if req.Nama == "" {
+		return "Nama is required"
+	}
+	if req.Jenis != models.JenisPendapatan && req.Jenis != models.JenisPotongan {
+		return "Invalid jenis. Use 'pendapatan' or 'potongan'"
+	}
+	switch req.TipeFormula {
+	case models.FormulaTetap, models.FormulaPersenPokok, models.FormulaPerHadir, models.FormulaPerJabatan:
+	default:
+		return "Invalid tipe formula. Use 'tetap', 'persen_pokok', 'per_hadir' or 'per_jabatan'"
+	}
+	if req.Nilai < 0 {
+		return "Nilai must not be negative"
+	}
+	return ""
+}
+
+func (req *KomponenGajiRequest) toModel() models.KomponenGaji {
+	komponen := models.KomponenGaji{
+		Kode:         req.Kode,
+		Nama:         req.Nama,
+		Jenis:        req.Jenis,
+		TipeFormula:  req.TipeFormula,
+		Nilai:        req.Nilai,
+		BerlakuSemua: true,
+		Aktif:        true,
+		Urutan:       req.Urutan,
+		NilaiJabatan: req.NilaiJabatan,
+		Karyawan:     req.Karyawan,
+	}
+	if req.BerlakuSemua != nil {
+		komponen.BerlakuSemua = *req.BerlakuSemua
+	}
+	if req.Aktif != nil {
+		komponen.Aktif = *req.Aktif
+	}
+	return komponen
+}
+
+// CreateKomponenGaji handles POST /api/komponen-gaji
+func (h *KomponenGajiHandler) CreateKomponenGaji(c *fiber.Ctx) error {
+	var req KomponenGajiRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if msg := req.validate(); msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	if _, err := h.repo.GetByKode(req.Kode); err == nil {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Kode already exists",
+		})
+	}
+
+	komponen := req.toModel()
+	if err := h.repo.Create(&komponen); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create komponen gaji",
+		})
+	}
+
+	result, _ := h.repo.GetByID(komponen.ID)
+	return c.Status(http.StatusCreated).JSON(result)
+}
+
+// GetAllKomponenGaji handles GET /api/komponen-gaji
+func (h *KomponenGajiHandler) GetAllKomponenGaji(c *fiber.Ctx) error {
+	komponen, err := h.repo.GetAll()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch komponen gaji",
+		})
+	}
+
+	return c.JSON(komponen)
+}
+
+// GetKomponenGajiByID handles GET /api/komponen-gaji/:id
+func (h *KomponenGajiHandler) GetKomponenGajiByID(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	komponen, err := h.repo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Komponen gaji not found",
+		})
+	}
+
+	return c.JSON(komponen)
+}
+
+// UpdateKomponenGaji handles PUT /api/komponen-gaji/:id
+func (h *KomponenGajiHandler) UpdateKomponenGaji(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	var req KomponenGajiRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if msg := req.validate(); msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	if other, err := h.repo.GetByKode(req.Kode); err == nil && other.ID != uint(id) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Kode already exists",
+		})
+	}
+
+	komponen := req.toModel()
+	if err := h.repo.Update(uint(id), &komponen); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update komponen gaji",
+		})
+	}
+
+	updated, _ := h.repo.GetByID(uint(id))
+	return c.JSON(updated)
+}
+
+// DeleteKomponenGaji handles DELETE /api/komponen-gaji/:id
+func (h *KomponenGajiHandler) DeleteKomponenGaji(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	if err := h.repo.Delete(uint(id)); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete komponen gaji",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Komponen gaji deleted successfully",
+	})
+}
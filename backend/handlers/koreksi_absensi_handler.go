@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"net/http"
+	"path/filepath"
+	"pemdes-payroll/backend/config"
+	"pemdes-payroll/backend/models"
+	"pemdes-payroll/backend/repositories"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type KoreksiAbsensiHandler struct {
+	koreksiRepo    repositories.KoreksiAbsensiRepository
+	absensiRepo    repositories.AbsensiRepository
+	karyawanRepo   repositories.KaryawanRepository
+	userRepo       repositories.UserRepository
+	payrollRunRepo repositories.PayrollRunRepository
+	cfg            *config.CutiConfig
+}
+
+// NewKoreksiAbsensiHandler creates a new handler for attendance correction requests
+func NewKoreksiAbsensiHandler(koreksiRepo repositories.KoreksiAbsensiRepository, absensiRepo repositories.AbsensiRepository, karyawanRepo repositories.KaryawanRepository, userRepo repositories.UserRepository, payrollRunRepo repositories.PayrollRunRepository) *KoreksiAbsensiHandler {
+	return &KoreksiAbsensiHandler{
+		koreksiRepo:    koreksiRepo,
+		absensiRepo:    absensiRepo,
+		karyawanRepo:   karyawanRepo,
+		userRepo:       userRepo,
+		payrollRunRepo: payrollRunRepo,
+		cfg:            config.GetCutiConfig(),
+	}
+}
+
+// KoreksiAbsensiRequest represents a correction request, sent as JSON or as a multipart
+// form with the attachment in "lampiran". Empty fields keep the recorded value.
+// KaryawanID is ignored for users with the karyawan role, who correct their own absensi.
+type KoreksiAbsensiRequest struct {
+	KaryawanID    uint                 `json:"karyawan_id" form:"karyawan_id"`
+	Tanggal       string               `json:"tanggal" form:"tanggal"`
+	JamMasuk      string               `json:"jam_masuk" form:"jam_masuk"`
+	JamKeluar     string               `json:"jam_keluar" form:"jam_keluar"`
+	StatusAbsensi models.AbsensiStatus `json:"status_absensi" form:"status_absensi"`
+	Alasan        string               `json:"alasan" form:"alasan"`
+
+	tanggal time.Time
+}
+
+// validate checks the day and the corrected values and returns an error message, or ""
+// if valid
+func (req *KoreksiAbsensiRequest) validate() string {
+	var err error
+	if req.tanggal, err = time.Parse("2006-01-02", req.Tanggal); err != nil {
+		return "Invalid tanggal format. Use YYYY-MM-DD"
+	}
+	if req.tanggal.After(hariIni()) {
+		return "Tanggal must not be in the future"
+	}
+	if msg := validateJamAbsensi(req.JamMasuk, req.JamKeluar); msg != "" {
+		return msg
+	}
+	switch req.StatusAbsensi {
+	case "", models.AbsensiHadir, models.AbsensiIzin, models.AbsensiSakit, models.AbsensiAlpha:
+	default:
+		return "Invalid status_absensi. Use 'hadir', 'izin', 'sakit' or 'alpha'"
+	}
+	if req.JamMasuk == "" && req.JamKeluar == "" && req.StatusAbsensi == "" {
+		return "Fill in jam_masuk, jam_keluar or status_absensi"
+	}
+	if strings.TrimSpace(req.Alasan) == "" {
+		return "Alasan is required"
+	}
+	return ""
+}
+
+// CreateKoreksiAbsensi handles POST /api/absensi/koreksi - submits a correction of a day's
+// absensi for approval by the Sekdes or Kades, e.g. a forgotten clock-out
+func (h *KoreksiAbsensiHandler) CreateKoreksiAbsensi(c *fiber.Ctx) error {
+	var req KoreksiAbsensiRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	if isPeranKaryawan(c) {
+		karyawanID, err := karyawanPengguna(c, h.userRepo)
+		if err != nil || karyawanID == nil {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{
+				"error": "User is not linked to a karyawan",
+			})
+		}
+		req.KaryawanID = *karyawanID
+	}
+	if req.KaryawanID == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Karyawan ID is required",
+		})
+	}
+	if _, err := h.karyawanRepo.GetByID(req.KaryawanID); err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Karyawan not found",
+		})
+	}
+	if ok, err := cekPeriodeTerbuka(c, h.payrollRunRepo, req.KaryawanID, req.tanggal); !ok {
+		return err
+	}
+
+	diajukan, err := h.koreksiRepo.GetDiajukan(req.KaryawanID, req.tanggal)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch koreksi absensi",
+		})
+	}
+	if diajukan != nil {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "A koreksi for this day is already waiting for approval",
+		})
+	}
+
+	absensi, err := h.absensiRepo.GetByKaryawanID(req.KaryawanID, req.tanggal, req.tanggal)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch absensi",
+		})
+	}
+	pengajuan := models.PengajuanKoreksiAbsensi{
+		KaryawanID:    req.KaryawanID,
+		Tanggal:       req.tanggal,
+		JamMasuk:      req.JamMasuk,
+		JamKeluar:     req.JamKeluar,
+		StatusAbsensi: req.StatusAbsensi,
+		Alasan:        strings.TrimSpace(req.Alasan),
+		Status:        models.KoreksiAbsensiDiajukan,
+		DiajukanOleh:  userIDFromCtx(c),
+	}
+	if len(absensi) > 0 {
+		if absensi[0].PengajuanCutiID != nil {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{
+				"error": "Absensi belongs to an approved cuti. Cancel the cuti instead",
+			})
+		}
+		if ok, err := cekBelumDigaji(c, absensi[0].GajiID, "Absensi", "POST /api/absensi/:id/koreksi-gaji"); !ok {
+			return err
+		}
+		pengajuan.AbsensiID = &absensi[0].ID
+	}
+
+	if fileHeader, err := c.FormFile("lampiran"); err == nil {
+		lampiran, msg := simpanLampiran(c, fileHeader, h.cfg.LampiranDir, "koreksi-absensi")
+		if msg != "" {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": msg,
+			})
+		}
+		pengajuan.Lampiran = lampiran
+	}
+
+	if err := h.koreksiRepo.Create(&pengajuan); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create koreksi absensi",
+		})
+	}
+
+	result, _ := h.koreksiRepo.GetByID(pengajuan.ID)
+	return c.Status(http.StatusCreated).JSON(result)
+}
+
+// GetAllKoreksiAbsensi handles GET /api/absensi/koreksi?status=&karyawan_id= - users with
+// the karyawan role only see their own requests
+func (h *KoreksiAbsensiHandler) GetAllKoreksiAbsensi(c *fiber.Ctx) error {
+	karyawanID, _ := strconv.ParseUint(c.Query("karyawan_id", "0"), 10, 32)
+
+	if isPeranKaryawan(c) {
+		id, err := karyawanPengguna(c, h.userRepo)
+		if err != nil || id == nil {
+			return c.JSON([]models.PengajuanKoreksiAbsensi{})
+		}
+		karyawanID = uint64(*id)
+	}
+
+	pengajuan, err := h.koreksiRepo.GetAll(c.Query("status"), uint(karyawanID))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch koreksi absensi",
+		})
+	}
+
+	return c.JSON(pengajuan)
+}
+
+// pengajuanDariParam loads the request of the :id parameter, checking that users with the
+// karyawan role only reach their own. When it cannot, it writes the error response and
+// returns nil.
+func (h *KoreksiAbsensiHandler) pengajuanDariParam(c *fiber.Ctx) (*models.PengajuanKoreksiAbsensi, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	pengajuan, err := h.koreksiRepo.GetByID(uint(id))
+	if err != nil {
+		return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Koreksi absensi not found",
+		})
+	}
+
+	if isPeranKaryawan(c) {
+		karyawanID, err := karyawanPengguna(c, h.userRepo)
+		if err != nil || karyawanID == nil || *karyawanID != pengajuan.KaryawanID {
+			return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Koreksi absensi not found",
+			})
+		}
+	}
+	return pengajuan, nil
+}
+
+// GetKoreksiAbsensiByID handles GET /api/absensi/koreksi/:id
+func (h *KoreksiAbsensiHandler) GetKoreksiAbsensiByID(c *fiber.Ctx) error {
+	pengajuan, err := h.pengajuanDariParam(c)
+	if err != nil || pengajuan == nil {
+		return err
+	}
+	return c.JSON(pengajuan)
+}
+
+// GetLampiranKoreksiAbsensi handles GET /api/absensi/koreksi/:id/lampiran - downloads the
+// attachment
+func (h *KoreksiAbsensiHandler) GetLampiranKoreksiAbsensi(c *fiber.Ctx) error {
+	pengajuan, err := h.pengajuanDariParam(c)
+	if err != nil || pengajuan == nil {
+		return err
+	}
+	if pengajuan.Lampiran == "" {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Koreksi absensi has no lampiran",
+		})
+	}
+	return c.Download(filepath.Join(h.cfg.LampiranDir, pengajuan.Lampiran))
+}
+
+// SetujuiKoreksiAbsensi handles POST /api/absensi/koreksi/:id/setujui - Sekdes or Kades
+// only. Writes the corrected values to the day's absensi, including times recorded by
+// clock-in, and keeps the previous values in its riwayat.
+func (h *KoreksiAbsensiHandler) SetujuiKoreksiAbsensi(c *fiber.Ctx) error {
+	catatan, ok := keputusan(c)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	pengajuan, err := h.pengajuanDariParam(c)
+	if err != nil || pengajuan == nil {
+		return err
+	}
+	if ok, err := cekPemutus(c, h.userRepo, pengajuan.DiajukanOleh, pengajuan.KaryawanID, "koreksi absensi"); !ok {
+		return err
+	}
+	if pengajuan.Status != models.KoreksiAbsensiDiajukan {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Koreksi absensi is already " + string(pengajuan.Status),
+		})
+	}
+	if ok, err := cekPeriodeTerbuka(c, h.payrollRunRepo, pengajuan.KaryawanID, pengajuan.Tanggal); !ok {
+		return err
+	}
+
+	err = h.koreksiRepo.Setujui(pengajuan, userIDFromCtx(c), catatan)
+	switch {
+	case err == repositories.ErrAbsensiBentrok:
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Absensi belongs to an approved cuti. Cancel the cuti instead",
+		})
+	case err == repositories.ErrAbsensiDigaji:
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Absensi has been paid in a slip gaji and is read-only. Submit a koreksi through POST /api/absensi/:id/koreksi-gaji instead",
+		})
+	case err != nil:
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to approve koreksi absensi",
+		})
+	}
+
+	return c.JSON(pengajuan)
+}
+
+// TolakKoreksiAbsensi handles POST /api/absensi/koreksi/:id/tolak - Sekdes or Kades only
+func (h *KoreksiAbsensiHandler) TolakKoreksiAbsensi(c *fiber.Ctx) error {
+	catatan, ok := keputusan(c)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if catatan == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Catatan is required to reject a koreksi absensi",
+		})
+	}
+
+	pengajuan, err := h.pengajuanDariParam(c)
+	if err != nil || pengajuan == nil {
+		return err
+	}
+	if ok, err := cekPemutus(c, h.userRepo, pengajuan.DiajukanOleh, pengajuan.KaryawanID, "koreksi absensi"); !ok {
+		return err
+	}
+	if pengajuan.Status != models.KoreksiAbsensiDiajukan {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Koreksi absensi is already " + string(pengajuan.Status),
+		})
+	}
+
+	if err := h.koreksiRepo.Putuskan(pengajuan, models.KoreksiAbsensiDitolak, userIDFromCtx(c), catatan); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reject koreksi absensi",
+		})
+	}
+
+	return c.JSON(pengajuan)
+}
+
+// BatalkanKoreksiAbsensi handles POST /api/absensi/koreksi/:id/batal - withdraws a
+// request that has not been decided yet
+func (h *KoreksiAbsensiHandler) BatalkanKoreksiAbsensi(c *fiber.Ctx) error {
+	catatan, ok := keputusan(c)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	pengajuan, err := h.pengajuanDariParam(c)
+	if err != nil || pengajuan == nil {
+		return err
+	}
+	if pengajuan.Status != models.KoreksiAbsensiDiajukan {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Koreksi absensi is already " + string(pengajuan.Status),
+		})
+	}
+
+	if err := h.koreksiRepo.Putuskan(pengajuan, models.KoreksiAbsensiDibatalkan, userIDFromCtx(c), catatan); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to cancel koreksi absensi",
+		})
+	}
+
+	return c.JSON(pengajuan)
+}
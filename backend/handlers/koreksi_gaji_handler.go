@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"net/http"
+	"pemdes-payroll/backend/models"
+	"pemdes-payroll/backend/repositories"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type KoreksiGajiHandler struct {
+	koreksiRepo repositories.KoreksiGajiRepository
+	gajiRepo    repositories.GajiRepository
+}
+
+// NewKoreksiGajiHandler creates a new KoreksiGaji handler
+func NewKoreksiGajiHandler(koreksiRepo repositories.KoreksiGajiRepository, gajiRepo repositories.GajiRepository) *KoreksiGajiHandler {
+	return &KoreksiGajiHandler{koreksiRepo: koreksiRepo, gajiRepo: gajiRepo}
+}
+
+// CreateKoreksiGaji handles POST /api/gaji/:id/koreksi
+func (h *KoreksiGajiHandler) CreateKoreksiGaji(c *fiber.Ctx) error {
+	if isPeranKaryawan(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Karyawan cannot manage koreksi gaji",
+		})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	var req struct {
+		Nama   string               `json:"nama"`
+		Jenis  models.JenisKomponen `json:"jenis"`
+		Jumlah float64              `json:"jumlah"`
+		Alasan string               `json:"alasan"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Validation
+	if req.Jenis != models.JenisPendapatan && req.Jenis != models.JenisPotongan {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid jenis. Use 'pendapatan' or 'potongan'",
+		})
+	}
+	if req.Jumlah <= 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Jumlah must be greater than 0",
+		})
+	}
+	req.Alasan = strings.TrimSpace(req.Alasan)
+	if req.Alasan == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Alasan is required",
+		})
+	}
+	if req.Nama == "" {
+		req.Nama = "Koreksi"
+	}
+
+	gaji, err := h.gajiRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Gaji not found",
+		})
+	}
+	if gaji.IsEditable() {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Gaji is still editable. Update it directly instead",
+		})
+	}
+
+	koreksi := models.KoreksiGaji{
+		GajiID:       gaji.ID,
+		KaryawanID:   gaji.KaryawanID,
+		PeriodeBulan: gaji.PeriodeBulan,
+		PeriodeTahun: gaji.PeriodeTahun,
+		Nama:         req.Nama,
+		Jenis:        req.Jenis,
+		Jumlah:       req.Jumlah,
+		Alasan:       req.Alasan,
+		Status:       models.KoreksiPending,
+		DibuatOleh:   userIDFromCtx(c),
+	}
+
+	if err := h.koreksiRepo.Create(&koreksi); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create koreksi gaji",
+		})
+	}
+
+	result, _ := h.koreksiRepo.GetByID(koreksi.ID)
+	return c.Status(http.StatusCreated).JSON(result)
+}
+
+// GetKoreksiByGaji handles GET /api/gaji/:id/koreksi
+func (h *KoreksiGajiHandler) GetKoreksiByGaji(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	koreksi, err := h.koreksiRepo.GetByGajiID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch koreksi gaji",
+		})
+	}
+
+	return c.JSON(koreksi)
+}
+
+// GetAllKoreksiGaji handles GET /api/koreksi-gaji?status=
+func (h *KoreksiGajiHandler) GetAllKoreksiGaji(c *fiber.Ctx) error {
+	status := c.Query("status")
+	if status != "" && status != string(models.KoreksiPending) && status != string(models.KoreksiDiterapkan) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid status. Use 'pending' or 'diterapkan'",
+		})
+	}
+
+	koreksi, err := h.koreksiRepo.GetAll(status)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch koreksi gaji",
+		})
+	}
+
+	return c.JSON(koreksi)
+}
+
+// DeleteKoreksiGaji handles DELETE /api/koreksi-gaji/:id - only pending corrections
+func (h *KoreksiGajiHandler) DeleteKoreksiGaji(c *fiber.Ctx) error {
+	if isPeranKaryawan(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Karyawan cannot manage koreksi gaji",
+		})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	koreksi, err := h.koreksiRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Koreksi gaji not found",
+		})
+	}
+	if koreksi.Status != models.KoreksiPending {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Koreksi gaji has already been carried into a slip",
+		})
+	}
+
+	if err := h.koreksiRepo.Delete(uint(id)); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete koreksi gaji",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Koreksi gaji deleted successfully",
+	})
+}
@@ -3,6 +3,8 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"pemdes-payroll/backend/config"
+	"pemdes-payroll/backend/models"
 	"pemdes-payroll/backend/repositories"
 	"pemdes-payroll/backend/services"
 	"strconv"
@@ -16,6 +18,8 @@ type LaporanHandler struct {
 	karyawanRepo repositories.KaryawanRepository
 	gajiRepo     repositories.GajiRepository
 	exportSvc    *services.ExportService
+	analisisSvc  *services.AnalisisGajiService
+	variansCfg   *config.VariansConfig
 }
 
 // NewLaporanHandler creates a new Laporan handler
@@ -29,10 +33,22 @@ func NewLaporanHandler(
 		karyawanRepo: karyawanRepo,
 		gajiRepo:     gajiRepo,
 		exportSvc:    services.NewExportService(),
+		analisisSvc:  services.NewAnalisisGajiService(),
+		variansCfg:   config.GetVariansConfig(),
 	}
 }
 
-// GetLaporanGajiByPeriod handles GET /api/laporan/gaji?bulan=&tahun=
+// jenisRunQuery reads the jenis query parameter selecting the payroll run a report
+// covers, defaulting to the regular run. It returns "" when the value is invalid.
+func jenisRunQuery(c *fiber.Ctx) models.JenisGaji {
+	jenis := models.JenisGaji(c.Query("jenis", string(models.JenisGajiReguler)))
+	if !jenis.IsJenisRun() {
+		return ""
+	}
+	return jenis
+}
+
+// GetLaporanGajiByPeriod handles GET /api/laporan/gaji?bulan=&tahun=&jenis=
 func (h *LaporanHandler) GetLaporanGajiByPeriod(c *fiber.Ctx) error {
 	bulan, _ := strconv.Atoi(c.Query("bulan", "0"))
 	tahun, _ := strconv.Atoi(c.Query("tahun", "0"))
@@ -48,7 +64,14 @@ func (h *LaporanHandler) GetLaporanGajiByPeriod(c *fiber.Ctx) error {
 		})
 	}
 
-	laporan, err := h.laporanRepo.GetLaporanGajiByPeriod(bulan, tahun)
+	jenis := jenisRunQuery(c)
+	if jenis == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid jenis parameter",
+		})
+	}
+
+	laporan, err := h.laporanRepo.GetLaporanGajiByPeriod(bulan, tahun, jenis)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch laporan",
@@ -77,7 +100,7 @@ func (h *LaporanHandler) GetRiwayatGajiKaryawan(c *fiber.Ctx) error {
 	return c.JSON(laporan)
 }
 
-// GetRekapGaji handles GET /api/laporan/rekap?bulan=&tahun=
+// GetRekapGaji handles GET /api/laporan/rekap?bulan=&tahun=&jenis=
 func (h *LaporanHandler) GetRekapGaji(c *fiber.Ctx) error {
 	bulan, _ := strconv.Atoi(c.Query("bulan", "0"))
 	tahun, _ := strconv.Atoi(c.Query("tahun", "0"))
@@ -93,7 +116,14 @@ func (h *LaporanHandler) GetRekapGaji(c *fiber.Ctx) error {
 		})
 	}
 
-	rekap, err := h.laporanRepo.GetRekapGaji(bulan, tahun)
+	jenis := jenisRunQuery(c)
+	if jenis == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid jenis parameter",
+		})
+	}
+
+	rekap, err := h.laporanRepo.GetRekapGaji(bulan, tahun, jenis)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch rekap gaji",
@@ -103,7 +133,173 @@ func (h *LaporanHandler) GetRekapGaji(c *fiber.Ctx) error {
 	return c.JSON(rekap)
 }
 
-// ExportLaporanExcel handles GET /api/laporan/export/excel?bulan=&tahun=
+// GetLaporanBPJS handles GET /api/laporan/bpjs?bulan=&tahun=
+func (h *LaporanHandler) GetLaporanBPJS(c *fiber.Ctx) error {
+	bulan, _ := strconv.Atoi(c.Query("bulan", "0"))
+	tahun, _ := strconv.Atoi(c.Query("tahun", "0"))
+
+	if bulan < 1 || bulan > 12 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid bulan parameter",
+		})
+	}
+	if tahun < 2000 || tahun > 2100 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tahun parameter",
+		})
+	}
+
+	laporan, err := h.laporanRepo.GetLaporanBPJSByPeriod(bulan, tahun)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch laporan BPJS",
+		})
+	}
+
+	// Totals per program, to match against the BPJS invoices
+	total := map[string]float64{}
+	for _, l := range laporan {
+		total["bpjs_kesehatan"] += l.BPJSKesehatanKaryawan + l.BPJSKesehatanPerusahaan
+		total["jht"] += l.JHTKaryawan + l.JHTPerusahaan
+		total["jkk"] += l.JKKPerusahaan
+		total["jkm"] += l.JKMPerusahaan
+		total["jp"] += l.JPKaryawan + l.JPPerusahaan
+	}
+
+	return c.JSON(fiber.Map{
+		"data":  laporan,
+		"total": total,
+	})
+}
+
+// variansQuery holds the parameters of a variance report request
+type variansQuery struct {
+	bulan, tahun                     int
+	pembandingBulan, pembandingTahun int
+	ambangPersen, ambangNominal      float64
+}
+
+// parseVariansQuery reads the variance report parameters and returns an error message,
+// or "" if valid. The comparison period defaults to the month before the period and the
+// thresholds default to the configured ones.
+func (h *LaporanHandler) parseVariansQuery(c *fiber.Ctx) (variansQuery, string) {
+	q := variansQuery{
+		ambangPersen:  h.variansCfg.AmbangPersen,
+		ambangNominal: h.variansCfg.AmbangNominal,
+	}
+	q.bulan, _ = strconv.Atoi(c.Query("bulan", "0"))
+	q.tahun, _ = strconv.Atoi(c.Query("tahun", "0"))
+
+	if q.bulan < 1 || q.bulan > 12 {
+		return q, "Invalid bulan parameter"
+	}
+	if q.tahun < 2000 || q.tahun > 2100 {
+		return q, "Invalid tahun parameter"
+	}
+
+	q.pembandingBulan, q.pembandingTahun = q.bulan-1, q.tahun
+	if q.pembandingBulan == 0 {
+		q.pembandingBulan, q.pembandingTahun = 12, q.tahun-1
+	}
+	if v := c.Query("pembanding_bulan"); v != "" {
+		q.pembandingBulan, _ = strconv.Atoi(v)
+	}
+	if v := c.Query("pembanding_tahun"); v != "" {
+		q.pembandingTahun, _ = strconv.Atoi(v)
+	}
+	if q.pembandingBulan < 1 || q.pembandingBulan > 12 {
+		return q, "Invalid pembanding_bulan parameter"
+	}
+	if q.pembandingTahun < 2000 || q.pembandingTahun > 2100 {
+		return q, "Invalid pembanding_tahun parameter"
+	}
+
+	if v := c.Query("ambang_persen"); v != "" {
+		persen, err := strconv.ParseFloat(v, 64)
+		if err != nil || persen < 0 {
+			return q, "Invalid ambang_persen parameter"
+		}
+		q.ambangPersen = persen
+	}
+	if v := c.Query("ambang_nominal"); v != "" {
+		nominal, err := strconv.ParseFloat(v, 64)
+		if err != nil || nominal < 0 {
+			return q, "Invalid ambang_nominal parameter"
+		}
+		q.ambangNominal = nominal
+	}
+
+	return q, ""
+}
+
+// laporanVarians builds the variance report from the salary reports of both periods
+func (h *LaporanHandler) laporanVarians(q variansQuery) (*models.LaporanVarians, error) {
+	sekarang, err := h.laporanRepo.GetLaporanGajiByPeriod(q.bulan, q.tahun, models.JenisGajiReguler)
+	if err != nil {
+		return nil, err
+	}
+	sebelumnya, err := h.laporanRepo.GetLaporanGajiByPeriod(q.pembandingBulan, q.pembandingTahun, models.JenisGajiReguler)
+	if err != nil {
+		return nil, err
+	}
+
+	laporan := h.analisisSvc.LaporanVarians(sebelumnya, sekarang, q.ambangPersen, q.ambangNominal)
+	laporan.PeriodeBulan = q.bulan
+	laporan.PeriodeTahun = q.tahun
+	laporan.PembandingBulan = q.pembandingBulan
+	laporan.PembandingTahun = q.pembandingTahun
+	return laporan, nil
+}
+
+// GetLaporanVarians handles GET /api/laporan/varians?bulan=&tahun=&pembanding_bulan=&pembanding_tahun=&ambang_persen=&ambang_nominal=
+func (h *LaporanHandler) GetLaporanVarians(c *fiber.Ctx) error {
+	q, msg := h.parseVariansQuery(c)
+	if msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	laporan, err := h.laporanVarians(q)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch laporan varians",
+		})
+	}
+
+	return c.JSON(laporan)
+}
+
+// ExportVariansExcel handles GET /api/laporan/export/varians/excel with the same parameters as GetLaporanVarians
+func (h *LaporanHandler) ExportVariansExcel(c *fiber.Ctx) error {
+	q, msg := h.parseVariansQuery(c)
+	if msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	laporan, err := h.laporanVarians(q)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch laporan varians",
+		})
+	}
+
+	data, err := h.exportSvc.ExportVariansToExcel(laporan)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate Excel",
+		})
+	}
+
+	c.Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=Laporan_Varians_%s_%d.xlsx", getMonthName(q.bulan), q.tahun))
+
+	return c.Send(data)
+}
+
+// ExportLaporanExcel handles GET /api/laporan/export/excel?bulan=&tahun=&jenis=
 func (h *LaporanHandler) ExportLaporanExcel(c *fiber.Ctx) error {
 	bulan, _ := strconv.Atoi(c.Query("bulan", "0"))
 	tahun, _ := strconv.Atoi(c.Query("tahun", "0"))
@@ -119,8 +315,15 @@ func (h *LaporanHandler) ExportLaporanExcel(c *fiber.Ctx) error {
 		})
 	}
 
+	jenis := jenisRunQuery(c)
+	if jenis == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid jenis parameter",
+		})
+	}
+
 	// Get laporan data for the period
-	laporanList, err := h.laporanRepo.GetLaporanGajiByPeriod(bulan, tahun)
+	laporanList, err := h.laporanRepo.GetLaporanGajiByPeriod(bulan, tahun, jenis)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch laporan data",
@@ -128,7 +331,7 @@ func (h *LaporanHandler) ExportLaporanExcel(c *fiber.Ctx) error {
 	}
 
 	// Generate Excel
-	data, err := h.exportSvc.ExportToExcel(laporanList, bulan, tahun)
+	data, err := h.exportSvc.ExportToExcel(laporanList, bulan, tahun, jenis)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate Excel",
@@ -138,7 +341,11 @@ func (h *LaporanHandler) ExportLaporanExcel(c *fiber.Ctx) error {
 	// Set headers for download
 	monthName := getMonthName(bulan)
 	c.Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=Laporan_Gaji_%s_%d.xlsx", monthName, tahun))
+	namaFile := "Laporan_Gaji"
+	if jenis != models.JenisGajiReguler {
+		namaFile += "_" + strings.ToUpper(string(jenis))
+	}
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s_%s_%d.xlsx", namaFile, monthName, tahun))
 
 	return c.Send(data)
 }
@@ -1,34 +1,187 @@
 package handlers
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"pemdes-payroll/backend/config"
 	"pemdes-payroll/backend/models"
 	"pemdes-payroll/backend/repositories"
+	"pemdes-payroll/backend/services"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type LemburHandler struct {
-	lemburRepo    repositories.LemburRepository
-	karyawanRepo repositories.KaryawanRepository
+	lemburRepo     repositories.LemburRepository
+	karyawanRepo   repositories.KaryawanRepository
+	payrollRunRepo repositories.PayrollRunRepository
+	tarifRepo      repositories.TarifJabatanRepository
+	hariLiburRepo  repositories.HariLiburRepository
+	suratRepo      repositories.SuratPerintahLemburRepository
+	userRepo       repositories.UserRepository
+	koreksiRepo    repositories.KoreksiGajiRepository
+	lemburSvc      *services.LemburService
 }
 
 // NewLemburHandler creates a new Lembur handler
-func NewLemburHandler(lemburRepo repositories.LemburRepository, karyawanRepo repositories.KaryawanRepository) *LemburHandler {
-	return &LemburHandler{lemburRepo: lemburRepo, karyawanRepo: karyawanRepo}
+func NewLemburHandler(lemburRepo repositories.LemburRepository, karyawanRepo repositories.KaryawanRepository, payrollRunRepo repositories.PayrollRunRepository, tarifRepo repositories.TarifJabatanRepository, hariLiburRepo repositories.HariLiburRepository, suratRepo repositories.SuratPerintahLemburRepository, userRepo repositories.UserRepository, koreksiRepo repositories.KoreksiGajiRepository) *LemburHandler {
+	return &LemburHandler{
+		lemburRepo:     lemburRepo,
+		karyawanRepo:   karyawanRepo,
+		payrollRunRepo: payrollRunRepo,
+		tarifRepo:      tarifRepo,
+		hariLiburRepo:  hariLiburRepo,
+		suratRepo:      suratRepo,
+		userRepo:       userRepo,
+		koreksiRepo:    koreksiRepo,
+		lemburSvc:      services.NewLemburService(config.GetLemburConfig()),
+	}
+}
+
+// tarifLembur returns the overtime rate of the karyawan's jabatan valid on tanggal,
+// or 0 if no jabatan is set
+func (h *LemburHandler) tarifLembur(karyawan *models.Karyawan, tanggal time.Time) (float64, error) {
+	if karyawan.JabatanID == nil {
+		return 0, nil
+	}
+	tarif, err := h.tarifRepo.GetBerlaku(*karyawan.JabatanID, tanggal)
+	if err != nil {
+		return 0, err
+	}
+	return tarif.TarifLemburPerJam, nil
 }
 
-// CreateLembur handles POST /api/lembur
+// cekRentangLembur derives the hours of an overtime record from its times and checks
+// that it neither overlaps another overtime of the karyawan nor falls inside the regular
+// working hours of its days. It then splits the hours into multiplier tiers at the
+// record's TarifPerJam. When the checks fail, it writes the error response and returns
+// false.
+func (h *LemburHandler) cekRentangLembur(c *fiber.Ctx, lembur *models.Lembur, kecualiID uint) (bool, error) {
+	if msg := lembur.HitungTotalJam(); msg != "" {
+		return false, c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	mulai, selesai, _ := lembur.Rentang()
+	besok := lembur.Tanggal.AddDate(0, 0, 1)
+
+	lainnya, err := h.lemburRepo.GetBentrok(lembur.KaryawanID, lembur.Tanggal.AddDate(0, 0, -1), besok, kecualiID)
+	if err != nil {
+		return false, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch lembur",
+		})
+	}
+	for _, l := range lainnya {
+		lMulai, lSelesai, ok := l.Rentang()
+		if ok && mulai.Before(lSelesai) && lMulai.Before(selesai) {
+			return false, c.Status(http.StatusConflict).JSON(fiber.Map{
+				"error": fmt.Sprintf("Overlaps lembur on %s from %s to %s", l.Tanggal.Format("2006-01-02"), l.JamMulai, l.JamSelesai),
+			})
+		}
+	}
+
+	kalender, err := h.hariLiburRepo.GetKalender(lembur.Tanggal, besok)
+	if err != nil {
+		return false, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch kalender kerja",
+		})
+	}
+	for _, hari := range []time.Time{lembur.Tanggal, besok} {
+		jadwal := kalender.JadwalUntuk(hari)
+		masuk, keluar, ok := models.JamKerjaReguler(hari, jadwal)
+		if ok && mulai.Before(keluar) && masuk.Before(selesai) {
+			return false, c.Status(http.StatusConflict).JSON(fiber.Map{
+				"error": fmt.Sprintf("Falls inside the regular working hours of %s %s (%s-%s)",
+					models.NamaHari[hari.Weekday()], hari.Format("2006-01-02"), jadwal.JamMasuk, jadwal.JamKeluar),
+			})
+		}
+	}
+
+	h.lemburSvc.Hitung(lembur, kalender)
+	return true, nil
+}
+
+// cekBatasLembur checks the overtime record against the weekly and monthly caps of the
+// karyawan and the desa budget of its month, counting the other overtime with one of the
+// given statuses. Exceeded limits are rejected when LEMBUR_BLOKIR_BATAS is set and are
+// otherwise added to the record's peringatan. When rejected, it writes the error response
+// and returns false.
+func (h *LemburHandler) cekBatasLembur(c *fiber.Ctx, lembur *models.Lembur, status []string, kecualiID uint) (bool, error) {
+	gagal := func() (bool, error) {
+		return false, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check lembur limits",
+		})
+	}
+
+	awalMinggu := services.AwalMinggu(lembur.Tanggal)
+	awalBulan := time.Date(lembur.Tanggal.Year(), lembur.Tanggal.Month(), 1, 0, 0, 0, 0, time.UTC)
+	akhirBulan := awalBulan.AddDate(0, 1, -1)
+
+	var p services.PemakaianBatas
+	var err error
+	if p.JamMinggu, _, err = h.lemburRepo.GetPemakaian(lembur.KaryawanID, awalMinggu, awalMinggu.AddDate(0, 0, 6), status, kecualiID); err != nil {
+		return gagal()
+	}
+	if p.JamBulan, _, err = h.lemburRepo.GetPemakaian(lembur.KaryawanID, awalBulan, akhirBulan, status, kecualiID); err != nil {
+		return gagal()
+	}
+	if _, p.NominalDesa, err = h.lemburRepo.GetPemakaian(0, awalBulan, akhirBulan, status, kecualiID); err != nil {
+		return gagal()
+	}
+	anggaran, err := h.lemburRepo.GetAnggaran(int(awalBulan.Month()), awalBulan.Year())
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return gagal()
+	}
+	if anggaran != nil {
+		p.Anggaran = anggaran.Jumlah
+	}
+
+	pelanggaran := h.lemburSvc.CekBatas(lembur, p)
+	if len(pelanggaran) > 0 && h.lemburSvc.BlokirBatas() {
+		return false, c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error":       "Lembur exceeds the overtime limits",
+			"pelanggaran": pelanggaran,
+		})
+	}
+	lembur.Peringatan = pelanggaran
+	return true, nil
+}
+
+// cekRencanaLembur adds a peringatan when the actual hours exceed the planned hours of the
+// surat perintah lembur they are reported against
+func (h *LemburHandler) cekRencanaLembur(lembur *models.Lembur) error {
+	if lembur.SuratPerintahID == nil {
+		return nil
+	}
+	surat, err := h.suratRepo.GetByID(*lembur.SuratPerintahID)
+	if err != nil {
+		return err
+	}
+	if lembur.TotalJam > surat.RencanaJam {
+		lembur.Peringatan = append(lembur.Peringatan, fmt.Sprintf("Actual overtime of %s hours exceeds the %s hours planned in the surat perintah lembur",
+			strconv.FormatFloat(lembur.TotalJam, 'f', -1, 64), strconv.FormatFloat(surat.RencanaJam, 'f', -1, 64)))
+	}
+	return nil
+}
+
+// CreateLembur handles POST /api/lembur - reports the actual hours worked, optionally
+// against an approved surat perintah lembur, required when LEMBUR_WAJIB_SURAT_PERINTAH is on.
+// Total jam is derived from jam mulai and jam selesai less the optional break, and paid
+// at the multipliers of the day. Users with the karyawan role report their own hours.
 func (h *LemburHandler) CreateLembur(c *fiber.Ctx) error {
 	var req struct {
-		KaryawanID uint   `json:"karyawan_id"`
-		Tanggal    string `json:"tanggal"`
-		JamMulai   string `json:"jam_mulai"`
-		JamSelesai string `json:"jam_selesai"`
-		TotalJam   float64 `json:"total_jam"`
-		Keterangan string `json:"keterangan"`
+		SuratPerintahID *uint  `json:"surat_perintah_id"`
+		KaryawanID     uint   `json:"karyawan_id"`
+		Tanggal        string `json:"tanggal"`
+		JamMulai       string `json:"jam_mulai"`
+		JamSelesai     string `json:"jam_selesai"`
+		IstirahatMenit int    `json:"istirahat_menit"`
+		Keterangan     string `json:"keterangan"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -37,12 +190,67 @@ func (h *LemburHandler) CreateLembur(c *fiber.Ctx) error {
 		})
 	}
 
+	if isPeranKaryawan(c) {
+		karyawanID, err := karyawanPengguna(c, h.userRepo)
+		if err != nil || karyawanID == nil {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{
+				"error": "User is not linked to a karyawan",
+			})
+		}
+		req.KaryawanID = *karyawanID
+	}
+
 	// Validation
 	if req.KaryawanID == 0 {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
 			"error": "Karyawan ID is required",
 		})
 	}
+
+	if req.SuratPerintahID != nil && *req.SuratPerintahID > 0 {
+		surat, err := h.suratRepo.GetByID(*req.SuratPerintahID)
+		if err != nil {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Surat perintah lembur not found",
+			})
+		}
+		if surat.Status != models.SuratPerintahLemburDisetujui {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{
+				"error": "Surat perintah lembur is " + string(surat.Status) + ", not disetujui",
+			})
+		}
+		if !surat.Mencakup(req.KaryawanID) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Karyawan is not named in the surat perintah lembur",
+			})
+		}
+		if req.Tanggal == "" {
+			req.Tanggal = surat.Tanggal.Format("2006-01-02")
+		}
+		if req.Tanggal != surat.Tanggal.Format("2006-01-02") {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Tanggal must match the surat perintah lembur",
+			})
+		}
+		realisasi, err := h.lemburRepo.GetRealisasi(surat.ID, req.KaryawanID)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch lembur",
+			})
+		}
+		if len(realisasi) > 0 {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{
+				"error": "The karyawan has already reported lembur against this surat perintah lembur",
+			})
+		}
+	} else if h.lemburSvc.WajibSuratPerintah() {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Surat perintah lembur is required. Report the hours against an approved surat perintah lembur",
+		})
+	} else {
+		req.SuratPerintahID = nil
+	}
+
 	if req.Tanggal == "" {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
 			"error": "Tanggal is required",
@@ -57,10 +265,8 @@ func (h *LemburHandler) CreateLembur(c *fiber.Ctx) error {
 		})
 	}
 
-	if req.TotalJam <= 0 {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-			"error": "Total jam must be greater than 0",
-		})
+	if ok, err := cekPeriodeTerbuka(c, h.payrollRunRepo, req.KaryawanID, tanggal); !ok {
+		return err
 	}
 
 	// Get karyawan data with jabatan to get overtime rate
@@ -71,22 +277,36 @@ func (h *LemburHandler) CreateLembur(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get tarif lembur from jabatan, default to 0 if no jabatan set
-	tarifPerJam := 0.0
-	if karyawan.Jabatan != nil {
-		tarifPerJam = karyawan.Jabatan.TarifLemburPerJam
+	// Get tarif lembur valid on the overtime date, default to 0 if no jabatan set
+	tarifPerJam, err := h.tarifLembur(karyawan, tanggal)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch tarif lembur",
+		})
 	}
 
 	lembur := models.Lembur{
-		KaryawanID:  req.KaryawanID,
-		Tanggal:     tanggal,
-		JamMulai:    req.JamMulai,
-		JamSelesai:  req.JamSelesai,
-		TotalJam:    req.TotalJam,
-		TarifPerJam: tarifPerJam,
-		TotalNominal: tarifPerJam * req.TotalJam,
-		Keterangan:  req.Keterangan,
-		Status:      "pending",
+		KaryawanID:     req.KaryawanID,
+		Tanggal:        tanggal,
+		JamMulai:       req.JamMulai,
+		JamSelesai:     req.JamSelesai,
+		IstirahatMenit: req.IstirahatMenit,
+		TarifPerJam:    tarifPerJam,
+		Keterangan:     req.Keterangan,
+		SuratPerintahID: req.SuratPerintahID,
+		Status:         "pending",
+		DiajukanOleh:   userIDFromCtx(c),
+	}
+	if ok, err := h.cekRentangLembur(c, &lembur, 0); !ok {
+		return err
+	}
+	if ok, err := h.cekBatasLembur(c, &lembur, []string{"pending", "disetujui"}, 0); !ok {
+		return err
+	}
+	if err := h.cekRencanaLembur(&lembur); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch surat perintah lembur",
+		})
 	}
 
 	if err := h.lemburRepo.Create(&lembur); err != nil {
@@ -97,6 +317,9 @@ func (h *LemburHandler) CreateLembur(c *fiber.Ctx) error {
 
 	// Fetch with Karyawan data
 	result, _ := h.lemburRepo.GetByID(lembur.ID)
+	if result != nil {
+		result.Peringatan = lembur.Peringatan
+	}
 	return c.Status(http.StatusCreated).JSON(result)
 }
 
@@ -176,7 +399,9 @@ func (h *LemburHandler) GetLemburByKaryawan(c *fiber.Ctx) error {
 	return c.JSON(lembur)
 }
 
-// UpdateLembur handles PUT /api/lembur/:id
+// UpdateLembur handles PUT /api/lembur/:id - a change to the date or times derives
+// total jam again. Only pending overtime can be changed; it is decided through
+// ApproveLembur.
 func (h *LemburHandler) UpdateLembur(c *fiber.Ctx) error {
 	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
 	if err != nil {
@@ -186,12 +411,11 @@ func (h *LemburHandler) UpdateLembur(c *fiber.Ctx) error {
 	}
 
 	var req struct {
-		Tanggal     string  `json:"tanggal"`
-		JamMulai    string  `json:"jam_mulai"`
-		JamSelesai  string  `json:"jam_selesai"`
-		TotalJam    float64 `json:"total_jam"`
-		Keterangan  string  `json:"keterangan"`
-		Status      string  `json:"status"`
+		Tanggal        string  `json:"tanggal"`
+		JamMulai       string  `json:"jam_mulai"`
+		JamSelesai     string  `json:"jam_selesai"`
+		IstirahatMenit *int    `json:"istirahat_menit"`
+		Keterangan     string  `json:"keterangan"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -211,13 +435,79 @@ func (h *LemburHandler) UpdateLembur(c *fiber.Ctx) error {
 		}
 	}
 
+	existing, err := h.lemburRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Lembur not found",
+		})
+	}
+	if ok, err := cekBelumDigaji(c, existing.GajiID, "Lembur", "POST /api/lembur/:id/koreksi"); !ok {
+		return err
+	}
+	if existing.Status != "pending" {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Lembur is already " + existing.Status,
+		})
+	}
+	if existing.SuratPerintahID != nil && !tanggal.IsZero() && !tanggal.Equal(existing.Tanggal) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tanggal must match the surat perintah lembur",
+		})
+	}
+	if ok, err := cekPeriodeTerbuka(c, h.payrollRunRepo, existing.KaryawanID, existing.Tanggal); !ok {
+		return err
+	}
+	if !tanggal.IsZero() {
+		if ok, err := cekPeriodeTerbuka(c, h.payrollRunRepo, existing.KaryawanID, tanggal); !ok {
+			return err
+		}
+	}
+
+	var peringatan []string
+	if !tanggal.IsZero() || req.JamMulai != "" || req.JamSelesai != "" || req.IstirahatMenit != nil {
+		rentang := models.Lembur{
+			KaryawanID:     existing.KaryawanID,
+			Tanggal:        existing.Tanggal,
+			JamMulai:       existing.JamMulai,
+			JamSelesai:     existing.JamSelesai,
+			IstirahatMenit: existing.IstirahatMenit,
+			TarifPerJam:    existing.TarifPerJam,
+			SuratPerintahID: existing.SuratPerintahID,
+		}
+		if !tanggal.IsZero() {
+			rentang.Tanggal = tanggal
+		}
+		if req.JamMulai != "" {
+			rentang.JamMulai = req.JamMulai
+		}
+		if req.JamSelesai != "" {
+			rentang.JamSelesai = req.JamSelesai
+		}
+		if req.IstirahatMenit != nil {
+			rentang.IstirahatMenit = *req.IstirahatMenit
+		}
+		if ok, err := h.cekRentangLembur(c, &rentang, existing.ID); !ok {
+			return err
+		}
+		if ok, err := h.cekBatasLembur(c, &rentang, []string{"pending", "disetujui"}, existing.ID); !ok {
+			return err
+		}
+		if err := h.cekRencanaLembur(&rentang); err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch surat perintah lembur",
+			})
+		}
+		peringatan = rentang.Peringatan
+
+		if err := h.lemburRepo.UpdatePerhitungan(uint(id), &rentang); err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update lembur",
+			})
+		}
+	}
+
 	lembur := models.Lembur{
-		Tanggal:    tanggal,
-		JamMulai:   req.JamMulai,
-		JamSelesai: req.JamSelesai,
-		TotalJam:   req.TotalJam,
 		Keterangan: req.Keterangan,
-		Status:     req.Status,
 	}
 
 	if err := h.lemburRepo.Update(uint(id), &lembur); err != nil {
@@ -228,6 +518,9 @@ func (h *LemburHandler) UpdateLembur(c *fiber.Ctx) error {
 
 	// Get updated data
 	updated, _ := h.lemburRepo.GetByID(uint(id))
+	if updated != nil {
+		updated.Peringatan = peringatan
+	}
 	return c.JSON(updated)
 }
 
@@ -240,6 +533,19 @@ func (h *LemburHandler) DeleteLembur(c *fiber.Ctx) error {
 		})
 	}
 
+	existing, err := h.lemburRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Lembur not found",
+		})
+	}
+	if ok, err := cekBelumDigaji(c, existing.GajiID, "Lembur", "POST /api/lembur/:id/koreksi"); !ok {
+		return err
+	}
+	if ok, err := cekPeriodeTerbuka(c, h.payrollRunRepo, existing.KaryawanID, existing.Tanggal); !ok {
+		return err
+	}
+
 	if err := h.lemburRepo.Delete(uint(id)); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete lembur",
@@ -251,7 +557,8 @@ func (h *LemburHandler) DeleteLembur(c *fiber.Ctx) error {
 	})
 }
 
-// ApproveLembur handles PATCH /api/lembur/:id/approve
+// ApproveLembur handles PATCH /api/lembur/:id/approve - Sekdes or Kades only, who may not
+// decide overtime they reported or worked themselves
 func (h *LemburHandler) ApproveLembur(c *fiber.Ctx) error {
 	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
 	if err != nil {
@@ -261,8 +568,7 @@ func (h *LemburHandler) ApproveLembur(c *fiber.Ctx) error {
 	}
 
 	var req struct {
-		Status     string `json:"status"`
-		ApproverID *uint  `json:"approver_id"`
+		Status string `json:"status"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -277,25 +583,168 @@ func (h *LemburHandler) ApproveLembur(c *fiber.Ctx) error {
 		})
 	}
 
-	// If approver_id is 0 or not provided, set to nil
-	var approverID *uint
-	if req.ApproverID != nil && *req.ApproverID > 0 {
-		approverID = req.ApproverID
+	existing, err := h.lemburRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Lembur not found",
+		})
+	}
+	if ok, err := cekPemutus(c, h.userRepo, existing.DiajukanOleh, existing.KaryawanID, "lembur"); !ok {
+		return err
+	}
+	if existing.Status != "pending" {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Lembur is already " + existing.Status,
+		})
+	}
+	if ok, err := cekPeriodeTerbuka(c, h.payrollRunRepo, existing.KaryawanID, existing.Tanggal); !ok {
+		return err
 	}
 
-	if err := h.lemburRepo.Approve(uint(id), approverID, req.Status); err != nil {
+	if req.Status == "disetujui" {
+		if ok, err := h.cekBatasLembur(c, existing, []string{"disetujui"}, existing.ID); !ok {
+			return err
+		}
+	}
+
+	if err := h.lemburRepo.Approve(uint(id), userIDFromCtx(c), req.Status); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to approve lembur",
 		})
 	}
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{
-		"message": "Lembur status updated successfully",
+		"message":    "Lembur status updated successfully",
+		"peringatan": existing.Peringatan,
 	})
 }
 
+// KoreksiLembur handles POST /api/lembur/:id/koreksi - corrects overtime already paid in a
+// slip gaji. The record stays as it was paid; the difference between the corrected and
+// the paid nominal becomes a koreksi gaji carried into the karyawan's next slip. With
+// hapus=true the overtime is taken back in full. A record has at most one koreksi; a
+// pending one can be deleted to submit it again.
+func (h *LemburHandler) KoreksiLembur(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	if isPeranKaryawan(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Karyawan cannot correct lembur",
+		})
+	}
+
+	var req struct {
+		JamMulai       string `json:"jam_mulai"`
+		JamSelesai     string `json:"jam_selesai"`
+		IstirahatMenit *int   `json:"istirahat_menit"`
+		Hapus          bool   `json:"hapus"`
+		Alasan         string `json:"alasan"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	req.Alasan = strings.TrimSpace(req.Alasan)
+	if req.Alasan == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Alasan is required",
+		})
+	}
+
+	existing, err := h.lemburRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Lembur not found",
+		})
+	}
+	if existing.GajiID == nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Lembur has not been paid in a slip gaji. Update it directly instead",
+		})
+	}
+	sebelumnya, err := h.koreksiRepo.GetByLemburID(existing.ID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch koreksi gaji",
+		})
+	}
+	if len(sebelumnya) > 0 {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Lembur already has a koreksi gaji. Delete it while pending to submit another",
+		})
+	}
+
+	semula := fmt.Sprintf("%s-%s (%s jam)", existing.JamMulai, existing.JamSelesai,
+		strconv.FormatFloat(existing.TotalJam, 'f', -1, 64))
+	nominal := 0.0
+	perubahan := "Lembur " + semula + " dibatalkan"
+	if !req.Hapus {
+		koreksi := models.Lembur{
+			KaryawanID:     existing.KaryawanID,
+			Tanggal:        existing.Tanggal,
+			JamMulai:       existing.JamMulai,
+			JamSelesai:     existing.JamSelesai,
+			IstirahatMenit: existing.IstirahatMenit,
+			TarifPerJam:    existing.TarifPerJam,
+		}
+		if req.JamMulai != "" {
+			koreksi.JamMulai = req.JamMulai
+		}
+		if req.JamSelesai != "" {
+			koreksi.JamSelesai = req.JamSelesai
+		}
+		if req.IstirahatMenit != nil {
+			koreksi.IstirahatMenit = *req.IstirahatMenit
+		}
+		if ok, err := h.cekRentangLembur(c, &koreksi, existing.ID); !ok {
+			return err
+		}
+		nominal = koreksi.TotalNominal
+		perubahan = fmt.Sprintf("Lembur %s menjadi %s-%s (%s jam)", semula, koreksi.JamMulai, koreksi.JamSelesai,
+			strconv.FormatFloat(koreksi.TotalJam, 'f', -1, 64))
+	}
+
+	selisih := math.Round((nominal-existing.TotalNominal)*100) / 100
+	if selisih == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "The correction does not change the paid nominal",
+		})
+	}
+	jenis := models.JenisPendapatan
+	if selisih < 0 {
+		jenis = models.JenisPotongan
+	}
+
+	koreksiGaji := models.KoreksiGaji{
+		GajiID:       *existing.GajiID,
+		KaryawanID:   existing.KaryawanID,
+		PeriodeBulan: int(existing.Tanggal.Month()),
+		PeriodeTahun: existing.Tanggal.Year(),
+		Nama:         "Koreksi Lembur " + existing.Tanggal.Format("02/01/2006"),
+		Jenis:        jenis,
+		Jumlah:       math.Abs(selisih),
+		Alasan:       perubahan + ": " + req.Alasan,
+		Status:       models.KoreksiPending,
+		DibuatOleh:   userIDFromCtx(c),
+		LemburID:     &existing.ID,
+	}
+	if err := h.koreksiRepo.Create(&koreksiGaji); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create koreksi gaji",
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(koreksiGaji)
+}
+
 // RecalculateTarifLembur handles POST /api/lembur/recalculate-tarif
-// This endpoint updates all lembur records with tarif_per_jam = 0 to use the correct tarif from jabatan
+// This endpoint updates all lembur records with tarif_per_jam = 0 to use the jabatan tarif valid on the lembur date
 func (h *LemburHandler) RecalculateTarifLembur(c *fiber.Ctx) error {
 	// Get all lembur with tarif = 0
 	lemburList, err := h.lemburRepo.GetAll()
@@ -308,23 +757,36 @@ func (h *LemburHandler) RecalculateTarifLembur(c *fiber.Ctx) error {
 	updated := 0
 	for _, lembur := range lemburList {
 		if lembur.TarifPerJam == 0 {
+			// Skip overtime that already fed an approved payroll run or was paid in a slip
+			if lembur.GajiID != nil {
+				continue
+			}
+			if terkunci, err := h.payrollRunRepo.IsPeriodeTerkunci(lembur.KaryawanID, lembur.Tanggal); err != nil || terkunci {
+				continue
+			}
+
 			// Get karyawan with jabatan
 			karyawan, err := h.karyawanRepo.GetByIDWithJabatan(lembur.KaryawanID)
 			if err != nil {
 				continue // Skip if karyawan not found
 			}
 
-			// Get tarif from jabatan
-			tarifPerJam := 0.0
-			if karyawan.Jabatan != nil {
-				tarifPerJam = karyawan.Jabatan.TarifLemburPerJam
+			// Get tarif valid on the lembur date
+			tarifPerJam, err := h.tarifLembur(karyawan, lembur.Tanggal)
+			if err != nil {
+				continue
+			}
+
+			kalender, err := h.hariLiburRepo.GetKalender(lembur.Tanggal, lembur.Tanggal)
+			if err != nil {
+				continue
 			}
 
-			// Update lembur with new tarif and recalculate total
+			// Update lembur with new tarif and recalculate the multiplier tiers
 			lembur.TarifPerJam = tarifPerJam
-			lembur.TotalNominal = tarifPerJam * lembur.TotalJam
+			h.lemburSvc.Hitung(&lembur, kalender)
 
-			if err := h.lemburRepo.Update(lembur.ID, &lembur); err == nil {
+			if err := h.lemburRepo.UpdatePerhitungan(lembur.ID, &lembur); err == nil {
 				updated++
 			}
 		}
@@ -336,3 +798,93 @@ func (h *LemburHandler) RecalculateTarifLembur(c *fiber.Ctx) error {
 		"total":   len(lemburList),
 	})
 }
+
+// GetDashboardLembur handles GET /api/lembur/dashboard?bulan=&tahun= - the period's
+// overtime per karyawan against the weekly and monthly caps, and the desa's against its
+// budget
+func (h *LemburHandler) GetDashboardLembur(c *fiber.Ctx) error {
+	bulan, _ := strconv.Atoi(c.Query("bulan", "0"))
+	tahun, _ := strconv.Atoi(c.Query("tahun", "0"))
+
+	if bulan < 1 || bulan > 12 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid bulan parameter",
+		})
+	}
+	if tahun < 2000 || tahun > 2100 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tahun parameter",
+		})
+	}
+
+	lembur, err := h.lemburRepo.GetByPeriod(bulan, tahun)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch lembur",
+		})
+	}
+	anggaran, err := h.lemburRepo.GetAnggaran(bulan, tahun)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch anggaran lembur",
+		})
+	}
+	jumlah := 0.0
+	if anggaran != nil {
+		jumlah = anggaran.Jumlah
+	}
+
+	return c.JSON(h.lemburSvc.Dashboard(bulan, tahun, lembur, jumlah))
+}
+
+// SetAnggaranLembur handles PUT /api/lembur/anggaran - sets the desa's overtime budget
+// for a period; a jumlah of 0 removes the limit
+func (h *LemburHandler) SetAnggaranLembur(c *fiber.Ctx) error {
+	if isPeranKaryawan(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Karyawan cannot set the anggaran lembur",
+		})
+	}
+
+	var req struct {
+		Bulan      int     `json:"bulan"`
+		Tahun      int     `json:"tahun"`
+		Jumlah     float64 `json:"jumlah"`
+		Keterangan string  `json:"keterangan"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if req.Bulan < 1 || req.Bulan > 12 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid bulan",
+		})
+	}
+	if req.Tahun < 2000 || req.Tahun > 2100 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tahun",
+		})
+	}
+	if req.Jumlah < 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Jumlah must not be negative",
+		})
+	}
+
+	anggaran := models.AnggaranLembur{
+		Bulan:      req.Bulan,
+		Tahun:      req.Tahun,
+		Jumlah:     req.Jumlah,
+		Keterangan: req.Keterangan,
+		DibuatOleh: userIDFromCtx(c),
+	}
+	if err := h.lemburRepo.SimpanAnggaran(&anggaran); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save anggaran lembur",
+		})
+	}
+
+	return c.JSON(anggaran)
+}
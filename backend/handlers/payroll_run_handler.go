@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"net/http"
+	"pemdes-payroll/backend/middleware"
+	"pemdes-payroll/backend/models"
+	"pemdes-payroll/backend/repositories"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PayrollRunHandler struct {
+	payrollRunRepo repositories.PayrollRunRepository
+	gaji           *GajiHandler
+}
+
+// NewPayrollRunHandler creates a new PayrollRun handler
+func NewPayrollRunHandler(payrollRunRepo repositories.PayrollRunRepository, gajiHandler *GajiHandler) *PayrollRunHandler {
+	return &PayrollRunHandler{payrollRunRepo: payrollRunRepo, gaji: gajiHandler}
+}
+
+// userIDFromCtx returns the ID of the authenticated user, or nil when there is none
+func userIDFromCtx(c *fiber.Ctx) *uint {
+	claims, ok := c.Locals("user").(*middleware.Claims)
+	if !ok || claims == nil {
+		return nil
+	}
+	id := claims.UserID
+	return &id
+}
+
+// cekPeriodeTerbuka checks that the karyawan's lembur and absensi on tanggal did not feed
+// an approved payroll run. When they did, it writes the error response and returns false.
+func cekPeriodeTerbuka(c *fiber.Ctx, repo repositories.PayrollRunRepository, karyawanID uint, tanggal time.Time) (bool, error) {
+	terkunci, err := repo.IsPeriodeTerkunci(karyawanID, tanggal)
+	if err != nil {
+		return false, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check payroll run",
+		})
+	}
+	if terkunci {
+		return false, c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Payroll for this period has been approved. Submit a koreksi gaji instead",
+		})
+	}
+	return true, nil
+}
+
+// cekBelumDigaji checks that a lembur or absensi row, described by objek, is not linked to
+// the slip gaji that paid it. Such rows are read-only; the error response written when it
+// is names the koreksi endpoint that carries a change into the next period.
+func cekBelumDigaji(c *fiber.Ctx, gajiID *uint, objek, koreksi string) (bool, error) {
+	if gajiID == nil {
+		return true, nil
+	}
+	return false, c.Status(http.StatusConflict).JSON(fiber.Map{
+		"error":   objek + " has been paid in slip gaji " + strconv.FormatUint(uint64(*gajiID), 10) + " and is read-only. Submit a koreksi through " + koreksi + " instead",
+		"gaji_id": *gajiID,
+	})
+}
+
+// isPengelolaGaji reports whether the authenticated user runs payroll: the admin or the
+// bendahara (finance)
+func isPengelolaGaji(c *fiber.Ctx) bool {
+	claims, ok := c.Locals("user").(*middleware.Claims)
+	return ok && claims != nil && (claims.Role == string(models.UserRoleAdmin) || claims.Role == string(models.UserRoleFinance))
+}
+
+// GetAllPayrollRun handles GET /api/payroll-run
+func (h *PayrollRunHandler) GetAllPayrollRun(c *fiber.Ctx) error {
+	runs, err := h.payrollRunRepo.GetAll()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch payroll run",
+		})
+	}
+
+	return c.JSON(runs)
+}
+
+// GetPayrollRunByID handles GET /api/payroll-run/:id
+func (h *PayrollRunHandler) GetPayrollRunByID(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	run, err := h.payrollRunRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Payroll run not found",
+		})
+	}
+
+	return c.JSON(run)
+}
+
+// ReviewPayrollRun handles POST /api/payroll-run/:id/review - admin and bendahara only
+func (h *PayrollRunHandler) ReviewPayrollRun(c *fiber.Ctx) error {
+	if !isPengelolaGaji(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the admin or bendahara can review a payroll run",
+		})
+	}
+	return h.transition(c, models.PayrollRunReviewed)
+}
+
+// ReopenPayrollRun handles POST /api/payroll-run/:id/reopen - send a reviewed run back to draft, admin and bendahara only
+func (h *PayrollRunHandler) ReopenPayrollRun(c *fiber.Ctx) error {
+	if !isPengelolaGaji(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the admin or bendahara can reopen a payroll run",
+		})
+	}
+	return h.transition(c, models.PayrollRunDraft)
+}
+
+// ApprovePayrollRun handles POST /api/payroll-run/:id/approve - Kepala Desa only
+func (h *PayrollRunHandler) ApprovePayrollRun(c *fiber.Ctx) error {
+	claims, ok := c.Locals("user").(*middleware.Claims)
+	if !ok || claims == nil || claims.Role != string(models.UserRoleKades) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the Kepala Desa can approve a payroll run",
+		})
+	}
+	return h.transition(c, models.PayrollRunApproved)
+}
+
+// PayPayrollRun handles POST /api/payroll-run/:id/pay - admin and bendahara only
+func (h *PayrollRunHandler) PayPayrollRun(c *fiber.Ctx) error {
+	if !isPengelolaGaji(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the admin or bendahara can mark a payroll run as paid",
+		})
+	}
+	return h.transition(c, models.PayrollRunPaid)
+}
+
+// LockPayrollRun handles POST /api/payroll-run/:id/lock - admin and bendahara only
+func (h *PayrollRunHandler) LockPayrollRun(c *fiber.Ctx) error {
+	if !isPengelolaGaji(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the admin or bendahara can lock a payroll run",
+		})
+	}
+	return h.transition(c, models.PayrollRunLocked)
+}
+
+// cekSumberGaji checks that every slip of a regular run still matches the absensi and
+// lembur it was computed from, as approval links exactly those rows to the slip. When
+// one does not, it writes the error response listing the slips and returns false.
+func (h *PayrollRunHandler) cekSumberGaji(c *fiber.Ctx, run *models.PayrollRun) (bool, error) {
+	var berubah []fiber.Map
+	for i := range run.Gaji {
+		g := &run.Gaji[i]
+		alasan, err := h.gaji.sumberBerubah(g)
+		if err != nil {
+			return false, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check absensi and lembur of the slips",
+			})
+		}
+		if alasan != "" {
+			berubah = append(berubah, fiber.Map{
+				"gaji_id":       g.ID,
+				"karyawan_id":   g.KaryawanID,
+				"nama_karyawan": g.Karyawan.Nama,
+				"alasan":        alasan,
+			})
+		}
+	}
+	if len(berubah) > 0 {
+		return false, c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Some slips no longer match their absensi or lembur. Reopen the run and save them again to recompute",
+			"slip":  berubah,
+		})
+	}
+	return true, nil
+}
+
+// transition moves a payroll run to the given status and records who did it
+func (h *PayrollRunHandler) transition(c *fiber.Ctx, to models.PayrollRunStatus) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	var req struct {
+		Catatan string `json:"catatan"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	run, err := h.payrollRunRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Payroll run not found",
+		})
+	}
+
+	if !run.CanTransition(to) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Cannot move payroll run from " + string(run.Status) + " to " + string(to),
+		})
+	}
+	if to == models.PayrollRunReviewed && run.JumlahSlip == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Payroll run has no gaji",
+		})
+	}
+	if to == models.PayrollRunApproved && run.Jenis == models.JenisGajiReguler {
+		if ok, err := h.cekSumberGaji(c, run); !ok {
+			return err
+		}
+	}
+
+	now := time.Now()
+	userID := userIDFromCtx(c)
+
+	switch to {
+	case models.PayrollRunDraft:
+		run.DireviewOleh = nil
+		run.DireviewPada = nil
+	case models.PayrollRunReviewed:
+		run.DireviewOleh = userID
+		run.DireviewPada = &now
+	case models.PayrollRunApproved:
+		run.DisetujuiOleh = userID
+		run.DisetujuiPada = &now
+	case models.PayrollRunPaid:
+		run.DibayarPada = &now
+	case models.PayrollRunLocked:
+		run.DikunciPada = &now
+	}
+	run.Status = to
+	if req.Catatan != "" {
+		run.Catatan = req.Catatan
+	}
+
+	if err := h.payrollRunRepo.UpdateStatus(run); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update payroll run",
+		})
+	}
+
+	updated, _ := h.payrollRunRepo.GetByID(run.ID)
+	return c.JSON(updated)
+}
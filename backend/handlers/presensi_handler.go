@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"net/http"
+	"pemdes-payroll/backend/config"
+	"pemdes-payroll/backend/models"
+	"pemdes-payroll/backend/repositories"
+	"pemdes-payroll/backend/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type PresensiHandler struct {
+	absensiRepo    repositories.AbsensiRepository
+	presensiRepo   repositories.PresensiRepository
+	karyawanRepo   repositories.KaryawanRepository
+	userRepo       repositories.UserRepository
+	payrollRunRepo repositories.PayrollRunRepository
+	presensiSvc    *services.PresensiService
+	cfg            *config.PresensiConfig
+}
+
+// NewPresensiHandler creates a new handler for self-service clock-in and clock-out
+func NewPresensiHandler(absensiRepo repositories.AbsensiRepository, presensiRepo repositories.PresensiRepository, karyawanRepo repositories.KaryawanRepository, userRepo repositories.UserRepository, payrollRunRepo repositories.PayrollRunRepository) *PresensiHandler {
+	cfg := config.GetPresensiConfig()
+	return &PresensiHandler{
+		absensiRepo:    absensiRepo,
+		presensiRepo:   presensiRepo,
+		karyawanRepo:   karyawanRepo,
+		userRepo:       userRepo,
+		payrollRunRepo: payrollRunRepo,
+		presensiSvc:    services.NewPresensiService(cfg),
+		cfg:            cfg,
+	}
+}
+
+// PresensiRequest represents a clock-in or clock-out attempt. The location is optional
+// unless PRESENSI_WAJIB_LOKASI is set. Token is the QR token scanned at the kiosk.
+type PresensiRequest struct {
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	Perangkat string   `json:"perangkat"`
+	Token     string   `json:"token"`
+}
+
+// potong shortens s to at most n bytes
+func potong(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// ClockIn handles POST /api/presensi/masuk - records the jam masuk of the logged-in
+// user's karyawan at the server time
+func (h *PresensiHandler) ClockIn(c *fiber.Ctx) error {
+	return h.rekamRequest(c, models.PresensiMasuk)
+}
+
+// ClockOut handles POST /api/presensi/keluar - records the jam keluar of the logged-in
+// user's karyawan at the server time
+func (h *PresensiHandler) ClockOut(c *fiber.Ctx) error {
+	return h.rekamRequest(c, models.PresensiKeluar)
+}
+
+func (h *PresensiHandler) rekamRequest(c *fiber.Ctx, jenis models.JenisPresensi) error {
+	var req PresensiRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+	return h.rekam(c, jenis, models.SumberAbsensiMandiri, req)
+}
+
+// rekam records a clock-in or clock-out of the logged-in user's karyawan at the server
+// time. Every attempt is logged; a rejected one is answered with the reason.
+func (h *PresensiHandler) rekam(c *fiber.Ctx, jenis models.JenisPresensi, metode string, req PresensiRequest) error {
+	waktu, tanggal := h.presensiSvc.Sekarang()
+	log := models.LogPresensi{
+		UserID:    userIDFromCtx(c),
+		Jenis:     jenis,
+		Metode:    metode,
+		Waktu:     waktu,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		IPAddress: c.IP(),
+		UserAgent: potong(c.Get(fiber.HeaderUserAgent), 255),
+		Perangkat: potong(req.Perangkat, 100),
+	}
+
+	absensi, status, alasan := h.catat(c, &log, req, waktu, tanggal)
+	if status == http.StatusInternalServerError {
+		return c.Status(status).JSON(fiber.Map{
+			"error": alasan,
+		})
+	}
+	if alasan != "" {
+		log.Alasan = alasan
+	} else {
+		log.Diterima = true
+		log.AbsensiID = &absensi.ID
+	}
+	if err := h.presensiRepo.CatatLog(&log); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record presensi log",
+		})
+	}
+	if alasan != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": alasan,
+		})
+	}
+
+	result, _ := h.absensiRepo.GetByID(absensi.ID)
+	return c.JSON(fiber.Map{
+		"message": "Presensi " + string(log.Jenis) + " recorded at " + waktu.Format("15:04"),
+		"absensi": result,
+	})
+}
+
+// catat applies a clock-in or clock-out to the karyawan's absensi of the day. It returns
+// the absensi, or the HTTP status and the reason the attempt is rejected.
+func (h *PresensiHandler) catat(c *fiber.Ctx, log *models.LogPresensi, req PresensiRequest, waktu, tanggal time.Time) (*models.Absensi, int, string) {
+	karyawanID, err := karyawanPengguna(c, h.userRepo)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Failed to fetch user"
+	}
+	if karyawanID == nil {
+		return nil, http.StatusForbidden, "User is not linked to a karyawan"
+	}
+	log.KaryawanID = karyawanID
+
+	if log.Metode == models.SumberAbsensiQR {
+		// A fresh token shown at the kantor desa stands in for the location
+		kiosk, alasan := h.presensiSvc.CekTokenQR(req.Token, waktu)
+		log.Kiosk = kiosk
+		if alasan != "" {
+			return nil, http.StatusForbidden, alasan
+		}
+	} else {
+		jarak, alasan := h.presensiSvc.CekLokasi(log.Latitude, log.Longitude)
+		log.JarakMeter = jarak
+		if alasan != "" {
+			return nil, http.StatusForbidden, alasan
+		}
+	}
+
+	karyawan, err := h.karyawanRepo.GetByID(*karyawanID)
+	if err != nil {
+		return nil, http.StatusNotFound, "Karyawan not found"
+	}
+	if karyawan.TanggalBerhenti != nil && karyawan.TanggalBerhenti.Before(tanggal) {
+		return nil, http.StatusForbidden, "Karyawan is no longer employed"
+	}
+
+	terkunci, err := h.payrollRunRepo.IsPeriodeTerkunci(*karyawanID, tanggal)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Failed to check payroll run"
+	}
+	if terkunci {
+		return nil, http.StatusConflict, "Payroll for this period has been approved"
+	}
+
+	hariIni, err := h.absensiRepo.GetByKaryawanID(*karyawanID, tanggal, tanggal)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Failed to fetch absensi"
+	}
+	jam := waktu.Format("15:04")
+
+	if log.Jenis == "" {
+		log.Jenis = models.PresensiMasuk
+		if len(hariIni) > 0 && hariIni[0].JamMasuk != "" {
+			log.Jenis = models.PresensiKeluar
+		}
+	}
+
+	if log.Jenis == models.PresensiMasuk {
+		if len(hariIni) > 0 {
+			if hariIni[0].JamMasuk != "" {
+				return nil, http.StatusConflict, "Already clocked in today at " + hariIni[0].JamMasuk
+			}
+			return nil, http.StatusConflict, "Absensi is already recorded today as " + string(hariIni[0].Status)
+		}
+
+		absensi := models.Absensi{
+			KaryawanID: *karyawanID,
+			Tanggal:    tanggal,
+			JamMasuk:   jam,
+			Status:     models.AbsensiHadir,
+			Sumber:     log.Metode,
+		}
+		if err := h.absensiRepo.Create(&absensi); err != nil {
+			if err == gorm.ErrDuplicatedKey {
+				return nil, http.StatusConflict, "Absensi is already recorded today"
+			}
+			return nil, http.StatusInternalServerError, "Failed to create absensi"
+		}
+		return &absensi, 0, ""
+	}
+
+	// A shift started yesterday may end after midnight
+	terbuka := hariIni
+	if len(terbuka) == 0 {
+		kemarin := tanggal.AddDate(0, 0, -1)
+		if terbuka, err = h.absensiRepo.GetByKaryawanID(*karyawanID, kemarin, kemarin); err != nil {
+			return nil, http.StatusInternalServerError, "Failed to fetch absensi"
+		}
+		if len(terbuka) > 0 && (terbuka[0].JamKeluar != "" || terbuka[0].Sumber == models.SumberAbsensiManual) {
+			terbuka = nil
+		}
+	}
+	if len(terbuka) == 0 || terbuka[0].Status != models.AbsensiHadir || terbuka[0].JamMasuk == "" {
+		return nil, http.StatusConflict, "Not clocked in today"
+	}
+	absensi := terbuka[0]
+	if absensi.JamKeluar != "" {
+		return nil, http.StatusConflict, "Already clocked out today at " + absensi.JamKeluar
+	}
+
+	if err := h.absensiRepo.Update(absensi.ID, &models.Absensi{JamKeluar: jam}); err != nil {
+		return nil, http.StatusInternalServerError, "Failed to update absensi"
+	}
+	return &absensi, 0, ""
+}
+
+// GetTokenQR handles GET /api/presensi/qr/token?kiosk= - issues a fresh signed token for
+// the QR code on the kiosk screen. The screen fetches a new one before it expires.
+func (h *PresensiHandler) GetTokenQR(c *fiber.Ctx) error {
+	if isPeranKaryawan(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Only staff accounts can run the kiosk",
+		})
+	}
+
+	kiosk := strings.TrimSpace(c.Query("kiosk", "kantor-desa"))
+	if kiosk == "" || len(kiosk) > 50 || strings.Contains(kiosk, "|") {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid kiosk name",
+		})
+	}
+
+	terbit := time.Now()
+	masaBerlaku := h.presensiSvc.MasaBerlakuQR()
+	return c.JSON(fiber.Map{
+		"token":              h.presensiSvc.BuatTokenQR(kiosk, terbit),
+		"kiosk":              kiosk,
+		"terbit":             terbit,
+		"berlaku_sampai":     terbit.Add(masaBerlaku),
+		"masa_berlaku_detik": int(masaBerlaku.Seconds()),
+	})
+}
+
+// ScanQR handles POST /api/presensi/qr - records attendance of the logged-in user's
+// karyawan from the token scanned at the kiosk: jam masuk on the first scan of the day,
+// jam keluar on the next. Every scan is logged with the device it came from.
+func (h *PresensiHandler) ScanQR(c *fiber.Ctx) error {
+	var req PresensiRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	return h.rekam(c, "", models.SumberAbsensiQR, req)
+}
+
+// GetPresensiHariIni handles GET /api/presensi/hari-ini - returns the logged-in user's
+// absensi of today and whether the geofence applies
+func (h *PresensiHandler) GetPresensiHariIni(c *fiber.Ctx) error {
+	karyawanID, err := karyawanPengguna(c, h.userRepo)
+	if err != nil || karyawanID == nil {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "User is not linked to a karyawan",
+		})
+	}
+
+	waktu, tanggal := h.presensiSvc.Sekarang()
+	absensi, err := h.absensiRepo.GetByKaryawanID(*karyawanID, tanggal, tanggal)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch absensi",
+		})
+	}
+
+	response := fiber.Map{
+		"waktu":        waktu,
+		"tanggal":      tanggal.Format("2006-01-02"),
+		"absensi":      nil,
+		"geofence":     h.cfg.AdaGeofence(),
+		"radius_meter": h.cfg.RadiusMeter,
+		"wajib_lokasi": h.cfg.WajibLokasi,
+	}
+	if len(absensi) > 0 {
+		response["absensi"] = absensi[0]
+	}
+	return c.JSON(response)
+}
+
+// GetLogPresensi handles GET /api/presensi/log?mulai=&selesai=&karyawan_id=&diterima= -
+// lists clock-in attempts, by default of the current month. Users with the karyawan role
+// only see their own.
+func (h *PresensiHandler) GetLogPresensi(c *fiber.Ctx) error {
+	mulai, selesai, msg := periodeKalender(c)
+	if msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	karyawanID, _ := strconv.ParseUint(c.Query("karyawan_id", "0"), 10, 32)
+
+	if isPeranKaryawan(c) {
+		id, err := karyawanPengguna(c, h.userRepo)
+		if err != nil || id == nil {
+			return c.JSON([]models.LogPresensi{})
+		}
+		karyawanID = uint64(*id)
+	}
+
+	zona := h.cfg.ZonaWaktu
+	log, err := h.presensiRepo.GetLog(uint(karyawanID),
+		time.Date(mulai.Year(), mulai.Month(), mulai.Day(), 0, 0, 0, 0, zona),
+		time.Date(selesai.Year(), selesai.Month(), selesai.Day(), 0, 0, 0, 0, zona),
+		c.Query("diterima"))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch presensi log",
+		})
+	}
+
+	return c.JSON(log)
+}
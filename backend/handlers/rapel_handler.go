@@ -0,0 +1,613 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"pemdes-payroll/backend/models"
+	"pemdes-payroll/backend/repositories"
+	"pemdes-payroll/backend/services"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type RapelHandler struct {
+	db        *gorm.DB
+	rapelRepo repositories.RapelRepository
+	gaji      *GajiHandler
+	rapelSvc  *services.RapelService
+}
+
+// NewRapelHandler creates a new Rapel handler. Slips are computed by the Gaji handler so
+// that rapel lines and rapel slips follow the same rules as regular slips. Applying or
+// cancelling a rapel writes its slips inside one transaction of db.
+func NewRapelHandler(db *gorm.DB, rapelRepo repositories.RapelRepository, gajiHandler *GajiHandler) *RapelHandler {
+	return &RapelHandler{
+		db:        db,
+		rapelRepo: rapelRepo,
+		gaji:      gajiHandler,
+		rapelSvc:  services.NewRapelService(),
+	}
+}
+
+// denganTx returns a copy of the handler whose repositories work inside tx
+func (h *RapelHandler) denganTx(tx *gorm.DB) *RapelHandler {
+	salinan := *h
+	salinan.rapelRepo = repositories.NewRapelRepository(tx)
+	salinan.gaji = h.gaji.denganTx(tx)
+	return &salinan
+}
+
+// RapelRequest represents the rapel calculation request. Periode and mode are only
+// needed to save a rapel.
+type RapelRequest struct {
+	DariBulan    int              `json:"dari_bulan"`
+	DariTahun    int              `json:"dari_tahun"`
+	SampaiBulan  int              `json:"sampai_bulan"`
+	SampaiTahun  int              `json:"sampai_tahun"`
+	JabatanID    *uint            `json:"jabatan_id"`
+	KaryawanID   *uint            `json:"karyawan_id"`
+	PeriodeBulan int              `json:"periode_bulan"`
+	PeriodeTahun int              `json:"periode_tahun"`
+	Mode         models.RapelMode `json:"mode"`
+	Keterangan   string           `json:"keterangan"`
+}
+
+// validate checks the recomputed range and returns an error message, or "" if valid
+func (req *RapelRequest) validate() string {
+	if req.DariBulan < 1 || req.DariBulan > 12 || req.SampaiBulan < 1 || req.SampaiBulan > 12 {
+		return "Invalid bulan (must be 1-12)"
+	}
+	if req.DariTahun < 2000 || req.DariTahun > 2100 || req.SampaiTahun < 2000 || req.SampaiTahun > 2100 {
+		return "Invalid tahun"
+	}
+	if req.DariTahun*12+req.DariBulan > req.SampaiTahun*12+req.SampaiBulan {
+		return "Dari must not be after sampai"
+	}
+	return ""
+}
+
+// validateTujuan checks the payroll period and mode the rapel is paid in
+func (req *RapelRequest) validateTujuan() string {
+	if req.PeriodeBulan < 1 || req.PeriodeBulan > 12 {
+		return "Invalid periode_bulan (must be 1-12)"
+	}
+	if req.PeriodeTahun < 2000 || req.PeriodeTahun > 2100 {
+		return "Invalid periode_tahun"
+	}
+	if req.PeriodeTahun*12+req.PeriodeBulan <= req.SampaiTahun*12+req.SampaiBulan {
+		return "Rapel must be paid in a period after the recomputed range"
+	}
+	if req.Mode != models.RapelModeItem && req.Mode != models.RapelModeSlip {
+		return "Invalid mode. Use 'item' or 'slip'"
+	}
+	return ""
+}
+
+func rapelRequestDari(r *models.Rapel) *RapelRequest {
+	return &RapelRequest{
+		DariBulan:   r.DariBulan,
+		DariTahun:   r.DariTahun,
+		SampaiBulan: r.SampaiBulan,
+		SampaiTahun: r.SampaiTahun,
+		JabatanID:   r.JabatanID,
+		KaryawanID:  r.KaryawanID,
+	}
+}
+
+// hitungDetail recomputes the slips of approved payroll runs in the requested range
+// under the rates now valid for their periods of the jabatan each slip was generated
+// under, and returns the non-zero differences. Slips still in a draft run are skipped;
+// they are regenerated instead.
+func (h *RapelHandler) hitungDetail(req *RapelRequest) ([]models.RapelDetail, error) {
+	komponenList, err := h.gaji.komponenRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	komponenPersen := make(map[uint]bool)
+	for _, k := range komponenList {
+		if k.TipeFormula == models.FormulaPersenPokok {
+			komponenPersen[k.ID] = true
+		}
+	}
+
+	detail := []models.RapelDetail{}
+	bulan, tahun := req.DariBulan, req.DariTahun
+	for tahun*12+bulan <= req.SampaiTahun*12+req.SampaiBulan {
+		gajiList, err := h.gaji.gajiRepo.GetByPeriod(bulan, tahun)
+		if err != nil {
+			return nil, err
+		}
+
+		awalPeriode := time.Date(tahun, time.Month(bulan), 1, 0, 0, 0, 0, time.UTC)
+		for i := range gajiList {
+			g := &gajiList[i]
+			// Slips saved before they recorded their jabatan fall back to the current one
+			jabatanID := g.JabatanID
+			if jabatanID == nil {
+				jabatanID = g.Karyawan.JabatanID
+			}
+			if g.Jenis != models.JenisGajiReguler || g.IsEditable() || jabatanID == nil {
+				continue
+			}
+			if req.KaryawanID != nil && g.KaryawanID != *req.KaryawanID {
+				continue
+			}
+			if req.JabatanID != nil && *jabatanID != *req.JabatanID {
+				continue
+			}
+
+			tarif, err := h.gaji.tarifRepo.GetBerlaku(*jabatanID, awalPeriode)
+			if err != nil {
+				return nil, err
+			}
+
+			lemburList, err := h.gaji.lemburRepo.GetByKaryawanAndPeriod(int(g.KaryawanID), bulan, tahun)
+			if err != nil {
+				return nil, err
+			}
+			// Only the overtime the slip paid is repriced; overtime approved after it was
+			// computed was never paid at the old rate
+			selisihLembur := 0.0
+			for _, l := range lemburList {
+				if l.GajiID == nil || *l.GajiID != g.ID {
+					continue
+				}
+				tarifLembur, err := h.gaji.tarifRepo.GetBerlaku(*jabatanID, l.Tanggal)
+				if err != nil {
+					return nil, err
+				}
+				selisihLembur += l.NominalDenganTarif(tarifLembur.TarifLemburPerJam) - l.TotalNominal
+			}
+
+			sebelumnya, err := h.rapelRepo.GetDetailTerakhir(g.ID)
+			if err != nil && err != gorm.ErrRecordNotFound {
+				return nil, err
+			}
+
+			d := h.rapelSvc.HitungSelisih(services.RapelInput{
+				Gaji:             g,
+				GajiPokok:        tarif.GajiPokok,
+				TunjanganJabatan: tarif.TunjanganJabatan,
+				SelisihLembur:    selisihLembur,
+				KomponenPersen:   komponenPersen,
+				Sebelumnya:       sebelumnya,
+			})
+			if d.Selisih == 0 {
+				continue
+			}
+			d.Karyawan = g.Karyawan
+			detail = append(detail, d)
+		}
+
+		bulan++
+		if bulan > 12 {
+			bulan, tahun = 1, tahun+1
+		}
+	}
+
+	return detail, nil
+}
+
+func totalRapel(detail []models.RapelDetail) float64 {
+	total := 0.0
+	for _, d := range detail {
+		total += d.Selisih
+	}
+	return total
+}
+
+// HitungRapel handles POST /api/rapel/hitung - shows the difference per karyawan and
+// month without saving anything
+func (h *RapelHandler) HitungRapel(c *fiber.Ctx) error {
+	var req RapelRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	detail, err := h.hitungDetail(&req)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to calculate rapel",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"detail":             detail,
+		"total_per_karyawan": h.rapelSvc.TotalPerKaryawan(detail),
+		"total_rapel":        totalRapel(detail),
+	})
+}
+
+// CreateRapel handles POST /api/rapel - saves a draft rapel to be paid in a chosen period
+func (h *RapelHandler) CreateRapel(c *fiber.Ctx) error {
+	var req RapelRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	msg := req.validate()
+	if msg == "" {
+		msg = req.validateTujuan()
+	}
+	if msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	if run, err := h.gaji.payrollRunRepo.GetByPeriod(req.PeriodeBulan, req.PeriodeTahun, models.JenisGajiReguler); err == nil && !run.IsEditable() {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Payroll run for this period is already " + string(run.Status),
+		})
+	}
+
+	detail, err := h.hitungDetail(&req)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to calculate rapel",
+		})
+	}
+	if len(detail) == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "No rapel difference found in this range",
+		})
+	}
+
+	rapel := models.Rapel{
+		Keterangan:   req.Keterangan,
+		DariBulan:    req.DariBulan,
+		DariTahun:    req.DariTahun,
+		SampaiBulan:  req.SampaiBulan,
+		SampaiTahun:  req.SampaiTahun,
+		JabatanID:    req.JabatanID,
+		KaryawanID:   req.KaryawanID,
+		PeriodeBulan: req.PeriodeBulan,
+		PeriodeTahun: req.PeriodeTahun,
+		Mode:         req.Mode,
+		Status:       models.RapelDraft,
+		TotalRapel:   totalRapel(detail),
+		DibuatOleh:   userIDFromCtx(c),
+	}
+	for _, d := range detail {
+		d.Karyawan = models.Karyawan{}
+		rapel.Detail = append(rapel.Detail, d)
+	}
+
+	if err := h.rapelRepo.Create(&rapel); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create rapel",
+		})
+	}
+
+	result, _ := h.rapelRepo.GetByID(rapel.ID)
+	return c.Status(http.StatusCreated).JSON(result)
+}
+
+// GetAllRapel handles GET /api/rapel
+func (h *RapelHandler) GetAllRapel(c *fiber.Ctx) error {
+	rapel, err := h.rapelRepo.GetAll()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch rapel",
+		})
+	}
+
+	return c.JSON(rapel)
+}
+
+// GetRapelByID handles GET /api/rapel/:id
+func (h *RapelHandler) GetRapelByID(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	rapel, err := h.rapelRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Rapel not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"rapel":              rapel,
+		"total_per_karyawan": h.rapelSvc.TotalPerKaryawan(rapel.Detail),
+	})
+}
+
+// TerapkanRapel handles POST /api/rapel/:id/terapkan - adds the rapel to its payroll
+// period, as RAPEL lines on the regular slips or as separate rapel slips. In item mode a
+// karyawan without a regular slip of the period yet is paid on a rapel slip instead.
+func (h *RapelHandler) TerapkanRapel(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	rapel, err := h.rapelRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Rapel not found",
+		})
+	}
+	if rapel.Status != models.RapelDraft {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Rapel has already been applied",
+		})
+	}
+
+	// Rates or other rapel may have changed since the draft was calculated
+	detail, err := h.hitungDetail(rapelRequestDari(rapel))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to calculate rapel",
+		})
+	}
+	if !sameRapelDetail(rapel.Detail, detail) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Rapel is out of date. Delete it and calculate it again",
+		})
+	}
+
+	run, err := h.gaji.payrollRunRepo.FindOrCreate(rapel.PeriodeBulan, rapel.PeriodeTahun, models.JenisGajiReguler, userIDFromCtx(c))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch payroll run",
+		})
+	}
+	if !run.IsEditable() {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Payroll run for this period is already " + string(run.Status),
+		})
+	}
+
+	perKaryawan := make(map[uint][]models.RapelDetail)
+	var urutan []uint
+	for _, d := range rapel.Detail {
+		if _, ok := perKaryawan[d.KaryawanID]; !ok {
+			urutan = append(urutan, d.KaryawanID)
+		}
+		perKaryawan[d.KaryawanID] = append(perKaryawan[d.KaryawanID], d)
+	}
+
+	// A karyawan has one rapel slip per period, so a second one is refused before
+	// anything is written
+	reguler := make(map[uint]*models.Gaji)
+	var sudahAdaSlipRapel []string
+	for _, karyawanID := range urutan {
+		slipList, err := h.gaji.gajiRepo.GetSlipPeriode(karyawanID, rapel.PeriodeBulan, rapel.PeriodeTahun)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch gaji",
+			})
+		}
+		adaSlipRapel := false
+		for i := range slipList {
+			switch slipList[i].Jenis {
+			case models.JenisGajiReguler:
+				reguler[karyawanID] = &slipList[i]
+			case models.JenisGajiRapel:
+				adaSlipRapel = true
+			}
+		}
+		if adaSlipRapel && (rapel.Mode == models.RapelModeSlip || reguler[karyawanID] == nil) {
+			sudahAdaSlipRapel = append(sudahAdaSlipRapel, perKaryawan[karyawanID][0].Karyawan.Nama)
+		}
+	}
+	if len(sudahAdaSlipRapel) > 0 {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error":    "Karyawan already have a rapel slip in this period. Cancel that rapel first or apply this one as line items",
+			"karyawan": sudahAdaSlipRapel,
+		})
+	}
+
+	var slipRapel []string
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		t := h.denganTx(tx)
+		now := time.Now()
+		rapel.Status = models.RapelDiterapkan
+		rapel.DiterapkanPada = &now
+		if err := t.rapelRepo.UpdateStatus(rapel); err != nil {
+			return err
+		}
+
+		for _, karyawanID := range urutan {
+			detail := perKaryawan[karyawanID]
+			slip := reguler[karyawanID]
+			if rapel.Mode == models.RapelModeSlip || slip == nil {
+				if err := t.buatSlipRapel(rapel, run, detail); err != nil {
+					return err
+				}
+				if rapel.Mode == models.RapelModeItem {
+					slipRapel = append(slipRapel, detail[0].Karyawan.Nama)
+				}
+				continue
+			}
+			if err := t.gaji.hitungUlangGaji(slip); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to apply rapel",
+		})
+	}
+
+	result, _ := h.rapelRepo.GetByID(rapel.ID)
+	return c.JSON(fiber.Map{
+		"message":    "Rapel applied successfully",
+		"rapel":      result,
+		"slip_rapel": slipRapel,
+	})
+}
+
+// buatSlipRapel creates the separate rapel slip of one karyawan
+func (h *RapelHandler) buatSlipRapel(rapel *models.Rapel, run *models.PayrollRun, detail []models.RapelDetail) error {
+	karyawan, err := h.gaji.karyawanRepo.GetByID(detail[0].KaryawanID)
+	if err != nil {
+		return err
+	}
+
+	gaji := models.Gaji{
+		KaryawanID:   karyawan.ID,
+		PeriodeBulan: rapel.PeriodeBulan,
+		PeriodeTahun: rapel.PeriodeTahun,
+		Jenis:        models.JenisGajiRapel,
+		Status:       models.GajiStatusPending,
+		PayrollRunID: &run.ID,
+	}
+	if err := h.gaji.hitungGajiRapel(&gaji, karyawan, detail); err != nil {
+		return err
+	}
+	if err := h.gaji.gajiRepo.Create(&gaji); err != nil {
+		return err
+	}
+
+	ids := make([]uint, len(detail))
+	for i, d := range detail {
+		ids[i] = d.ID
+	}
+	return h.rapelRepo.SetDiterapkanGaji(ids, &gaji.ID)
+}
+
+// BatalkanRapel handles POST /api/rapel/:id/batal - takes an applied rapel back out of
+// its payroll period while that run is still a draft
+func (h *RapelHandler) BatalkanRapel(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	rapel, err := h.rapelRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Rapel not found",
+		})
+	}
+	if rapel.Status != models.RapelDiterapkan {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Rapel has not been applied",
+		})
+	}
+	if run, err := h.gaji.payrollRunRepo.GetByPeriod(rapel.PeriodeBulan, rapel.PeriodeTahun, models.JenisGajiReguler); err == nil && !run.IsEditable() {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Payroll run for this period is already " + string(run.Status) + ". Submit a koreksi gaji instead",
+		})
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		t := h.denganTx(tx)
+		rapel.Status = models.RapelDraft
+		rapel.DiterapkanPada = nil
+		if err := t.rapelRepo.UpdateStatus(rapel); err != nil {
+			return err
+		}
+
+		dihitung := make(map[uint]bool)
+		var ids []uint
+		for _, d := range rapel.Detail {
+			ids = append(ids, d.ID)
+			// Rapel slips, also those of item mode, are deleted; regular slips are
+			// recalculated without the rapel lines
+			if d.DiterapkanGajiID != nil {
+				if !dihitung[*d.DiterapkanGajiID] {
+					dihitung[*d.DiterapkanGajiID] = true
+					if err := t.gaji.gajiRepo.Delete(*d.DiterapkanGajiID); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if rapel.Mode == models.RapelModeSlip || dihitung[d.KaryawanID] {
+				continue
+			}
+			dihitung[d.KaryawanID] = true
+			slip, err := t.gaji.gajiRepo.GetByKaryawanAndPeriod(int(d.KaryawanID), rapel.PeriodeBulan, rapel.PeriodeTahun)
+			if err != nil {
+				continue
+			}
+			if err := t.gaji.hitungUlangGaji(slip); err != nil {
+				return err
+			}
+		}
+		return t.rapelRepo.SetDiterapkanGaji(ids, nil)
+	})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to cancel rapel",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Rapel cancelled successfully",
+	})
+}
+
+// DeleteRapel handles DELETE /api/rapel/:id - only draft rapel
+func (h *RapelHandler) DeleteRapel(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	rapel, err := h.rapelRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Rapel not found",
+		})
+	}
+	if rapel.Status != models.RapelDraft {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Rapel has been applied. Cancel it first",
+		})
+	}
+
+	if err := h.rapelRepo.Delete(uint(id)); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete rapel",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Rapel deleted successfully",
+	})
+}
+
+// sameRapelDetail checks whether a recalculation gives the same differences per slip
+func sameRapelDetail(saved, fresh []models.RapelDetail) bool {
+	if len(saved) != len(fresh) {
+		return false
+	}
+	selisih := make(map[uint]float64, len(saved))
+	for _, d := range saved {
+		selisih[d.GajiID] = d.Selisih
+	}
+	for _, d := range fresh {
+		s, ok := selisih[d.GajiID]
+		if !ok || math.Abs(s-d.Selisih) >= 0.01 {
+			return false
+		}
+	}
+	return true
+}
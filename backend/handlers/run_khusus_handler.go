@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"net/http"
+	"pemdes-payroll/backend/config"
+	"pemdes-payroll/backend/models"
+	"pemdes-payroll/backend/services"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type RunKhususHandler struct {
+	gaji         *GajiHandler
+	masaKerjaSvc *services.MasaKerjaService
+}
+
+// NewRunKhususHandler creates a new handler for the THR, gaji ke-13 and bonus runs. Slips
+// are saved through the Gaji handler so that they are taxed together with the other
+// slips of their period.
+func NewRunKhususHandler(gajiHandler *GajiHandler) *RunKhususHandler {
+	return &RunKhususHandler{
+		gaji:         gajiHandler,
+		masaKerjaSvc: services.NewMasaKerjaService(config.GetMasaKerjaConfig()),
+	}
+}
+
+// RunKhususRequest represents a THR, gaji ke-13 or bonus run or its preview. The full
+// amount is Jumlah when set, otherwise Pengali times the upah (gaji pokok plus tunjangan
+// jabatan) valid on the reference date; it is then reduced by masa kerja.
+type RunKhususRequest struct {
+	Jenis        models.JenisGaji `json:"jenis"`
+	PeriodeBulan int              `json:"periode_bulan"`
+	PeriodeTahun int              `json:"periode_tahun"`
+	TanggalAcuan string           `json:"tanggal_acuan"`
+	Pengali      float64          `json:"pengali"`
+	Jumlah       float64          `json:"jumlah"`
+	MinimalBulan *int             `json:"minimal_bulan"`
+
+	acuan time.Time
+}
+
+// validate checks the request and returns an error message, or "" if valid
+func (req *RunKhususRequest) validate() string {
+	if !req.Jenis.IsJenisRun() || req.Jenis == models.JenisGajiReguler {
+		return "Invalid jenis. Use 'thr', 'ke13' or 'bonus'"
+	}
+	if req.PeriodeBulan < 1 || req.PeriodeBulan > 12 {
+		return "Periode bulan must be between 1 and 12"
+	}
+	if req.PeriodeTahun < 2000 || req.PeriodeTahun > 2100 {
+		return "Invalid periode tahun"
+	}
+	if req.Pengali < 0 || req.Jumlah < 0 {
+		return "Pengali and jumlah must not be negative"
+	}
+	if req.MinimalBulan != nil && *req.MinimalBulan < 0 {
+		return "Minimal bulan must not be negative"
+	}
+
+	// Masa kerja is counted up to the last day of the period unless given
+	req.acuan = time.Date(req.PeriodeTahun, time.Month(req.PeriodeBulan)+1, 0, 0, 0, 0, 0, time.UTC)
+	if req.TanggalAcuan != "" {
+		acuan, err := time.Parse("2006-01-02", req.TanggalAcuan)
+		if err != nil {
+			return "Invalid tanggal_acuan format. Use YYYY-MM-DD"
+		}
+		req.acuan = acuan
+	}
+	if req.Pengali == 0 {
+		req.Pengali = 1
+	}
+	return ""
+}
+
+// kodeRunKhusus returns the kode and name of the line item paid by a run of jenis
+func kodeRunKhusus(jenis models.JenisGaji) (string, string) {
+	switch jenis {
+	case models.JenisGajiTHR:
+		return models.KodeTHR, "Tunjangan Hari Raya"
+	case models.JenisGajiKe13:
+		return models.KodeGajiKe13, "Gaji ke-13"
+	}
+	return models.KodeBonus, "Bonus"
+}
+
+// buildSlip computes a karyawan's slip for the run. It returns a nil slip when the
+// karyawan is not entitled to anything.
+func (h *RunKhususHandler) buildSlip(k *models.Karyawan, req *RunKhususRequest) (*models.Gaji, services.HakMasaKerja, error) {
+	hak := h.masaKerjaSvc.Hitung(k.TanggalBergabung, req.acuan, req.MinimalBulan)
+
+	upah := req.Jumlah
+	if upah == 0 && k.JabatanID != nil {
+		tarif, err := h.gaji.tarifRepo.GetBerlaku(*k.JabatanID, req.acuan)
+		if err != nil {
+			return nil, hak, err
+		}
+		upah = (tarif.GajiPokok + tarif.TunjanganJabatan) * req.Pengali
+	}
+
+	jumlah := hak.Terapkan(upah)
+	if jumlah <= 0 {
+		return nil, hak, nil
+	}
+
+	gaji := &models.Gaji{
+		KaryawanID:   k.ID,
+		PeriodeBulan: req.PeriodeBulan,
+		PeriodeTahun: req.PeriodeTahun,
+		Jenis:        req.Jenis,
+		Status:       models.GajiStatusPending,
+	}
+	kode, nama := kodeRunKhusus(req.Jenis)
+	gaji.AddItem(kode, nama, models.JenisPendapatan, jumlah)
+	gaji.Items[0].Keterangan = hak.Keterangan()
+	gaji.CalculateBruto()
+
+	var err error
+	if gaji.PPh21, err = h.gaji.hitungPPh21(gaji, k); err != nil {
+		return nil, hak, err
+	}
+	gaji.AddItem(models.KodePPh21, "PPh 21", models.JenisPotongan, gaji.PPh21)
+
+	gaji.CalculateTotal()
+	return gaji, hak, nil
+}
+
+// sudahAda returns the karyawan who already have a slip of the run's jenis in the period
+func (h *RunKhususHandler) sudahAda(req *RunKhususRequest) (map[uint]bool, error) {
+	existingList, err := h.gaji.gajiRepo.GetByPeriod(req.PeriodeBulan, req.PeriodeTahun)
+	if err != nil {
+		return nil, err
+	}
+	ada := make(map[uint]bool, len(existingList))
+	for _, g := range existingList {
+		if g.Jenis == req.Jenis {
+			ada[g.KaryawanID] = true
+		}
+	}
+	return ada, nil
+}
+
+// GenerateRunKhusus handles POST /api/gaji/run-khusus - creates the THR, gaji ke-13 or
+// bonus slips of a period in a payroll run of their own
+func (h *RunKhususHandler) GenerateRunKhusus(c *fiber.Ctx) error {
+	var req RunKhususRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if msg := req.validate(); msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	run, err := h.gaji.payrollRunRepo.FindOrCreate(req.PeriodeBulan, req.PeriodeTahun, req.Jenis, userIDFromCtx(c))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch payroll run",
+		})
+	}
+	if !run.IsEditable() {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": req.Jenis.Label() + " payroll run for this period is already " + string(run.Status),
+		})
+	}
+
+	karyawanList, err := h.gaji.karyawanRepo.GetUntukPeriode(req.acuan, req.acuan)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch karyawan",
+		})
+	}
+
+	sudahAda, err := h.sudahAda(&req)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch gaji",
+		})
+	}
+
+	var gajiList []models.Gaji
+	var skipped, tidakBerhak []string
+
+	for _, k := range karyawanList {
+		if sudahAda[k.ID] {
+			skipped = append(skipped, k.Nama)
+			continue
+		}
+
+		gaji, _, err := h.buildSlip(&k, &req)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to calculate gaji for " + k.Nama,
+			})
+		}
+		if gaji == nil {
+			tidakBerhak = append(tidakBerhak, k.Nama)
+			continue
+		}
+		gaji.PayrollRunID = &run.ID
+		gajiList = append(gajiList, *gaji)
+	}
+
+	if len(gajiList) > 0 {
+		if err := h.gaji.gajiRepo.CreateBatch(gajiList); err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to create gaji batch",
+			})
+		}
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message":        req.Jenis.Label() + " generation completed",
+		"payroll_run_id": run.ID,
+		"jenis":          req.Jenis,
+		"created":        len(gajiList),
+		"skipped":        skipped,
+		"tidak_berhak":   tidakBerhak,
+		"periode":        map[string]int{"bulan": req.PeriodeBulan, "tahun": req.PeriodeTahun},
+	})
+}
+
+// PreviewRunKhusus handles POST /api/gaji/run-khusus/preview - computes the slips
+// GenerateRunKhusus would create, with each karyawan's masa kerja, without writing anything
+func (h *RunKhususHandler) PreviewRunKhusus(c *fiber.Ctx) error {
+	var req RunKhususRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if msg := req.validate(); msg != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	peringatan := []models.PeringatanGaji{}
+
+	if run, err := h.gaji.payrollRunRepo.GetByPeriod(req.PeriodeBulan, req.PeriodeTahun, req.Jenis); err == nil && !run.IsEditable() {
+		peringatan = append(peringatan, models.PeringatanGaji{
+			Pesan: req.Jenis.Label() + " payroll run for this period is already " + string(run.Status) + " so generating will be rejected",
+		})
+	}
+
+	karyawanList, err := h.gaji.karyawanRepo.GetUntukPeriode(req.acuan, req.acuan)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch karyawan",
+		})
+	}
+
+	sudahAda, err := h.sudahAda(&req)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch gaji",
+		})
+	}
+
+	gajiList := []models.Gaji{}
+	var skipped, tidakBerhak []string
+	totalGaji := 0.0
+
+	for _, k := range karyawanList {
+		if sudahAda[k.ID] {
+			skipped = append(skipped, k.Nama)
+			continue
+		}
+
+		gaji, hak, err := h.buildSlip(&k, &req)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to calculate gaji for " + k.Nama,
+			})
+		}
+
+		if hak.TanpaTanggal {
+			peringatan = append(peringatan, models.PeringatanGaji{KaryawanID: k.ID, NamaKaryawan: k.Nama,
+				Pesan: "Karyawan has no tanggal bergabung so the full amount is given"})
+		}
+		if gaji == nil {
+			tidakBerhak = append(tidakBerhak, k.Nama)
+			if req.Jumlah == 0 && k.JabatanID == nil {
+				peringatan = append(peringatan, models.PeringatanGaji{KaryawanID: k.ID, NamaKaryawan: k.Nama,
+					Pesan: "Karyawan has no jabatan so upah = 0"})
+			}
+			continue
+		}
+
+		gaji.Karyawan = k
+		gajiList = append(gajiList, *gaji)
+		totalGaji += gaji.TotalGaji
+	}
+
+	return c.JSON(fiber.Map{
+		"message":           req.Jenis.Label() + " preview completed",
+		"jenis":             req.Jenis,
+		"tanggal_acuan":     req.acuan.Format("2006-01-02"),
+		"created":           len(gajiList),
+		"skipped":           skipped,
+		"tidak_berhak":      tidakBerhak,
+		"periode":           map[string]int{"bulan": req.PeriodeBulan, "tahun": req.PeriodeTahun},
+		"gaji":              gajiList,
+		"total_gaji":        totalGaji,
+		"total_per_jabatan": h.gaji.analisisSvc.TotalPerJabatan(gajiList),
+		"peringatan":        peringatan,
+	})
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"pemdes-payroll/backend/models"
+	"pemdes-payroll/backend/repositories"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SuratPerintahLemburHandler struct {
+	suratRepo      repositories.SuratPerintahLemburRepository
+	karyawanRepo   repositories.KaryawanRepository
+	userRepo       repositories.UserRepository
+	payrollRunRepo repositories.PayrollRunRepository
+}
+
+// NewSuratPerintahLemburHandler creates a new handler for overtime orders
+func NewSuratPerintahLemburHandler(suratRepo repositories.SuratPerintahLemburRepository, karyawanRepo repositories.KaryawanRepository, userRepo repositories.UserRepository, payrollRunRepo repositories.PayrollRunRepository) *SuratPerintahLemburHandler {
+	return &SuratPerintahLemburHandler{
+		suratRepo:      suratRepo,
+		karyawanRepo:   karyawanRepo,
+		userRepo:       userRepo,
+		payrollRunRepo: payrollRunRepo,
+	}
+}
+
+// CreateSuratPerintahLembur handles POST /api/lembur/surat-perintah - drafts an overtime
+// order before the work for approval by the Sekdes or Kades. Jam mulai and jam selesai
+// are optional; rencana jam is the planned hours of each karyawan.
+func (h *SuratPerintahLemburHandler) CreateSuratPerintahLembur(c *fiber.Ctx) error {
+	if isPeranKaryawan(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Karyawan cannot issue a surat perintah lembur",
+		})
+	}
+
+	var req struct {
+		KaryawanIDs []uint  `json:"karyawan_ids"`
+		Tanggal     string  `json:"tanggal"`
+		JamMulai    string  `json:"jam_mulai"`
+		JamSelesai  string  `json:"jam_selesai"`
+		RencanaJam  float64 `json:"rencana_jam"`
+		Tugas       string  `json:"tugas"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	tanggal, err := time.Parse("2006-01-02", req.Tanggal)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tanggal format. Use YYYY-MM-DD",
+		})
+	}
+	if len(req.KaryawanIDs) == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one karyawan is required",
+		})
+	}
+	if strings.TrimSpace(req.Tugas) == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tugas is required",
+		})
+	}
+	if req.RencanaJam <= 0 || req.RencanaJam > 24 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Rencana jam must be more than 0 and at most 24",
+		})
+	}
+	if (req.JamMulai == "") != (req.JamSelesai == "") {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Fill in both jam_mulai and jam_selesai, or neither",
+		})
+	}
+	if req.JamMulai != "" {
+		rencana := models.Lembur{Tanggal: tanggal, JamMulai: req.JamMulai, JamSelesai: req.JamSelesai}
+		if msg := rencana.HitungTotalJam(); msg != "" {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": msg,
+			})
+		}
+		req.JamMulai, req.JamSelesai = rencana.JamMulai, rencana.JamSelesai
+	}
+
+	surat := models.SuratPerintahLembur{
+		Tanggal:      tanggal,
+		JamMulai:     req.JamMulai,
+		JamSelesai:   req.JamSelesai,
+		RencanaJam:   math.Round(req.RencanaJam*100) / 100,
+		Tugas:        strings.TrimSpace(req.Tugas),
+		Status:       models.SuratPerintahLemburDiajukan,
+		DiajukanOleh: userIDFromCtx(c),
+	}
+	dipilih := make(map[uint]bool)
+	for _, id := range req.KaryawanIDs {
+		if dipilih[id] {
+			continue
+		}
+		dipilih[id] = true
+		karyawan, err := h.karyawanRepo.GetByID(id)
+		if err != nil {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Karyawan " + strconv.FormatUint(uint64(id), 10) + " not found",
+			})
+		}
+		if ok, err := cekPeriodeTerbuka(c, h.payrollRunRepo, id, tanggal); !ok {
+			return err
+		}
+		surat.Karyawan = append(surat.Karyawan, *karyawan)
+	}
+
+	if err := h.suratRepo.Create(&surat); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create surat perintah lembur",
+		})
+	}
+
+	result, _ := h.suratRepo.GetByID(surat.ID)
+	return c.Status(http.StatusCreated).JSON(result)
+}
+
+// GetAllSuratPerintahLembur handles GET /api/lembur/surat-perintah?status=&karyawan_id=&bulan=&tahun=
+// - users with the karyawan role only see the orders naming them
+func (h *SuratPerintahLemburHandler) GetAllSuratPerintahLembur(c *fiber.Ctx) error {
+	karyawanID, _ := strconv.ParseUint(c.Query("karyawan_id", "0"), 10, 32)
+	bulan, _ := strconv.Atoi(c.Query("bulan", "0"))
+	tahun, _ := strconv.Atoi(c.Query("tahun", "0"))
+
+	if isPeranKaryawan(c) {
+		id, err := karyawanPengguna(c, h.userRepo)
+		if err != nil || id == nil {
+			return c.JSON([]models.SuratPerintahLembur{})
+		}
+		karyawanID = uint64(*id)
+	}
+
+	surat, err := h.suratRepo.GetAll(c.Query("status"), uint(karyawanID), bulan, tahun)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch surat perintah lembur",
+		})
+	}
+
+	return c.JSON(surat)
+}
+
+// suratDariParam loads the order of the :id parameter, checking that users with the
+// karyawan role only reach the orders naming them. When it cannot, it writes the error
+// response and returns nil.
+func (h *SuratPerintahLemburHandler) suratDariParam(c *fiber.Ctx) (*models.SuratPerintahLembur, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID",
+		})
+	}
+
+	surat, err := h.suratRepo.GetByID(uint(id))
+	if err != nil {
+		return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Surat perintah lembur not found",
+		})
+	}
+
+	if isPeranKaryawan(c) {
+		karyawanID, err := karyawanPengguna(c, h.userRepo)
+		if err != nil || karyawanID == nil || !surat.Mencakup(*karyawanID) {
+			return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Surat perintah lembur not found",
+			})
+		}
+	}
+	return surat, nil
+}
+
+// GetSuratPerintahLemburByID handles GET /api/lembur/surat-perintah/:id - includes the
+// overtime reported against the order
+func (h *SuratPerintahLemburHandler) GetSuratPerintahLemburByID(c *fiber.Ctx) error {
+	surat, err := h.suratDariParam(c)
+	if err != nil || surat == nil {
+		return err
+	}
+	return c.JSON(surat)
+}
+
+// cekPemutusSurat checks that the logged-in user may decide on the order: the Sekdes or
+// Kades, who neither drafted it nor is named in it
+func (h *SuratPerintahLemburHandler) cekPemutusSurat(c *fiber.Ctx, surat *models.SuratPerintahLembur) (bool, error) {
+	for _, k := range surat.Karyawan {
+		if ok, err := cekPemutus(c, h.userRepo, surat.DiajukanOleh, k.ID, "surat perintah lembur"); !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// SetujuiSuratPerintahLembur handles POST /api/lembur/surat-perintah/:id/setujui - Sekdes
+// or Kades only. Issues the order so the actual hours can be reported against it.
+func (h *SuratPerintahLemburHandler) SetujuiSuratPerintahLembur(c *fiber.Ctx) error {
+	catatan, ok := keputusan(c)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	surat, err := h.suratDariParam(c)
+	if err != nil || surat == nil {
+		return err
+	}
+	if ok, err := h.cekPemutusSurat(c, surat); !ok {
+		return err
+	}
+	if surat.Status != models.SuratPerintahLemburDiajukan {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Surat perintah lembur is already " + string(surat.Status),
+		})
+	}
+
+	if err := h.suratRepo.Putuskan(surat, models.SuratPerintahLemburDisetujui, userIDFromCtx(c), catatan); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to approve surat perintah lembur",
+		})
+	}
+
+	return c.JSON(surat)
+}
+
+// TolakSuratPerintahLembur handles POST /api/lembur/surat-perintah/:id/tolak - Sekdes or
+// Kades only
+func (h *SuratPerintahLemburHandler) TolakSuratPerintahLembur(c *fiber.Ctx) error {
+	catatan, ok := keputusan(c)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if catatan == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Catatan is required to reject a surat perintah lembur",
+		})
+	}
+
+	surat, err := h.suratDariParam(c)
+	if err != nil || surat == nil {
+		return err
+	}
+	if ok, err := h.cekPemutusSurat(c, surat); !ok {
+		return err
+	}
+	if surat.Status != models.SuratPerintahLemburDiajukan {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Surat perintah lembur is already " + string(surat.Status),
+		})
+	}
+
+	if err := h.suratRepo.Putuskan(surat, models.SuratPerintahLemburDitolak, userIDFromCtx(c), catatan); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reject surat perintah lembur",
+		})
+	}
+
+	return c.JSON(surat)
+}
+
+// BatalkanSuratPerintahLembur handles POST /api/lembur/surat-perintah/:id/batal - withdraws
+// an order that has no overtime reported against it
+func (h *SuratPerintahLemburHandler) BatalkanSuratPerintahLembur(c *fiber.Ctx) error {
+	if isPeranKaryawan(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Karyawan cannot cancel a surat perintah lembur",
+		})
+	}
+	catatan, ok := keputusan(c)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	surat, err := h.suratDariParam(c)
+	if err != nil || surat == nil {
+		return err
+	}
+	if surat.Status != models.SuratPerintahLemburDiajukan && surat.Status != models.SuratPerintahLemburDisetujui {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Surat perintah lembur is already " + string(surat.Status),
+		})
+	}
+	for _, l := range surat.Realisasi {
+		if l.Status != "ditolak" {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{
+				"error": "Overtime has been reported against this surat perintah lembur",
+			})
+		}
+	}
+
+	if err := h.suratRepo.Putuskan(surat, models.SuratPerintahLemburDibatalkan, userIDFromCtx(c), catatan); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to cancel surat perintah lembur",
+		})
+	}
+
+	return c.JSON(surat)
+}
@@ -16,6 +16,16 @@ const (
 	AbsensiAlpha  AbsensiStatus = "alpha"
 )
 
+// Sources of an absensi row. The times of rows recorded by the karyawan themselves
+// cannot be edited afterwards.
+const (
+	SumberAbsensiManual  = "manual"
+	SumberAbsensiMandiri = "mandiri"
+	SumberAbsensiQR      = "qr"
+	SumberAbsensiFingerprint = "fingerprint"
+	SumberAbsensiKoreksi = "koreksi" // set by an approved koreksi absensi
+)
+
 // Absensi represents daily attendance record
 type Absensi struct {
 	ID          uint          `json:"id" gorm:"primaryKey"`
@@ -25,9 +35,26 @@ type Absensi struct {
 	JamKeluar   string        `json:"jam_keluar" gorm:"size:10"`
 	Status      AbsensiStatus `json:"status" gorm:"default:'hadir';type:enum('hadir','izin','sakit','alpha')"`
 	Keterangan  string        `json:"keterangan" gorm:"type:text"`
+	PengajuanCutiID *uint     `json:"pengajuan_cuti_id,omitempty" gorm:"index"` // set for days of an approved cuti
+	// What an approved cuti replaced on the day, put back when the cuti is cancelled.
+	// StatusSebelumCuti is empty when the cuti created the row.
+	StatusSebelumCuti     AbsensiStatus `json:"-" gorm:"size:10"`
+	KeteranganSebelumCuti string        `json:"-" gorm:"type:text"`
+	JamMasukSebelumCuti   string        `json:"-" gorm:"size:10"`
+	JamKeluarSebelumCuti  string        `json:"-" gorm:"size:10"`
+	Sumber      string        `json:"sumber" gorm:"size:20;default:'manual'"`
+	GajiID      *uint         `json:"gaji_id" gorm:"index"` // the slip that paid the day, after which the row is read-only
+	DihitungGajiID *uint      `json:"dihitung_gaji_id" gorm:"index"` // the slip that counted the day, copied to GajiID when its run is approved
 	CreatedAt   time.Time     `json:"created_at"`
 	UpdatedAt   time.Time     `json:"updated_at"`
 	Karyawan    Karyawan      `json:"karyawan,omitempty" gorm:"foreignKey:KaryawanID"`
+	Riwayat     []RiwayatAbsensi `json:"riwayat,omitempty" gorm:"foreignKey:AbsensiID"`
+
+	// Computed from the jadwal kerja of the day when the row is read
+	MenitTerlambat   int     `json:"menit_terlambat" gorm:"-"`
+	MenitPulangCepat int     `json:"menit_pulang_cepat" gorm:"-"`
+	MenitKerja       int     `json:"menit_kerja" gorm:"-"`
+	JamKerja         float64 `json:"jam_kerja" gorm:"-"`
 }
 
 // TableName specifies the table name for Absensi model
@@ -0,0 +1,71 @@
+package models
+
+// PeringatanGaji is a warning about a computed slip that may need attention
+type PeringatanGaji struct {
+	KaryawanID   uint   `json:"karyawan_id"`
+	NamaKaryawan string `json:"nama_karyawan"`
+	Pesan        string `json:"pesan"`
+}
+
+// TotalJabatan summarizes the slips of one jabatan
+type TotalJabatan struct {
+	JabatanID       *uint   `json:"jabatan_id"`
+	Jabatan         string  `json:"jabatan"`
+	JumlahKaryawan  int     `json:"jumlah_karyawan"`
+	TotalPendapatan float64 `json:"total_pendapatan"`
+	TotalPotongan   float64 `json:"total_potongan"`
+	TotalGaji       float64 `json:"total_gaji"`
+}
+
+// StatusSelisih describes how a karyawan's slip compares to the previous period
+type StatusSelisih string
+
+const (
+	SelisihBaru   StatusSelisih = "baru"   // no slip in the previous period
+	SelisihKeluar StatusSelisih = "keluar" // no slip in this period
+	SelisihTetap  StatusSelisih = "tetap"  // slips in both periods
+)
+
+// SelisihItem compares the amount of one line item kode between two periods
+type SelisihItem struct {
+	Kode           string        `json:"kode"`
+	Nama           string        `json:"nama"`
+	Jenis          JenisKomponen `json:"jenis"`
+	Sebelumnya     float64       `json:"sebelumnya"`
+	Sekarang       float64       `json:"sekarang"`
+	Selisih        float64       `json:"selisih"`
+	MelebihiAmbang bool          `json:"melebihi_ambang"`
+}
+
+// SelisihGaji compares a karyawan's slip between two periods
+type SelisihGaji struct {
+	KaryawanID      uint          `json:"karyawan_id"`
+	NIK             string        `json:"nik"`
+	NamaKaryawan    string        `json:"nama_karyawan"`
+	Jabatan         string        `json:"jabatan"`
+	Status          StatusSelisih `json:"status"`
+	TotalSebelumnya float64       `json:"total_sebelumnya"`
+	TotalSekarang   float64       `json:"total_sekarang"`
+	Selisih         float64       `json:"selisih"`
+	MelebihiAmbang  bool          `json:"melebihi_ambang"`
+	LemburBerubah   bool          `json:"lembur_berubah"`
+	Items           []SelisihItem `json:"items"`
+}
+
+// LaporanVarians compares the slips of a period with those of an earlier period
+type LaporanVarians struct {
+	PeriodeBulan         int           `json:"periode_bulan"`
+	PeriodeTahun         int           `json:"periode_tahun"`
+	PembandingBulan      int           `json:"pembanding_bulan"`
+	PembandingTahun      int           `json:"pembanding_tahun"`
+	AmbangPersen         float64       `json:"ambang_persen"`
+	AmbangNominal        float64       `json:"ambang_nominal"`
+	TotalSebelumnya      float64       `json:"total_sebelumnya"`
+	TotalSekarang        float64       `json:"total_sekarang"`
+	Selisih              float64       `json:"selisih"`
+	JumlahBaru           int           `json:"jumlah_baru"`
+	JumlahKeluar         int           `json:"jumlah_keluar"`
+	JumlahMelebihiAmbang int           `json:"jumlah_melebihi_ambang"`
+	JumlahLemburBerubah  int           `json:"jumlah_lembur_berubah"`
+	Data                 []SelisihGaji `json:"data"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// AnggaranLembur is the desa's overtime budget for one period
+type AnggaranLembur struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Bulan      int       `json:"bulan" gorm:"not null;uniqueIndex:idx_anggaran_lembur"`
+	Tahun      int       `json:"tahun" gorm:"not null;uniqueIndex:idx_anggaran_lembur"`
+	Jumlah     float64   `json:"jumlah" gorm:"type:decimal(15,2);not null"`
+	Keterangan string    `json:"keterangan" gorm:"type:text"`
+	DibuatOleh *uint     `json:"dibuat_oleh"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for AnggaranLembur model
+func (AnggaranLembur) TableName() string {
+	return "anggaran_lembur"
+}
+
+// PemakaianLembur is a karyawan's overtime in a period against the caps. Jam counts
+// approved overtime and JamPending the overtime still waiting for approval.
+type PemakaianLembur struct {
+	KaryawanID         uint    `json:"karyawan_id"`
+	NamaKaryawan       string  `json:"nama_karyawan"`
+	JamBulan           float64 `json:"jam_bulan"`
+	JamPending         float64 `json:"jam_pending"`
+	MaksJamBulanan     float64 `json:"maks_jam_bulanan"`
+	JamMingguTerbanyak float64 `json:"jam_minggu_terbanyak"` // busiest week, counting only days of the period
+	MaksJamMingguan    float64 `json:"maks_jam_mingguan"`
+	Nominal            float64 `json:"nominal"`
+	NominalPending     float64 `json:"nominal_pending"`
+	MelebihiBatas      bool    `json:"melebihi_batas"`
+}
+
+// DashboardLembur summarizes the overtime of a period against the caps and the desa budget
+type DashboardLembur struct {
+	Bulan          int               `json:"bulan"`
+	Tahun          int               `json:"tahun"`
+	Anggaran       float64           `json:"anggaran"` // 0 when no budget is set
+	Nominal        float64           `json:"nominal"`
+	NominalPending float64           `json:"nominal_pending"`
+	SisaAnggaran   float64           `json:"sisa_anggaran"`
+	PersenAnggaran float64           `json:"persen_anggaran"`
+	TotalJam       float64           `json:"total_jam"`
+	JumlahMelebihi int               `json:"jumlah_melebihi"`
+	Karyawan       []PemakaianLembur `json:"karyawan"`
+}
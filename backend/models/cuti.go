@@ -0,0 +1,97 @@
+package models
+
+import "time"
+
+// JenisCuti represents the type of leave
+type JenisCuti string
+
+const (
+	CutiTahunan       JenisCuti = "tahunan"        // annual leave, from the yearly balance
+	CutiSakit         JenisCuti = "sakit"          // sick leave, needs a surat dokter
+	CutiMelahirkan    JenisCuti = "melahirkan"     // maternity leave
+	CutiAlasanPenting JenisCuti = "alasan_penting" // leave for important reasons, from the yearly balance
+)
+
+// IsValid reports whether j is a known leave type
+func (j JenisCuti) IsValid() bool {
+	switch j {
+	case CutiTahunan, CutiSakit, CutiMelahirkan, CutiAlasanPenting:
+		return true
+	}
+	return false
+}
+
+// BerKuota reports whether the leave is taken from a yearly balance
+func (j JenisCuti) BerKuota() bool {
+	return j == CutiTahunan || j == CutiAlasanPenting
+}
+
+// StatusAbsensi returns the absensi status recorded for the days of the leave
+func (j JenisCuti) StatusAbsensi() AbsensiStatus {
+	if j == CutiSakit {
+		return AbsensiSakit
+	}
+	return AbsensiIzin
+}
+
+// CutiStatus represents the approval state of a leave request
+type CutiStatus string
+
+const (
+	CutiDiajukan   CutiStatus = "diajukan"   // waiting for the Sekdes or Kades
+	CutiDisetujui  CutiStatus = "disetujui"  // absensi created, balance taken
+	CutiDitolak    CutiStatus = "ditolak"    // rejected
+	CutiDibatalkan CutiStatus = "dibatalkan" // withdrawn, or cancelled after approval
+)
+
+// PengajuanCuti is a karyawan's leave request. JumlahHari counts the working days of
+// the kalender kerja between Mulai and Selesai; those days get an absensi row once the
+// request is approved.
+type PengajuanCuti struct {
+	ID               uint       `json:"id" gorm:"primaryKey"`
+	KaryawanID       uint       `json:"karyawan_id" gorm:"not null;index"`
+	Jenis            JenisCuti  `json:"jenis" gorm:"type:enum('tahunan','sakit','melahirkan','alasan_penting');not null"`
+	Mulai            time.Time  `json:"mulai" gorm:"type:date;not null"`
+	Selesai          time.Time  `json:"selesai" gorm:"type:date;not null"`
+	JumlahHari       int        `json:"jumlah_hari"`
+	Alasan           string     `json:"alasan" gorm:"type:text"`
+	Lampiran         string     `json:"lampiran" gorm:"size:255"` // stored file name, e.g. the surat dokter
+	Status           CutiStatus `json:"status" gorm:"default:'diajukan';type:enum('diajukan','disetujui','ditolak','dibatalkan');index"`
+	DiajukanOleh     *uint      `json:"diajukan_oleh"`
+	DiputuskanOleh   *uint      `json:"diputuskan_oleh"`
+	DiputuskanPada   *time.Time `json:"diputuskan_pada"`
+	CatatanKeputusan string     `json:"catatan_keputusan" gorm:"type:text"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	Karyawan         Karyawan   `json:"karyawan,omitempty" gorm:"foreignKey:KaryawanID"`
+}
+
+// TableName specifies the table name for PengajuanCuti model
+func (PengajuanCuti) TableName() string {
+	return "pengajuan_cuti"
+}
+
+// SaldoCuti is a karyawan's balance of one leave type in a year: the entitlement, the
+// days carried over from the previous year and the days taken by approved requests
+type SaldoCuti struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	KaryawanID uint      `json:"karyawan_id" gorm:"not null;uniqueIndex:idx_saldo_cuti"`
+	Tahun      int       `json:"tahun" gorm:"not null;uniqueIndex:idx_saldo_cuti"`
+	Jenis      JenisCuti `json:"jenis" gorm:"size:20;not null;uniqueIndex:idx_saldo_cuti"`
+	Hak        int       `json:"hak"`
+	CarryOver  int       `json:"carry_over"`
+	Terpakai   int       `json:"terpakai"`
+	Sisa       int       `json:"sisa" gorm:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for SaldoCuti model
+func (SaldoCuti) TableName() string {
+	return "saldo_cuti"
+}
+
+// HitungSisa fills the remaining days of the balance
+func (s *SaldoCuti) HitungSisa() {
+	s.Sisa = s.Hak + s.CarryOver - s.Terpakai
+}
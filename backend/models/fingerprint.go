@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// HasilFingerprint is the outcome of one day of an imported fingerprint log
+type HasilFingerprint string
+
+const (
+	FingerprintDibuat          HasilFingerprint = "dibuat"            // new absensi row
+	FingerprintDiperbarui      HasilFingerprint = "diperbarui"        // times added to an existing row
+	FingerprintTidakBerubah    HasilFingerprint = "tidak_berubah"     // already imported
+	FingerprintKonflik         HasilFingerprint = "konflik"           // existing row left as it is, see keterangan
+	FingerprintPinTidakDikenal HasilFingerprint = "pin_tidak_dikenal" // no karyawan has the PIN
+)
+
+// PunchFingerprint is one line of a fingerprint terminal log
+type PunchFingerprint struct {
+	Baris      int
+	PIN        string
+	Waktu      time.Time
+	VerifyMode string
+}
+
+// RekapFingerprint collapses a PIN's punches on one day into jam masuk, the first punch,
+// and jam keluar, the last one
+type RekapFingerprint struct {
+	Baris        []int            `json:"baris"`
+	PIN          string           `json:"pin"`
+	KaryawanID   uint             `json:"karyawan_id,omitempty"`
+	NamaKaryawan string           `json:"nama_karyawan,omitempty"`
+	Tanggal      time.Time        `json:"tanggal"`
+	JamMasuk     string           `json:"jam_masuk"`
+	JamKeluar    string           `json:"jam_keluar"`
+	JumlahPunch  int              `json:"jumlah_punch"`
+	Hasil        HasilFingerprint `json:"hasil"`
+	Keterangan   string           `json:"keterangan,omitempty"`
+}
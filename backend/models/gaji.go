@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
@@ -11,26 +12,119 @@ type GajiStatus string
 
 const (
 	GajiStatusPending GajiStatus = "pending"
-	GajiStatusDibayar  GajiStatus = "dibayar"
+	GajiStatusDibayar GajiStatus = "dibayar"
 )
 
+// JenisGaji represents the kind of slip
+type JenisGaji string
+
+const (
+	JenisGajiReguler JenisGaji = "reguler" // the monthly slip
+	JenisGajiRapel   JenisGaji = "rapel"   // back pay for past periods, paid in the regular run
+	JenisGajiTHR     JenisGaji = "thr"     // tunjangan hari raya
+	JenisGajiKe13    JenisGaji = "ke13"    // gaji ke-13
+	JenisGajiBonus   JenisGaji = "bonus"   // bonus
+)
+
+// IsJenisRun checks whether slips of this jenis are paid in a payroll run of their own
+func (j JenisGaji) IsJenisRun() bool {
+	switch j {
+	case JenisGajiReguler, JenisGajiTHR, JenisGajiKe13, JenisGajiBonus:
+		return true
+	}
+	return false
+}
+
+// IsTidakTeratur checks whether the slip is irregular income, taxed together with the
+// regular slip of the month
+func (j JenisGaji) IsTidakTeratur() bool {
+	return j != JenisGajiReguler
+}
+
+// JenisRun returns the jenis of the payroll run the slip is paid in. Rapel slips are
+// paid in the regular run.
+func (j JenisGaji) JenisRun() JenisGaji {
+	if j == JenisGajiRapel || j == "" {
+		return JenisGajiReguler
+	}
+	return j
+}
+
+// Label returns the name of the jenis as shown on slips and reports
+func (j JenisGaji) Label() string {
+	switch j {
+	case JenisGajiRapel:
+		return "Rapel Gaji"
+	case JenisGajiTHR:
+		return "Tunjangan Hari Raya"
+	case JenisGajiKe13:
+		return "Gaji ke-13"
+	case JenisGajiBonus:
+		return "Bonus"
+	}
+	return "Gaji Bulanan"
+}
+
+// MetodeProrata represents how the days of a period are counted for pro-rating
+type MetodeProrata string
+
+const (
+	ProrataKalender  MetodeProrata = "kalender"   // calendar days
+	ProrataHariKerja MetodeProrata = "hari_kerja" // working days of the kalender kerja
+)
+
+// IsValid checks whether the pro-rating method is one of the known values
+func (m MetodeProrata) IsValid() bool {
+	return m == ProrataKalender || m == ProrataHariKerja
+}
+
 // Gaji represents an employee's salary record
 type Gaji struct {
-	ID                 uint      `json:"id" gorm:"primaryKey"`
-	KaryawanID         uint      `json:"karyawan_id" gorm:"not null;index"`
-	PeriodeBulan       int       `json:"periode_bulan" gorm:"not null"`
-	PeriodeTahun       int       `json:"periode_tahun" gorm:"not null"`
-	GajiPokok          float64   `json:"gaji_pokok" gorm:"not null;type:decimal(15,2)"`
-	TunjanganJabatan   float64   `json:"tunjangan_jabatan" gorm:"default:0;type:decimal(15,2)"`
-	TunjanganTransport float64   `json:"tunjangan_transport" gorm:"default:0;type:decimal(15,2)"`
-	TunjanganMakan     float64   `json:"tunjangan_makan" gorm:"default:0;type:decimal(15,2)"`
-	Lembur             float64   `json:"lembur" gorm:"default:0;type:decimal(15,2)"`
-	Potongan           float64   `json:"potongan" gorm:"default:0;type:decimal(15,2)"`
-	TotalGaji          float64   `json:"total_gaji" gorm:"not null;type:decimal(15,2)"`
-	Status             GajiStatus `json:"status" gorm:"default:'pending';type:enum('pending','dibayar')"`
-	CreatedAt          time.Time `json:"created_at"`
-	UpdatedAt          time.Time `json:"updated_at"`
-	Karyawan           Karyawan  `json:"karyawan,omitempty" gorm:"foreignKey:KaryawanID"`
+	ID                      uint        `json:"id" gorm:"primaryKey"`
+	KaryawanID              uint        `json:"karyawan_id" gorm:"not null;index"`
+	JabatanID               *uint       `json:"jabatan_id" gorm:"index"` // the karyawan's jabatan when the slip was generated
+	PeriodeBulan            int         `json:"periode_bulan" gorm:"not null"`
+	PeriodeTahun            int         `json:"periode_tahun" gorm:"not null"`
+	GajiPokok               float64     `json:"gaji_pokok" gorm:"not null;type:decimal(15,2)"`
+	TunjanganJabatan        float64     `json:"tunjangan_jabatan" gorm:"default:0;type:decimal(15,2)"`
+	TunjanganTransport      float64     `json:"tunjangan_transport" gorm:"default:0;type:decimal(15,2)"`
+	TunjanganMakan          float64     `json:"tunjangan_makan" gorm:"default:0;type:decimal(15,2)"`
+	Lembur                  float64     `json:"lembur" gorm:"default:0;type:decimal(15,2)"`
+	Potongan                float64     `json:"potongan" gorm:"default:0;type:decimal(15,2)"`
+	PenghasilanBruto        float64     `json:"penghasilan_bruto" gorm:"default:0;type:decimal(15,2)"`
+	PPh21                   float64     `json:"pph21" gorm:"column:pph21;default:0;type:decimal(15,2)"`
+	BPJSKesehatanKaryawan   float64     `json:"bpjs_kesehatan_karyawan" gorm:"column:bpjs_kesehatan_karyawan;default:0;type:decimal(15,2)"`
+	BPJSKesehatanPerusahaan float64     `json:"bpjs_kesehatan_perusahaan" gorm:"column:bpjs_kesehatan_perusahaan;default:0;type:decimal(15,2)"`
+	JHTKaryawan             float64     `json:"jht_karyawan" gorm:"column:jht_karyawan;default:0;type:decimal(15,2)"`
+	JHTPerusahaan           float64     `json:"jht_perusahaan" gorm:"column:jht_perusahaan;default:0;type:decimal(15,2)"`
+	JKKPerusahaan           float64     `json:"jkk_perusahaan" gorm:"column:jkk_perusahaan;default:0;type:decimal(15,2)"`
+	JKMPerusahaan           float64     `json:"jkm_perusahaan" gorm:"column:jkm_perusahaan;default:0;type:decimal(15,2)"`
+	JPKaryawan              float64     `json:"jp_karyawan" gorm:"column:jp_karyawan;default:0;type:decimal(15,2)"`
+	JPPerusahaan            float64     `json:"jp_perusahaan" gorm:"column:jp_perusahaan;default:0;type:decimal(15,2)"`
+	TotalGaji               float64     `json:"total_gaji" gorm:"not null;type:decimal(15,2)"`
+	Status                  GajiStatus  `json:"status" gorm:"default:'pending';type:enum('pending','dibayar')"`
+	Jenis                   JenisGaji   `json:"jenis" gorm:"default:'reguler';size:20;index"`
+	FaktorProrata           float64     `json:"faktor_prorata" gorm:"default:1;type:decimal(7,4)"`
+	HariDibayar             int         `json:"hari_dibayar" gorm:"default:0"`
+	HariPeriode             int         `json:"hari_periode" gorm:"default:0"`
+	MetodeProrata           string      `json:"metode_prorata" gorm:"size:20"`
+	HariHadir               int         `json:"hari_hadir" gorm:"default:0"`
+	HariIzin                int         `json:"hari_izin" gorm:"default:0"`
+	HariSakit               int         `json:"hari_sakit" gorm:"default:0"`
+	HariAlpha               int         `json:"hari_alpha" gorm:"default:0"`
+	HariTerlambat           int         `json:"hari_terlambat" gorm:"default:0"`
+	MenitTerlambat          int         `json:"menit_terlambat" gorm:"default:0"`
+	MakanPerHadir           float64     `json:"makan_per_hadir" gorm:"default:0;type:decimal(15,2)"`
+	PotonganPerAlpha        float64     `json:"potongan_per_alpha" gorm:"default:0;type:decimal(15,2)"`
+	DendaPerTerlambat       float64     `json:"denda_per_terlambat" gorm:"default:0;type:decimal(15,2)"`
+	PayrollRunID            *uint       `json:"payroll_run_id" gorm:"index"`
+	LemburIDs               []uint      `json:"-" gorm:"-"` // overtime the lembur column was computed from
+	AbsensiIDs              []uint      `json:"-" gorm:"-"` // attendance rows the attendance figures were counted from
+	CreatedAt               time.Time   `json:"created_at"`
+	UpdatedAt               time.Time   `json:"updated_at"`
+	Karyawan                Karyawan    `json:"karyawan,omitempty" gorm:"foreignKey:KaryawanID"`
+	Items                   []GajiItem  `json:"items" gorm:"foreignKey:GajiID"`
+	PayrollRun              *PayrollRun `json:"payroll_run,omitempty" gorm:"foreignKey:PayrollRunID"`
 }
 
 // TableName specifies the table name for Gaji model
@@ -38,18 +132,156 @@ func (Gaji) TableName() string {
 	return "gaji"
 }
 
-// BeforeCreate hook to ensure unique period per employee
+// BeforeCreate hook to ensure one slip of each jenis per employee per period
 func (g *Gaji) BeforeCreate(tx *gorm.DB) error {
+	if g.Jenis == "" {
+		g.Jenis = JenisGajiReguler
+	}
+
 	var existing Gaji
-	err := tx.Where("karyawan_id = ? AND periode_bulan = ? AND periode_tahun = ?",
-		g.KaryawanID, g.PeriodeBulan, g.PeriodeTahun).First(&existing).Error
+	err := tx.Where("karyawan_id = ? AND periode_bulan = ? AND periode_tahun = ? AND jenis = ?",
+		g.KaryawanID, g.PeriodeBulan, g.PeriodeTahun, g.Jenis).First(&existing).Error
 	if err == nil {
 		return gorm.ErrDuplicatedKey
 	}
 	return nil
 }
 
-// CalculateTotal computes the total salary
+// IsEditable reports whether the slip may still be recalculated or deleted
+func (g *Gaji) IsEditable() bool {
+	if g.Status == GajiStatusDibayar {
+		return false
+	}
+	return g.PayrollRun == nil || g.PayrollRun.IsEditable()
+}
+
+// AddItem appends a line item to the slip, skipping zero amounts
+func (g *Gaji) AddItem(kode, nama string, jenis JenisKomponen, jumlah float64) {
+	if jumlah == 0 {
+		return
+	}
+	g.Items = append(g.Items, GajiItem{
+		Kode:   kode,
+		Nama:   nama,
+		Jenis:  jenis,
+		Jumlah: jumlah,
+	})
+}
+
+// KoreksiIDs returns the corrections carried by the slip's line items
+func (g *Gaji) KoreksiIDs() []uint {
+	var ids []uint
+	for _, item := range g.Items {
+		if item.KoreksiGajiID != nil {
+			ids = append(ids, *item.KoreksiGajiID)
+		}
+	}
+	return ids
+}
+
+// AngsuranKasbonIDs returns the kasbon installments collected by the slip's line items
+func (g *Gaji) AngsuranKasbonIDs() []uint {
+	var ids []uint
+	for _, item := range g.Items {
+		if item.AngsuranKasbonID != nil {
+			ids = append(ids, *item.AngsuranKasbonID)
+		}
+	}
+	return ids
+}
+
+// SetStandardItems resets the line items to those mirroring the fixed gaji columns
+func (g *Gaji) SetStandardItems() {
+	g.Items = nil
+	g.AddItem(KodeGajiPokok, "Gaji Pokok", JenisPendapatan, g.GajiPokok)
+	g.AddItem(KodeTunjanganJabatan, "Tunjangan Jabatan", JenisPendapatan, g.TunjanganJabatan)
+	g.AddItem(KodeTunjanganTransport, "Tunjangan Transport", JenisPendapatan, g.TunjanganTransport)
+	g.AddItem(KodeTunjanganMakan, "Tunjangan Makan", JenisPendapatan, g.TunjanganMakan)
+	g.AddItem(KodeLembur, "Lembur", JenisPendapatan, g.Lembur)
+	g.AddItem(KodePotongan, "Potongan", JenisPotongan, g.Potongan)
+
+	if ket := g.KeteranganProrata(); ket != "" {
+		for i := range g.Items {
+			switch g.Items[i].Kode {
+			case KodeGajiPokok, KodeTunjanganJabatan, KodeTunjanganTransport, KodeTunjanganMakan:
+				g.Items[i].Keterangan = ket
+			}
+		}
+	}
+}
+
+// IsProrata reports whether the fixed monthly amounts were pro-rated
+func (g *Gaji) IsProrata() bool {
+	return g.FaktorProrata > 0 && g.FaktorProrata < 1
+}
+
+// KeteranganProrata describes the pro-rate factor, or returns "" for a full month
+func (g *Gaji) KeteranganProrata() string {
+	if !g.IsProrata() {
+		return ""
+	}
+	hari := "hari kalender"
+	if g.MetodeProrata == string(ProrataHariKerja) {
+		hari = "hari kerja"
+	}
+	return fmt.Sprintf("Prorata %d/%d %s (%.4f)", g.HariDibayar, g.HariPeriode, hari, g.FaktorProrata)
+}
+
+// JumlahItem sums the line items with the given kode
+func (g *Gaji) JumlahItem(kode string) float64 {
+	total := 0.0
+	for _, item := range g.Items {
+		if item.Kode == kode {
+			total += item.Jumlah
+		}
+	}
+	return total
+}
+
+// TotalPendapatan sums the earning line items
+func (g *Gaji) TotalPendapatan() float64 {
+	total := 0.0
+	for _, item := range g.Items {
+		if item.Jenis == JenisPendapatan {
+			total += item.Jumlah
+		}
+	}
+	return total
+}
+
+// TotalPotongan sums the deduction line items
+func (g *Gaji) TotalPotongan() float64 {
+	total := 0.0
+	for _, item := range g.Items {
+		if item.Jenis == JenisPotongan {
+			total += item.Jumlah
+		}
+	}
+	return total
+}
+
+// IuranKaryawan returns the BPJS contributions deducted from the employee
+func (g *Gaji) IuranKaryawan() float64 {
+	return g.BPJSKesehatanKaryawan + g.JHTKaryawan + g.JPKaryawan
+}
+
+// IuranPerusahaan returns the BPJS contributions paid by the desa as employer
+func (g *Gaji) IuranPerusahaan() float64 {
+	return g.BPJSKesehatanPerusahaan + g.JHTPerusahaan + g.JKKPerusahaan + g.JKMPerusahaan + g.JPPerusahaan
+}
+
+// IuranPensiun returns the employee JHT and JP contributions, which reduce taxable income
+func (g *Gaji) IuranPensiun() float64 {
+	return g.JHTKaryawan + g.JPKaryawan
+}
+
+// CalculateBruto computes the gross income used as the PPh 21 tax base.
+// Employer-paid JKK, JKM and BPJS Kesehatan premiums count as taxable benefits.
+func (g *Gaji) CalculateBruto() {
+	g.PenghasilanBruto = g.TotalPendapatan() + g.JKKPerusahaan + g.JKMPerusahaan + g.BPJSKesehatanPerusahaan
+}
+
+// CalculateTotal computes the total salary from the line items
 func (g *Gaji) CalculateTotal() {
-	g.TotalGaji = g.GajiPokok + g.TunjanganJabatan + g.TunjanganTransport + g.TunjanganMakan + g.Lembur - g.Potongan
+	g.TotalGaji = g.TotalPendapatan() - g.TotalPotongan()
 }
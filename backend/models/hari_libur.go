@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// JenisHariLibur represents where a calendar entry comes from
+type JenisHariLibur string
+
+const (
+	LiburNasional      JenisHariLibur = "nasional"        // national public holiday
+	LiburCutiBersama   JenisHariLibur = "cuti_bersama"    // collective leave day set by the government
+	LiburDesa          JenisHariLibur = "desa"            // holiday added by the desa
+	HariKerjaPengganti JenisHariLibur = "kerja_pengganti" // working day on a day the jadwal kerja has off
+)
+
+// IsValid checks whether the jenis is one of the known values
+func (j JenisHariLibur) IsValid() bool {
+	switch j {
+	case LiburNasional, LiburCutiBersama, LiburDesa, HariKerjaPengganti:
+		return true
+	}
+	return false
+}
+
+// HariLibur is one day of the working calendar that differs from the weekly jadwal kerja:
+// a holiday, or a working day on a day that is normally off
+type HariLibur struct {
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	Tanggal    time.Time      `json:"tanggal" gorm:"type:date;not null;uniqueIndex"`
+	Nama       string         `json:"nama" gorm:"not null;size:150"`
+	Jenis      JenisHariLibur `json:"jenis" gorm:"not null;size:20;default:'nasional'"`
+	Sumber     string         `json:"sumber" gorm:"size:20"` // manual, ics or csv
+	DibuatOleh *uint          `json:"dibuat_oleh"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// TableName specifies the table name for HariLibur model
+func (HariLibur) TableName() string {
+	return "hari_libur"
+}
+
+// KalenderKerja tells working days from days off using the weekly jadwal kerja and the
+// calendar entries
+type KalenderKerja struct {
+	Jadwal JadwalMingguan
+	Libur  map[string]HariLibur // by tanggal as YYYY-MM-DD
+}
+
+// NewKalenderKerja builds a working calendar from the weekly schedule and calendar entries
+func NewKalenderKerja(jadwal JadwalMingguan, libur []HariLibur) *KalenderKerja {
+	k := &KalenderKerja{Jadwal: jadwal, Libur: make(map[string]HariLibur, len(libur))}
+	for _, l := range libur {
+		k.Libur[l.Tanggal.Format("2006-01-02")] = l
+	}
+	return k
+}
+
+// JadwalUntuk returns the schedule of a date. Holidays are days off and a kerja pengganti
+// day takes the schedule of Senin when its own weekday is off.
+func (k *KalenderKerja) JadwalUntuk(t time.Time) JadwalKerja {
+	j := k.Jadwal[t.Weekday()]
+	l, ok := k.Libur[t.Format("2006-01-02")]
+	if !ok {
+		return j
+	}
+	if l.Jenis != HariKerjaPengganti {
+		j.Libur = true
+	} else if j.Libur {
+		j = k.Jadwal[time.Monday]
+		j.Hari = int(t.Weekday())
+	}
+	return j
+}
+
+// IsHariKerja checks whether a date is a working day
+func (k *KalenderKerja) IsHariKerja(t time.Time) bool {
+	return !k.JadwalUntuk(t).Libur
+}
+
+// HariKerja lists the working days from awal up to akhir, both inclusive
+func (k *KalenderKerja) HariKerja(awal, akhir time.Time) []time.Time {
+	var hari []time.Time
+	for d := awal; !d.After(akhir); d = d.AddDate(0, 0, 1) {
+		if k.IsHariKerja(d) {
+			hari = append(hari, d)
+		}
+	}
+	return hari
+}
+
+// Hitung fills the minutes late, minutes left early and net working minutes of an
+// absensi using the schedule of its date
+func (k *KalenderKerja) Hitung(a *Absensi) {
+	hitungAbsensi(a, k.JadwalUntuk(a.Tanggal))
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// NamaHari holds the Indonesian day names indexed by time.Weekday
+var NamaHari = [7]string{"Minggu", "Senin", "Selasa", "Rabu", "Kamis", "Jumat", "Sabtu"}
+
+// JadwalKerja is the official working time of one weekday. Minutes late are counted from
+// JamMasuk once they exceed ToleransiMenit, minutes left early up to JamKeluar, and the
+// break between IstirahatMulai and IstirahatSelesai is not counted as working time.
+type JadwalKerja struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	Hari             int       `json:"hari" gorm:"not null;uniqueIndex"` // time.Weekday, 0 = Minggu
+	Libur            bool      `json:"libur" gorm:"default:false"`
+	JamMasuk         string    `json:"jam_masuk" gorm:"size:5"`
+	JamKeluar        string    `json:"jam_keluar" gorm:"size:5"`
+	IstirahatMulai   string    `json:"istirahat_mulai" gorm:"size:5"`
+	IstirahatSelesai string    `json:"istirahat_selesai" gorm:"size:5"`
+	ToleransiMenit   int       `json:"toleransi_menit" gorm:"default:0"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for JadwalKerja model
+func (JadwalKerja) TableName() string {
+	return "jadwal_kerja"
+}
+
+// NamaHari returns the Indonesian name of the weekday
+func (j *JadwalKerja) NamaHari() string {
+	if j.Hari < 0 || j.Hari > 6 {
+		return ""
+	}
+	return NamaHari[j.Hari]
+}
+
+// JadwalKerjaDefault returns the schedule used until one is configured: Senin to Kamis
+// 08:00-16:00 with a break at noon, Jumat 08:00-11:30, Sabtu and Minggu off
+func JadwalKerjaDefault() []JadwalKerja {
+	jadwal := make([]JadwalKerja, 0, 7)
+	for hari := time.Sunday; hari <= time.Saturday; hari++ {
+		j := JadwalKerja{Hari: int(hari)}
+		switch hari {
+		case time.Saturday, time.Sunday:
+			j.Libur = true
+		case time.Friday:
+			j.JamMasuk, j.JamKeluar = "08:00", "11:30"
+		default:
+			j.JamMasuk, j.JamKeluar = "08:00", "16:00"
+			j.IstirahatMulai, j.IstirahatSelesai = "12:00", "13:00"
+		}
+		jadwal = append(jadwal, j)
+	}
+	return jadwal
+}
+
+// JadwalMingguan is the working schedule of a week by weekday
+type JadwalMingguan map[time.Weekday]JadwalKerja
+
+// NewJadwalMingguan indexes a schedule by weekday. Weekdays missing from the list take
+// the default schedule.
+func NewJadwalMingguan(list []JadwalKerja) JadwalMingguan {
+	jadwal := make(JadwalMingguan, 7)
+	for _, j := range JadwalKerjaDefault() {
+		jadwal[time.Weekday(j.Hari)] = j
+	}
+	for _, j := range list {
+		jadwal[time.Weekday(j.Hari)] = j
+	}
+	return jadwal
+}
+
+// hitungAbsensi fills the minutes late, minutes left early and net working minutes of an
+// absensi from its jam masuk and jam keluar against the schedule of its day. Only hadir
+// rows on working days can be late or leave early; a jam keluar before jam masuk is taken
+// as the next day.
+func hitungAbsensi(a *Absensi, j JadwalKerja) {
+	a.MenitTerlambat, a.MenitPulangCepat, a.MenitKerja, a.JamKerja = 0, 0, 0, 0
+	if a.Status != AbsensiHadir {
+		return
+	}
+
+	masuk, adaMasuk := ParseJam(a.JamMasuk)
+	keluar, adaKeluar := ParseJam(a.JamKeluar)
+
+	if !j.Libur {
+		if mulai, ok := ParseJam(j.JamMasuk); ok && adaMasuk && masuk-mulai > j.ToleransiMenit {
+			a.MenitTerlambat = masuk - mulai
+		}
+		if selesai, ok := ParseJam(j.JamKeluar); ok && adaKeluar && keluar >= masuk && selesai > keluar {
+			a.MenitPulangCepat = selesai - keluar
+		}
+	}
+
+	if !adaMasuk || !adaKeluar {
+		return
+	}
+	if keluar < masuk {
+		keluar += 24 * 60
+	}
+	a.MenitKerja = keluar - masuk
+	if istirahatMulai, ok := ParseJam(j.IstirahatMulai); ok && !j.Libur {
+		if istirahatSelesai, ok := ParseJam(j.IstirahatSelesai); ok {
+			a.MenitKerja -= irisan(masuk, keluar, istirahatMulai, istirahatSelesai)
+		}
+	}
+	a.JamKerja = math.Round(float64(a.MenitKerja)/60*100) / 100
+}
+
+// ParseJam converts "HH:MM" or "HH:MM:SS" to minutes after midnight
+func ParseJam(jam string) (int, bool) {
+	jam = strings.TrimSpace(jam)
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, jam); err == nil {
+			return t.Hour()*60 + t.Minute(), true
+		}
+	}
+	return 0, false
+}
+
+// irisan returns the minutes two time ranges overlap
+func irisan(mulaiA, selesaiA, mulaiB, selesaiB int) int {
+	mulai, selesai := mulaiA, selesaiA
+	if mulaiB > mulai {
+		mulai = mulaiB
+	}
+	if selesaiB < selesai {
+		selesai = selesaiB
+	}
+	if selesai <= mulai {
+		return 0
+	}
+	return selesai - mulai
+}
+
+// LaporanKeterlambatan summarizes a karyawan's late arrivals, early departures and
+// working hours in a period
+type LaporanKeterlambatan struct {
+	KaryawanID       uint    `json:"karyawan_id"`
+	NIK              string  `json:"nik"`
+	NamaKaryawan     string  `json:"nama_karyawan"`
+	NamaJabatan      string  `json:"nama_jabatan"`
+	HariHadir        int     `json:"hari_hadir"`
+	HariTerlambat    int     `json:"hari_terlambat"`
+	MenitTerlambat   int     `json:"menit_terlambat"`
+	HariPulangCepat  int     `json:"hari_pulang_cepat"`
+	MenitPulangCepat int     `json:"menit_pulang_cepat"`
+	JamKerja         float64 `json:"jam_kerja"`
+}
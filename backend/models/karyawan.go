@@ -14,6 +14,29 @@ const (
 	StatusNonAktif KaryawanStatus = "non_aktif"
 )
 
+// StatusPTKP represents the tax-exempt income (PTKP) status of an employee
+type StatusPTKP string
+
+const (
+	PTKPTK0 StatusPTKP = "TK/0"
+	PTKPTK1 StatusPTKP = "TK/1"
+	PTKPTK2 StatusPTKP = "TK/2"
+	PTKPTK3 StatusPTKP = "TK/3"
+	PTKPK0  StatusPTKP = "K/0"
+	PTKPK1  StatusPTKP = "K/1"
+	PTKPK2  StatusPTKP = "K/2"
+	PTKPK3  StatusPTKP = "K/3"
+)
+
+// IsValid checks whether the PTKP status is one of the known values
+func (s StatusPTKP) IsValid() bool {
+	switch s {
+	case PTKPTK0, PTKPTK1, PTKPTK2, PTKPTK3, PTKPK0, PTKPK1, PTKPK2, PTKPK3:
+		return true
+	}
+	return false
+}
+
 // Karyawan represents an employee
 type Karyawan struct {
 	ID              uint           `json:"id" gorm:"primaryKey"`
@@ -24,6 +47,9 @@ type Karyawan struct {
 	Alamat          string         `json:"alamat" gorm:"type:text"`
 	JabatanID       *uint          `json:"jabatan_id" gorm:"index"`
 	TanggalBergabung *time.Time    `json:"tanggal_bergabung" gorm:"type:date"`
+	TanggalBerhenti *time.Time     `json:"tanggal_berhenti" gorm:"type:date"`
+	StatusPTKP      StatusPTKP     `json:"status_ptkp" gorm:"default:'TK/0';size:5"`
+	PinFingerprint  *string        `json:"pin_fingerprint" gorm:"size:20;uniqueIndex"` // user PIN on the fingerprint terminal
 	Status          KaryawanStatus `json:"status" gorm:"default:'aktif';type:enum('aktif','non_aktif')"`
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// KasbonStatus represents whether a loan still has installments to pay
+type KasbonStatus string
+
+const (
+	KasbonAktif KasbonStatus = "aktif"
+	KasbonLunas KasbonStatus = "lunas"
+)
+
+// AngsuranStatus represents the state of one loan installment
+type AngsuranStatus string
+
+const (
+	AngsuranTerjadwal AngsuranStatus = "terjadwal" // not deducted yet
+	AngsuranDipotong  AngsuranStatus = "dipotong"  // deducted from a slip
+	AngsuranDilewati  AngsuranStatus = "dilewati"  // skipped, moved to the end of the schedule
+	AngsuranDilunasi  AngsuranStatus = "dilunasi"  // repaid in cash outside payroll
+)
+
+// Kasbon is a loan or cash advance from the desa treasury, repaid by deducting
+// installments from the karyawan's regular slips
+type Kasbon struct {
+	ID            uint             `json:"id" gorm:"primaryKey"`
+	KaryawanID    uint             `json:"karyawan_id" gorm:"not null;index"`
+	Tanggal       time.Time        `json:"tanggal" gorm:"type:date;not null"`
+	Pokok         float64          `json:"pokok" gorm:"not null;type:decimal(15,2)"`
+	JumlahCicilan int              `json:"jumlah_cicilan" gorm:"not null"`
+	MulaiBulan    int              `json:"mulai_bulan" gorm:"not null"`
+	MulaiTahun    int              `json:"mulai_tahun" gorm:"not null"`
+	Keterangan    string           `json:"keterangan" gorm:"type:text"`
+	Status        KasbonStatus     `json:"status" gorm:"default:'aktif';type:enum('aktif','lunas');index"`
+	DibuatOleh    *uint            `json:"dibuat_oleh"`
+	DilunasiPada  *time.Time       `json:"dilunasi_pada"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+	Karyawan      Karyawan         `json:"karyawan,omitempty" gorm:"foreignKey:KaryawanID"`
+	Angsuran      []AngsuranKasbon `json:"angsuran,omitempty" gorm:"foreignKey:KasbonID"`
+}
+
+// TableName specifies the table name for Kasbon model
+func (Kasbon) TableName() string {
+	return "kasbon"
+}
+
+// BuatJadwal splits the principal into monthly installments from the start period.
+// Installments are rounded down to whole rupiah and the last one takes the remainder, so
+// it is never smaller than the others.
+func (k *Kasbon) BuatJadwal() {
+	k.Angsuran = nil
+	cicilan := math.Floor(k.Pokok / float64(k.JumlahCicilan))
+	sisa := k.Pokok
+	bulan, tahun := k.MulaiBulan, k.MulaiTahun
+	for i := 1; i <= k.JumlahCicilan; i++ {
+		jumlah := cicilan
+		if i == k.JumlahCicilan {
+			jumlah = sisa
+		}
+		k.Angsuran = append(k.Angsuran, AngsuranKasbon{
+			Ke:           i,
+			PeriodeBulan: bulan,
+			PeriodeTahun: tahun,
+			Jumlah:       jumlah,
+			Status:       AngsuranTerjadwal,
+		})
+		sisa -= jumlah
+		bulan, tahun = PeriodeBerikutnya(bulan, tahun)
+	}
+}
+
+// AngsuranKasbon is one monthly installment of a kasbon
+type AngsuranKasbon struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	KasbonID     uint           `json:"kasbon_id" gorm:"not null;index"`
+	Ke           int            `json:"ke" gorm:"not null"`
+	PeriodeBulan int            `json:"periode_bulan" gorm:"not null"`
+	PeriodeTahun int            `json:"periode_tahun" gorm:"not null"`
+	Jumlah       float64        `json:"jumlah" gorm:"not null;type:decimal(15,2)"`
+	Status       AngsuranStatus `json:"status" gorm:"default:'terjadwal';type:enum('terjadwal','dipotong','dilewati','dilunasi');index"`
+	GajiID       *uint          `json:"gaji_id" gorm:"index"`
+	Keterangan   string         `json:"keterangan" gorm:"size:255"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	Kasbon       *Kasbon        `json:"kasbon,omitempty" gorm:"foreignKey:KasbonID"`
+}
+
+// TableName specifies the table name for AngsuranKasbon model
+func (AngsuranKasbon) TableName() string {
+	return "angsuran_kasbon"
+}
+
+// ToItem returns the deduction line item that collects the installment. Kasbon must be loaded.
+func (a *AngsuranKasbon) ToItem() GajiItem {
+	id := a.ID
+	keterangan := fmt.Sprintf("Cicilan ke-%d periode %02d/%d", a.Ke, a.PeriodeBulan, a.PeriodeTahun)
+	if a.Kasbon != nil {
+		keterangan = fmt.Sprintf("Cicilan ke-%d kasbon %s", a.Ke, a.Kasbon.Tanggal.Format("02/01/2006"))
+	}
+	return GajiItem{
+		AngsuranKasbonID: &id,
+		Kode:             KodeKasbon,
+		Nama:             "Cicilan Kasbon",
+		Jenis:            JenisPotongan,
+		Jumlah:           a.Jumlah,
+		Keterangan:       keterangan,
+	}
+}
+
+// SaldoKasbon summarizes the repayment of one kasbon
+type SaldoKasbon struct {
+	KasbonID        uint         `json:"kasbon_id"`
+	KaryawanID      uint         `json:"karyawan_id"`
+	NIK             string       `json:"nik"`
+	NamaKaryawan    string       `json:"nama_karyawan"`
+	Tanggal         time.Time    `json:"tanggal"`
+	Pokok           float64      `json:"pokok"`
+	Terbayar        float64      `json:"terbayar"`
+	Sisa            float64      `json:"sisa"`
+	CicilanTersisa  int          `json:"cicilan_tersisa"`
+	AngsuranBerikut *string      `json:"angsuran_berikut"`
+	Status          KasbonStatus `json:"status"`
+}
+
+// Saldo summarizes the kasbon's repayment from its installments
+func (k *Kasbon) Saldo() SaldoKasbon {
+	saldo := SaldoKasbon{
+		KasbonID:     k.ID,
+		KaryawanID:   k.KaryawanID,
+		NIK:          k.Karyawan.NIK,
+		NamaKaryawan: k.Karyawan.Nama,
+		Tanggal:      k.Tanggal,
+		Pokok:        k.Pokok,
+		Status:       k.Status,
+	}
+
+	berikut := 0
+	for _, a := range k.Angsuran {
+		switch a.Status {
+		case AngsuranDipotong, AngsuranDilunasi:
+			saldo.Terbayar += a.Jumlah
+		case AngsuranTerjadwal:
+			saldo.CicilanTersisa++
+			periode := a.PeriodeTahun*12 + a.PeriodeBulan
+			if berikut == 0 || periode < berikut {
+				berikut = periode
+				s := fmt.Sprintf("%02d/%d", a.PeriodeBulan, a.PeriodeTahun)
+				saldo.AngsuranBerikut = &s
+			}
+		}
+	}
+	saldo.Sisa = k.Pokok - saldo.Terbayar
+	return saldo
+}
+
+// PeriodeBerikutnya returns the month after bulan/tahun
+func PeriodeBerikutnya(bulan, tahun int) (int, int) {
+	if bulan == 12 {
+		return 1, tahun + 1
+	}
+	return bulan + 1, tahun
+}
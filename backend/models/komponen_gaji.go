@@ -0,0 +1,124 @@
+package models
+
+import (
+	"time"
+)
+
+// JenisKomponen represents whether a salary component adds to or deducts from pay
+type JenisKomponen string
+
+const (
+	JenisPendapatan JenisKomponen = "pendapatan"
+	JenisPotongan   JenisKomponen = "potongan"
+)
+
+// TipeFormula represents how a salary component amount is computed
+type TipeFormula string
+
+const (
+	FormulaTetap       TipeFormula = "tetap"        // fixed amount
+	FormulaPersenPokok TipeFormula = "persen_pokok" // percent of gaji pokok
+	FormulaPerHadir    TipeFormula = "per_hadir"    // amount per hadir day
+	FormulaPerJabatan  TipeFormula = "per_jabatan"  // amount set per jabatan
+)
+
+// Kode of the built-in line items generated by the system
+const (
+	KodeGajiPokok          = "GAJI_POKOK"
+	KodeTunjanganJabatan   = "TUNJ_JABATAN"
+	KodeTunjanganTransport = "TUNJ_TRANSPORT"
+	KodeTunjanganMakan     = "TUNJ_MAKAN"
+	KodeLembur             = "LEMBUR"
+	KodePotongan           = "POTONGAN"
+	KodePPh21              = "PPH21"
+	KodeBPJSKesehatan      = "BPJS_KES"
+	KodeBPJSJHT            = "BPJS_JHT"
+	KodeBPJSJP             = "BPJS_JP"
+	KodeKoreksi            = "KOREKSI"
+	KodeRapel              = "RAPEL"
+	KodeTHR                = "THR"
+	KodeGajiKe13           = "GAJI_13"
+	KodeBonus              = "BONUS"
+	KodeKasbon             = "KASBON"
+	KodePotonganAlpha      = "POT_ALPHA"
+	KodeDendaTerlambat     = "DENDA_TERLAMBAT"
+)
+
+// IsKodeSistem checks whether a kode is reserved for built-in line items
+func IsKodeSistem(kode string) bool {
+	switch kode {
+	case KodeGajiPokok, KodeTunjanganJabatan, KodeTunjanganTransport, KodeTunjanganMakan,
+		KodeLembur, KodePotongan, KodePPh21, KodeBPJSKesehatan, KodeBPJSJHT, KodeBPJSJP, KodeKoreksi, KodeRapel,
+		KodeTHR, KodeGajiKe13, KodeBonus, KodeKasbon, KodePotonganAlpha, KodeDendaTerlambat:
+		return true
+	}
+	return false
+}
+
+// KomponenGaji represents an admin-defined earning or deduction component
+type KomponenGaji struct {
+	ID           uint               `json:"id" gorm:"primaryKey"`
+	Kode         string             `json:"kode" gorm:"unique;not null;size:30"`
+	Nama         string             `json:"nama" gorm:"not null;size:100"`
+	Jenis        JenisKomponen      `json:"jenis" gorm:"not null;type:enum('pendapatan','potongan')"`
+	TipeFormula  TipeFormula        `json:"tipe_formula" gorm:"not null;type:enum('tetap','persen_pokok','per_hadir','per_jabatan')"`
+	Nilai        float64            `json:"nilai" gorm:"default:0;type:decimal(15,2)"`
+	BerlakuSemua bool               `json:"berlaku_semua" gorm:"not null"`
+	Aktif        bool               `json:"aktif" gorm:"not null"`
+	Urutan       int                `json:"urutan" gorm:"default:0"`
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+	NilaiJabatan []KomponenJabatan  `json:"nilai_jabatan,omitempty" gorm:"foreignKey:KomponenGajiID"`
+	Karyawan     []KomponenKaryawan `json:"karyawan,omitempty" gorm:"foreignKey:KomponenGajiID"`
+}
+
+// TableName specifies the table name for KomponenGaji model
+func (KomponenGaji) TableName() string {
+	return "komponen_gaji"
+}
+
+// KomponenJabatan holds the amount of a per_jabatan component for one jabatan
+type KomponenJabatan struct {
+	ID             uint    `json:"id" gorm:"primaryKey"`
+	KomponenGajiID uint    `json:"komponen_gaji_id" gorm:"not null;index"`
+	JabatanID      uint    `json:"jabatan_id" gorm:"not null;index"`
+	Nilai          float64 `json:"nilai" gorm:"not null;type:decimal(15,2)"`
+}
+
+// TableName specifies the table name for KomponenJabatan model
+func (KomponenJabatan) TableName() string {
+	return "komponen_jabatan"
+}
+
+// KomponenKaryawan assigns a component to a karyawan when it does not apply
+// to everyone, optionally overriding the component's nilai
+type KomponenKaryawan struct {
+	ID             uint     `json:"id" gorm:"primaryKey"`
+	KomponenGajiID uint     `json:"komponen_gaji_id" gorm:"not null;index"`
+	KaryawanID     uint     `json:"karyawan_id" gorm:"not null;index"`
+	Nilai          *float64 `json:"nilai" gorm:"type:decimal(15,2)"`
+}
+
+// TableName specifies the table name for KomponenKaryawan model
+func (KomponenKaryawan) TableName() string {
+	return "komponen_karyawan"
+}
+
+// GajiItem represents one earning or deduction line on a salary slip
+type GajiItem struct {
+	ID               uint          `json:"id" gorm:"primaryKey"`
+	GajiID           uint          `json:"gaji_id" gorm:"not null;index"`
+	KomponenGajiID   *uint         `json:"komponen_gaji_id"`
+	KoreksiGajiID    *uint         `json:"koreksi_gaji_id"`
+	AngsuranKasbonID *uint         `json:"angsuran_kasbon_id"`
+	Kode             string        `json:"kode" gorm:"not null;size:30"`
+	Nama             string        `json:"nama" gorm:"not null;size:100"`
+	Jenis            JenisKomponen `json:"jenis" gorm:"not null;type:enum('pendapatan','potongan')"`
+	Jumlah           float64       `json:"jumlah" gorm:"not null;type:decimal(15,2)"`
+	Keterangan       string        `json:"keterangan" gorm:"size:255"`
+}
+
+// TableName specifies the table name for GajiItem model
+func (GajiItem) TableName() string {
+	return "gaji_item"
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// StatusKoreksiAbsensi represents the approval state of an attendance correction request
+type StatusKoreksiAbsensi string
+
+const (
+	KoreksiAbsensiDiajukan   StatusKoreksiAbsensi = "diajukan"   // waiting for the Sekdes or Kades
+	KoreksiAbsensiDisetujui  StatusKoreksiAbsensi = "disetujui"  // applied to the absensi row
+	KoreksiAbsensiDitolak    StatusKoreksiAbsensi = "ditolak"    // rejected
+	KoreksiAbsensiDibatalkan StatusKoreksiAbsensi = "dibatalkan" // withdrawn before a decision
+)
+
+// PengajuanKoreksiAbsensi is a request to correct the absensi of a karyawan on one day,
+// e.g. after forgetting to clock out. Empty fields keep the recorded value. The absensi
+// row is created on approval when the day has none.
+type PengajuanKoreksiAbsensi struct {
+	ID               uint                 `json:"id" gorm:"primaryKey"`
+	KaryawanID       uint                 `json:"karyawan_id" gorm:"not null;index"`
+	Tanggal          time.Time            `json:"tanggal" gorm:"type:date;not null;index"`
+	AbsensiID        *uint                `json:"absensi_id" gorm:"index"`
+	JamMasuk         string               `json:"jam_masuk" gorm:"size:10"`
+	JamKeluar        string               `json:"jam_keluar" gorm:"size:10"`
+	StatusAbsensi    AbsensiStatus        `json:"status_absensi" gorm:"size:10"`
+	Alasan           string               `json:"alasan" gorm:"type:text;not null"`
+	Lampiran         string               `json:"lampiran" gorm:"size:255"`
+	Status           StatusKoreksiAbsensi `json:"status" gorm:"default:'diajukan';type:enum('diajukan','disetujui','ditolak','dibatalkan');index"`
+	DiajukanOleh     *uint                `json:"diajukan_oleh"`
+	DiputuskanOleh   *uint                `json:"diputuskan_oleh"`
+	DiputuskanPada   *time.Time           `json:"diputuskan_pada"`
+	CatatanKeputusan string               `json:"catatan_keputusan" gorm:"type:text"`
+	CreatedAt        time.Time            `json:"created_at"`
+	UpdatedAt        time.Time            `json:"updated_at"`
+	Karyawan         Karyawan             `json:"karyawan,omitempty" gorm:"foreignKey:KaryawanID"`
+}
+
+// TableName specifies the table name for PengajuanKoreksiAbsensi model
+func (PengajuanKoreksiAbsensi) TableName() string {
+	return "pengajuan_koreksi_absensi"
+}
+
+// RiwayatAbsensi keeps the values of an absensi row before and after a change, made
+// through an approved correction request or edited by staff. A row created by a
+// correction has no status semula.
+type RiwayatAbsensi struct {
+	ID                 uint          `json:"id" gorm:"primaryKey"`
+	AbsensiID          uint          `json:"absensi_id" gorm:"not null;index"`
+	PengajuanKoreksiID *uint         `json:"pengajuan_koreksi_id" gorm:"index"`
+	JamMasukSemula     string        `json:"jam_masuk_semula" gorm:"size:10"`
+	JamKeluarSemula    string        `json:"jam_keluar_semula" gorm:"size:10"`
+	StatusSemula       AbsensiStatus `json:"status_semula" gorm:"size:10"`
+	SumberSemula       string        `json:"sumber_semula" gorm:"size:20"`
+	JamMasuk           string        `json:"jam_masuk" gorm:"size:10"`
+	JamKeluar          string        `json:"jam_keluar" gorm:"size:10"`
+	Status             AbsensiStatus `json:"status" gorm:"size:10"`
+	Alasan             string        `json:"alasan" gorm:"type:text"`
+	DiubahOleh         *uint         `json:"diubah_oleh"`
+	CreatedAt          time.Time     `json:"created_at"`
+}
+
+// TableName specifies the table name for RiwayatAbsensi model
+func (RiwayatAbsensi) TableName() string {
+	return "riwayat_absensi"
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// KoreksiStatus represents whether a correction has been carried into a slip
+type KoreksiStatus string
+
+const (
+	KoreksiPending    KoreksiStatus = "pending"
+	KoreksiDiterapkan KoreksiStatus = "diterapkan"
+)
+
+// KoreksiGaji is a correction to a slip of an approved payroll run. The approved slip is
+// left untouched; the correction is added as a line item to the karyawan's next slip.
+// A correction of a lembur or absensi row paid by the slip refers to that row; a
+// correction of a paid day of attendance also records the day, which may have no row.
+type KoreksiGaji struct {
+	ID               uint          `json:"id" gorm:"primaryKey"`
+	GajiID           uint          `json:"gaji_id" gorm:"not null;index"`
+	KaryawanID       uint          `json:"karyawan_id" gorm:"not null;index"`
+	PeriodeBulan     int           `json:"periode_bulan" gorm:"not null"`
+	PeriodeTahun     int           `json:"periode_tahun" gorm:"not null"`
+	Nama             string        `json:"nama" gorm:"not null;size:100"`
+	Jenis            JenisKomponen `json:"jenis" gorm:"not null;type:enum('pendapatan','potongan')"`
+	Jumlah           float64       `json:"jumlah" gorm:"not null;type:decimal(15,2)"`
+	Alasan           string        `json:"alasan" gorm:"type:text;not null"`
+	Status           KoreksiStatus `json:"status" gorm:"default:'pending';type:enum('pending','diterapkan')"`
+	DiterapkanGajiID *uint         `json:"diterapkan_gaji_id" gorm:"index"`
+	LemburID         *uint         `json:"lembur_id,omitempty" gorm:"index"`
+	AbsensiID        *uint         `json:"absensi_id,omitempty" gorm:"index"`
+	TanggalAbsensi   *time.Time    `json:"tanggal_absensi,omitempty" gorm:"type:date;index"`
+	DibuatOleh       *uint         `json:"dibuat_oleh"`
+	CreatedAt        time.Time     `json:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at"`
+	Karyawan         Karyawan      `json:"karyawan,omitempty" gorm:"foreignKey:KaryawanID"`
+}
+
+// TableName specifies the table name for KoreksiGaji model
+func (KoreksiGaji) TableName() string {
+	return "koreksi_gaji"
+}
+
+// ToItem returns the line item that carries the correction into a slip
+func (k *KoreksiGaji) ToItem() GajiItem {
+	id := k.ID
+	return GajiItem{
+		KoreksiGajiID: &id,
+		Kode:          KodeKoreksi,
+		Nama:          k.Nama,
+		Jenis:         k.Jenis,
+		Jumlah:        k.Jumlah,
+		Keterangan:    fmt.Sprintf("Koreksi slip periode %02d/%d", k.PeriodeBulan, k.PeriodeTahun),
+	}
+}
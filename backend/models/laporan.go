@@ -1,34 +1,123 @@
 package models
 
-// LaporanGaji represents a salary report
+// LaporanGaji represents a salary report. The component columns are summaries
+// of the slip's line items, filled in by AddItem.
 type LaporanGaji struct {
-	ID                 uint    `json:"id"`
-	KaryawanID         uint    `json:"karyawan_id"`
-	NIK                string  `json:"nik"`
-	NamaKaryawan       string  `json:"nama_karyawan"`
-	Jabatan            string  `json:"jabatan"`
-	PeriodeBulan       int     `json:"periode_bulan"`
-	PeriodeTahun       int     `json:"periode_tahun"`
-	GajiPokok          float64 `json:"gaji_pokok"`
-	TunjanganJabatan   float64 `json:"tunjangan_jabatan"`
-	TunjanganTransport float64 `json:"tunjangan_transport"`
-	TunjanganMakan     float64 `json:"tunjangan_makan"`
-	Lembur             float64 `json:"lembur"`
-	Potongan           float64 `json:"potongan"`
-	TotalGaji          float64 `json:"total_gaji"`
-	Status             string  `json:"status"`
+	ID                 uint       `json:"id"`
+	KaryawanID         uint       `json:"karyawan_id"`
+	NIK                string     `json:"nik"`
+	NamaKaryawan       string     `json:"nama_karyawan"`
+	Jabatan            string     `json:"jabatan"`
+	PeriodeBulan       int        `json:"periode_bulan"`
+	PeriodeTahun       int        `json:"periode_tahun"`
+	Jenis              JenisGaji  `json:"jenis"`
+	GajiPokok          float64    `json:"gaji_pokok" gorm:"-"`
+	TunjanganJabatan   float64    `json:"tunjangan_jabatan" gorm:"-"`
+	TunjanganTransport float64    `json:"tunjangan_transport" gorm:"-"`
+	TunjanganMakan     float64    `json:"tunjangan_makan" gorm:"-"`
+	TunjanganLain      float64    `json:"tunjangan_lain" gorm:"-"`
+	Lembur             float64    `json:"lembur" gorm:"-"`
+	Potongan           float64    `json:"potongan" gorm:"-"`
+	PPh21              float64    `json:"pph21" gorm:"-"`
+	IuranKaryawan      float64    `json:"iuran_karyawan" gorm:"-"`
+	IuranPerusahaan    float64    `json:"iuran_perusahaan"`
+	TotalPendapatan    float64    `json:"total_pendapatan" gorm:"-"`
+	TotalPotongan      float64    `json:"total_potongan" gorm:"-"`
+	TotalGaji          float64    `json:"total_gaji"`
+	Status             string     `json:"status"`
+	Items              []GajiItem `json:"items" gorm:"-"`
+}
+
+// AddItem adds a slip line item to the report row and its summary columns
+func (l *LaporanGaji) AddItem(item GajiItem) {
+	l.Items = append(l.Items, item)
+
+	if item.Jenis == JenisPendapatan {
+		l.TotalPendapatan += item.Jumlah
+		switch item.Kode {
+		case KodeGajiPokok:
+			l.GajiPokok += item.Jumlah
+		case KodeTunjanganJabatan:
+			l.TunjanganJabatan += item.Jumlah
+		case KodeTunjanganTransport:
+			l.TunjanganTransport += item.Jumlah
+		case KodeTunjanganMakan:
+			l.TunjanganMakan += item.Jumlah
+		case KodeLembur:
+			l.Lembur += item.Jumlah
+		default:
+			l.TunjanganLain += item.Jumlah
+		}
+		return
+	}
+
+	l.TotalPotongan += item.Jumlah
+	switch item.Kode {
+	case KodePPh21:
+		l.PPh21 += item.Jumlah
+	case KodeBPJSKesehatan, KodeBPJSJHT, KodeBPJSJP:
+		l.IuranKaryawan += item.Jumlah
+	default:
+		l.Potongan += item.Jumlah
+	}
 }
 
 // RekapGaji represents salary recapitulation
 type RekapGaji struct {
-	PeriodeBulan    int     `json:"periode_bulan"`
-	PeriodeTahun    int     `json:"periode_tahun"`
-	TotalKaryawan   int     `json:"total_karyawan"`
-	TotalGajiPokok  float64 `json:"total_gaji_pokok"`
-	TotalTunjangan  float64 `json:"total_tunjangan"`
-	TotalLembur     float64 `json:"total_lembur"`
-	TotalPotongan   float64 `json:"total_potongan"`
-	TotalGaji       float64 `json:"total_gaji"`
-	StatusPending   int     `json:"status_pending"`
-	StatusDibayar   int     `json:"status_dibayar"`
+	PeriodeBulan         int       `json:"periode_bulan"`
+	PeriodeTahun         int       `json:"periode_tahun"`
+	Jenis                JenisGaji `json:"jenis" gorm:"-"`
+	TotalKaryawan        int       `json:"total_karyawan"`
+	TotalGajiPokok       float64   `json:"total_gaji_pokok" gorm:"-"`
+	TotalTunjangan       float64   `json:"total_tunjangan" gorm:"-"`
+	TotalLembur          float64   `json:"total_lembur" gorm:"-"`
+	TotalPotongan        float64   `json:"total_potongan" gorm:"-"`
+	TotalPPh21           float64   `json:"total_pph21" gorm:"-"`
+	TotalIuranKaryawan   float64   `json:"total_iuran_karyawan" gorm:"-"`
+	TotalIuranPerusahaan float64   `json:"total_iuran_perusahaan"`
+	TotalGaji            float64   `json:"total_gaji"`
+	StatusPending        int       `json:"status_pending"`
+	StatusDibayar        int       `json:"status_dibayar"`
+}
+
+// AddItemTotal adds the period total of one line item kode to the recap
+func (r *RekapGaji) AddItemTotal(kode string, jenis JenisKomponen, jumlah float64) {
+	if jenis == JenisPendapatan {
+		switch kode {
+		case KodeGajiPokok:
+			r.TotalGajiPokok += jumlah
+		case KodeLembur:
+			r.TotalLembur += jumlah
+		default:
+			r.TotalTunjangan += jumlah
+		}
+		return
+	}
+
+	switch kode {
+	case KodePPh21:
+		r.TotalPPh21 += jumlah
+	case KodeBPJSKesehatan, KodeBPJSJHT, KodeBPJSJP:
+		r.TotalIuranKaryawan += jumlah
+	default:
+		r.TotalPotongan += jumlah
+	}
+}
+
+// LaporanBPJS represents BPJS contributions per employee for reconciliation with BPJS invoices
+type LaporanBPJS struct {
+	KaryawanID              uint    `json:"karyawan_id"`
+	NIK                     string  `json:"nik"`
+	NamaKaryawan            string  `json:"nama_karyawan"`
+	PeriodeBulan            int     `json:"periode_bulan"`
+	PeriodeTahun            int     `json:"periode_tahun"`
+	UpahBPJS                float64 `json:"upah_bpjs" gorm:"column:upah_bpjs"`
+	BPJSKesehatanKaryawan   float64 `json:"bpjs_kesehatan_karyawan" gorm:"column:bpjs_kesehatan_karyawan"`
+	BPJSKesehatanPerusahaan float64 `json:"bpjs_kesehatan_perusahaan" gorm:"column:bpjs_kesehatan_perusahaan"`
+	JHTKaryawan             float64 `json:"jht_karyawan" gorm:"column:jht_karyawan"`
+	JHTPerusahaan           float64 `json:"jht_perusahaan" gorm:"column:jht_perusahaan"`
+	JKKPerusahaan           float64 `json:"jkk_perusahaan" gorm:"column:jkk_perusahaan"`
+	JKMPerusahaan           float64 `json:"jkm_perusahaan" gorm:"column:jkm_perusahaan"`
+	JPKaryawan              float64 `json:"jp_karyawan" gorm:"column:jp_karyawan"`
+	JPPerusahaan            float64 `json:"jp_perusahaan" gorm:"column:jp_perusahaan"`
 }
@@ -1,11 +1,25 @@
 package models
 
 import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// JenisHariLembur classifies the date of an overtime record for its multipliers
+type JenisHariLembur string
+
+const (
+	HariLemburKerja     JenisHariLembur = "hari_kerja"     // working day under the kalender kerja
+	HariLemburIstirahat JenisHariLembur = "hari_istirahat" // weekly day off, cuti bersama or libur desa
+	HariLemburLibur     JenisHariLembur = "hari_libur"     // public holiday
+)
+
 // Lembur represents overtime record
 type Lembur struct {
 	ID              uint       `json:"id" gorm:"primaryKey"`
@@ -13,12 +27,22 @@ type Lembur struct {
 	Tanggal         time.Time  `json:"tanggal" gorm:"type:date;not null"`
 	JamMulai        string     `json:"jam_mulai" gorm:"size:5"`
 	JamSelesai      string     `json:"jam_selesai" gorm:"size:5"`
-	TotalJam        float64    `json:"total_jam" gorm:"not null"`
+	IstirahatMenit  int        `json:"istirahat_menit" gorm:"default:0"` // break not counted as overtime
+	TotalJam        float64    `json:"total_jam" gorm:"not null"`        // derived from the times, see HitungTotalJam
 	TarifPerJam     float64    `json:"tarif_per_jam" gorm:"not null"`
 	TotalNominal    float64    `json:"total_nominal" gorm:"not null"`
+	JenisHari       JenisHariLembur `json:"jenis_hari" gorm:"size:20"`
+	Rincian         []RincianLembur `json:"rincian,omitempty" gorm:"foreignKey:LemburID"`
+	Peringatan      []string        `json:"peringatan,omitempty" gorm:"-"` // caps or budget exceeded when not blocked
 	Keterangan      string     `json:"keterangan" gorm:"type:text"`
+	SuratPerintahID *uint      `json:"surat_perintah_id" gorm:"index"` // the order the hours are reported against
+	GajiID          *uint      `json:"gaji_id" gorm:"index"`           // the slip that paid the overtime, after which the record is read-only
+	DihitungGajiID  *uint      `json:"dihitung_gaji_id" gorm:"index"`  // the slip whose lembur line counts the overtime, copied to GajiID when its run is approved
 	Status          string     `json:"status" gorm:"default:'pending';type:enum('pending','disetujui','ditolak')"`
-	DisetujuiOleh   *uint      `json:"disetujui_olej"`
+	DisetujuiOleh   *uint      `json:"disetujui_olej"` // karyawan, only on records decided before approvals were tied to users
+	DiajukanOleh    *uint      `json:"diajukan_oleh"`
+	DiputuskanOleh  *uint      `json:"diputuskan_oleh"`
+	DiputuskanPada  *time.Time `json:"diputuskan_pada"`
 	CreatedAt       time.Time  `json:"created_at"`
 	UpdatedAt       time.Time  `json:"updated_at"`
 	Karyawan        Karyawan   `json:"karyawan,omitempty" gorm:"foreignKey:KaryawanID"`
@@ -30,22 +54,138 @@ func (Lembur) TableName() string {
 	return "lembur"
 }
 
-// BeforeCreate hook for validation
+// BeforeCreate hook to total the nominal of the multiplier tiers
 func (l *Lembur) BeforeCreate(tx *gorm.DB) error {
-	if l.TotalJam <= 0 {
-		l.TotalNominal = 0
-	} else {
-		l.TotalNominal = l.TotalJam * l.TarifPerJam
-	}
+	l.TotalNominal = l.HitungNominal()
 	return nil
 }
 
-// BeforeUpdate hook to recalculate total
-func (l *Lembur) BeforeUpdate(tx *gorm.DB) error {
+// HitungNominal totals the nominal of the multiplier tiers. Records without tiers, made
+// before multipliers were applied, pay TotalJam at the flat rate.
+func (l *Lembur) HitungNominal() float64 {
 	if l.TotalJam <= 0 {
-		l.TotalNominal = 0
-	} else {
-		l.TotalNominal = l.TotalJam * l.TarifPerJam
+		return 0
 	}
-	return nil
+	if len(l.Rincian) == 0 {
+		return l.TotalJam * l.TarifPerJam
+	}
+	total := 0.0
+	for _, r := range l.Rincian {
+		total += r.Nominal
+	}
+	return total
+}
+
+// NominalDenganTarif returns what the overtime pays at another hourly rate with the same
+// multipliers
+func (l *Lembur) NominalDenganTarif(tarif float64) float64 {
+	if len(l.Rincian) == 0 {
+		return l.TotalJam * tarif
+	}
+	total := 0.0
+	for _, r := range l.Rincian {
+		total += r.Jam * r.Pengali * tarif
+	}
+	return total
+}
+
+// RincianLembur is one multiplier tier of an overtime record: Jam hours paid at Pengali
+// times the hourly rate
+type RincianLembur struct {
+	ID       uint    `json:"id" gorm:"primaryKey"`
+	LemburID uint    `json:"lembur_id" gorm:"not null;index"`
+	Urutan   int     `json:"urutan"`
+	Jam      float64 `json:"jam"`
+	Pengali  float64 `json:"pengali" gorm:"type:decimal(5,2)"`
+	Nominal  float64 `json:"nominal" gorm:"type:decimal(15,2)"`
+}
+
+// TableName specifies the table name for RincianLembur model
+func (RincianLembur) TableName() string {
+	return "rincian_lembur"
+}
+
+// KeteranganRincianLembur sums tiers by multiplier for the lembur line of a slip, e.g.
+// "2 jam x1.5, 6.5 jam x2"
+func KeteranganRincianLembur(rincian []RincianLembur) string {
+	perPengali := make(map[float64]float64)
+	for _, r := range rincian {
+		perPengali[r.Pengali] += r.Jam
+	}
+	pengali := make([]float64, 0, len(perPengali))
+	for p := range perPengali {
+		pengali = append(pengali, p)
+	}
+	sort.Float64s(pengali)
+
+	bagian := make([]string, 0, len(pengali))
+	for _, p := range pengali {
+		bagian = append(bagian, fmt.Sprintf("%s jam x%s",
+			strconv.FormatFloat(math.Round(perPengali[p]*100)/100, 'f', -1, 64), strconv.FormatFloat(p, 'f', -1, 64)))
+	}
+	return strings.Join(bagian, ", ")
+}
+
+// Rentang returns the start and end of the overtime. A jam selesai not after jam mulai
+// is taken as the next day.
+func (l *Lembur) Rentang() (time.Time, time.Time, bool) {
+	mulai, ok := ParseJam(l.JamMulai)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	selesai, ok := ParseJam(l.JamSelesai)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	if selesai <= mulai {
+		selesai += 24 * 60
+	}
+	hari := time.Date(l.Tanggal.Year(), l.Tanggal.Month(), l.Tanggal.Day(), 0, 0, 0, 0, time.UTC)
+	return hari.Add(time.Duration(mulai) * time.Minute), hari.Add(time.Duration(selesai) * time.Minute), true
+}
+
+// HitungTotalJam derives TotalJam from jam mulai and jam selesai less the break, rounded
+// to two decimals. It also writes the times as HH:MM. It returns an error message, or ""
+// if the times are valid; equal times are rejected rather than read as a full day.
+func (l *Lembur) HitungTotalJam() string {
+	if l.JamMulai == "" || l.JamSelesai == "" {
+		return "Jam mulai and jam selesai are required"
+	}
+	mulai, selesai, ok := l.Rentang()
+	if !ok {
+		return "Invalid jam_mulai or jam_selesai format. Use HH:MM"
+	}
+	if selesai.Sub(mulai) >= 24*time.Hour {
+		return "Jam selesai must differ from jam mulai"
+	}
+	if l.IstirahatMenit < 0 {
+		return "Istirahat menit must not be negative"
+	}
+	menit := int(selesai.Sub(mulai).Minutes()) - l.IstirahatMenit
+	if menit <= 0 {
+		return "Istirahat must be shorter than the overtime"
+	}
+	l.JamMulai = mulai.Format("15:04")
+	l.JamSelesai = selesai.Format("15:04")
+	l.TotalJam = math.Round(float64(menit)/60*100) / 100
+	return ""
+}
+
+// JamKerjaReguler returns the official working time of a day under its schedule, or
+// false on a day off
+func JamKerjaReguler(tanggal time.Time, j JadwalKerja) (time.Time, time.Time, bool) {
+	if j.Libur {
+		return time.Time{}, time.Time{}, false
+	}
+	masuk, ok := ParseJam(j.JamMasuk)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	keluar, ok := ParseJam(j.JamKeluar)
+	if !ok || keluar <= masuk {
+		return time.Time{}, time.Time{}, false
+	}
+	hari := time.Date(tanggal.Year(), tanggal.Month(), tanggal.Day(), 0, 0, 0, 0, time.UTC)
+	return hari.Add(time.Duration(masuk) * time.Minute), hari.Add(time.Duration(keluar) * time.Minute), true
 }
+
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PayrollRunStatus represents the lifecycle state of a payroll run
+type PayrollRunStatus string
+
+const (
+	PayrollRunDraft    PayrollRunStatus = "draft"
+	PayrollRunReviewed PayrollRunStatus = "reviewed"
+	PayrollRunApproved PayrollRunStatus = "approved"
+	PayrollRunPaid     PayrollRunStatus = "paid"
+	PayrollRunLocked   PayrollRunStatus = "locked"
+)
+
+// payrollRunTransitions lists the states each state may move to
+var payrollRunTransitions = map[PayrollRunStatus][]PayrollRunStatus{
+	PayrollRunDraft:    {PayrollRunReviewed},
+	PayrollRunReviewed: {PayrollRunDraft, PayrollRunApproved},
+	PayrollRunApproved: {PayrollRunPaid},
+	PayrollRunPaid:     {PayrollRunLocked},
+}
+
+// PayrollRun groups the salary slips of one period and run type and tracks their approval
+type PayrollRun struct {
+	ID            uint             `json:"id" gorm:"primaryKey"`
+	PeriodeBulan  int              `json:"periode_bulan" gorm:"not null"`
+	PeriodeTahun  int              `json:"periode_tahun" gorm:"not null"`
+	Jenis         JenisGaji        `json:"jenis" gorm:"default:'reguler';size:20;index"`
+	Status        PayrollRunStatus `json:"status" gorm:"default:'draft';type:enum('draft','reviewed','approved','paid','locked')"`
+	Catatan       string           `json:"catatan" gorm:"type:text"`
+	DibuatOleh    *uint            `json:"dibuat_oleh"`
+	DireviewOleh  *uint            `json:"direview_oleh"`
+	DireviewPada  *time.Time       `json:"direview_pada"`
+	DisetujuiOleh *uint            `json:"disetujui_oleh"`
+	DisetujuiPada *time.Time       `json:"disetujui_pada"`
+	DibayarPada   *time.Time       `json:"dibayar_pada"`
+	DikunciPada   *time.Time       `json:"dikunci_pada"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+	JumlahSlip    int              `json:"jumlah_slip" gorm:"-"`
+	TotalGaji     float64          `json:"total_gaji" gorm:"-"`
+	Penyetuju     *User            `json:"penyetuju,omitempty" gorm:"foreignKey:DisetujuiOleh"`
+	Gaji          []Gaji           `json:"gaji,omitempty" gorm:"foreignKey:PayrollRunID"`
+}
+
+// TableName specifies the table name for PayrollRun model
+func (PayrollRun) TableName() string {
+	return "payroll_run"
+}
+
+// BeforeCreate hook to ensure one payroll run per period and run type
+func (r *PayrollRun) BeforeCreate(tx *gorm.DB) error {
+	if r.Jenis == "" {
+		r.Jenis = JenisGajiReguler
+	}
+
+	var existing PayrollRun
+	err := tx.Where("periode_bulan = ? AND periode_tahun = ? AND jenis = ?", r.PeriodeBulan, r.PeriodeTahun, r.Jenis).First(&existing).Error
+	if err == nil {
+		return gorm.ErrDuplicatedKey
+	}
+	return nil
+}
+
+// IsEditable reports whether the run's slips may still be created, changed or deleted
+func (r *PayrollRun) IsEditable() bool {
+	return r.Status == PayrollRunDraft
+}
+
+// IsFinal reports whether the run has been approved, after which its slips and the
+// lembur and absensi they were computed from are immutable
+func (r *PayrollRun) IsFinal() bool {
+	return r.Status == PayrollRunApproved || r.Status == PayrollRunPaid || r.Status == PayrollRunLocked
+}
+
+// CanTransition checks whether the run may move to the given status
+func (r *PayrollRun) CanTransition(to PayrollRunStatus) bool {
+	for _, s := range payrollRunTransitions[r.Status] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// JenisPresensi tells whether an attempt clocks in or out. A QR scan leaves it to the
+// absensi of the day.
+type JenisPresensi string
+
+const (
+	PresensiMasuk  JenisPresensi = "masuk"
+	PresensiKeluar JenisPresensi = "keluar"
+)
+
+// LogPresensi records every clock-in and clock-out attempt, accepted or not, with the
+// server time, the reported location and the device it came from
+type LogPresensi struct {
+	ID         uint          `json:"id" gorm:"primaryKey"`
+	UserID     *uint         `json:"user_id" gorm:"index"`
+	KaryawanID *uint         `json:"karyawan_id" gorm:"index"`
+	Jenis      JenisPresensi `json:"jenis" gorm:"size:10"`
+	Metode     string        `json:"metode" gorm:"size:20"` // same values as Absensi.Sumber
+	Waktu      time.Time     `json:"waktu" gorm:"not null;index"`
+	Latitude   *float64      `json:"latitude"`
+	Longitude  *float64      `json:"longitude"`
+	JarakMeter *float64      `json:"jarak_meter"` // distance to the kantor desa
+	Diterima   bool          `json:"diterima" gorm:"index"`
+	Alasan     string        `json:"alasan" gorm:"size:255"` // why the attempt was rejected
+	AbsensiID  *uint         `json:"absensi_id"`
+	IPAddress  string        `json:"ip_address" gorm:"size:45"`
+	UserAgent  string        `json:"user_agent" gorm:"size:255"`
+	Perangkat  string        `json:"perangkat" gorm:"size:100"` // device name or id sent by the client
+	Kiosk      string        `json:"kiosk" gorm:"size:50"`      // kiosk screen of a scanned QR token
+	CreatedAt  time.Time     `json:"created_at"`
+	Karyawan   *Karyawan     `json:"karyawan,omitempty" gorm:"foreignKey:KaryawanID"`
+}
+
+// TableName specifies the table name for LogPresensi model
+func (LogPresensi) TableName() string {
+	return "log_presensi"
+}
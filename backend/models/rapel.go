@@ -0,0 +1,106 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// RapelStatus represents whether a back pay has been added to its payroll period
+type RapelStatus string
+
+const (
+	RapelDraft      RapelStatus = "draft"
+	RapelDiterapkan RapelStatus = "diterapkan"
+)
+
+// RapelMode represents how a back pay is paid out
+type RapelMode string
+
+const (
+	RapelModeItem RapelMode = "item" // a RAPEL line on the karyawan's regular slip
+	RapelModeSlip RapelMode = "slip" // a separate rapel slip
+)
+
+// Rapel is the back pay owed after jabatan rates were raised with retroactive effect.
+// Slips of past periods are recomputed under the rates now valid for them and the
+// differences are paid in a later payroll period; the past slips are left untouched.
+type Rapel struct {
+	ID             uint          `json:"id" gorm:"primaryKey"`
+	Keterangan     string        `json:"keterangan" gorm:"size:255"`
+	DariBulan      int           `json:"dari_bulan" gorm:"not null"`
+	DariTahun      int           `json:"dari_tahun" gorm:"not null"`
+	SampaiBulan    int           `json:"sampai_bulan" gorm:"not null"`
+	SampaiTahun    int           `json:"sampai_tahun" gorm:"not null"`
+	JabatanID      *uint         `json:"jabatan_id"`
+	KaryawanID     *uint         `json:"karyawan_id"`
+	PeriodeBulan   int           `json:"periode_bulan" gorm:"not null"`
+	PeriodeTahun   int           `json:"periode_tahun" gorm:"not null"`
+	Mode           RapelMode     `json:"mode" gorm:"not null;type:enum('item','slip')"`
+	Status         RapelStatus   `json:"status" gorm:"default:'draft';type:enum('draft','diterapkan')"`
+	TotalRapel     float64       `json:"total_rapel" gorm:"default:0;type:decimal(15,2)"`
+	DibuatOleh     *uint         `json:"dibuat_oleh"`
+	DiterapkanPada *time.Time    `json:"diterapkan_pada"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	Detail         []RapelDetail `json:"detail,omitempty" gorm:"foreignKey:RapelID"`
+}
+
+// TableName specifies the table name for Rapel model
+func (Rapel) TableName() string {
+	return "rapel"
+}
+
+// RapelDetail is the difference for one past slip. The Lama amounts are those paid so
+// far, including earlier rapel; the Baru amounts are those under the current rates.
+type RapelDetail struct {
+	ID                   uint      `json:"id" gorm:"primaryKey"`
+	RapelID              uint      `json:"rapel_id" gorm:"not null;index"`
+	KaryawanID           uint      `json:"karyawan_id" gorm:"not null;index"`
+	GajiID               uint      `json:"gaji_id" gorm:"not null;index"`
+	PeriodeBulan         int       `json:"periode_bulan" gorm:"not null"`
+	PeriodeTahun         int       `json:"periode_tahun" gorm:"not null"`
+	GajiPokokLama        float64   `json:"gaji_pokok_lama" gorm:"default:0;type:decimal(15,2)"`
+	GajiPokokBaru        float64   `json:"gaji_pokok_baru" gorm:"default:0;type:decimal(15,2)"`
+	TunjanganJabatanLama float64   `json:"tunjangan_jabatan_lama" gorm:"default:0;type:decimal(15,2)"`
+	TunjanganJabatanBaru float64   `json:"tunjangan_jabatan_baru" gorm:"default:0;type:decimal(15,2)"`
+	LemburLama           float64   `json:"lembur_lama" gorm:"default:0;type:decimal(15,2)"`
+	LemburBaru           float64   `json:"lembur_baru" gorm:"default:0;type:decimal(15,2)"`
+	KomponenLama         float64   `json:"komponen_lama" gorm:"default:0;type:decimal(15,2)"`
+	KomponenBaru         float64   `json:"komponen_baru" gorm:"default:0;type:decimal(15,2)"`
+	Selisih              float64   `json:"selisih" gorm:"not null;type:decimal(15,2)"`
+	DiterapkanGajiID     *uint     `json:"diterapkan_gaji_id" gorm:"index"`
+	CreatedAt            time.Time `json:"created_at"`
+	Karyawan             Karyawan  `json:"karyawan,omitempty" gorm:"foreignKey:KaryawanID"`
+}
+
+// TableName specifies the table name for RapelDetail model
+func (RapelDetail) TableName() string {
+	return "rapel_detail"
+}
+
+// ToItem returns the line item that pays the difference; a negative difference is
+// deducted
+func (d *RapelDetail) ToItem() GajiItem {
+	jenis := JenisPendapatan
+	if d.Selisih < 0 {
+		jenis = JenisPotongan
+	}
+	return GajiItem{
+		Kode:       KodeRapel,
+		Nama:       "Rapel Gaji",
+		Jenis:      jenis,
+		Jumlah:     math.Abs(d.Selisih),
+		Keterangan: fmt.Sprintf("Rapel periode %02d/%d", d.PeriodeBulan, d.PeriodeTahun),
+	}
+}
+
+// RapelKaryawan sums the differences of one karyawan over the rapel range
+type RapelKaryawan struct {
+	KaryawanID   uint    `json:"karyawan_id"`
+	NIK          string  `json:"nik"`
+	NamaKaryawan string  `json:"nama_karyawan"`
+	Jabatan      string  `json:"jabatan"`
+	JumlahBulan  int     `json:"jumlah_bulan"`
+	TotalRapel   float64 `json:"total_rapel"`
+}
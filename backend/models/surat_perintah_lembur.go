@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// StatusSuratPerintahLembur represents the approval state of an overtime order
+type StatusSuratPerintahLembur string
+
+const (
+	SuratPerintahLemburDiajukan   StatusSuratPerintahLembur = "diajukan"   // waiting for the Sekdes or Kades
+	SuratPerintahLemburDisetujui  StatusSuratPerintahLembur = "disetujui"  // issued, actual hours may be reported against it
+	SuratPerintahLemburDitolak    StatusSuratPerintahLembur = "ditolak"    // rejected
+	SuratPerintahLemburDibatalkan StatusSuratPerintahLembur = "dibatalkan" // withdrawn before any hours were reported
+)
+
+// SuratPerintahLembur is an overtime order issued before the work: the karyawan ordered
+// to work overtime on one day, the task and the planned hours. Each karyawan reports the
+// actual hours as a Lembur record against the approved order.
+type SuratPerintahLembur struct {
+	ID               uint                      `json:"id" gorm:"primaryKey"`
+	Tanggal          time.Time                 `json:"tanggal" gorm:"type:date;not null;index"`
+	JamMulai         string                    `json:"jam_mulai" gorm:"size:5"`
+	JamSelesai       string                    `json:"jam_selesai" gorm:"size:5"`
+	RencanaJam       float64                   `json:"rencana_jam" gorm:"not null"` // planned hours per karyawan
+	Tugas            string                    `json:"tugas" gorm:"type:text;not null"`
+	Status           StatusSuratPerintahLembur `json:"status" gorm:"default:'diajukan';type:enum('diajukan','disetujui','ditolak','dibatalkan');index"`
+	DiajukanOleh     *uint                     `json:"diajukan_oleh"`
+	DiputuskanOleh   *uint                     `json:"diputuskan_oleh"`
+	DiputuskanPada   *time.Time                `json:"diputuskan_pada"`
+	CatatanKeputusan string                    `json:"catatan_keputusan" gorm:"type:text"`
+	CreatedAt        time.Time                 `json:"created_at"`
+	UpdatedAt        time.Time                 `json:"updated_at"`
+	Karyawan         []Karyawan                `json:"karyawan" gorm:"many2many:surat_perintah_lembur_karyawan"`
+	Realisasi        []Lembur                  `json:"realisasi,omitempty" gorm:"foreignKey:SuratPerintahID"`
+}
+
+// TableName specifies the table name for SuratPerintahLembur model
+func (SuratPerintahLembur) TableName() string {
+	return "surat_perintah_lembur"
+}
+
+// Mencakup reports whether the order names the karyawan
+func (s *SuratPerintahLembur) Mencakup(karyawanID uint) bool {
+	for _, k := range s.Karyawan {
+		if k.ID == karyawanID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TarifJabatan holds the salary scale of a jabatan from a given date, so that past
+// periods keep using the rates that were valid then and raises can be entered ahead of time
+type TarifJabatan struct {
+	ID                uint      `json:"id" gorm:"primaryKey"`
+	JabatanID         uint      `json:"jabatan_id" gorm:"not null;index"`
+	BerlakuMulai      time.Time `json:"berlaku_mulai" gorm:"type:date;not null;index"`
+	GajiPokok         float64   `json:"gaji_pokok" gorm:"not null;type:decimal(15,2)"`
+	TunjanganJabatan  float64   `json:"tunjangan_jabatan" gorm:"default:0;type:decimal(15,2)"`
+	TarifLemburPerJam float64   `json:"tarif_lembur_per_jam" gorm:"default:0;type:decimal(15,2)"`
+	Keterangan        string    `json:"keterangan" gorm:"size:255"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for TarifJabatan model
+func (TarifJabatan) TableName() string {
+	return "tarif_jabatan"
+}
+
+// BeforeCreate hook to ensure one rate per jabatan per effective date
+func (t *TarifJabatan) BeforeCreate(tx *gorm.DB) error {
+	var existing TarifJabatan
+	err := tx.Where("jabatan_id = ? AND berlaku_mulai = ?", t.JabatanID, t.BerlakuMulai).First(&existing).Error
+	if err == nil {
+		return gorm.ErrDuplicatedKey
+	}
+	return nil
+}
+
+// TarifDari returns the jabatan's own rate columns as a TarifJabatan, used when a
+// jabatan has no rate history
+func TarifDari(j *Jabatan) *TarifJabatan {
+	return &TarifJabatan{
+		JabatanID:         j.ID,
+		GajiPokok:         j.GajiPokok,
+		TunjanganJabatan:  j.TunjanganJabatan,
+		TarifLemburPerJam: j.TarifLemburPerJam,
+	}
+}
+
+// SamaDengan checks whether two rates have the same amounts
+func (t *TarifJabatan) SamaDengan(other *TarifJabatan) bool {
+	return t.GajiPokok == other.GajiPokok &&
+		t.TunjanganJabatan == other.TunjanganJabatan &&
+		t.TarifLemburPerJam == other.TarifLemburPerJam
+}
@@ -12,6 +12,8 @@ const (
 	UserRoleHR       UserRole = "hr"
 	UserRoleFinance  UserRole = "finance"
 	UserRoleKaryawan UserRole = "karyawan"
+	UserRoleKades    UserRole = "kades"
+	UserRoleSekdes   UserRole = "sekdes"
 )
 
 // User represents admin user
@@ -39,6 +41,8 @@ func (u *User) HasPermission(requiredRole UserRole) bool {
 	roleHierarchy := map[UserRole]int{
 		UserRoleAdmin:    4,
 		UserRoleFinance:  3,
+		UserRoleKades:    3,
+		UserRoleSekdes:   3,
 		UserRoleHR:       2,
 		UserRoleKaryawan: 1,
 	}
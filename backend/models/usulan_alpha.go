@@ -0,0 +1,92 @@
+package models
+
+import "time"
+
+// UsulanAlphaStatus represents the review state of an auto-alpha proposal
+type UsulanAlphaStatus string
+
+const (
+	UsulanAlphaDraft      UsulanAlphaStatus = "draft"      // waiting for review
+	UsulanAlphaDiterapkan UsulanAlphaStatus = "diterapkan" // alpha records created
+	UsulanAlphaDibatalkan UsulanAlphaStatus = "dibatalkan" // discarded
+)
+
+// DetailAlphaStatus represents the outcome of one proposed alpha day
+type DetailAlphaStatus string
+
+const (
+	DetailAlphaUsulan       DetailAlphaStatus = "usulan"       // proposed, not applied yet
+	DetailAlphaDibuat       DetailAlphaStatus = "dibuat"       // alpha absensi created
+	DetailAlphaDilewati     DetailAlphaStatus = "dilewati"     // not created, see keterangan
+	DetailAlphaDikecualikan DetailAlphaStatus = "dikecualikan" // left out by the reviewer
+)
+
+// UsulanAlpha is a proposal to mark the working days without any absensi row in a date
+// range as alpha. It is created by the scheduled job or on request and only writes
+// absensi once reviewed and applied.
+type UsulanAlpha struct {
+	ID             uint                `json:"id" gorm:"primaryKey"`
+	Mulai          time.Time           `json:"mulai" gorm:"type:date;not null"`
+	Selesai        time.Time           `json:"selesai" gorm:"type:date;not null"`
+	Dikecualikan   string              `json:"dikecualikan" gorm:"type:text"` // excluded dates, comma separated
+	Sumber         string              `json:"sumber" gorm:"size:20"`         // jadwal or manual
+	Status         UsulanAlphaStatus   `json:"status" gorm:"default:'draft';type:enum('draft','diterapkan','dibatalkan');index"`
+	DibuatOleh     *uint               `json:"dibuat_oleh"`
+	DiterapkanOleh *uint               `json:"diterapkan_oleh"`
+	DiterapkanPada *time.Time          `json:"diterapkan_pada"`
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+	Detail         []DetailUsulanAlpha `json:"detail,omitempty" gorm:"foreignKey:UsulanAlphaID"`
+}
+
+// TableName specifies the table name for UsulanAlpha model
+func (UsulanAlpha) TableName() string {
+	return "usulan_alpha"
+}
+
+// DetailUsulanAlpha is one karyawan's working day proposed as alpha
+type DetailUsulanAlpha struct {
+	ID            uint              `json:"id" gorm:"primaryKey"`
+	UsulanAlphaID uint              `json:"usulan_alpha_id" gorm:"not null;index"`
+	KaryawanID    uint              `json:"karyawan_id" gorm:"not null;index"`
+	Tanggal       time.Time         `json:"tanggal" gorm:"type:date;not null"`
+	Status        DetailAlphaStatus `json:"status" gorm:"default:'usulan';size:20"`
+	AbsensiID     *uint             `json:"absensi_id"`
+	Keterangan    string            `json:"keterangan" gorm:"size:255"`
+	Karyawan      Karyawan          `json:"karyawan,omitempty" gorm:"foreignKey:KaryawanID"`
+}
+
+// TableName specifies the table name for DetailUsulanAlpha model
+func (DetailUsulanAlpha) TableName() string {
+	return "detail_usulan_alpha"
+}
+
+// RingkasanAlpha counts a proposal's days for review
+type RingkasanAlpha struct {
+	KaryawanID   uint     `json:"karyawan_id"`
+	NIK          string   `json:"nik"`
+	NamaKaryawan string   `json:"nama_karyawan"`
+	JumlahHari   int      `json:"jumlah_hari"`
+	Tanggal      []string `json:"tanggal"`
+}
+
+// Ringkasan groups the proposed days per karyawan. Karyawan must be loaded.
+func (u *UsulanAlpha) Ringkasan() []RingkasanAlpha {
+	ringkasan := []RingkasanAlpha{}
+	indeks := make(map[uint]int)
+	for _, d := range u.Detail {
+		i, ok := indeks[d.KaryawanID]
+		if !ok {
+			ringkasan = append(ringkasan, RingkasanAlpha{
+				KaryawanID:   d.KaryawanID,
+				NIK:          d.Karyawan.NIK,
+				NamaKaryawan: d.Karyawan.Nama,
+			})
+			i = len(ringkasan) - 1
+			indeks[d.KaryawanID] = i
+		}
+		ringkasan[i].JumlahHari++
+		ringkasan[i].Tanggal = append(ringkasan[i].Tanggal, d.Tanggal.Format("2006-01-02"))
+	}
+	return ringkasan
+}
@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"errors"
 	"pemdes-payroll/backend/models"
 	"time"
 
@@ -14,8 +15,12 @@ type AbsensiRepository interface {
 	GetByKaryawanID(karyawanID uint, startDate, endDate time.Time) ([]models.Absensi, error)
 	GetByDateRange(startDate, endDate time.Time) ([]models.Absensi, error)
 	Update(id uint, absensi *models.Absensi) error
+	UpdateDenganRiwayat(id uint, absensi *models.Absensi, oleh *uint, alasan string) error
 	Delete(id uint) error
+	SetDihitung(gajiID uint, absensiIDs []uint) error
 	GetRekapBulanan(karyawanID uint, bulan, tahun int) (map[string]int, error)
+	GetHariTanpaAbsensi(karyawanID uint, startDate, endDate time.Time) ([]time.Time, error)
+	ImportFingerprint(rekap []models.RekapFingerprint, simulasi bool) error
 }
 
 type absensiRepository struct {
@@ -34,30 +39,70 @@ func (r *absensiRepository) Create(absensi *models.Absensi) error {
 func (r *absensiRepository) GetAll() ([]models.Absensi, error) {
 	var absensi []models.Absensi
 	err := r.db.Preload("Karyawan.Jabatan").Order("tanggal DESC, created_at DESC").Find(&absensi).Error
-	return absensi, err
+	if err != nil {
+		return nil, err
+	}
+	return absensi, r.hitungJadwal(absensi)
+}
+
+// hitungJadwal fills the minutes late, minutes left early and working hours of the rows
+// from the working calendar
+func (r *absensiRepository) hitungJadwal(absensi []models.Absensi) error {
+	if len(absensi) == 0 {
+		return nil
+	}
+	awal, akhir := absensi[0].Tanggal, absensi[0].Tanggal
+	for _, a := range absensi {
+		if a.Tanggal.Before(awal) {
+			awal = a.Tanggal
+		}
+		if a.Tanggal.After(akhir) {
+			akhir = a.Tanggal
+		}
+	}
+	kalender, err := muatKalender(r.db, awal, akhir)
+	if err != nil {
+		return err
+	}
+	for i := range absensi {
+		kalender.Hitung(&absensi[i])
+	}
+	return nil
 }
 
 func (r *absensiRepository) GetByID(id uint) (*models.Absensi, error) {
 	var absensi models.Absensi
-	err := r.db.Preload("Karyawan.Jabatan").First(&absensi, id).Error
+	err := r.db.Preload("Karyawan.Jabatan").Preload("Riwayat", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at, id")
+	}).First(&absensi, id).Error
 	if err != nil {
 		return nil, err
 	}
-	return &absensi, nil
+	list := []models.Absensi{absensi}
+	if err := r.hitungJadwal(list); err != nil {
+		return nil, err
+	}
+	return &list[0], nil
 }
 
 func (r *absensiRepository) GetByKaryawanID(karyawanID uint, startDate, endDate time.Time) ([]models.Absensi, error) {
 	var absensi []models.Absensi
 	err := r.db.Where("karyawan_id = ? AND tanggal BETWEEN ? AND ?", karyawanID, startDate, endDate).
 		Order("tanggal DESC").Find(&absensi).Error
-	return absensi, err
+	if err != nil {
+		return nil, err
+	}
+	return absensi, r.hitungJadwal(absensi)
 }
 
 func (r *absensiRepository) GetByDateRange(startDate, endDate time.Time) ([]models.Absensi, error) {
 	var absensi []models.Absensi
 	err := r.db.Preload("Karyawan.Jabatan").Where("tanggal BETWEEN ? AND ?", startDate, endDate).
 		Order("tanggal DESC, karyawan_id").Find(&absensi).Error
-	return absensi, err
+	if err != nil {
+		return nil, err
+	}
+	return absensi, r.hitungJadwal(absensi)
 }
 
 func (r *absensiRepository) Update(id uint, absensi *models.Absensi) error {
@@ -68,41 +113,270 @@ func (r *absensiRepository) Update(id uint, absensi *models.Absensi) error {
 	return r.db.Model(&existing).Updates(absensi).Error
 }
 
+// UpdateDenganRiwayat updates an absensi row like Update and records the previous and new
+// times and status in its riwayat when they change
+func (r *absensiRepository) UpdateDenganRiwayat(id uint, absensi *models.Absensi, oleh *uint, alasan string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var semula models.Absensi
+		if err := tx.First(&semula, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Absensi{ID: id}).Updates(absensi).Error; err != nil {
+			return err
+		}
+		var baru models.Absensi
+		if err := tx.First(&baru, id).Error; err != nil {
+			return err
+		}
+		return catatRiwayat(tx, &semula, &baru, nil, oleh, alasan)
+	})
+}
+
+// catatRiwayat records the change of an absensi row from semula to baru, unless its
+// times and status stayed the same. semula is nil for a row that did not exist yet.
+func catatRiwayat(tx *gorm.DB, semula, baru *models.Absensi, pengajuanKoreksiID, oleh *uint, alasan string) error {
+	riwayat := models.RiwayatAbsensi{
+		AbsensiID:          baru.ID,
+		PengajuanKoreksiID: pengajuanKoreksiID,
+		JamMasuk:           baru.JamMasuk,
+		JamKeluar:          baru.JamKeluar,
+		Status:             baru.Status,
+		Alasan:             alasan,
+		DiubahOleh:         oleh,
+	}
+	if semula != nil {
+		if semula.JamMasuk == baru.JamMasuk && semula.JamKeluar == baru.JamKeluar && semula.Status == baru.Status {
+			return nil
+		}
+		riwayat.JamMasukSemula = semula.JamMasuk
+		riwayat.JamKeluarSemula = semula.JamKeluar
+		riwayat.StatusSemula = semula.Status
+		riwayat.SumberSemula = semula.Sumber
+	}
+	return tx.Create(&riwayat).Error
+}
+
 func (r *absensiRepository) Delete(id uint) error {
 	return r.db.Delete(&models.Absensi{}, id).Error
 }
 
-// GetRekapBulanan gets attendance summary for a specific month
-func (r *absensiRepository) GetRekapBulanan(karyawanID uint, bulan, tahun int) (map[string]int, error) {
-	type Result struct {
-		Status string
-		Count  int
-	}
+// SetDihitung records which attendance rows a slip's attendance figures were counted
+// from. Rows the slip no longer counts are released; rows already paid are left alone.
+func (r *absensiRepository) SetDihitung(gajiID uint, absensiIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Absensi{}).Where("dihitung_gaji_id = ? AND gaji_id IS NULL", gajiID).
+			Update("dihitung_gaji_id", nil).Error
+		if err != nil {
+			return err
+		}
+		if len(absensiIDs) == 0 {
+			return nil
+		}
+		return tx.Model(&models.Absensi{}).Where("id IN ? AND gaji_id IS NULL", absensiIDs).
+			Update("dihitung_gaji_id", gajiID).Error
+	})
+}
 
+// GetRekapBulanan gets attendance summary for a specific month: the days per status,
+// the days late and left early with their minutes, the net working minutes, the working
+// days of the month and the working days up to today without any absensi row
+func (r *absensiRepository) GetRekapBulanan(karyawanID uint, bulan, tahun int) (map[string]int, error) {
 	startDate := time.Date(tahun, time.Month(bulan), 1, 0, 0, 0, 0, time.UTC)
 	endDate := startDate.AddDate(0, 1, -1)
 
-	var results []Result
-	err := r.db.Model(&models.Absensi{}).
-		Select("status, COUNT(*) as count").
-		Where("karyawan_id = ? AND tanggal BETWEEN ? AND ?", karyawanID, startDate, endDate).
-		Group("status").
-		Scan(&results).Error
+	absensi, err := r.GetByKaryawanID(karyawanID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
 
+	hariKerja, tanpaAbsensi, err := r.hariKerjaTanpaAbsensi(karyawanID, startDate, endDate, absensi)
 	if err != nil {
 		return nil, err
 	}
 
 	rekap := map[string]int{
-		"hadir":  0,
-		"izin":   0,
-		"sakit":  0,
-		"alpha":  0,
+		"hadir":              0,
+		"izin":               0,
+		"sakit":              0,
+		"alpha":              0,
+		"terlambat":          0,
+		"menit_terlambat":    0,
+		"pulang_cepat":       0,
+		"menit_pulang_cepat": 0,
+		"menit_kerja":        0,
+		"hari_kerja":         hariKerja,
+		"tanpa_absensi":      len(tanpaAbsensi),
 	}
 
-	for _, r := range results {
-		rekap[r.Status] = r.Count
+	for _, a := range absensi {
+		rekap[string(a.Status)]++
+		if a.MenitTerlambat > 0 {
+			rekap["terlambat"]++
+			rekap["menit_terlambat"] += a.MenitTerlambat
+		}
+		if a.MenitPulangCepat > 0 {
+			rekap["pulang_cepat"]++
+			rekap["menit_pulang_cepat"] += a.MenitPulangCepat
+		}
+		rekap["menit_kerja"] += a.MenitKerja
 	}
 
 	return rekap, nil
 }
+
+// GetHariTanpaAbsensi lists the working days of the period, up to today and while the
+// karyawan is employed, that have no absensi row
+func (r *absensiRepository) GetHariTanpaAbsensi(karyawanID uint, startDate, endDate time.Time) ([]time.Time, error) {
+	absensi, err := r.GetByKaryawanID(karyawanID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	_, tanpaAbsensi, err := r.hariKerjaTanpaAbsensi(karyawanID, startDate, endDate, absensi)
+	return tanpaAbsensi, err
+}
+
+// hariKerjaTanpaAbsensi counts the working days of the period and lists those, up to
+// today and while the karyawan is employed, that have no absensi row
+func (r *absensiRepository) hariKerjaTanpaAbsensi(karyawanID uint, startDate, endDate time.Time, absensi []models.Absensi) (int, []time.Time, error) {
+	kalender, err := muatKalender(r.db, startDate, endDate)
+	if err != nil {
+		return 0, nil, err
+	}
+	hariKerja := kalender.HariKerja(startDate, endDate)
+
+	var karyawan models.Karyawan
+	if err := r.db.Select("id", "tanggal_bergabung", "tanggal_berhenti").First(&karyawan, karyawanID).Error; err != nil {
+		return 0, nil, err
+	}
+
+	ada := make(map[string]bool, len(absensi))
+	for _, a := range absensi {
+		ada[a.Tanggal.Format("2006-01-02")] = true
+	}
+
+	hariIni := time.Now()
+	var tanpaAbsensi []time.Time
+	for _, d := range hariKerja {
+		if d.After(hariIni) || ada[d.Format("2006-01-02")] {
+			continue
+		}
+		if karyawan.TanggalBergabung != nil && d.Before(*karyawan.TanggalBergabung) {
+			continue
+		}
+		if karyawan.TanggalBerhenti != nil && d.After(*karyawan.TanggalBerhenti) {
+			continue
+		}
+		tanpaAbsensi = append(tanpaAbsensi, d)
+	}
+	return len(hariKerja), tanpaAbsensi, nil
+}
+
+// errSimulasi rolls back a simulated import
+var errSimulasi = errors.New("simulasi")
+
+// ImportFingerprint applies the days of a fingerprint log that have a karyawan and no
+// result yet, recording the result of each. Existing rows are looked up first, so the
+// duplicate check of Absensi.BeforeCreate only guards new rows: earlier imports are
+// merged, alpha and rows without times are filled in, and any other row is reported as
+// a conflict. A simulation reports the results without saving them.
+func (r *absensiRepository) ImportFingerprint(rekap []models.RekapFingerprint, simulasi bool) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range rekap {
+			d := &rekap[i]
+			if d.Hasil != "" {
+				continue
+			}
+
+			var existing models.Absensi
+			err := tx.Where("karyawan_id = ? AND tanggal = ?", d.KaryawanID, d.Tanggal.Format("2006-01-02")).First(&existing).Error
+			if err == gorm.ErrRecordNotFound {
+				absensi := models.Absensi{
+					KaryawanID: d.KaryawanID,
+					Tanggal:    d.Tanggal,
+					JamMasuk:   d.JamMasuk,
+					JamKeluar:  d.JamKeluar,
+					Status:     models.AbsensiHadir,
+					Sumber:     models.SumberAbsensiFingerprint,
+				}
+				if err := tx.Create(&absensi).Error; err != nil {
+					return err
+				}
+				d.Hasil = models.FingerprintDibuat
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			jamMasuk, jamKeluar := d.JamMasuk, d.JamKeluar
+			switch {
+			case existing.GajiID != nil:
+				d.Hasil, d.Keterangan = models.FingerprintKonflik, "Absensi has been paid in a slip gaji"
+				continue
+			case existing.PengajuanCutiID != nil:
+				d.Hasil, d.Keterangan = models.FingerprintKonflik, "Absensi belongs to an approved cuti"
+				continue
+			case existing.Status == models.AbsensiAlpha:
+				d.Keterangan = "Alpha replaced by the fingerprint log"
+			case existing.Status != models.AbsensiHadir:
+				d.Hasil, d.Keterangan = models.FingerprintKonflik, "Absensi already recorded as "+string(existing.Status)
+				continue
+			case existing.Sumber == models.SumberAbsensiFingerprint:
+				jamMasuk, jamKeluar = gabungJam(existing.JamMasuk, existing.JamKeluar, d.JamMasuk, d.JamKeluar)
+			case existing.JamMasuk == "" && existing.JamKeluar == "":
+			case existing.JamMasuk == d.JamMasuk && existing.JamKeluar == d.JamKeluar:
+				d.Hasil = models.FingerprintTidakBerubah
+				continue
+			default:
+				d.Hasil, d.Keterangan = models.FingerprintKonflik, "Absensi already recorded from "+existing.JamMasuk+" to "+existing.JamKeluar
+				continue
+			}
+
+			if existing.Status == models.AbsensiHadir && existing.JamMasuk == jamMasuk && existing.JamKeluar == jamKeluar {
+				d.Hasil = models.FingerprintTidakBerubah
+				continue
+			}
+			err = tx.Model(&existing).Updates(map[string]interface{}{
+				"status":     models.AbsensiHadir,
+				"jam_masuk":  jamMasuk,
+				"jam_keluar": jamKeluar,
+				"sumber":     models.SumberAbsensiFingerprint,
+			}).Error
+			if err != nil {
+				return err
+			}
+			d.JamMasuk, d.JamKeluar = jamMasuk, jamKeluar
+			d.Hasil = models.FingerprintDiperbarui
+		}
+
+		if simulasi {
+			return errSimulasi
+		}
+		return nil
+	})
+	if err == errSimulasi {
+		return nil
+	}
+	return err
+}
+
+// gabungJam merges the times of a day imported twice: the earliest as jam masuk and the
+// latest, when later, as jam keluar
+func gabungJam(jam ...string) (string, string) {
+	var masuk, keluar string
+	for _, j := range jam {
+		if j == "" {
+			continue
+		}
+		if masuk == "" || j < masuk {
+			masuk = j
+		}
+		if j > keluar {
+			keluar = j
+		}
+	}
+	if keluar == masuk {
+		keluar = ""
+	}
+	return masuk, keluar
+}
@@ -0,0 +1,280 @@
+package repositories
+
+import (
+	"errors"
+	"pemdes-payroll/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSaldoCutiKurang is returned when the balance no longer covers the requested days
+	ErrSaldoCutiKurang = errors.New("insufficient cuti balance")
+	// ErrAbsensiBentrok is returned when a day of the leave already has an absensi row
+	// other than alpha
+	ErrAbsensiBentrok = errors.New("absensi already recorded")
+	// ErrAbsensiDigaji is returned when an absensi row has been paid in a slip gaji and
+	// can only be changed through a koreksi gaji
+	ErrAbsensiDigaji = errors.New("absensi already paid")
+)
+
+type CutiRepository interface {
+	CreatePengajuan(pengajuan *models.PengajuanCuti) error
+	GetAllPengajuan(status string, karyawanID uint, tahun int) ([]models.PengajuanCuti, error)
+	GetPengajuanByID(id uint) (*models.PengajuanCuti, error)
+	GetBentrok(karyawanID uint, mulai, selesai time.Time, kecualiID uint) (*models.PengajuanCuti, error)
+	GetSaldo(karyawanID uint, tahun int, jenis models.JenisCuti, hak, maksCarryOver int) (*models.SaldoCuti, error)
+	Setujui(pengajuan *models.PengajuanCuti, tanggal []time.Time, diputuskanOleh *uint, catatan string) error
+	Putuskan(pengajuan *models.PengajuanCuti, status models.CutiStatus, diputuskanOleh *uint, catatan string) error
+	Batalkan(pengajuan *models.PengajuanCuti, oleh *uint, catatan string) error
+}
+
+type cutiRepository struct {
+	db *gorm.DB
+}
+
+// NewCutiRepository creates a new Cuti repository
+func NewCutiRepository(db *gorm.DB) CutiRepository {
+	return &cutiRepository{db: db}
+}
+
+func (r *cutiRepository) CreatePengajuan(pengajuan *models.PengajuanCuti) error {
+	return r.db.Create(pengajuan).Error
+}
+
+// GetAllPengajuan lists leave requests, optionally of one status, karyawan or year
+func (r *cutiRepository) GetAllPengajuan(status string, karyawanID uint, tahun int) ([]models.PengajuanCuti, error) {
+	var pengajuan []models.PengajuanCuti
+	query := r.db.Preload("Karyawan")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if karyawanID != 0 {
+		query = query.Where("karyawan_id = ?", karyawanID)
+	}
+	if tahun != 0 {
+		query = query.Where("YEAR(mulai) = ?", tahun)
+	}
+	err := query.Order("mulai DESC, created_at DESC").Find(&pengajuan).Error
+	return pengajuan, err
+}
+
+func (r *cutiRepository) GetPengajuanByID(id uint) (*models.PengajuanCuti, error) {
+	var pengajuan models.PengajuanCuti
+	err := r.db.Preload("Karyawan").First(&pengajuan, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pengajuan, nil
+}
+
+// GetBentrok returns a pending or approved request of the karyawan overlapping mulai up
+// to selesai, other than kecualiID
+func (r *cutiRepository) GetBentrok(karyawanID uint, mulai, selesai time.Time, kecualiID uint) (*models.PengajuanCuti, error) {
+	var pengajuan models.PengajuanCuti
+	err := r.db.Where("karyawan_id = ? AND id <> ? AND status IN ? AND mulai <= ? AND selesai >= ?",
+		karyawanID, kecualiID, []models.CutiStatus{models.CutiDiajukan, models.CutiDisetujui}, selesai, mulai).
+		First(&pengajuan).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pengajuan, nil
+}
+
+// GetSaldo returns the karyawan's balance of jenis in tahun, opening it with the given
+// entitlement when the year has none yet. Unused days of the previous year's cuti
+// tahunan carry over up to maksCarryOver, recomputed on every read so that changes to
+// the previous year's balance follow through.
+func (r *cutiRepository) GetSaldo(karyawanID uint, tahun int, jenis models.JenisCuti, hak, maksCarryOver int) (*models.SaldoCuti, error) {
+	var saldo models.SaldoCuti
+	err := r.db.Where("karyawan_id = ? AND tahun = ? AND jenis = ?", karyawanID, tahun, jenis).First(&saldo).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	if err == gorm.ErrRecordNotFound {
+		saldo = models.SaldoCuti{KaryawanID: karyawanID, Tahun: tahun, Jenis: jenis, Hak: hak}
+		if err := r.db.Create(&saldo).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if jenis == models.CutiTahunan {
+		if err := r.segarkanCarryOver(&saldo, maksCarryOver); err != nil {
+			return nil, err
+		}
+	}
+	saldo.HitungSisa()
+	return &saldo, nil
+}
+
+// segarkanCarryOver sets the carry-over of a cuti tahunan balance from the remaining days
+// of the previous year, refreshing that year's own carry-over first
+func (r *cutiRepository) segarkanCarryOver(saldo *models.SaldoCuti, maksCarryOver int) error {
+	carryOver := 0
+	var sebelumnya models.SaldoCuti
+	err := r.db.Where("karyawan_id = ? AND tahun = ? AND jenis = ?", saldo.KaryawanID, saldo.Tahun-1, saldo.Jenis).First(&sebelumnya).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	if err == nil {
+		if err := r.segarkanCarryOver(&sebelumnya, maksCarryOver); err != nil {
+			return err
+		}
+		sebelumnya.HitungSisa()
+		carryOver = min(max(sebelumnya.Sisa, 0), max(maksCarryOver, 0))
+	}
+
+	if carryOver == saldo.CarryOver {
+		return nil
+	}
+	if err := r.db.Model(saldo).Update("carry_over", carryOver).Error; err != nil {
+		return err
+	}
+	saldo.CarryOver = carryOver
+	return nil
+}
+
+// Setujui approves a leave request: each day in tanggal gets an absensi row of the
+// leave's status, replacing an alpha row, and the days are taken from the balance of
+// the year they fall in. It fails with ErrAbsensiBentrok or ErrSaldoCutiKurang without
+// changing anything.
+func (r *cutiRepository) Setujui(pengajuan *models.PengajuanCuti, tanggal []time.Time, diputuskanOleh *uint, catatan string) error {
+	keterangan := "Cuti " + string(pengajuan.Jenis)
+	if pengajuan.Alasan != "" {
+		keterangan += ": " + pengajuan.Alasan
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, t := range tanggal {
+			var existing models.Absensi
+			err := tx.Where("karyawan_id = ? AND tanggal = ?", pengajuan.KaryawanID, t.Format("2006-01-02")).First(&existing).Error
+			switch {
+			case err == gorm.ErrRecordNotFound:
+				absensi := models.Absensi{
+					KaryawanID:      pengajuan.KaryawanID,
+					Tanggal:         t,
+					Status:          pengajuan.Jenis.StatusAbsensi(),
+					Keterangan:      keterangan,
+					PengajuanCutiID: &pengajuan.ID,
+				}
+				if err := tx.Create(&absensi).Error; err != nil {
+					return err
+				}
+			case err != nil:
+				return err
+			case existing.Status == models.AbsensiAlpha && existing.PengajuanCutiID == nil && existing.GajiID == nil:
+				err := tx.Model(&existing).Updates(map[string]interface{}{
+					"status":                  pengajuan.Jenis.StatusAbsensi(),
+					"keterangan":              keterangan,
+					"jam_masuk":               "",
+					"jam_keluar":              "",
+					"pengajuan_cuti_id":       pengajuan.ID,
+					"status_sebelum_cuti":     existing.Status,
+					"keterangan_sebelum_cuti": existing.Keterangan,
+					"jam_masuk_sebelum_cuti":  existing.JamMasuk,
+					"jam_keluar_sebelum_cuti": existing.JamKeluar,
+				}).Error
+				if err != nil {
+					return err
+				}
+			default:
+				return ErrAbsensiBentrok
+			}
+		}
+
+		if pengajuan.Jenis.BerKuota() {
+			perTahun := map[int]int{}
+			for _, t := range tanggal {
+				perTahun[t.Year()]++
+			}
+			for tahun, hari := range perTahun {
+				result := tx.Model(&models.SaldoCuti{}).
+					Where("karyawan_id = ? AND tahun = ? AND jenis = ? AND hak + carry_over - terpakai >= ?",
+						pengajuan.KaryawanID, tahun, pengajuan.Jenis, hari).
+					Update("terpakai", gorm.Expr("terpakai + ?", hari))
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.RowsAffected == 0 {
+					return ErrSaldoCutiKurang
+				}
+			}
+		}
+
+		pengajuan.JumlahHari = len(tanggal)
+		return putuskan(tx, pengajuan, models.CutiDisetujui, diputuskanOleh, catatan)
+	})
+}
+
+// Putuskan rejects or withdraws a request that has not been approved
+func (r *cutiRepository) Putuskan(pengajuan *models.PengajuanCuti, status models.CutiStatus, diputuskanOleh *uint, catatan string) error {
+	return putuskan(r.db, pengajuan, status, diputuskanOleh, catatan)
+}
+
+// Batalkan cancels a request. For an approved one it puts back the absensi rows the
+// leave replaced, removes the ones it created and returns each day to the balance of
+// its year.
+func (r *cutiRepository) Batalkan(pengajuan *models.PengajuanCuti, oleh *uint, catatan string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if pengajuan.Status == models.CutiDisetujui {
+			var absensi []models.Absensi
+			if err := tx.Where("pengajuan_cuti_id = ?", pengajuan.ID).Find(&absensi).Error; err != nil {
+				return err
+			}
+
+			perTahun := map[int]int{}
+			for _, a := range absensi {
+				perTahun[a.Tanggal.Year()]++
+				if a.StatusSebelumCuti == "" {
+					if err := tx.Delete(&a).Error; err != nil {
+						return err
+					}
+					continue
+				}
+				err := tx.Model(&a).Updates(map[string]interface{}{
+					"status":                  a.StatusSebelumCuti,
+					"keterangan":              a.KeteranganSebelumCuti,
+					"jam_masuk":               a.JamMasukSebelumCuti,
+					"jam_keluar":              a.JamKeluarSebelumCuti,
+					"pengajuan_cuti_id":       nil,
+					"status_sebelum_cuti":     "",
+					"keterangan_sebelum_cuti": "",
+					"jam_masuk_sebelum_cuti":  "",
+					"jam_keluar_sebelum_cuti": "",
+				}).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			if pengajuan.Jenis.BerKuota() {
+				for tahun, hari := range perTahun {
+					err := tx.Model(&models.SaldoCuti{}).
+						Where("karyawan_id = ? AND tahun = ? AND jenis = ?", pengajuan.KaryawanID, tahun, pengajuan.Jenis).
+						Update("terpakai", gorm.Expr("GREATEST(terpakai - ?, 0)", hari)).Error
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return putuskan(tx, pengajuan, models.CutiDibatalkan, oleh, catatan)
+	})
+}
+
+// putuskan records the new status of a request and who set it
+func putuskan(db *gorm.DB, pengajuan *models.PengajuanCuti, status models.CutiStatus, oleh *uint, catatan string) error {
+	now := time.Now()
+	pengajuan.Status = status
+	pengajuan.DiputuskanOleh = oleh
+	pengajuan.DiputuskanPada = &now
+	pengajuan.CatatanKeputusan = catatan
+	return db.Model(pengajuan).Updates(map[string]interface{}{
+		"status":            status,
+		"jumlah_hari":       pengajuan.JumlahHari,
+		"diputuskan_oleh":   oleh,
+		"diputuskan_pada":   now,
+		"catatan_keputusan": catatan,
+	}).Error
+}
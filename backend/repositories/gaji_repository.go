@@ -14,10 +14,13 @@ type GajiRepository interface {
 	GetByKaryawanID(karyawanID uint) ([]models.Gaji, error)
 	GetByPeriod(bulan, tahun int) ([]models.Gaji, error)
 	GetByKaryawanAndPeriod(karyawanID, bulan, tahun int) (*models.Gaji, error)
+	GetSlipPeriode(karyawanID uint, bulan, tahun int) ([]models.Gaji, error)
 	Update(id uint, gaji *models.Gaji) error
 	Delete(id uint) error
 	UpdateStatus(id uint, status models.GajiStatus) error
 	GetTotalGajiByPeriod(bulan, tahun int) (float64, error)
+	GetAkumulasiPPh21(karyawanID uint, bulan, tahun int) (float64, float64, float64, int, error)
+	MigrateLegacyItems() (int, error)
 }
 
 type gajiRepository struct {
@@ -39,13 +42,13 @@ func (r *gajiRepository) CreateBatch(gajiList []models.Gaji) error {
 
 func (r *gajiRepository) GetAll() ([]models.Gaji, error) {
 	var gaji []models.Gaji
-	err := r.db.Preload("Karyawan.Jabatan").Order("periode_tahun DESC, periode_bulan DESC, created_at DESC").Find(&gaji).Error
+	err := r.db.Preload("Karyawan.Jabatan").Preload("Items").Order("periode_tahun DESC, periode_bulan DESC, created_at DESC").Find(&gaji).Error
 	return gaji, err
 }
 
 func (r *gajiRepository) GetByID(id uint) (*models.Gaji, error) {
 	var gaji models.Gaji
-	err := r.db.Preload("Karyawan.Jabatan").First(&gaji, id).Error
+	err := r.db.Preload("Karyawan.Jabatan").Preload("Items").Preload("PayrollRun").First(&gaji, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -54,38 +57,72 @@ func (r *gajiRepository) GetByID(id uint) (*models.Gaji, error) {
 
 func (r *gajiRepository) GetByKaryawanID(karyawanID uint) ([]models.Gaji, error) {
 	var gaji []models.Gaji
-	err := r.db.Preload("Karyawan.Jabatan").Where("karyawan_id = ?", karyawanID).
+	err := r.db.Preload("Karyawan.Jabatan").Preload("Items").Where("karyawan_id = ?", karyawanID).
 		Order("periode_tahun DESC, periode_bulan DESC").Find(&gaji).Error
 	return gaji, err
 }
 
 func (r *gajiRepository) GetByPeriod(bulan, tahun int) ([]models.Gaji, error) {
 	var gaji []models.Gaji
-	err := r.db.Preload("Karyawan.Jabatan").Where("periode_bulan = ? AND periode_tahun = ?", bulan, tahun).
+	err := r.db.Preload("Karyawan.Jabatan").Preload("Items").Preload("PayrollRun").Where("periode_bulan = ? AND periode_tahun = ?", bulan, tahun).
 		Order("created_at DESC").Find(&gaji).Error
 	return gaji, err
 }
 
+// GetByKaryawanAndPeriod returns the karyawan's regular slip of the period
 func (r *gajiRepository) GetByKaryawanAndPeriod(karyawanID, bulan, tahun int) (*models.Gaji, error) {
 	var gaji models.Gaji
-	err := r.db.Where("karyawan_id = ? AND periode_bulan = ? AND periode_tahun = ?",
-		karyawanID, bulan, tahun).First(&gaji).Error
+	err := r.db.Preload("Items").Preload("PayrollRun").Where("karyawan_id = ? AND periode_bulan = ? AND periode_tahun = ? AND jenis = ?",
+		karyawanID, bulan, tahun, models.JenisGajiReguler).First(&gaji).Error
 	if err != nil {
 		return nil, err
 	}
 	return &gaji, nil
 }
 
+// GetSlipPeriode returns all of the karyawan's slips of the period, of every jenis
+func (r *gajiRepository) GetSlipPeriode(karyawanID uint, bulan, tahun int) ([]models.Gaji, error) {
+	var gaji []models.Gaji
+	err := r.db.Preload("Items").Preload("PayrollRun").Where("karyawan_id = ? AND periode_bulan = ? AND periode_tahun = ?",
+		karyawanID, bulan, tahun).Order("id").Find(&gaji).Error
+	return gaji, err
+}
+
+// Update writes every column of gaji so that recalculated zero amounts are saved too,
+// and replaces its line items
 func (r *gajiRepository) Update(id uint, gaji *models.Gaji) error {
-	var existing models.Gaji
-	if err := r.db.First(&existing, id).Error; err != nil {
-		return err
-	}
-	return r.db.Model(&existing).Updates(gaji).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.Gaji
+		if err := tx.First(&existing, id).Error; err != nil {
+			return err
+		}
+
+		err := tx.Model(&existing).Select("*").Omit("ID", "Jenis", "CreatedAt", "Karyawan", "Items", "PayrollRun").Updates(gaji).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("gaji_id = ?", id).Delete(&models.GajiItem{}).Error; err != nil {
+			return err
+		}
+		if len(gaji.Items) == 0 {
+			return nil
+		}
+		for i := range gaji.Items {
+			gaji.Items[i].ID = 0
+			gaji.Items[i].GajiID = id
+		}
+		return tx.Create(&gaji.Items).Error
+	})
 }
 
 func (r *gajiRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Gaji{}, id).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("gaji_id = ?", id).Delete(&models.GajiItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Gaji{}, id).Error
+	})
 }
 
 func (r *gajiRepository) UpdateStatus(id uint, status models.GajiStatus) error {
@@ -98,3 +135,56 @@ func (r *gajiRepository) GetTotalGajiByPeriod(bulan, tahun int) (float64, error)
 		Select("COALESCE(SUM(total_gaji), 0)").Scan(&total).Error
 	return total, err
 }
+
+// GetAkumulasiPPh21 sums gross income, employee pension contributions and withheld PPh 21
+// from January up to the month before bulan, and counts the months of those slips
+func (r *gajiRepository) GetAkumulasiPPh21(karyawanID uint, bulan, tahun int) (float64, float64, float64, int, error) {
+	type Result struct {
+		TotalBruto  float64
+		TotalIuran  float64
+		TotalPPh21  float64 `gorm:"column:total_pph21"`
+		JumlahBulan int
+	}
+
+	var result Result
+	err := r.db.Model(&models.Gaji{}).
+		Select("COALESCE(SUM(penghasilan_bruto), 0) as total_bruto, "+
+			"COALESCE(SUM(jht_karyawan + jp_karyawan), 0) as total_iuran, "+
+			"COALESCE(SUM(pph21), 0) as total_pph21, "+
+			"COUNT(DISTINCT periode_bulan) as jumlah_bulan").
+		Where("karyawan_id = ? AND periode_tahun = ? AND periode_bulan < ?", karyawanID, tahun, bulan).
+		Scan(&result).Error
+
+	return result.TotalBruto, result.TotalIuran, result.TotalPPh21, result.JumlahBulan, err
+}
+
+// MigrateLegacyItems creates line items for gaji rows saved before slips were itemized
+func (r *gajiRepository) MigrateLegacyItems() (int, error) {
+	var gajiList []models.Gaji
+	err := r.db.Where("NOT EXISTS (SELECT 1 FROM gaji_item gi WHERE gi.gaji_id = gaji.id)").Find(&gajiList).Error
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, g := range gajiList {
+		g.SetStandardItems()
+		g.AddItem(models.KodeBPJSKesehatan, "BPJS Kesehatan", models.JenisPotongan, g.BPJSKesehatanKaryawan)
+		g.AddItem(models.KodeBPJSJHT, "BPJS JHT", models.JenisPotongan, g.JHTKaryawan)
+		g.AddItem(models.KodeBPJSJP, "BPJS JP", models.JenisPotongan, g.JPKaryawan)
+		g.AddItem(models.KodePPh21, "PPh 21", models.JenisPotongan, g.PPh21)
+		if len(g.Items) == 0 {
+			continue
+		}
+
+		for i := range g.Items {
+			g.Items[i].GajiID = g.ID
+		}
+		if err := r.db.Create(&g.Items).Error; err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"pemdes-payroll/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type HariLiburRepository interface {
+	Create(libur *models.HariLibur) error
+	GetByRange(awal, akhir time.Time) ([]models.HariLibur, error)
+	GetByID(id uint) (*models.HariLibur, error)
+	Import(list []models.HariLibur) (int, int, error)
+	Delete(id uint) error
+	GetKalender(awal, akhir time.Time) (*models.KalenderKerja, error)
+}
+
+type hariLiburRepository struct {
+	db *gorm.DB
+}
+
+// NewHariLiburRepository creates a new HariLibur repository
+func NewHariLiburRepository(db *gorm.DB) HariLiburRepository {
+	return &hariLiburRepository{db: db}
+}
+
+func (r *hariLiburRepository) Create(libur *models.HariLibur) error {
+	return r.db.Create(libur).Error
+}
+
+func (r *hariLiburRepository) GetByRange(awal, akhir time.Time) ([]models.HariLibur, error) {
+	var libur []models.HariLibur
+	err := r.db.Where("tanggal BETWEEN ? AND ?", awal, akhir).Order("tanggal").Find(&libur).Error
+	return libur, err
+}
+
+func (r *hariLiburRepository) GetByID(id uint) (*models.HariLibur, error) {
+	var libur models.HariLibur
+	err := r.db.First(&libur, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &libur, nil
+}
+
+// Import saves imported calendar entries. An entry on a date already in the calendar
+// replaces its name and jenis. It returns the number of entries created and updated.
+func (r *hariLiburRepository) Import(list []models.HariLibur) (int, int, error) {
+	dibuat, diperbarui := 0, 0
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range list {
+			var existing models.HariLibur
+			err := tx.Where("tanggal = ?", list[i].Tanggal.Format("2006-01-02")).First(&existing).Error
+			if err == gorm.ErrRecordNotFound {
+				if err := tx.Create(&list[i]).Error; err != nil {
+					return err
+				}
+				dibuat++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			err = tx.Model(&existing).Updates(map[string]interface{}{
+				"nama":   list[i].Nama,
+				"jenis":  list[i].Jenis,
+				"sumber": list[i].Sumber,
+			}).Error
+			if err != nil {
+				return err
+			}
+			diperbarui++
+		}
+		return nil
+	})
+	return dibuat, diperbarui, err
+}
+
+func (r *hariLiburRepository) Delete(id uint) error {
+	return r.db.Delete(&models.HariLibur{}, id).Error
+}
+
+// GetKalender returns the working calendar of awal up to akhir
+func (r *hariLiburRepository) GetKalender(awal, akhir time.Time) (*models.KalenderKerja, error) {
+	return muatKalender(r.db, awal, akhir)
+}
+
+// muatKalender loads the jadwal kerja and the calendar entries of awal up to akhir
+func muatKalender(db *gorm.DB, awal, akhir time.Time) (*models.KalenderKerja, error) {
+	var jadwal []models.JadwalKerja
+	if err := db.Find(&jadwal).Error; err != nil {
+		return nil, err
+	}
+	var libur []models.HariLibur
+	if err := db.Where("tanggal BETWEEN ? AND ?", awal, akhir).Find(&libur).Error; err != nil {
+		return nil, err
+	}
+	return models.NewKalenderKerja(models.NewJadwalMingguan(jadwal), libur), nil
+}
@@ -52,7 +52,12 @@ func (r *jabatanRepository) Update(id uint, jabatan *models.Jabatan) error {
 }
 
 func (r *jabatanRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Jabatan{}, id).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("jabatan_id = ?", id).Delete(&models.TarifJabatan{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Jabatan{}, id).Error
+	})
 }
 
 func (r *jabatanRepository) Count() (int64, error) {
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"pemdes-payroll/backend/models"
+
+	"gorm.io/gorm"
+)
+
+type JadwalKerjaRepository interface {
+	GetAll() ([]models.JadwalKerja, error)
+	GetMingguan() (models.JadwalMingguan, error)
+	Simpan(jadwal []models.JadwalKerja) error
+	InitDefault() (int, error)
+}
+
+type jadwalKerjaRepository struct {
+	db *gorm.DB
+}
+
+// NewJadwalKerjaRepository creates a new JadwalKerja repository
+func NewJadwalKerjaRepository(db *gorm.DB) JadwalKerjaRepository {
+	return &jadwalKerjaRepository{db: db}
+}
+
+func (r *jadwalKerjaRepository) GetAll() ([]models.JadwalKerja, error) {
+	var jadwal []models.JadwalKerja
+	err := r.db.Order("hari").Find(&jadwal).Error
+	return jadwal, err
+}
+
+// GetMingguan returns the schedule indexed by weekday
+func (r *jadwalKerjaRepository) GetMingguan() (models.JadwalMingguan, error) {
+	jadwal, err := r.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return models.NewJadwalMingguan(jadwal), nil
+}
+
+// Simpan replaces the schedule of the given weekdays
+func (r *jadwalKerjaRepository) Simpan(jadwal []models.JadwalKerja) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range jadwal {
+			var existing models.JadwalKerja
+			err := tx.Where("hari = ?", jadwal[i].Hari).First(&existing).Error
+			if err == gorm.ErrRecordNotFound {
+				if err := tx.Create(&jadwal[i]).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			jadwal[i].ID = existing.ID
+			err = tx.Model(&existing).Select("*").Omit("ID", "Hari", "CreatedAt").Updates(&jadwal[i]).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// InitDefault saves the default schedule when none is configured yet
+func (r *jadwalKerjaRepository) InitDefault() (int, error) {
+	var count int64
+	if err := r.db.Model(&models.JadwalKerja{}).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, nil
+	}
+	jadwal := models.JadwalKerjaDefault()
+	if err := r.db.Create(&jadwal).Error; err != nil {
+		return 0, err
+	}
+	return len(jadwal), nil
+}
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"pemdes-payroll/backend/models"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -14,6 +15,11 @@ type KaryawanRepository interface {
 	Update(id uint, karyawan *models.Karyawan) error
 	Delete(id uint) error
 	GetByStatus(status models.KaryawanStatus) ([]models.Karyawan, error)
+	GetUntukPeriode(awal, akhir time.Time) ([]models.Karyawan, error)
+	SetTanggalBerhenti(id uint, tanggal *time.Time) error
+	SetPinFingerprint(id uint, pin *string) error
+	GetByPinFingerprint(pin string) (*models.Karyawan, error)
+	GetDenganPinFingerprint() ([]models.Karyawan, error)
 	Search(keyword string) ([]models.Karyawan, error)
 	Count() (int64, error)
 }
@@ -73,6 +79,42 @@ func (r *karyawanRepository) GetByStatus(status models.KaryawanStatus) ([]models
 	return karyawan, err
 }
 
+// GetUntukPeriode returns the karyawan to be paid for a period: those who joined by its
+// last day and are aktif without an end of service, or whose service ends in or after it
+func (r *karyawanRepository) GetUntukPeriode(awal, akhir time.Time) ([]models.Karyawan, error) {
+	var karyawan []models.Karyawan
+	err := r.db.Preload("Jabatan").
+		Where("tanggal_bergabung IS NULL OR tanggal_bergabung <= ?", akhir.Format("2006-01-02")).
+		Where("(tanggal_berhenti IS NULL AND status = ?) OR tanggal_berhenti >= ?", models.StatusAktif, awal.Format("2006-01-02")).
+		Find(&karyawan).Error
+	return karyawan, err
+}
+
+// SetTanggalBerhenti sets or clears the end-of-service date
+func (r *karyawanRepository) SetTanggalBerhenti(id uint, tanggal *time.Time) error {
+	return r.db.Model(&models.Karyawan{}).Where("id = ?", id).Update("tanggal_berhenti", tanggal).Error
+}
+
+func (r *karyawanRepository) SetPinFingerprint(id uint, pin *string) error {
+	return r.db.Model(&models.Karyawan{}).Where("id = ?", id).Update("pin_fingerprint", pin).Error
+}
+
+func (r *karyawanRepository) GetByPinFingerprint(pin string) (*models.Karyawan, error) {
+	var karyawan models.Karyawan
+	err := r.db.Where("pin_fingerprint = ?", pin).First(&karyawan).Error
+	if err != nil {
+		return nil, err
+	}
+	return &karyawan, nil
+}
+
+// GetDenganPinFingerprint returns the karyawan enrolled on the fingerprint terminal
+func (r *karyawanRepository) GetDenganPinFingerprint() ([]models.Karyawan, error) {
+	var karyawan []models.Karyawan
+	err := r.db.Where("pin_fingerprint IS NOT NULL").Find(&karyawan).Error
+	return karyawan, err
+}
+
 func (r *karyawanRepository) Search(keyword string) ([]models.Karyawan, error) {
 	var karyawan []models.Karyawan
 	searchPattern := "%" + keyword + "%"
@@ -0,0 +1,249 @@
+package repositories
+
+import (
+	"fmt"
+	"pemdes-payroll/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type KasbonRepository interface {
+	Create(kasbon *models.Kasbon) error
+	GetAll(status string, karyawanID uint) ([]models.Kasbon, error)
+	GetByID(id uint) (*models.Kasbon, error)
+	GetAngsuranByID(id uint) (*models.AngsuranKasbon, error)
+	GetAngsuranUntukGaji(karyawanID uint, bulan, tahun int, gajiID uint) ([]models.AngsuranKasbon, error)
+	SetDipotong(gajiID uint, angsuranIDs []uint) error
+	Lewati(angsuran *models.AngsuranKasbon, keterangan string) error
+	Lunasi(kasbonID uint, keterangan string) error
+	PercepatPelunasan(kasbonID uint, bulan, tahun int, keterangan string) error
+	Delete(id uint) error
+}
+
+type kasbonRepository struct {
+	db *gorm.DB
+}
+
+// NewKasbonRepository creates a new Kasbon repository
+func NewKasbonRepository(db *gorm.DB) KasbonRepository {
+	return &kasbonRepository{db: db}
+}
+
+// Create saves the kasbon together with its installment schedule
+func (r *kasbonRepository) Create(kasbon *models.Kasbon) error {
+	return r.db.Create(kasbon).Error
+}
+
+func (r *kasbonRepository) GetAll(status string, karyawanID uint) ([]models.Kasbon, error) {
+	var kasbon []models.Kasbon
+	query := r.db.Preload("Karyawan.Jabatan").Preload("Angsuran", func(db *gorm.DB) *gorm.DB {
+		return db.Order("periode_tahun, periode_bulan, ke")
+	})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if karyawanID > 0 {
+		query = query.Where("karyawan_id = ?", karyawanID)
+	}
+	err := query.Order("tanggal DESC, id DESC").Find(&kasbon).Error
+	return kasbon, err
+}
+
+func (r *kasbonRepository) GetByID(id uint) (*models.Kasbon, error) {
+	var kasbon models.Kasbon
+	err := r.db.Preload("Karyawan.Jabatan").Preload("Angsuran", func(db *gorm.DB) *gorm.DB {
+		return db.Order("periode_tahun, periode_bulan, ke")
+	}).First(&kasbon, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &kasbon, nil
+}
+
+func (r *kasbonRepository) GetAngsuranByID(id uint) (*models.AngsuranKasbon, error) {
+	var angsuran models.AngsuranKasbon
+	err := r.db.Preload("Kasbon").First(&angsuran, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &angsuran, nil
+}
+
+// GetAngsuranUntukGaji returns the installments to deduct from a slip: the karyawan's
+// scheduled installments due up to the period, including overdue ones, plus those already
+// deducted by this slip when it is recalculated
+func (r *kasbonRepository) GetAngsuranUntukGaji(karyawanID uint, bulan, tahun int, gajiID uint) ([]models.AngsuranKasbon, error) {
+	var angsuran []models.AngsuranKasbon
+	jatuhTempo := r.db.Where("angsuran_kasbon.status = ? AND (angsuran_kasbon.periode_tahun * 12 + angsuran_kasbon.periode_bulan) <= ?",
+		models.AngsuranTerjadwal, tahun*12+bulan)
+	if gajiID > 0 {
+		jatuhTempo = jatuhTempo.Or("angsuran_kasbon.gaji_id = ?", gajiID)
+	}
+	err := r.db.Preload("Kasbon").
+		Joins("INNER JOIN kasbon ON kasbon.id = angsuran_kasbon.kasbon_id").
+		Where("kasbon.karyawan_id = ?", karyawanID).
+		Where(jatuhTempo).
+		Order("angsuran_kasbon.periode_tahun, angsuran_kasbon.periode_bulan, angsuran_kasbon.id").
+		Find(&angsuran).Error
+	return angsuran, err
+}
+
+// SetDipotong records which installments are deducted by a slip. Installments the slip
+// no longer deducts go back to terjadwal, and each kasbon touched is marked lunas or
+// aktif depending on whether it still has scheduled installments.
+func (r *kasbonRepository) SetDipotong(gajiID uint, angsuranIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// The installments deducted before and after, so both kasbon are updated
+		query := tx.Model(&models.AngsuranKasbon{}).Where("gaji_id = ?", gajiID)
+		if len(angsuranIDs) > 0 {
+			query = query.Or("id IN ?", angsuranIDs)
+		}
+		var kasbonIDs []uint
+		err := query.Distinct().Pluck("kasbon_id", &kasbonIDs).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.AngsuranKasbon{}).Where("gaji_id = ?", gajiID).
+			Updates(map[string]interface{}{"status": models.AngsuranTerjadwal, "gaji_id": nil}).Error
+		if err != nil {
+			return err
+		}
+		if len(angsuranIDs) > 0 {
+			err = tx.Model(&models.AngsuranKasbon{}).Where("id IN ?", angsuranIDs).
+				Updates(map[string]interface{}{"status": models.AngsuranDipotong, "gaji_id": gajiID}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, id := range kasbonIDs {
+			if err := perbaruiStatusKasbon(tx, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// perbaruiStatusKasbon marks a kasbon lunas when it has no scheduled installments left,
+// and aktif otherwise
+func perbaruiStatusKasbon(tx *gorm.DB, kasbonID uint) error {
+	var sisa int64
+	err := tx.Model(&models.AngsuranKasbon{}).
+		Where("kasbon_id = ? AND status = ?", kasbonID, models.AngsuranTerjadwal).
+		Count(&sisa).Error
+	if err != nil {
+		return err
+	}
+
+	if sisa > 0 {
+		return tx.Model(&models.Kasbon{}).Where("id = ?", kasbonID).
+			Updates(map[string]interface{}{"status": models.KasbonAktif, "dilunasi_pada": nil}).Error
+	}
+	return tx.Model(&models.Kasbon{}).Where("id = ? AND status <> ?", kasbonID, models.KasbonLunas).
+		Updates(map[string]interface{}{"status": models.KasbonLunas, "dilunasi_pada": time.Now()}).Error
+}
+
+// angsuranTerakhir returns the installment scheduled last
+func angsuranTerakhir(tx *gorm.DB, kasbonID uint) (*models.AngsuranKasbon, error) {
+	var angsuran models.AngsuranKasbon
+	err := tx.Where("kasbon_id = ?", kasbonID).
+		Order("periode_tahun DESC, periode_bulan DESC, ke DESC").First(&angsuran).Error
+	if err != nil {
+		return nil, err
+	}
+	return &angsuran, nil
+}
+
+// Lewati skips a scheduled installment and adds one of the same amount after the last
+// installment, so the loan runs one month longer
+func (r *kasbonRepository) Lewati(angsuran *models.AngsuranKasbon, keterangan string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		terakhir, err := angsuranTerakhir(tx, angsuran.KasbonID)
+		if err != nil {
+			return err
+		}
+		var maxKe int
+		err = tx.Model(&models.AngsuranKasbon{}).Where("kasbon_id = ?", angsuran.KasbonID).
+			Select("COALESCE(MAX(ke), 0)").Scan(&maxKe).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.AngsuranKasbon{}).Where("id = ?", angsuran.ID).
+			Updates(map[string]interface{}{"status": models.AngsuranDilewati, "keterangan": keterangan}).Error
+		if err != nil {
+			return err
+		}
+
+		bulan, tahun := models.PeriodeBerikutnya(terakhir.PeriodeBulan, terakhir.PeriodeTahun)
+		return tx.Create(&models.AngsuranKasbon{
+			KasbonID:     angsuran.KasbonID,
+			Ke:           maxKe + 1,
+			PeriodeBulan: bulan,
+			PeriodeTahun: tahun,
+			Jumlah:       angsuran.Jumlah,
+			Status:       models.AngsuranTerjadwal,
+			Keterangan:   fmt.Sprintf("Pengganti cicilan ke-%d", angsuran.Ke),
+		}).Error
+	})
+}
+
+// Lunasi settles the remaining installments of a kasbon in cash, outside payroll
+func (r *kasbonRepository) Lunasi(kasbonID uint, keterangan string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.AngsuranKasbon{}).
+			Where("kasbon_id = ? AND status = ?", kasbonID, models.AngsuranTerjadwal).
+			Updates(map[string]interface{}{"status": models.AngsuranDilunasi, "keterangan": keterangan}).Error
+		if err != nil {
+			return err
+		}
+		return perbaruiStatusKasbon(tx, kasbonID)
+	})
+}
+
+// PercepatPelunasan replaces the remaining scheduled installments of a kasbon with one
+// installment of the remaining amount, deducted from the slip of the given period
+func (r *kasbonRepository) PercepatPelunasan(kasbonID uint, bulan, tahun int, keterangan string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var sisa []models.AngsuranKasbon
+		err := tx.Where("kasbon_id = ? AND status = ?", kasbonID, models.AngsuranTerjadwal).
+			Order("ke").Find(&sisa).Error
+		if err != nil {
+			return err
+		}
+		if len(sisa) == 0 {
+			return nil
+		}
+
+		jumlah := 0.0
+		for _, a := range sisa {
+			jumlah += a.Jumlah
+		}
+		if err := tx.Where("kasbon_id = ? AND status = ?", kasbonID, models.AngsuranTerjadwal).
+			Delete(&models.AngsuranKasbon{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.AngsuranKasbon{
+			KasbonID:     kasbonID,
+			Ke:           sisa[0].Ke,
+			PeriodeBulan: bulan,
+			PeriodeTahun: tahun,
+			Jumlah:       jumlah,
+			Status:       models.AngsuranTerjadwal,
+			Keterangan:   keterangan,
+		}).Error
+	})
+}
+
+func (r *kasbonRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("kasbon_id = ?", id).Delete(&models.AngsuranKasbon{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Kasbon{}, id).Error
+	})
+}
@@ -0,0 +1,116 @@
+package repositories
+
+import (
+	"pemdes-payroll/backend/models"
+
+	"gorm.io/gorm"
+)
+
+type KomponenGajiRepository interface {
+	Create(komponen *models.KomponenGaji) error
+	GetAll() ([]models.KomponenGaji, error)
+	GetAktif() ([]models.KomponenGaji, error)
+	GetByID(id uint) (*models.KomponenGaji, error)
+	GetByKode(kode string) (*models.KomponenGaji, error)
+	Update(id uint, komponen *models.KomponenGaji) error
+	Delete(id uint) error
+}
+
+type komponenGajiRepository struct {
+	db *gorm.DB
+}
+
+// NewKomponenGajiRepository creates a new KomponenGaji repository
+func NewKomponenGajiRepository(db *gorm.DB) KomponenGajiRepository {
+	return &komponenGajiRepository{db: db}
+}
+
+func (r *komponenGajiRepository) Create(komponen *models.KomponenGaji) error {
+	return r.db.Create(komponen).Error
+}
+
+func (r *komponenGajiRepository) GetAll() ([]models.KomponenGaji, error) {
+	var komponen []models.KomponenGaji
+	err := r.db.Preload("NilaiJabatan").Preload("Karyawan").Order("jenis, urutan, id").Find(&komponen).Error
+	return komponen, err
+}
+
+func (r *komponenGajiRepository) GetAktif() ([]models.KomponenGaji, error) {
+	var komponen []models.KomponenGaji
+	err := r.db.Preload("NilaiJabatan").Preload("Karyawan").Where("aktif = ?", true).
+		Order("jenis, urutan, id").Find(&komponen).Error
+	return komponen, err
+}
+
+func (r *komponenGajiRepository) GetByID(id uint) (*models.KomponenGaji, error) {
+	var komponen models.KomponenGaji
+	err := r.db.Preload("NilaiJabatan").Preload("Karyawan").First(&komponen, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &komponen, nil
+}
+
+func (r *komponenGajiRepository) GetByKode(kode string) (*models.KomponenGaji, error) {
+	var komponen models.KomponenGaji
+	err := r.db.Where("kode = ?", kode).First(&komponen).Error
+	if err != nil {
+		return nil, err
+	}
+	return &komponen, nil
+}
+
+// Update saves the component and replaces its per-jabatan values and karyawan assignments
+func (r *komponenGajiRepository) Update(id uint, komponen *models.KomponenGaji) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.KomponenGaji
+		if err := tx.First(&existing, id).Error; err != nil {
+			return err
+		}
+
+		err := tx.Model(&existing).Select("*").Omit("ID", "CreatedAt", "NilaiJabatan", "Karyawan").Updates(komponen).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("komponen_gaji_id = ?", id).Delete(&models.KomponenJabatan{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("komponen_gaji_id = ?", id).Delete(&models.KomponenKaryawan{}).Error; err != nil {
+			return err
+		}
+
+		for i := range komponen.NilaiJabatan {
+			komponen.NilaiJabatan[i].ID = 0
+			komponen.NilaiJabatan[i].KomponenGajiID = id
+		}
+		for i := range komponen.Karyawan {
+			komponen.Karyawan[i].ID = 0
+			komponen.Karyawan[i].KomponenGajiID = id
+		}
+
+		if len(komponen.NilaiJabatan) > 0 {
+			if err := tx.Create(&komponen.NilaiJabatan).Error; err != nil {
+				return err
+			}
+		}
+		if len(komponen.Karyawan) > 0 {
+			if err := tx.Create(&komponen.Karyawan).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *komponenGajiRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("komponen_gaji_id = ?", id).Delete(&models.KomponenJabatan{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("komponen_gaji_id = ?", id).Delete(&models.KomponenKaryawan{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.KomponenGaji{}, id).Error
+	})
+}
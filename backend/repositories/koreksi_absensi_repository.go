@@ -0,0 +1,153 @@
+package repositories
+
+import (
+	"pemdes-payroll/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type KoreksiAbsensiRepository interface {
+	Create(pengajuan *models.PengajuanKoreksiAbsensi) error
+	GetAll(status string, karyawanID uint) ([]models.PengajuanKoreksiAbsensi, error)
+	GetByID(id uint) (*models.PengajuanKoreksiAbsensi, error)
+	GetDiajukan(karyawanID uint, tanggal time.Time) (*models.PengajuanKoreksiAbsensi, error)
+	Setujui(pengajuan *models.PengajuanKoreksiAbsensi, diputuskanOleh *uint, catatan string) error
+	Putuskan(pengajuan *models.PengajuanKoreksiAbsensi, status models.StatusKoreksiAbsensi, diputuskanOleh *uint, catatan string) error
+}
+
+type koreksiAbsensiRepository struct {
+	db *gorm.DB
+}
+
+// NewKoreksiAbsensiRepository creates a new KoreksiAbsensi repository
+func NewKoreksiAbsensiRepository(db *gorm.DB) KoreksiAbsensiRepository {
+	return &koreksiAbsensiRepository{db: db}
+}
+
+func (r *koreksiAbsensiRepository) Create(pengajuan *models.PengajuanKoreksiAbsensi) error {
+	return r.db.Create(pengajuan).Error
+}
+
+// GetAll lists correction requests, optionally of one status or karyawan
+func (r *koreksiAbsensiRepository) GetAll(status string, karyawanID uint) ([]models.PengajuanKoreksiAbsensi, error) {
+	var pengajuan []models.PengajuanKoreksiAbsensi
+	query := r.db.Preload("Karyawan")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if karyawanID != 0 {
+		query = query.Where("karyawan_id = ?", karyawanID)
+	}
+	err := query.Order("tanggal DESC, created_at DESC").Find(&pengajuan).Error
+	return pengajuan, err
+}
+
+func (r *koreksiAbsensiRepository) GetByID(id uint) (*models.PengajuanKoreksiAbsensi, error) {
+	var pengajuan models.PengajuanKoreksiAbsensi
+	err := r.db.Preload("Karyawan").First(&pengajuan, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pengajuan, nil
+}
+
+// GetDiajukan returns the pending correction request of the karyawan for tanggal
+func (r *koreksiAbsensiRepository) GetDiajukan(karyawanID uint, tanggal time.Time) (*models.PengajuanKoreksiAbsensi, error) {
+	var pengajuan models.PengajuanKoreksiAbsensi
+	err := r.db.Where("karyawan_id = ? AND tanggal = ? AND status = ?", karyawanID, tanggal.Format("2006-01-02"), models.KoreksiAbsensiDiajukan).
+		First(&pengajuan).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pengajuan, nil
+}
+
+// Setujui approves a correction request: the requested values are written to the day's
+// absensi row, created when missing, and the previous and corrected values are kept in
+// its riwayat. It fails with ErrAbsensiBentrok when the day belongs to an approved cuti
+// and with ErrAbsensiDigaji when it has been paid in a slip gaji.
+func (r *koreksiAbsensiRepository) Setujui(pengajuan *models.PengajuanKoreksiAbsensi, diputuskanOleh *uint, catatan string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var semula models.Absensi
+		err := tx.Where("karyawan_id = ? AND tanggal = ?", pengajuan.KaryawanID, pengajuan.Tanggal.Format("2006-01-02")).First(&semula).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		ada := err == nil
+		if ada && semula.PengajuanCutiID != nil {
+			return ErrAbsensiBentrok
+		}
+		if ada && semula.GajiID != nil {
+			return ErrAbsensiDigaji
+		}
+
+		baru := semula
+		if !ada {
+			baru = models.Absensi{
+				KaryawanID: pengajuan.KaryawanID,
+				Tanggal:    pengajuan.Tanggal,
+				Status:     models.AbsensiHadir,
+			}
+		}
+		if pengajuan.JamMasuk != "" {
+			baru.JamMasuk = pengajuan.JamMasuk
+		}
+		if pengajuan.JamKeluar != "" {
+			baru.JamKeluar = pengajuan.JamKeluar
+		}
+		if pengajuan.StatusAbsensi != "" {
+			baru.Status = pengajuan.StatusAbsensi
+		}
+		baru.Sumber = models.SumberAbsensiKoreksi
+
+		if ada {
+			err = tx.Model(&semula).Updates(map[string]interface{}{
+				"jam_masuk":  baru.JamMasuk,
+				"jam_keluar": baru.JamKeluar,
+				"status":     baru.Status,
+				"sumber":     baru.Sumber,
+			}).Error
+		} else {
+			baru.Keterangan = "Koreksi absensi"
+			err = tx.Create(&baru).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		var asal *models.Absensi
+		if ada {
+			asal = &semula
+		}
+		if err := catatRiwayat(tx, asal, &baru, &pengajuan.ID, diputuskanOleh, pengajuan.Alasan); err != nil {
+			return err
+		}
+
+		pengajuan.AbsensiID = &baru.ID
+		if err := tx.Model(pengajuan).Update("absensi_id", baru.ID).Error; err != nil {
+			return err
+		}
+		return putuskanKoreksi(tx, pengajuan, models.KoreksiAbsensiDisetujui, diputuskanOleh, catatan)
+	})
+}
+
+// Putuskan rejects or withdraws a request that has not been approved
+func (r *koreksiAbsensiRepository) Putuskan(pengajuan *models.PengajuanKoreksiAbsensi, status models.StatusKoreksiAbsensi, diputuskanOleh *uint, catatan string) error {
+	return putuskanKoreksi(r.db, pengajuan, status, diputuskanOleh, catatan)
+}
+
+// putuskanKoreksi records the new status of a correction request and who set it
+func putuskanKoreksi(db *gorm.DB, pengajuan *models.PengajuanKoreksiAbsensi, status models.StatusKoreksiAbsensi, oleh *uint, catatan string) error {
+	now := time.Now()
+	pengajuan.Status = status
+	pengajuan.DiputuskanOleh = oleh
+	pengajuan.DiputuskanPada = &now
+	pengajuan.CatatanKeputusan = catatan
+	return db.Model(pengajuan).Updates(map[string]interface{}{
+		"status":            status,
+		"diputuskan_oleh":   oleh,
+		"diputuskan_pada":   now,
+		"catatan_keputusan": catatan,
+	}).Error
+}
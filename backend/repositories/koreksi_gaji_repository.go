@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"pemdes-payroll/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type KoreksiGajiRepository interface {
+	Create(koreksi *models.KoreksiGaji) error
+	GetAll(status string) ([]models.KoreksiGaji, error)
+	GetByID(id uint) (*models.KoreksiGaji, error)
+	GetByGajiID(gajiID uint) ([]models.KoreksiGaji, error)
+	GetByLemburID(lemburID uint) ([]models.KoreksiGaji, error)
+	GetByTanggalAbsensi(karyawanID uint, tanggal time.Time) ([]models.KoreksiGaji, error)
+	GetUntukGaji(karyawanID, gajiID uint) ([]models.KoreksiGaji, error)
+	SetDiterapkan(gajiID uint, koreksiIDs []uint) error
+	Delete(id uint) error
+}
+
+type koreksiGajiRepository struct {
+	db *gorm.DB
+}
+
+// NewKoreksiGajiRepository creates a new KoreksiGaji repository
+func NewKoreksiGajiRepository(db *gorm.DB) KoreksiGajiRepository {
+	return &koreksiGajiRepository{db: db}
+}
+
+func (r *koreksiGajiRepository) Create(koreksi *models.KoreksiGaji) error {
+	return r.db.Create(koreksi).Error
+}
+
+func (r *koreksiGajiRepository) GetAll(status string) ([]models.KoreksiGaji, error) {
+	var koreksi []models.KoreksiGaji
+	query := r.db.Preload("Karyawan.Jabatan")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("created_at DESC").Find(&koreksi).Error
+	return koreksi, err
+}
+
+func (r *koreksiGajiRepository) GetByID(id uint) (*models.KoreksiGaji, error) {
+	var koreksi models.KoreksiGaji
+	err := r.db.Preload("Karyawan.Jabatan").First(&koreksi, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &koreksi, nil
+}
+
+func (r *koreksiGajiRepository) GetByGajiID(gajiID uint) ([]models.KoreksiGaji, error) {
+	var koreksi []models.KoreksiGaji
+	err := r.db.Where("gaji_id = ?", gajiID).Order("created_at").Find(&koreksi).Error
+	return koreksi, err
+}
+
+// GetByLemburID returns the corrections of a paid lembur record
+func (r *koreksiGajiRepository) GetByLemburID(lemburID uint) ([]models.KoreksiGaji, error) {
+	var koreksi []models.KoreksiGaji
+	err := r.db.Where("lembur_id = ?", lemburID).Order("created_at").Find(&koreksi).Error
+	return koreksi, err
+}
+
+// GetByTanggalAbsensi returns the corrections of a karyawan's paid day of attendance,
+// recorded by the day or by the absensi row of that day
+func (r *koreksiGajiRepository) GetByTanggalAbsensi(karyawanID uint, tanggal time.Time) ([]models.KoreksiGaji, error) {
+	var koreksi []models.KoreksiGaji
+	absensiHari := r.db.Model(&models.Absensi{}).Select("id").Where("karyawan_id = ? AND tanggal = ?", karyawanID, tanggal)
+	err := r.db.Where("karyawan_id = ? AND (tanggal_absensi = ? OR absensi_id IN ?)", karyawanID, tanggal, absensiHari).
+		Order("created_at").Find(&koreksi).Error
+	return koreksi, err
+}
+
+// GetUntukGaji returns the corrections to carry into a slip: the karyawan's pending
+// corrections plus those already carried into this slip when it is recalculated
+func (r *koreksiGajiRepository) GetUntukGaji(karyawanID, gajiID uint) ([]models.KoreksiGaji, error) {
+	var koreksi []models.KoreksiGaji
+	query := r.db.Where("karyawan_id = ?", karyawanID)
+	if gajiID > 0 {
+		query = query.Where("(status = ? OR diterapkan_gaji_id = ?)", models.KoreksiPending, gajiID)
+	} else {
+		query = query.Where("status = ?", models.KoreksiPending)
+	}
+	err := query.Order("created_at").Find(&koreksi).Error
+	return koreksi, err
+}
+
+// SetDiterapkan records which corrections are carried by a slip. Corrections the slip
+// no longer carries go back to pending.
+func (r *koreksiGajiRepository) SetDiterapkan(gajiID uint, koreksiIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.KoreksiGaji{}).Where("diterapkan_gaji_id = ?", gajiID).
+			Updates(map[string]interface{}{"status": models.KoreksiPending, "diterapkan_gaji_id": nil}).Error
+		if err != nil {
+			return err
+		}
+		if len(koreksiIDs) == 0 {
+			return nil
+		}
+		return tx.Model(&models.KoreksiGaji{}).Where("id IN ?", koreksiIDs).
+			Updates(map[string]interface{}{"status": models.KoreksiDiterapkan, "diterapkan_gaji_id": gajiID}).Error
+	})
+}
+
+func (r *koreksiGajiRepository) Delete(id uint) error {
+	return r.db.Delete(&models.KoreksiGaji{}, id).Error
+}
@@ -7,9 +7,10 @@ import (
 )
 
 type LaporanRepository interface {
-	GetLaporanGajiByPeriod(bulan, tahun int) ([]models.LaporanGaji, error)
+	GetLaporanGajiByPeriod(bulan, tahun int, jenis models.JenisGaji) ([]models.LaporanGaji, error)
 	GetRiwayatGajiKaryawan(karyawanID uint) ([]models.LaporanGaji, error)
-	GetRekapGaji(bulan, tahun int) (*models.RekapGaji, error)
+	GetRekapGaji(bulan, tahun int, jenis models.JenisGaji) (*models.RekapGaji, error)
+	GetLaporanBPJSByPeriod(bulan, tahun int) ([]models.LaporanBPJS, error)
 }
 
 type laporanRepository struct {
@@ -21,69 +22,86 @@ func NewLaporanRepository(db *gorm.DB) LaporanRepository {
 	return &laporanRepository{db: db}
 }
 
-func (r *laporanRepository) GetLaporanGajiByPeriod(bulan, tahun int) ([]models.LaporanGaji, error) {
+// laporanGajiSelect selects the slip-level columns of a salary report; component
+// amounts come from the line items attached by attachItems
+const laporanGajiSelect = `
+	SELECT
+		g.id,
+		g.karyawan_id,
+		k.nik,
+		k.nama AS nama_karyawan,
+		COALESCE(j.nama_jabatan, '-') AS jabatan,
+		g.periode_bulan,
+		g.periode_tahun,
+		g.jenis,
+		(g.bpjs_kesehatan_perusahaan + g.jht_perusahaan + g.jkk_perusahaan + g.jkm_perusahaan + g.jp_perusahaan) AS iuran_perusahaan,
+		g.total_gaji,
+		g.status
+	FROM gaji g
+	INNER JOIN karyawan k ON g.karyawan_id = k.id
+	LEFT JOIN jabatan j ON k.jabatan_id = j.id
+	LEFT JOIN payroll_run pr ON g.payroll_run_id = pr.id
+`
+
+// jenisRunFilter limits a report to the slips of the payroll runs of one jenis. Slips
+// outside a run belong to the regular run.
+const jenisRunFilter = "COALESCE(pr.jenis, 'reguler') = ?"
+
+// attachItems loads the line items of every report row and fills in its summary columns
+func (r *laporanRepository) attachItems(results []models.LaporanGaji) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(results))
+	index := make(map[uint]int, len(results))
+	for i, l := range results {
+		ids[i] = l.ID
+		index[l.ID] = i
+	}
+
+	var items []models.GajiItem
+	if err := r.db.Where("gaji_id IN ?", ids).Order("id").Find(&items).Error; err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		results[index[item.GajiID]].AddItem(item)
+	}
+	return nil
+}
+
+// GetLaporanGajiByPeriod returns the slips of the period's payroll run of the given jenis
+func (r *laporanRepository) GetLaporanGajiByPeriod(bulan, tahun int, jenis models.JenisGaji) ([]models.LaporanGaji, error) {
 	var results []models.LaporanGaji
 
-	query := `
-		SELECT
-			g.id,
-			g.karyawan_id,
-			k.nik,
-			k.nama AS nama_karyawan,
-			COALESCE(j.nama_jabatan, '-') AS jabatan,
-			g.periode_bulan,
-			g.periode_tahun,
-			g.gaji_pokok,
-			g.tunjangan_jabatan,
-			g.tunjangan_transport,
-			g.tunjangan_makan,
-			g.lembur,
-			g.potongan,
-			g.total_gaji,
-			g.status
-		FROM gaji g
-		INNER JOIN karyawan k ON g.karyawan_id = k.id
-		LEFT JOIN jabatan j ON k.jabatan_id = j.id
-		WHERE g.periode_bulan = ? AND g.periode_tahun = ?
-		ORDER BY k.nama ASC
+	query := laporanGajiSelect + `
+		WHERE g.periode_bulan = ? AND g.periode_tahun = ? AND ` + jenisRunFilter + `
+		ORDER BY k.nama ASC, g.id ASC
 	`
 
-	err := r.db.Raw(query, bulan, tahun).Scan(&results).Error
-	return results, err
+	if err := r.db.Raw(query, bulan, tahun, jenis).Scan(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, r.attachItems(results)
 }
 
 func (r *laporanRepository) GetRiwayatGajiKaryawan(karyawanID uint) ([]models.LaporanGaji, error) {
 	var results []models.LaporanGaji
 
-	query := `
-		SELECT
-			g.id,
-			g.karyawan_id,
-			k.nik,
-			k.nama AS nama_karyawan,
-			COALESCE(j.nama_jabatan, '-') AS jabatan,
-			g.periode_bulan,
-			g.periode_tahun,
-			g.gaji_pokok,
-			g.tunjangan_jabatan,
-			g.tunjangan_transport,
-			g.tunjangan_makan,
-			g.lembur,
-			g.potongan,
-			g.total_gaji,
-			g.status
-		FROM gaji g
-		INNER JOIN karyawan k ON g.karyawan_id = k.id
-		LEFT JOIN jabatan j ON k.jabatan_id = j.id
+	query := laporanGajiSelect + `
 		WHERE k.id = ?
-		ORDER BY g.periode_tahun DESC, g.periode_bulan DESC
+		ORDER BY g.periode_tahun DESC, g.periode_bulan DESC, g.id ASC
 	`
 
-	err := r.db.Raw(query, karyawanID).Scan(&results).Error
-	return results, err
+	if err := r.db.Raw(query, karyawanID).Scan(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, r.attachItems(results)
 }
 
-func (r *laporanRepository) GetRekapGaji(bulan, tahun int) (*models.RekapGaji, error) {
+// GetRekapGaji sums the slips of the period's payroll run of the given jenis
+func (r *laporanRepository) GetRekapGaji(bulan, tahun int, jenis models.JenisGaji) (*models.RekapGaji, error) {
 	var result models.RekapGaji
 
 	query := `
@@ -91,17 +109,73 @@ func (r *laporanRepository) GetRekapGaji(bulan, tahun int) (*models.RekapGaji, e
 			? AS periode_bulan,
 			? AS periode_tahun,
 			COUNT(DISTINCT g.karyawan_id) AS total_karyawan,
-			COALESCE(SUM(g.gaji_pokok), 0) AS total_gaji_pokok,
-			COALESCE(SUM(g.tunjangan_jabatan + g.tunjangan_transport + g.tunjangan_makan), 0) AS total_tunjangan,
-			COALESCE(SUM(g.lembur), 0) AS total_lembur,
-			COALESCE(SUM(g.potongan), 0) AS total_potongan,
+			COALESCE(SUM(g.bpjs_kesehatan_perusahaan + g.jht_perusahaan + g.jkk_perusahaan + g.jkm_perusahaan + g.jp_perusahaan), 0) AS total_iuran_perusahaan,
 			COALESCE(SUM(g.total_gaji), 0) AS total_gaji,
-			SUM(CASE WHEN g.status = 'pending' THEN 1 ELSE 0 END) AS status_pending,
-			SUM(CASE WHEN g.status = 'dibayar' THEN 1 ELSE 0 END) AS status_dibayar
+			COALESCE(SUM(CASE WHEN g.status = 'pending' THEN 1 ELSE 0 END), 0) AS status_pending,
+			COALESCE(SUM(CASE WHEN g.status = 'dibayar' THEN 1 ELSE 0 END), 0) AS status_dibayar
+		FROM gaji g
+		LEFT JOIN payroll_run pr ON g.payroll_run_id = pr.id
+		WHERE g.periode_bulan = ? AND g.periode_tahun = ? AND ` + jenisRunFilter + `
+	`
+
+	if err := r.db.Raw(query, bulan, tahun, bulan, tahun, jenis).Scan(&result).Error; err != nil {
+		return nil, err
+	}
+
+	type itemTotal struct {
+		Kode  string
+		Jenis models.JenisKomponen
+		Total float64
+	}
+
+	var totals []itemTotal
+	err := r.db.Table("gaji_item gi").
+		Select("gi.kode, gi.jenis, SUM(gi.jumlah) AS total").
+		Joins("INNER JOIN gaji g ON gi.gaji_id = g.id").
+		Joins("LEFT JOIN payroll_run pr ON g.payroll_run_id = pr.id").
+		Where("g.periode_bulan = ? AND g.periode_tahun = ?", bulan, tahun).
+		Where(jenisRunFilter, jenis).
+		Group("gi.kode, gi.jenis").
+		Scan(&totals).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result.Jenis = jenis
+	for _, t := range totals {
+		result.AddItemTotal(t.Kode, t.Jenis, t.Total)
+	}
+
+	return &result, nil
+}
+
+// GetLaporanBPJSByPeriod returns the contributions of the period's regular slips, the
+// only slips BPJS is computed on
+func (r *laporanRepository) GetLaporanBPJSByPeriod(bulan, tahun int) ([]models.LaporanBPJS, error) {
+	var results []models.LaporanBPJS
+
+	query := `
+		SELECT
+			g.karyawan_id,
+			k.nik,
+			k.nama AS nama_karyawan,
+			g.periode_bulan,
+			g.periode_tahun,
+			(g.gaji_pokok + g.tunjangan_jabatan) AS upah_bpjs,
+			g.bpjs_kesehatan_karyawan,
+			g.bpjs_kesehatan_perusahaan,
+			g.jht_karyawan,
+			g.jht_perusahaan,
+			g.jkk_perusahaan,
+			g.jkm_perusahaan,
+			g.jp_karyawan,
+			g.jp_perusahaan
 		FROM gaji g
-		WHERE g.periode_bulan = ? AND g.periode_tahun = ?
+		INNER JOIN karyawan k ON g.karyawan_id = k.id
+		WHERE g.periode_bulan = ? AND g.periode_tahun = ? AND g.jenis = ?
+		ORDER BY k.nama ASC
 	`
 
-	err := r.db.Raw(query, bulan, tahun, bulan, tahun).Scan(&result).Error
-	return &result, err
+	err := r.db.Raw(query, bulan, tahun, models.JenisGajiReguler).Scan(&results).Error
+	return results, err
 }
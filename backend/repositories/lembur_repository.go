@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"pemdes-payroll/backend/models"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -14,9 +15,16 @@ type LemburRepository interface {
 	GetByPeriod(bulan, tahun int) ([]models.Lembur, error)
 	GetByKaryawanAndPeriod(karyawanID, bulan, tahun int) ([]models.Lembur, error)
 	Update(id uint, lembur *models.Lembur) error
+	UpdatePerhitungan(id uint, lembur *models.Lembur) error
 	Delete(id uint) error
-	Approve(id uint, approverID *uint, status string) error
+	Approve(id uint, diputuskanOleh *uint, status string) error
+	SetDihitung(gajiID uint, lemburIDs []uint) error
 	GetTotalLemburByPeriod(karyawanID, bulan, tahun int) (float64, float64, error)
+	GetBentrok(karyawanID uint, awal, akhir time.Time, kecualiID uint) ([]models.Lembur, error)
+	GetRealisasi(suratPerintahID, karyawanID uint) ([]models.Lembur, error)
+	GetPemakaian(karyawanID uint, awal, akhir time.Time, status []string, kecualiID uint) (float64, float64, error)
+	GetAnggaran(bulan, tahun int) (*models.AnggaranLembur, error)
+	SimpanAnggaran(anggaran *models.AnggaranLembur) error
 }
 
 type lemburRepository struct {
@@ -28,19 +36,24 @@ func NewLemburRepository(db *gorm.DB) LemburRepository {
 	return &lemburRepository{db: db}
 }
 
+// urutRincian orders the multiplier tiers of an overtime record
+func urutRincian(db *gorm.DB) *gorm.DB {
+	return db.Order("urutan")
+}
+
 func (r *lemburRepository) Create(lembur *models.Lembur) error {
 	return r.db.Create(lembur).Error
 }
 
 func (r *lemburRepository) GetAll() ([]models.Lembur, error) {
 	var lembur []models.Lembur
-	err := r.db.Preload("Karyawan.Jabatan").Order("tanggal DESC, created_at DESC").Find(&lembur).Error
+	err := r.db.Preload("Karyawan.Jabatan").Preload("Rincian", urutRincian).Order("tanggal DESC, created_at DESC").Find(&lembur).Error
 	return lembur, err
 }
 
 func (r *lemburRepository) GetByID(id uint) (*models.Lembur, error) {
 	var lembur models.Lembur
-	err := r.db.Preload("Karyawan.Jabatan").First(&lembur, id).Error
+	err := r.db.Preload("Karyawan.Jabatan").Preload("Rincian", urutRincian).First(&lembur, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -49,21 +62,21 @@ func (r *lemburRepository) GetByID(id uint) (*models.Lembur, error) {
 
 func (r *lemburRepository) GetByKaryawanID(karyawanID uint) ([]models.Lembur, error) {
 	var lembur []models.Lembur
-	err := r.db.Preload("Karyawan.Jabatan").Where("karyawan_id = ?", karyawanID).
+	err := r.db.Preload("Karyawan.Jabatan").Preload("Rincian", urutRincian).Where("karyawan_id = ?", karyawanID).
 		Order("tanggal DESC").Find(&lembur).Error
 	return lembur, err
 }
 
 func (r *lemburRepository) GetByPeriod(bulan, tahun int) ([]models.Lembur, error) {
 	var lembur []models.Lembur
-	err := r.db.Preload("Karyawan.Jabatan").Where("MONTH(tanggal) = ? AND YEAR(tanggal) = ?", bulan, tahun).
+	err := r.db.Preload("Karyawan.Jabatan").Preload("Rincian", urutRincian).Where("MONTH(tanggal) = ? AND YEAR(tanggal) = ?", bulan, tahun).
 		Order("tanggal DESC, karyawan_id").Find(&lembur).Error
 	return lembur, err
 }
 
 func (r *lemburRepository) GetByKaryawanAndPeriod(karyawanID, bulan, tahun int) ([]models.Lembur, error) {
 	var lembur []models.Lembur
-	err := r.db.Preload("Karyawan.Jabatan").Where("karyawan_id = ? AND MONTH(tanggal) = ? AND YEAR(tanggal) = ?",
+	err := r.db.Preload("Karyawan.Jabatan").Preload("Rincian", urutRincian).Where("karyawan_id = ? AND MONTH(tanggal) = ? AND YEAR(tanggal) = ?",
 		karyawanID, bulan, tahun).
 		Order("tanggal DESC").Find(&lembur).Error
 	return lembur, err
@@ -77,21 +90,75 @@ func (r *lemburRepository) Update(id uint, lembur *models.Lembur) error {
 	return r.db.Model(&existing).Updates(lembur).Error
 }
 
-func (r *lemburRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Lembur{}, id).Error
+// UpdatePerhitungan writes the date, times and rate of an overtime record with the hours,
+// multiplier tiers and nominal derived from them, including a break of zero minutes
+func (r *lemburRepository) UpdatePerhitungan(id uint, lembur *models.Lembur) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Lembur{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"tanggal":         lembur.Tanggal,
+			"jam_mulai":       lembur.JamMulai,
+			"jam_selesai":     lembur.JamSelesai,
+			"istirahat_menit": lembur.IstirahatMenit,
+			"total_jam":       lembur.TotalJam,
+			"tarif_per_jam":   lembur.TarifPerJam,
+			"total_nominal":   lembur.TotalNominal,
+			"jenis_hari":      lembur.JenisHari,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return simpanRincianLembur(tx, id, lembur.Rincian)
+	})
 }
 
-// Approve updates overtime approval status
-func (r *lemburRepository) Approve(id uint, approverID *uint, status string) error {
-	updates := map[string]interface{}{
-		"status": status,
+// simpanRincianLembur replaces the multiplier tiers of an overtime record
+func simpanRincianLembur(tx *gorm.DB, lemburID uint, rincian []models.RincianLembur) error {
+	if err := tx.Where("lembur_id = ?", lemburID).Delete(&models.RincianLembur{}).Error; err != nil {
+		return err
+	}
+	if len(rincian) == 0 {
+		return nil
 	}
-	if approverID != nil {
-		updates["disetujui_oleh"] = *approverID
-	} else {
-		updates["disetujui_oleh"] = nil
+	for i := range rincian {
+		rincian[i].ID = 0
+		rincian[i].LemburID = lemburID
 	}
-	return r.db.Model(&models.Lembur{}).Where("id = ?", id).Updates(updates).Error
+	return tx.Create(&rincian).Error
+}
+
+func (r *lemburRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("lembur_id = ?", id).Delete(&models.RincianLembur{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Lembur{}, id).Error
+	})
+}
+
+// Approve records the decision on an overtime record and the user who made it
+func (r *lemburRepository) Approve(id uint, diputuskanOleh *uint, status string) error {
+	return r.db.Model(&models.Lembur{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":          status,
+		"diputuskan_oleh": diputuskanOleh,
+		"diputuskan_pada": time.Now(),
+	}).Error
+}
+
+// SetDihitung records which overtime a slip's lembur column was computed from. Overtime
+// the slip no longer counts is released; overtime already paid is left alone.
+func (r *lemburRepository) SetDihitung(gajiID uint, lemburIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Lembur{}).Where("dihitung_gaji_id = ? AND gaji_id IS NULL", gajiID).
+			Update("dihitung_gaji_id", nil).Error
+		if err != nil {
+			return err
+		}
+		if len(lemburIDs) == 0 {
+			return nil
+		}
+		return tx.Model(&models.Lembur{}).Where("id IN ? AND gaji_id IS NULL", lemburIDs).
+			Update("dihitung_gaji_id", gajiID).Error
+	})
 }
 
 // GetTotalLemburByPeriod calculates total hours and nominal for approved overtime in a period
@@ -110,3 +177,70 @@ func (r *lemburRepository) GetTotalLemburByPeriod(karyawanID, bulan, tahun int)
 
 	return result.TotalJam, result.TotalNominal, err
 }
+
+// GetBentrok returns the karyawan's overtime dated from awal up to akhir that was not
+// rejected, other than kecualiID, to check a new record for overlaps
+func (r *lemburRepository) GetBentrok(karyawanID uint, awal, akhir time.Time, kecualiID uint) ([]models.Lembur, error) {
+	var lembur []models.Lembur
+	err := r.db.Where("karyawan_id = ? AND id <> ? AND status <> 'ditolak' AND tanggal BETWEEN ? AND ?",
+		karyawanID, kecualiID, awal, akhir).
+		Order("tanggal, jam_mulai").Find(&lembur).Error
+	return lembur, err
+}
+
+// GetRealisasi returns the overtime the karyawan reported against an order, leaving out
+// rejected reports
+func (r *lemburRepository) GetRealisasi(suratPerintahID, karyawanID uint) ([]models.Lembur, error) {
+	var lembur []models.Lembur
+	err := r.db.Where("surat_perintah_id = ? AND karyawan_id = ? AND status <> ?", suratPerintahID, karyawanID, "ditolak").
+		Find(&lembur).Error
+	return lembur, err
+}
+
+// GetPemakaian totals the hours and nominal of overtime dated from awal up to akhir with
+// one of the given statuses, other than kecualiID. A karyawanID of 0 totals the whole desa.
+func (r *lemburRepository) GetPemakaian(karyawanID uint, awal, akhir time.Time, status []string, kecualiID uint) (float64, float64, error) {
+	var result struct {
+		TotalJam     float64
+		TotalNominal float64
+	}
+	query := r.db.Model(&models.Lembur{}).
+		Select("COALESCE(SUM(total_jam), 0) as total_jam, COALESCE(SUM(total_nominal), 0) as total_nominal").
+		Where("tanggal BETWEEN ? AND ? AND status IN ? AND id <> ?", awal, akhir, status, kecualiID)
+	if karyawanID != 0 {
+		query = query.Where("karyawan_id = ?", karyawanID)
+	}
+	err := query.Scan(&result).Error
+	return result.TotalJam, result.TotalNominal, err
+}
+
+// GetAnggaran returns the desa's overtime budget for a period
+func (r *lemburRepository) GetAnggaran(bulan, tahun int) (*models.AnggaranLembur, error) {
+	var anggaran models.AnggaranLembur
+	err := r.db.Where("bulan = ? AND tahun = ?", bulan, tahun).First(&anggaran).Error
+	if err != nil {
+		return nil, err
+	}
+	return &anggaran, nil
+}
+
+// SimpanAnggaran sets the overtime budget of the period, replacing an earlier one
+func (r *lemburRepository) SimpanAnggaran(anggaran *models.AnggaranLembur) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.AnggaranLembur
+		err := tx.Where("bulan = ? AND tahun = ?", anggaran.Bulan, anggaran.Tahun).First(&existing).Error
+		if err == gorm.ErrRecordNotFound {
+			return tx.Create(anggaran).Error
+		}
+		if err != nil {
+			return err
+		}
+		anggaran.ID = existing.ID
+		anggaran.CreatedAt = existing.CreatedAt
+		return tx.Model(&existing).Updates(map[string]interface{}{
+			"jumlah":      anggaran.Jumlah,
+			"keterangan":  anggaran.Keterangan,
+			"dibuat_oleh": anggaran.DibuatOleh,
+		}).Error
+	})
+}
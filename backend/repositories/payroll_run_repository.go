@@ -0,0 +1,281 @@
+package repositories
+
+import (
+	"pemdes-payroll/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PayrollRunRepository interface {
+	Create(run *models.PayrollRun) error
+	GetAll() ([]models.PayrollRun, error)
+	GetByID(id uint) (*models.PayrollRun, error)
+	GetByPeriod(bulan, tahun int, jenis models.JenisGaji) (*models.PayrollRun, error)
+	FindOrCreate(bulan, tahun int, jenis models.JenisGaji, dibuatOleh *uint) (*models.PayrollRun, error)
+	UpdateStatus(run *models.PayrollRun) error
+	IsPeriodeTerkunci(karyawanID uint, tanggal time.Time) (bool, error)
+	MigrateLegacyRuns() (int, error)
+	MigrateSumberGaji() (int, error)
+}
+
+type payrollRunRepository struct {
+	db *gorm.DB
+}
+
+// NewPayrollRunRepository creates a new PayrollRun repository
+func NewPayrollRunRepository(db *gorm.DB) PayrollRunRepository {
+	return &payrollRunRepository{db: db}
+}
+
+func (r *payrollRunRepository) Create(run *models.PayrollRun) error {
+	return r.db.Create(run).Error
+}
+
+func (r *payrollRunRepository) GetAll() ([]models.PayrollRun, error) {
+	var runs []models.PayrollRun
+	err := r.db.Preload("Penyetuju").Order("periode_tahun DESC, periode_bulan DESC").Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	type Result struct {
+		PayrollRunID uint
+		JumlahSlip   int
+		TotalGaji    float64
+	}
+
+	var results []Result
+	err = r.db.Model(&models.Gaji{}).
+		Select("payroll_run_id, COUNT(*) as jumlah_slip, COALESCE(SUM(total_gaji), 0) as total_gaji").
+		Where("payroll_run_id IS NOT NULL").
+		Group("payroll_run_id").
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[uint]Result, len(results))
+	for _, res := range results {
+		totals[res.PayrollRunID] = res
+	}
+	for i := range runs {
+		runs[i].JumlahSlip = totals[runs[i].ID].JumlahSlip
+		runs[i].TotalGaji = totals[runs[i].ID].TotalGaji
+	}
+
+	return runs, nil
+}
+
+func (r *payrollRunRepository) GetByID(id uint) (*models.PayrollRun, error) {
+	var run models.PayrollRun
+	err := r.db.Preload("Penyetuju").Preload("Gaji.Karyawan.Jabatan").Preload("Gaji.Items").First(&run, id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	run.JumlahSlip = len(run.Gaji)
+	for _, g := range run.Gaji {
+		run.TotalGaji += g.TotalGaji
+	}
+	return &run, nil
+}
+
+func (r *payrollRunRepository) GetByPeriod(bulan, tahun int, jenis models.JenisGaji) (*models.PayrollRun, error) {
+	var run models.PayrollRun
+	err := r.db.Where("periode_bulan = ? AND periode_tahun = ? AND jenis = ?", bulan, tahun, jenis).First(&run).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// FindOrCreate returns the payroll run of a period and run type, starting a draft run
+// when there is none
+func (r *payrollRunRepository) FindOrCreate(bulan, tahun int, jenis models.JenisGaji, dibuatOleh *uint) (*models.PayrollRun, error) {
+	run, err := r.GetByPeriod(bulan, tahun, jenis)
+	if err == nil {
+		return run, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	run = &models.PayrollRun{
+		PeriodeBulan: bulan,
+		PeriodeTahun: tahun,
+		Jenis:        jenis,
+		Status:       models.PayrollRunDraft,
+		DibuatOleh:   dibuatOleh,
+	}
+	if err := r.db.Create(run).Error; err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// UpdateStatus saves the run's status and approval trail. Approving a run links the
+// lembur and absensi of its slips to them; moving it to paid marks all of its slips as
+// dibayar.
+func (r *payrollRunRepository) UpdateStatus(run *models.PayrollRun) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.PayrollRun{}).Where("id = ?", run.ID).Updates(map[string]interface{}{
+			"status":         run.Status,
+			"catatan":        run.Catatan,
+			"direview_oleh":  run.DireviewOleh,
+			"direview_pada":  run.DireviewPada,
+			"disetujui_oleh": run.DisetujuiOleh,
+			"disetujui_pada": run.DisetujuiPada,
+			"dibayar_pada":   run.DibayarPada,
+			"dikunci_pada":   run.DikunciPada,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if run.Status == models.PayrollRunApproved {
+			_, err := tautkanSumberGaji(tx, []uint{run.ID})
+			return err
+		}
+		if run.Status != models.PayrollRunPaid {
+			return nil
+		}
+		return tx.Model(&models.Gaji{}).Where("payroll_run_id = ?", run.ID).
+			Update("status", models.GajiStatusDibayar).Error
+	})
+}
+
+// IsPeriodeTerkunci checks whether the karyawan's regular slip for the month of tanggal
+// belongs to an approved, paid or locked payroll run
+func (r *payrollRunRepository) IsPeriodeTerkunci(karyawanID uint, tanggal time.Time) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Gaji{}).
+		Joins("INNER JOIN payroll_run pr ON pr.id = gaji.payroll_run_id").
+		Where("gaji.karyawan_id = ? AND gaji.periode_bulan = ? AND gaji.periode_tahun = ?",
+			karyawanID, int(tanggal.Month()), tanggal.Year()).
+		Where("pr.jenis = ?", models.JenisGajiReguler).
+		Where("pr.status IN ?", []models.PayrollRunStatus{
+			models.PayrollRunApproved, models.PayrollRunPaid, models.PayrollRunLocked,
+		}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// tautkanSumberGaji links the lembur and absensi each regular slip of the given runs, a
+// list of IDs or a subquery, was computed from to that slip. Rows already linked are left
+// alone. It returns the number of rows linked.
+func tautkanSumberGaji(tx *gorm.DB, runIDs interface{}) (int, error) {
+	slip := tx.Model(&models.Gaji{}).Select("id").Where("payroll_run_id IN ? AND jenis = ?", runIDs, models.JenisGajiReguler)
+	lembur := tx.Model(&models.Lembur{}).
+		Where("dihitung_gaji_id IN ? AND status = ? AND gaji_id IS NULL", slip, "disetujui").
+		Update("gaji_id", gorm.Expr("dihitung_gaji_id"))
+	if lembur.Error != nil {
+		return 0, lembur.Error
+	}
+	absensi := tx.Model(&models.Absensi{}).
+		Where("dihitung_gaji_id IN ? AND gaji_id IS NULL", slip).
+		Update("gaji_id", gorm.Expr("dihitung_gaji_id"))
+	if absensi.Error != nil {
+		return 0, absensi.Error
+	}
+	return int(lembur.RowsAffected + absensi.RowsAffected), nil
+}
+
+// MigrateSumberGaji links the lembur and absensi paid by runs approved before slips
+// recorded their sources. The approved overtime of a slip's period is taken as its source
+// only when it totals the slip's lembur column, and the attendance rows only when their
+// counts per status match the slip's.
+func (r *payrollRunRepository) MigrateSumberGaji() (int, error) {
+	runIDs := r.db.Model(&models.PayrollRun{}).Select("id").Where("status IN ?", []models.PayrollRunStatus{
+		models.PayrollRunApproved, models.PayrollRunPaid, models.PayrollRunLocked,
+	})
+	var migrated int
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`UPDATE lembur l
+			INNER JOIN gaji g ON g.karyawan_id = l.karyawan_id
+				AND g.periode_bulan = MONTH(l.tanggal) AND g.periode_tahun = YEAR(l.tanggal)
+			INNER JOIN (SELECT karyawan_id, MONTH(tanggal) AS bulan, YEAR(tanggal) AS tahun,
+					SUM(total_nominal) AS total
+				FROM lembur WHERE status = 'disetujui'
+				GROUP BY karyawan_id, MONTH(tanggal), YEAR(tanggal)) s
+				ON s.karyawan_id = g.karyawan_id AND s.bulan = g.periode_bulan AND s.tahun = g.periode_tahun
+			SET l.dihitung_gaji_id = g.id
+			WHERE g.payroll_run_id IN ? AND g.jenis = ? AND l.status = 'disetujui'
+				AND l.gaji_id IS NULL AND l.dihitung_gaji_id IS NULL AND ABS(s.total - g.lembur) < 0.01`,
+			runIDs, models.JenisGajiReguler).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec(`UPDATE absensi a
+			INNER JOIN gaji g ON g.karyawan_id = a.karyawan_id
+				AND g.periode_bulan = MONTH(a.tanggal) AND g.periode_tahun = YEAR(a.tanggal)
+			INNER JOIN (SELECT karyawan_id, MONTH(tanggal) AS bulan, YEAR(tanggal) AS tahun,
+					SUM(status = 'hadir') AS hadir, SUM(status = 'izin') AS izin,
+					SUM(status = 'sakit') AS sakit, SUM(status = 'alpha') AS alpha
+				FROM absensi GROUP BY karyawan_id, MONTH(tanggal), YEAR(tanggal)) s
+				ON s.karyawan_id = g.karyawan_id AND s.bulan = g.periode_bulan AND s.tahun = g.periode_tahun
+			SET a.dihitung_gaji_id = g.id
+			WHERE g.payroll_run_id IN ? AND g.jenis = ? AND a.gaji_id IS NULL AND a.dihitung_gaji_id IS NULL
+				AND s.hadir = g.hari_hadir AND s.izin = g.hari_izin AND s.sakit = g.hari_sakit AND s.alpha = g.hari_alpha`,
+			runIDs, models.JenisGajiReguler).Error
+		if err != nil {
+			return err
+		}
+		migrated, err = tautkanSumberGaji(tx, runIDs)
+		return err
+	})
+	return migrated, err
+}
+
+// MigrateLegacyRuns attaches gaji rows saved before payroll runs existed to a run for
+// their period. Periods whose slips are all dibayar get a paid run, others a draft run.
+func (r *payrollRunRepository) MigrateLegacyRuns() (int, error) {
+	type Periode struct {
+		PeriodeBulan int
+		PeriodeTahun int
+		JumlahSlip   int
+		JumlahBayar  int
+	}
+
+	var periods []Periode
+	err := r.db.Model(&models.Gaji{}).
+		Select("periode_bulan, periode_tahun, COUNT(*) as jumlah_slip, " +
+			"SUM(CASE WHEN status = 'dibayar' THEN 1 ELSE 0 END) as jumlah_bayar").
+		Where("payroll_run_id IS NULL").
+		Group("periode_bulan, periode_tahun").
+		Scan(&periods).Error
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, p := range periods {
+		run, err := r.GetByPeriod(p.PeriodeBulan, p.PeriodeTahun, models.JenisGajiReguler)
+		if err == gorm.ErrRecordNotFound {
+			run = &models.PayrollRun{
+				PeriodeBulan: p.PeriodeBulan,
+				PeriodeTahun: p.PeriodeTahun,
+				Status:       models.PayrollRunDraft,
+			}
+			if p.JumlahBayar == p.JumlahSlip {
+				now := time.Now()
+				run.Status = models.PayrollRunPaid
+				run.DibayarPada = &now
+			}
+			err = r.db.Create(run).Error
+		}
+		if err != nil {
+			return migrated, err
+		}
+
+		err = r.db.Model(&models.Gaji{}).
+			Where("payroll_run_id IS NULL AND periode_bulan = ? AND periode_tahun = ?", p.PeriodeBulan, p.PeriodeTahun).
+			Update("payroll_run_id", run.ID).Error
+		if err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"pemdes-payroll/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PresensiRepository interface {
+	CatatLog(log *models.LogPresensi) error
+	GetLog(karyawanID uint, mulai, selesai time.Time, diterima string) ([]models.LogPresensi, error)
+}
+
+type presensiRepository struct {
+	db *gorm.DB
+}
+
+// NewPresensiRepository creates a new Presensi repository
+func NewPresensiRepository(db *gorm.DB) PresensiRepository {
+	return &presensiRepository{db: db}
+}
+
+func (r *presensiRepository) CatatLog(log *models.LogPresensi) error {
+	return r.db.Create(log).Error
+}
+
+// GetLog lists the clock-in attempts from mulai up to the end of selesai, optionally of
+// one karyawan and only accepted ("true") or rejected ("false") ones
+func (r *presensiRepository) GetLog(karyawanID uint, mulai, selesai time.Time, diterima string) ([]models.LogPresensi, error) {
+	var log []models.LogPresensi
+	query := r.db.Preload("Karyawan").Where("waktu >= ? AND waktu < ?", mulai, selesai.AddDate(0, 0, 1))
+	if karyawanID != 0 {
+		query = query.Where("karyawan_id = ?", karyawanID)
+	}
+	switch diterima {
+	case "true":
+		query = query.Where("diterima = ?", true)
+	case "false":
+		query = query.Where("diterima = ?", false)
+	}
+	err := query.Order("waktu DESC").Find(&log).Error
+	return log, err
+}
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"pemdes-payroll/backend/models"
+
+	"gorm.io/gorm"
+)
+
+type RapelRepository interface {
+	Create(rapel *models.Rapel) error
+	GetAll() ([]models.Rapel, error)
+	GetByID(id uint) (*models.Rapel, error)
+	GetDetailTerakhir(gajiID uint) (*models.RapelDetail, error)
+	GetItemUntukGaji(karyawanID uint, bulan, tahun int) ([]models.RapelDetail, error)
+	UpdateStatus(rapel *models.Rapel) error
+	SetDiterapkanGaji(detailIDs []uint, gajiID *uint) error
+	Delete(id uint) error
+}
+
+type rapelRepository struct {
+	db *gorm.DB
+}
+
+// NewRapelRepository creates a new Rapel repository
+func NewRapelRepository(db *gorm.DB) RapelRepository {
+	return &rapelRepository{db: db}
+}
+
+func (r *rapelRepository) Create(rapel *models.Rapel) error {
+	return r.db.Create(rapel).Error
+}
+
+func (r *rapelRepository) GetAll() ([]models.Rapel, error) {
+	var rapel []models.Rapel
+	err := r.db.Order("created_at DESC").Find(&rapel).Error
+	return rapel, err
+}
+
+func (r *rapelRepository) GetByID(id uint) (*models.Rapel, error) {
+	var rapel models.Rapel
+	err := r.db.Preload("Detail", func(db *gorm.DB) *gorm.DB {
+		return db.Order("karyawan_id, periode_tahun, periode_bulan")
+	}).Preload("Detail.Karyawan.Jabatan").First(&rapel, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rapel, nil
+}
+
+// GetDetailTerakhir returns the latest applied rapel difference of a past slip, whose
+// Baru amounts are what the slip counts as paid for a following rapel
+func (r *rapelRepository) GetDetailTerakhir(gajiID uint) (*models.RapelDetail, error) {
+	var detail models.RapelDetail
+	err := r.db.Joins("JOIN rapel ON rapel.id = rapel_detail.rapel_id").
+		Where("rapel_detail.gaji_id = ? AND rapel.status = ?", gajiID, models.RapelDiterapkan).
+		Order("rapel.diterapkan_pada DESC, rapel_detail.id DESC").First(&detail).Error
+	if err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+// GetItemUntukGaji returns the differences to add as RAPEL lines to the karyawan's
+// regular slip of a period, from applied rapel paid as line items in that period. Those
+// paid on a rapel slip because the karyawan had no regular slip yet are left out.
+func (r *rapelRepository) GetItemUntukGaji(karyawanID uint, bulan, tahun int) ([]models.RapelDetail, error) {
+	var detail []models.RapelDetail
+	err := r.db.Joins("JOIN rapel ON rapel.id = rapel_detail.rapel_id").
+		Where("rapel_detail.karyawan_id = ? AND rapel.periode_bulan = ? AND rapel.periode_tahun = ?", karyawanID, bulan, tahun).
+		Where("rapel.status = ? AND rapel.mode = ?", models.RapelDiterapkan, models.RapelModeItem).
+		Where("rapel_detail.diterapkan_gaji_id IS NULL").
+		Order("rapel_detail.periode_tahun, rapel_detail.periode_bulan, rapel_detail.id").
+		Find(&detail).Error
+	return detail, err
+}
+
+func (r *rapelRepository) UpdateStatus(rapel *models.Rapel) error {
+	return r.db.Model(&models.Rapel{}).Where("id = ?", rapel.ID).Updates(map[string]interface{}{
+		"status":          rapel.Status,
+		"diterapkan_pada": rapel.DiterapkanPada,
+	}).Error
+}
+
+// SetDiterapkanGaji records the slip that pays the given differences, or clears it
+func (r *rapelRepository) SetDiterapkanGaji(detailIDs []uint, gajiID *uint) error {
+	if len(detailIDs) == 0 {
+		return nil
+	}
+	return r.db.Model(&models.RapelDetail{}).Where("id IN ?", detailIDs).
+		Update("diterapkan_gaji_id", gajiID).Error
+}
+
+func (r *rapelRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("rapel_id = ?", id).Delete(&models.RapelDetail{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Rapel{}, id).Error
+	})
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"pemdes-payroll/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SuratPerintahLemburRepository interface {
+	Create(surat *models.SuratPerintahLembur) error
+	GetAll(status string, karyawanID uint, bulan, tahun int) ([]models.SuratPerintahLembur, error)
+	GetByID(id uint) (*models.SuratPerintahLembur, error)
+	Putuskan(surat *models.SuratPerintahLembur, status models.StatusSuratPerintahLembur, diputuskanOleh *uint, catatan string) error
+}
+
+type suratPerintahLemburRepository struct {
+	db *gorm.DB
+}
+
+// NewSuratPerintahLemburRepository creates a new SuratPerintahLembur repository
+func NewSuratPerintahLemburRepository(db *gorm.DB) SuratPerintahLemburRepository {
+	return &suratPerintahLemburRepository{db: db}
+}
+
+// Create saves the order together with the karyawan it names
+func (r *suratPerintahLemburRepository) Create(surat *models.SuratPerintahLembur) error {
+	return r.db.Omit("Karyawan.*").Create(surat).Error
+}
+
+// GetAll lists overtime orders, optionally of one status, one karyawan or one month
+func (r *suratPerintahLemburRepository) GetAll(status string, karyawanID uint, bulan, tahun int) ([]models.SuratPerintahLembur, error) {
+	var surat []models.SuratPerintahLembur
+	query := r.db.Preload("Karyawan")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if karyawanID != 0 {
+		query = query.Where("id IN (?)", r.db.Table("surat_perintah_lembur_karyawan").
+			Select("surat_perintah_lembur_id").Where("karyawan_id = ?", karyawanID))
+	}
+	if bulan != 0 && tahun != 0 {
+		query = query.Where("MONTH(tanggal) = ? AND YEAR(tanggal) = ?", bulan, tahun)
+	}
+	err := query.Order("tanggal DESC, created_at DESC").Find(&surat).Error
+	return surat, err
+}
+
+// GetByID returns the order with its karyawan and the overtime reported against it
+func (r *suratPerintahLemburRepository) GetByID(id uint) (*models.SuratPerintahLembur, error) {
+	var surat models.SuratPerintahLembur
+	err := r.db.Preload("Karyawan").Preload("Realisasi", func(db *gorm.DB) *gorm.DB {
+		return db.Order("karyawan_id")
+	}).First(&surat, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &surat, nil
+}
+
+// Putuskan records the new status of an order and who set it
+func (r *suratPerintahLemburRepository) Putuskan(surat *models.SuratPerintahLembur, status models.StatusSuratPerintahLembur, diputuskanOleh *uint, catatan string) error {
+	now := time.Now()
+	surat.Status = status
+	surat.DiputuskanOleh = diputuskanOleh
+	surat.DiputuskanPada = &now
+	surat.CatatanKeputusan = catatan
+	return r.db.Model(&models.SuratPerintahLembur{}).Where("id = ?", surat.ID).Updates(map[string]interface{}{
+		"status":            status,
+		"diputuskan_oleh":   diputuskanOleh,
+		"diputuskan_pada":   now,
+		"catatan_keputusan": catatan,
+	}).Error
+}
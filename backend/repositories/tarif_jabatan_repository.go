@@ -0,0 +1,139 @@
+package repositories
+
+import (
+	"pemdes-payroll/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TarifJabatanRepository interface {
+	Create(tarif *models.TarifJabatan) error
+	GetByJabatanID(jabatanID uint) ([]models.TarifJabatan, error)
+	GetByID(id uint) (*models.TarifJabatan, error)
+	GetByTanggal(jabatanID uint, tanggal time.Time) (*models.TarifJabatan, error)
+	GetBerlaku(jabatanID uint, tanggal time.Time) (*models.TarifJabatan, error)
+	Update(id uint, tarif *models.TarifJabatan) error
+	Delete(id uint) error
+	SyncJabatan(jabatanID uint) error
+	MigrateTarifAwal() (int, error)
+}
+
+type tarifJabatanRepository struct {
+	db *gorm.DB
+}
+
+// NewTarifJabatanRepository creates a new TarifJabatan repository
+func NewTarifJabatanRepository(db *gorm.DB) TarifJabatanRepository {
+	return &tarifJabatanRepository{db: db}
+}
+
+func (r *tarifJabatanRepository) Create(tarif *models.TarifJabatan) error {
+	return r.db.Create(tarif).Error
+}
+
+func (r *tarifJabatanRepository) GetByJabatanID(jabatanID uint) ([]models.TarifJabatan, error) {
+	var tarif []models.TarifJabatan
+	err := r.db.Where("jabatan_id = ?", jabatanID).Order("berlaku_mulai DESC").Find(&tarif).Error
+	return tarif, err
+}
+
+func (r *tarifJabatanRepository) GetByID(id uint) (*models.TarifJabatan, error) {
+	var tarif models.TarifJabatan
+	err := r.db.First(&tarif, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tarif, nil
+}
+
+// GetByTanggal returns the rate that takes effect exactly on tanggal
+func (r *tarifJabatanRepository) GetByTanggal(jabatanID uint, tanggal time.Time) (*models.TarifJabatan, error) {
+	var tarif models.TarifJabatan
+	err := r.db.Where("jabatan_id = ? AND berlaku_mulai = ?", jabatanID, tanggal.Format("2006-01-02")).
+		First(&tarif).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tarif, nil
+}
+
+// GetBerlaku returns the rate valid on tanggal. Dates before the first recorded rate use
+// the first rate, and a jabatan without rate history uses its own rate columns.
+func (r *tarifJabatanRepository) GetBerlaku(jabatanID uint, tanggal time.Time) (*models.TarifJabatan, error) {
+	var tarif models.TarifJabatan
+	err := r.db.Where("jabatan_id = ? AND berlaku_mulai <= ?", jabatanID, tanggal.Format("2006-01-02")).
+		Order("berlaku_mulai DESC").First(&tarif).Error
+	if err == nil {
+		return &tarif, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	err = r.db.Where("jabatan_id = ?", jabatanID).Order("berlaku_mulai").First(&tarif).Error
+	if err == nil {
+		return &tarif, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	var jabatan models.Jabatan
+	if err := r.db.First(&jabatan, jabatanID).Error; err != nil {
+		return nil, err
+	}
+	return models.TarifDari(&jabatan), nil
+}
+
+// Update writes every amount column so that a rate can be lowered to zero
+func (r *tarifJabatanRepository) Update(id uint, tarif *models.TarifJabatan) error {
+	return r.db.Model(&models.TarifJabatan{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"gaji_pokok":           tarif.GajiPokok,
+		"tunjangan_jabatan":    tarif.TunjanganJabatan,
+		"tarif_lembur_per_jam": tarif.TarifLemburPerJam,
+		"keterangan":           tarif.Keterangan,
+	}).Error
+}
+
+func (r *tarifJabatanRepository) Delete(id uint) error {
+	return r.db.Delete(&models.TarifJabatan{}, id).Error
+}
+
+// SyncJabatan copies the rate valid today into the jabatan's own rate columns, which
+// are kept for display and for the screens that read the jabatan directly
+func (r *tarifJabatanRepository) SyncJabatan(jabatanID uint) error {
+	tarif, err := r.GetBerlaku(jabatanID, time.Now())
+	if err != nil {
+		return err
+	}
+	return r.db.Model(&models.Jabatan{}).Where("id = ?", jabatanID).Updates(map[string]interface{}{
+		"gaji_pokok":           tarif.GajiPokok,
+		"tunjangan_jabatan":    tarif.TunjanganJabatan,
+		"tarif_lembur_per_jam": tarif.TarifLemburPerJam,
+	}).Error
+}
+
+// MigrateTarifAwal records the current rates of jabatan without rate history as their
+// first rate, effective from the date the jabatan was created
+func (r *tarifJabatanRepository) MigrateTarifAwal() (int, error) {
+	var jabatanList []models.Jabatan
+	err := r.db.Where("NOT EXISTS (SELECT 1 FROM tarif_jabatan tj WHERE tj.jabatan_id = jabatan.id)").
+		Find(&jabatanList).Error
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, j := range jabatanList {
+		tarif := models.TarifDari(&j)
+		tarif.BerlakuMulai = time.Date(j.CreatedAt.Year(), j.CreatedAt.Month(), j.CreatedAt.Day(), 0, 0, 0, 0, time.UTC)
+		tarif.Keterangan = "Tarif awal"
+		if err := r.db.Create(tarif).Error; err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"pemdes-payroll/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UsulanAlphaRepository interface {
+	Create(usulan *models.UsulanAlpha) error
+	GetAll(status string) ([]models.UsulanAlpha, error)
+	GetByID(id uint) (*models.UsulanAlpha, error)
+	Terapkan(usulan *models.UsulanAlpha, diterapkanOleh *uint) error
+	UpdateStatus(id uint, status models.UsulanAlphaStatus) error
+	GetTerakhirTerjadwal() (*models.UsulanAlpha, error)
+}
+
+type usulanAlphaRepository struct {
+	db *gorm.DB
+}
+
+// NewUsulanAlphaRepository creates a new UsulanAlpha repository
+func NewUsulanAlphaRepository(db *gorm.DB) UsulanAlphaRepository {
+	return &usulanAlphaRepository{db: db}
+}
+
+// Create saves the proposal together with its proposed days
+func (r *usulanAlphaRepository) Create(usulan *models.UsulanAlpha) error {
+	return r.db.Create(usulan).Error
+}
+
+func (r *usulanAlphaRepository) GetAll(status string) ([]models.UsulanAlpha, error) {
+	var usulan []models.UsulanAlpha
+	query := r.db.Model(&models.UsulanAlpha{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("created_at DESC").Find(&usulan).Error
+	return usulan, err
+}
+
+func (r *usulanAlphaRepository) GetByID(id uint) (*models.UsulanAlpha, error) {
+	var usulan models.UsulanAlpha
+	err := r.db.Preload("Detail", func(db *gorm.DB) *gorm.DB {
+		return db.Order("karyawan_id, tanggal")
+	}).Preload("Detail.Karyawan").First(&usulan, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &usulan, nil
+}
+
+// Terapkan creates the alpha absensi of the proposed days that are still usulan and
+// records the outcome of each day. A day that got an absensi row since the proposal was
+// made is skipped by the uniqueness check of Absensi.BeforeCreate.
+func (r *usulanAlphaRepository) Terapkan(usulan *models.UsulanAlpha, diterapkanOleh *uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range usulan.Detail {
+			d := &usulan.Detail[i]
+			if d.Status != models.DetailAlphaUsulan {
+				err := tx.Model(d).Updates(map[string]interface{}{"status": d.Status, "keterangan": d.Keterangan}).Error
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			absensi := models.Absensi{
+				KaryawanID: d.KaryawanID,
+				Tanggal:    d.Tanggal,
+				Status:     models.AbsensiAlpha,
+				Keterangan: "Tidak ada absensi (ditandai otomatis)",
+			}
+			err := tx.Create(&absensi).Error
+			switch {
+			case err == gorm.ErrDuplicatedKey:
+				d.Status = models.DetailAlphaDilewati
+				d.Keterangan = "Absensi already recorded"
+			case err != nil:
+				return err
+			default:
+				d.Status = models.DetailAlphaDibuat
+				d.AbsensiID = &absensi.ID
+			}
+
+			err = tx.Model(d).Updates(map[string]interface{}{
+				"status":     d.Status,
+				"absensi_id": d.AbsensiID,
+				"keterangan": d.Keterangan,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		usulan.Status = models.UsulanAlphaDiterapkan
+		usulan.DiterapkanOleh = diterapkanOleh
+		usulan.DiterapkanPada = &now
+		return tx.Model(usulan).Updates(map[string]interface{}{
+			"status":          usulan.Status,
+			"diterapkan_oleh": diterapkanOleh,
+			"diterapkan_pada": now,
+		}).Error
+	})
+}
+
+func (r *usulanAlphaRepository) UpdateStatus(id uint, status models.UsulanAlphaStatus) error {
+	return r.db.Model(&models.UsulanAlpha{}).Where("id = ?", id).Update("status", status).Error
+}
+
+// GetTerakhirTerjadwal returns the latest proposal made by the scheduled job
+func (r *usulanAlphaRepository) GetTerakhirTerjadwal() (*models.UsulanAlpha, error) {
+	var usulan models.UsulanAlpha
+	err := r.db.Where("sumber = ?", "jadwal").Order("selesai DESC").First(&usulan).Error
+	if err != nil {
+		return nil, err
+	}
+	return &usulan, nil
+}
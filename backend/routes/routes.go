@@ -16,6 +16,20 @@ func SetupRoutes(app *fiber.App,
 	absensiHandler *handlers.AbsensiHandler,
 	lemburHandler *handlers.LemburHandler,
 	authHandler *handlers.AuthHandler,
+	komponenGajiHandler *handlers.KomponenGajiHandler,
+	payrollRunHandler *handlers.PayrollRunHandler,
+	koreksiGajiHandler *handlers.KoreksiGajiHandler,
+	rapelHandler *handlers.RapelHandler,
+	runKhususHandler *handlers.RunKhususHandler,
+	kasbonHandler *handlers.KasbonHandler,
+	jadwalKerjaHandler *handlers.JadwalKerjaHandler,
+	hariLiburHandler *handlers.HariLiburHandler,
+	autoAlphaHandler *handlers.AutoAlphaHandler,
+	cutiHandler *handlers.CutiHandler,
+	presensiHandler *handlers.PresensiHandler,
+	fingerprintHandler *handlers.FingerprintHandler,
+	koreksiAbsensiHandler *handlers.KoreksiAbsensiHandler,
+	suratPerintahLemburHandler *handlers.SuratPerintahLemburHandler,
 ) {
 	// Public routes (no auth required)
 	app.Post("/api/auth/login", authHandler.Login)
@@ -38,6 +52,9 @@ func SetupRoutes(app *fiber.App,
 	api.Post("/jabatan", jabatanHandler.CreateJabatan)
 	api.Put("/jabatan/:id", jabatanHandler.UpdateJabatan)
 	api.Delete("/jabatan/:id", jabatanHandler.DeleteJabatan)
+	api.Get("/jabatan/:id/tarif", jabatanHandler.GetTarifJabatan)
+	api.Post("/jabatan/:id/tarif", jabatanHandler.CreateTarifJabatan)
+	api.Delete("/jabatan/:id/tarif/:tarif_id", jabatanHandler.DeleteTarifJabatan)
 
 	// Karyawan routes
 	api.Get("/karyawan", karyawanHandler.GetAllKaryawan)
@@ -50,24 +67,60 @@ func SetupRoutes(app *fiber.App,
 
 	// Absensi routes
 	api.Get("/absensi", absensiHandler.GetAllAbsensi)
+	api.Get("/absensi/keterlambatan", absensiHandler.GetLaporanKeterlambatan) // Static route before :id
+	api.Get("/absensi/auto-alpha", autoAlphaHandler.GetAllUsulanAlpha)
+	api.Get("/absensi/auto-alpha/:id", autoAlphaHandler.GetUsulanAlphaByID)
+	api.Post("/absensi/auto-alpha", autoAlphaHandler.CreateUsulanAlpha)
+	api.Post("/absensi/auto-alpha/:id/terapkan", autoAlphaHandler.TerapkanUsulanAlpha)
+	api.Post("/absensi/auto-alpha/:id/batal", autoAlphaHandler.BatalkanUsulanAlpha)
+	api.Post("/absensi/import/fingerprint", fingerprintHandler.ImportFingerprint)
+	api.Get("/absensi/koreksi", koreksiAbsensiHandler.GetAllKoreksiAbsensi)
+	api.Get("/absensi/koreksi/:id", koreksiAbsensiHandler.GetKoreksiAbsensiByID)
+	api.Get("/absensi/koreksi/:id/lampiran", koreksiAbsensiHandler.GetLampiranKoreksiAbsensi)
+	api.Post("/absensi/koreksi", koreksiAbsensiHandler.CreateKoreksiAbsensi)
+	api.Post("/absensi/koreksi-gaji", absensiHandler.KoreksiGajiHariAbsensi)
+	api.Post("/absensi/koreksi/:id/setujui", koreksiAbsensiHandler.SetujuiKoreksiAbsensi)
+	api.Post("/absensi/koreksi/:id/tolak", koreksiAbsensiHandler.TolakKoreksiAbsensi)
+	api.Post("/absensi/koreksi/:id/batal", koreksiAbsensiHandler.BatalkanKoreksiAbsensi)
 	api.Get("/absensi/:id", absensiHandler.GetAbsensiByID)
 	api.Get("/absensi/karyawan/:id", absensiHandler.GetAbsensiByKaryawan)
 	api.Get("/absensi/rekap/:karyawan_id", absensiHandler.GetRekapAbsensi)
 	api.Post("/absensi", absensiHandler.CreateAbsensi)
 	api.Put("/absensi/:id", absensiHandler.UpdateAbsensi)
 	api.Delete("/absensi/:id", absensiHandler.DeleteAbsensi)
+	api.Post("/absensi/:id/koreksi-gaji", absensiHandler.KoreksiGajiAbsensi)
 	api.Get("/absensi/export/excel", absensiHandler.ExportAbsensiExcel)
 	api.Get("/absensi/export/karyawan/:id/pdf", absensiHandler.ExportAbsensiPDF)
 
+	// Jadwal kerja routes
+	api.Get("/jadwal-kerja", jadwalKerjaHandler.GetJadwalKerja)
+	api.Put("/jadwal-kerja", jadwalKerjaHandler.UpdateJadwalKerja)
+
+	// Kalender kerja routes
+	api.Get("/hari-libur", hariLiburHandler.GetAllHariLibur)
+	api.Post("/hari-libur", hariLiburHandler.CreateHariLibur)
+	api.Post("/hari-libur/import", hariLiburHandler.ImportHariLibur)
+	api.Delete("/hari-libur/:id", hariLiburHandler.DeleteHariLibur)
+	api.Get("/kalender/hari-kerja", hariLiburHandler.GetHariKerja)
+
 	// Lembur routes
 	api.Get("/lembur", lemburHandler.GetAllLembur)
 	api.Get("/lembur/period", lemburHandler.GetLemburByPeriod)
+	api.Get("/lembur/dashboard", lemburHandler.GetDashboardLembur)
+	api.Put("/lembur/anggaran", lemburHandler.SetAnggaranLembur)
+	api.Get("/lembur/surat-perintah", suratPerintahLemburHandler.GetAllSuratPerintahLembur)
+	api.Get("/lembur/surat-perintah/:id", suratPerintahLemburHandler.GetSuratPerintahLemburByID)
+	api.Post("/lembur/surat-perintah", suratPerintahLemburHandler.CreateSuratPerintahLembur)
+	api.Post("/lembur/surat-perintah/:id/setujui", suratPerintahLemburHandler.SetujuiSuratPerintahLembur)
+	api.Post("/lembur/surat-perintah/:id/tolak", suratPerintahLemburHandler.TolakSuratPerintahLembur)
+	api.Post("/lembur/surat-perintah/:id/batal", suratPerintahLemburHandler.BatalkanSuratPerintahLembur)
 	api.Get("/lembur/:id", lemburHandler.GetLemburByID)
 	api.Get("/lembur/karyawan/:id", lemburHandler.GetLemburByKaryawan)
 	api.Post("/lembur", lemburHandler.CreateLembur)
 	api.Put("/lembur/:id", lemburHandler.UpdateLembur)
 	api.Delete("/lembur/:id", lemburHandler.DeleteLembur)
 	api.Patch("/lembur/:id/approve", lemburHandler.ApproveLembur)
+	api.Post("/lembur/:id/koreksi", lemburHandler.KoreksiLembur)
 	api.Post("/lembur/recalculate-tarif", lemburHandler.RecalculateTarifLembur)
 
 	// Gaji routes - static routes first, then parameterized routes
@@ -80,16 +133,80 @@ func SetupRoutes(app *fiber.App,
 	api.Get("/gaji/slip/:id", gajiHandler.GetSlipGaji)
 	api.Post("/gaji", gajiHandler.CreateGaji)
 	api.Post("/gaji/generate-batch", gajiHandler.GenerateBatch)
+	api.Post("/gaji/generate-batch/preview", gajiHandler.PreviewBatch)
+	api.Post("/gaji/run-khusus", runKhususHandler.GenerateRunKhusus)
+	api.Post("/gaji/run-khusus/preview", runKhususHandler.PreviewRunKhusus)
 	api.Put("/gaji/:id", gajiHandler.UpdateGaji)
 	api.Delete("/gaji/:id", gajiHandler.DeleteGaji)
 	api.Patch("/gaji/:id/status", gajiHandler.UpdateGajiStatus)
+	api.Get("/gaji/:id/koreksi", koreksiGajiHandler.GetKoreksiByGaji)
+	api.Post("/gaji/:id/koreksi", koreksiGajiHandler.CreateKoreksiGaji)
+
+	// Payroll run routes
+	api.Get("/payroll-run", payrollRunHandler.GetAllPayrollRun)
+	api.Get("/payroll-run/:id", payrollRunHandler.GetPayrollRunByID)
+	api.Post("/payroll-run/:id/review", payrollRunHandler.ReviewPayrollRun)
+	api.Post("/payroll-run/:id/reopen", payrollRunHandler.ReopenPayrollRun)
+	api.Post("/payroll-run/:id/approve", payrollRunHandler.ApprovePayrollRun)
+	api.Post("/payroll-run/:id/pay", payrollRunHandler.PayPayrollRun)
+	api.Post("/payroll-run/:id/lock", payrollRunHandler.LockPayrollRun)
+
+	// Koreksi gaji routes
+	api.Get("/koreksi-gaji", koreksiGajiHandler.GetAllKoreksiGaji)
+	api.Delete("/koreksi-gaji/:id", koreksiGajiHandler.DeleteKoreksiGaji)
+
+	// Rapel routes
+	api.Post("/rapel/hitung", rapelHandler.HitungRapel)
+	api.Get("/rapel", rapelHandler.GetAllRapel)
+	api.Get("/rapel/:id", rapelHandler.GetRapelByID)
+	api.Post("/rapel", rapelHandler.CreateRapel)
+	api.Post("/rapel/:id/terapkan", rapelHandler.TerapkanRapel)
+	api.Post("/rapel/:id/batal", rapelHandler.BatalkanRapel)
+	api.Delete("/rapel/:id", rapelHandler.DeleteRapel)
+
+	// Presensi routes - self-service clock-in and clock-out
+	api.Get("/presensi/hari-ini", presensiHandler.GetPresensiHariIni)
+	api.Get("/presensi/log", presensiHandler.GetLogPresensi)
+	api.Post("/presensi/masuk", presensiHandler.ClockIn)
+	api.Post("/presensi/keluar", presensiHandler.ClockOut)
+	api.Get("/presensi/qr/token", presensiHandler.GetTokenQR)
+	api.Post("/presensi/qr", presensiHandler.ScanQR)
+
+	// Cuti routes
+	api.Get("/cuti", cutiHandler.GetAllPengajuanCuti)
+	api.Get("/cuti/saldo", cutiHandler.GetSaldoCuti) // Static route before :id
+	api.Get("/cuti/:id", cutiHandler.GetPengajuanCutiByID)
+	api.Get("/cuti/:id/lampiran", cutiHandler.GetLampiranCuti)
+	api.Post("/cuti", cutiHandler.CreatePengajuanCuti)
+	api.Post("/cuti/:id/setujui", cutiHandler.SetujuiPengajuanCuti)
+	api.Post("/cuti/:id/tolak", cutiHandler.TolakPengajuanCuti)
+	api.Post("/cuti/:id/batal", cutiHandler.BatalkanPengajuanCuti)
+
+	// Kasbon routes
+	api.Get("/kasbon", kasbonHandler.GetAllKasbon)
+	api.Get("/kasbon/saldo", kasbonHandler.GetSaldoKasbon) // Static route before :id
+	api.Get("/kasbon/:id", kasbonHandler.GetKasbonByID)
+	api.Post("/kasbon", kasbonHandler.CreateKasbon)
+	api.Post("/kasbon/:id/angsuran/:angsuran_id/lewati", kasbonHandler.LewatiAngsuran)
+	api.Post("/kasbon/:id/lunasi", kasbonHandler.LunasiKasbon)
+	api.Delete("/kasbon/:id", kasbonHandler.DeleteKasbon)
+
+	// Komponen gaji routes
+	api.Get("/komponen-gaji", komponenGajiHandler.GetAllKomponenGaji)
+	api.Get("/komponen-gaji/:id", komponenGajiHandler.GetKomponenGajiByID)
+	api.Post("/komponen-gaji", komponenGajiHandler.CreateKomponenGaji)
+	api.Put("/komponen-gaji/:id", komponenGajiHandler.UpdateKomponenGaji)
+	api.Delete("/komponen-gaji/:id", komponenGajiHandler.DeleteKomponenGaji)
 
 	// Laporan routes
 	api.Get("/laporan/gaji", laporanHandler.GetLaporanGajiByPeriod)
 	api.Get("/laporan/gaji/karyawan/:id", laporanHandler.GetRiwayatGajiKaryawan)
 	api.Get("/laporan/rekap", laporanHandler.GetRekapGaji)
+	api.Get("/laporan/bpjs", laporanHandler.GetLaporanBPJS)
+	api.Get("/laporan/varians", laporanHandler.GetLaporanVarians)
 
 	// Export routes
 	api.Get("/laporan/export/excel", laporanHandler.ExportLaporanExcel)
+	api.Get("/laporan/export/varians/excel", laporanHandler.ExportVariansExcel)
 	api.Get("/laporan/export/karyawan/:id/pdf", laporanHandler.ExportKaryawanPDF)
 }
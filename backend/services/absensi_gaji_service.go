@@ -0,0 +1,163 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"pemdes-payroll/backend/config"
+	"pemdes-payroll/backend/models"
+)
+
+// AbsensiGajiService applies the attendance rules of a period to a slip: tunjangan makan
+// per hadir day, a deduction per alpha day and a penalty per late day
+type AbsensiGajiService struct {
+	cfg *config.AbsensiGajiConfig
+}
+
+// NewAbsensiGajiService creates a new attendance payroll service
+func NewAbsensiGajiService(cfg *config.AbsensiGajiConfig) *AbsensiGajiService {
+	return &AbsensiGajiService{cfg: cfg}
+}
+
+// AturanAbsensi holds the rates of the attendance rules. A rate of 0 turns its rule off.
+type AturanAbsensi struct {
+	MakanPerHadir     float64
+	PotonganPerAlpha  float64
+	DendaPerTerlambat float64
+}
+
+// Aturan returns the configured rates, each replaced by its override when not nil
+func (s *AbsensiGajiService) Aturan(makanPerHadir, potonganPerAlpha, dendaPerTerlambat *float64) AturanAbsensi {
+	aturan := AturanAbsensi{
+		MakanPerHadir:     s.cfg.TunjanganMakanPerHadir,
+		PotonganPerAlpha:  s.cfg.PotonganPerAlpha,
+		DendaPerTerlambat: s.cfg.DendaPerTerlambat,
+	}
+	if makanPerHadir != nil {
+		aturan.MakanPerHadir = *makanPerHadir
+	}
+	if potonganPerAlpha != nil {
+		aturan.PotonganPerAlpha = *potonganPerAlpha
+	}
+	if dendaPerTerlambat != nil {
+		aturan.DendaPerTerlambat = *dendaPerTerlambat
+	}
+	return aturan
+}
+
+// RekapAbsensi counts a karyawan's attendance in a period
+type RekapAbsensi struct {
+	Hadir          int
+	Izin           int
+	Sakit          int
+	Alpha          int
+	Terlambat      int // hadir days late according to the jadwal kerja
+	MenitTerlambat int // minutes late summed over those days
+}
+
+// Rekap counts the attendance records of a period, whose minutes late are already
+// computed from the jadwal kerja
+func (s *AbsensiGajiService) Rekap(absensiList []models.Absensi) RekapAbsensi {
+	var rekap RekapAbsensi
+	for _, a := range absensiList {
+		switch a.Status {
+		case "hadir":
+			rekap.Hadir++
+			if a.MenitTerlambat > 0 {
+				rekap.Terlambat++
+				rekap.MenitTerlambat += a.MenitTerlambat
+			}
+		case "izin":
+			rekap.Izin++
+		case "sakit":
+			rekap.Sakit++
+		case "alpha":
+			rekap.Alpha++
+		}
+	}
+	return rekap
+}
+
+// RekapGaji returns the attendance figures a slip was computed with
+func (s *AbsensiGajiService) RekapGaji(gaji *models.Gaji) RekapAbsensi {
+	return RekapAbsensi{
+		Hadir:          gaji.HariHadir,
+		Izin:           gaji.HariIzin,
+		Sakit:          gaji.HariSakit,
+		Alpha:          gaji.HariAlpha,
+		Terlambat:      gaji.HariTerlambat,
+		MenitTerlambat: gaji.MenitTerlambat,
+	}
+}
+
+// GantiHari returns rekap with one day counted as baru instead of semula. Either may be
+// nil for a day without a record. No figure goes below zero.
+func (s *AbsensiGajiService) GantiHari(rekap RekapAbsensi, semula, baru *models.Absensi) RekapAbsensi {
+	var lama, kini RekapAbsensi
+	if semula != nil {
+		lama = s.Rekap([]models.Absensi{*semula})
+	}
+	if baru != nil {
+		kini = s.Rekap([]models.Absensi{*baru})
+	}
+	return RekapAbsensi{
+		Hadir:          max(rekap.Hadir-lama.Hadir, 0) + kini.Hadir,
+		Izin:           max(rekap.Izin-lama.Izin, 0) + kini.Izin,
+		Sakit:          max(rekap.Sakit-lama.Sakit, 0) + kini.Sakit,
+		Alpha:          max(rekap.Alpha-lama.Alpha, 0) + kini.Alpha,
+		Terlambat:      max(rekap.Terlambat-lama.Terlambat, 0) + kini.Terlambat,
+		MenitTerlambat: max(rekap.MenitTerlambat-lama.MenitTerlambat, 0) + kini.MenitTerlambat,
+	}
+}
+
+// Terapkan records the attendance figures on the slip and, when tunjangan makan is paid
+// per hadir day, replaces the flat amount. The rates are those already on the slip.
+func (s *AbsensiGajiService) Terapkan(gaji *models.Gaji, rekap RekapAbsensi) {
+	gaji.HariHadir = rekap.Hadir
+	gaji.HariIzin = rekap.Izin
+	gaji.HariSakit = rekap.Sakit
+	gaji.HariAlpha = rekap.Alpha
+	gaji.HariTerlambat = rekap.Terlambat
+	gaji.MenitTerlambat = rekap.MenitTerlambat
+
+	if gaji.MakanPerHadir > 0 {
+		gaji.TunjanganMakan = math.Round(gaji.MakanPerHadir * float64(rekap.Hadir))
+	}
+}
+
+// TambahItem adds the attendance deductions as line items and notes the attendance
+// figure behind tunjangan makan. It is called after the standard items are set.
+func (s *AbsensiGajiService) TambahItem(gaji *models.Gaji) {
+	if gaji.MakanPerHadir > 0 {
+		for i := range gaji.Items {
+			if gaji.Items[i].Kode == models.KodeTunjanganMakan {
+				gaji.Items[i].Keterangan = fmt.Sprintf("%d hari hadir x %s", gaji.HariHadir, formatCurrency(gaji.MakanPerHadir))
+			}
+		}
+	}
+
+	if gaji.PotonganPerAlpha > 0 && gaji.HariAlpha > 0 {
+		gaji.AddItem(models.KodePotonganAlpha, "Potongan Alpha", models.JenisPotongan,
+			math.Round(gaji.PotonganPerAlpha*float64(gaji.HariAlpha)))
+		gaji.Items[len(gaji.Items)-1].Keterangan = fmt.Sprintf("%d hari alpha x %s", gaji.HariAlpha, formatCurrency(gaji.PotonganPerAlpha))
+	}
+
+	if gaji.DendaPerTerlambat > 0 && gaji.HariTerlambat > 0 {
+		gaji.AddItem(models.KodeDendaTerlambat, "Denda Terlambat", models.JenisPotongan,
+			math.Round(gaji.DendaPerTerlambat*float64(gaji.HariTerlambat)))
+		gaji.Items[len(gaji.Items)-1].Keterangan = fmt.Sprintf("%d hari terlambat (%d menit) x %s",
+			gaji.HariTerlambat, gaji.MenitTerlambat, formatCurrency(gaji.DendaPerTerlambat))
+	}
+}
+
+// NilaiAbsensi returns what the attendance rules at the slip's rates add to its net pay
+// for the given attendance: tunjangan makan per hadir day, when paid that way, less the
+// alpha deduction and the late penalty
+func (s *AbsensiGajiService) NilaiAbsensi(gaji *models.Gaji, rekap RekapAbsensi) float64 {
+	nilai := 0.0
+	if gaji.MakanPerHadir > 0 {
+		nilai += math.Round(gaji.MakanPerHadir * float64(rekap.Hadir))
+	}
+	nilai -= math.Round(gaji.PotonganPerAlpha * float64(rekap.Alpha))
+	nilai -= math.Round(gaji.DendaPerTerlambat * float64(rekap.Terlambat))
+	return nilai
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"math"
+	"pemdes-payroll/backend/models"
+	"sort"
+)
+
+// AnalisisGajiService summarizes and compares sets of salary slips
+type AnalisisGajiService struct{}
+
+// NewAnalisisGajiService creates a new salary analysis service
+func NewAnalisisGajiService() *AnalisisGajiService {
+	return &AnalisisGajiService{}
+}
+
+// TotalPerJabatan sums the slips per jabatan. Slips must have Karyawan.Jabatan loaded;
+// karyawan without a jabatan are grouped under "Tanpa Jabatan".
+func (s *AnalisisGajiService) TotalPerJabatan(gajiList []models.Gaji) []models.TotalJabatan {
+	var totals []models.TotalJabatan
+	index := make(map[uint]int)
+	tanpaJabatan := -1
+
+	for _, g := range gajiList {
+		var i int
+		jabatan := g.Karyawan.Jabatan
+		switch {
+		case jabatan == nil && tanpaJabatan >= 0:
+			i = tanpaJabatan
+		case jabatan == nil:
+			totals = append(totals, models.TotalJabatan{Jabatan: "Tanpa Jabatan"})
+			i = len(totals) - 1
+			tanpaJabatan = i
+		default:
+			var ok bool
+			if i, ok = index[jabatan.ID]; !ok {
+				id := jabatan.ID
+				totals = append(totals, models.TotalJabatan{JabatanID: &id, Jabatan: jabatan.NamaJabatan})
+				i = len(totals) - 1
+				index[jabatan.ID] = i
+			}
+		}
+
+		totals[i].JumlahKaryawan++
+		totals[i].TotalPendapatan += g.TotalPendapatan()
+		totals[i].TotalPotongan += g.TotalPotongan()
+		totals[i].TotalGaji += g.TotalGaji
+	}
+
+	sort.SliceStable(totals, func(a, b int) bool {
+		return totals[a].Jabatan < totals[b].Jabatan
+	})
+	return totals
+}
+
+// slipRingkas is the part of a slip the period comparison needs, taken from either
+// a Gaji or a LaporanGaji row. A karyawan's slips of one period are merged into one.
+type slipRingkas struct {
+	KaryawanID uint
+	NIK        string
+	Nama       string
+	Jabatan    string
+	TotalGaji  float64
+	Items      []models.GajiItem
+}
+
+func ringkasGaji(gajiList []models.Gaji) []slipRingkas {
+	slips := make([]slipRingkas, 0, len(gajiList))
+	for _, g := range gajiList {
+		slip := slipRingkas{
+			KaryawanID: g.KaryawanID,
+			NIK:        g.Karyawan.NIK,
+			Nama:       g.Karyawan.Nama,
+			TotalGaji:  g.TotalGaji,
+			Items:      g.Items,
+		}
+		if g.Karyawan.Jabatan != nil {
+			slip.Jabatan = g.Karyawan.Jabatan.NamaJabatan
+		}
+		slips = gabungSlip(slips, slip)
+	}
+	return slips
+}
+
+func ringkasLaporan(laporanList []models.LaporanGaji) []slipRingkas {
+	slips := make([]slipRingkas, 0, len(laporanList))
+	for _, l := range laporanList {
+		slips = gabungSlip(slips, slipRingkas{
+			KaryawanID: l.KaryawanID,
+			NIK:        l.NIK,
+			Nama:       l.NamaKaryawan,
+			Jabatan:    l.Jabatan,
+			TotalGaji:  l.TotalGaji,
+			Items:      l.Items,
+		})
+	}
+	return slips
+}
+
+// gabungSlip appends slip, or adds it to the karyawan's slip already in slips
+func gabungSlip(slips []slipRingkas, slip slipRingkas) []slipRingkas {
+	for i := range slips {
+		if slips[i].KaryawanID == slip.KaryawanID {
+			slips[i].TotalGaji += slip.TotalGaji
+			slips[i].Items = append(append([]models.GajiItem{}, slips[i].Items...), slip.Items...)
+			return slips
+		}
+	}
+	return append(slips, slip)
+}
+
+// Bandingkan compares the slips of two periods per karyawan and per line item kode.
+// Slips must have Karyawan loaded for the name columns to be filled.
+func (s *AnalisisGajiService) Bandingkan(sebelumnya, sekarang []models.Gaji) []models.SelisihGaji {
+	return s.bandingkan(ringkasGaji(sebelumnya), ringkasGaji(sekarang))
+}
+
+// BandingkanLaporan compares two periods of the salary report per karyawan and per line item kode
+func (s *AnalisisGajiService) BandingkanLaporan(sebelumnya, sekarang []models.LaporanGaji) []models.SelisihGaji {
+	return s.bandingkan(ringkasLaporan(sebelumnya), ringkasLaporan(sekarang))
+}
+
+// LaporanVarians compares two periods of the salary report and flags new and removed
+// karyawan, changes in lembur, and changes at or above both thresholds. The caller
+// fills in the periods.
+func (s *AnalisisGajiService) LaporanVarians(sebelumnya, sekarang []models.LaporanGaji, ambangPersen, ambangNominal float64) *models.LaporanVarians {
+	laporan := &models.LaporanVarians{
+		AmbangPersen:  ambangPersen,
+		AmbangNominal: ambangNominal,
+		Data:          s.BandingkanLaporan(sebelumnya, sekarang),
+	}
+
+	for i := range laporan.Data {
+		d := &laporan.Data[i]
+		d.MelebihiAmbang = melebihiAmbang(d.TotalSebelumnya, d.Selisih, ambangPersen, ambangNominal)
+		for j := range d.Items {
+			item := &d.Items[j]
+			item.MelebihiAmbang = melebihiAmbang(item.Sebelumnya, item.Selisih, ambangPersen, ambangNominal)
+			if item.MelebihiAmbang {
+				d.MelebihiAmbang = true
+			}
+			if item.Kode == models.KodeLembur && item.Selisih != 0 {
+				d.LemburBerubah = true
+			}
+		}
+
+		laporan.TotalSebelumnya += d.TotalSebelumnya
+		laporan.TotalSekarang += d.TotalSekarang
+		switch d.Status {
+		case models.SelisihBaru:
+			laporan.JumlahBaru++
+		case models.SelisihKeluar:
+			laporan.JumlahKeluar++
+		}
+		if d.MelebihiAmbang {
+			laporan.JumlahMelebihiAmbang++
+		}
+		if d.LemburBerubah {
+			laporan.JumlahLemburBerubah++
+		}
+	}
+	laporan.Selisih = laporan.TotalSekarang - laporan.TotalSebelumnya
+
+	return laporan
+}
+
+// melebihiAmbang checks whether a change reaches both the percentage and the nominal
+// threshold. Any change from zero counts as reaching the percentage threshold.
+func melebihiAmbang(sebelumnya, selisih, ambangPersen, ambangNominal float64) bool {
+	if selisih == 0 || math.Abs(selisih) < ambangNominal {
+		return false
+	}
+	if sebelumnya == 0 {
+		return true
+	}
+	return math.Abs(selisih)/math.Abs(sebelumnya)*100 >= ambangPersen
+}
+
+func (s *AnalisisGajiService) bandingkan(sebelumnya, sekarang []slipRingkas) []models.SelisihGaji {
+	lama := make(map[uint]*slipRingkas, len(sebelumnya))
+	for i := range sebelumnya {
+		lama[sebelumnya[i].KaryawanID] = &sebelumnya[i]
+	}
+
+	var hasil []models.SelisihGaji
+	dilihat := make(map[uint]bool, len(sekarang))
+
+	for i := range sekarang {
+		baru := &sekarang[i]
+		dilihat[baru.KaryawanID] = true
+		hasil = append(hasil, s.bandingkanSlip(lama[baru.KaryawanID], baru))
+	}
+	for i := range sebelumnya {
+		if !dilihat[sebelumnya[i].KaryawanID] {
+			hasil = append(hasil, s.bandingkanSlip(&sebelumnya[i], nil))
+		}
+	}
+
+	sort.SliceStable(hasil, func(a, b int) bool {
+		return hasil[a].NamaKaryawan < hasil[b].NamaKaryawan
+	})
+	return hasil
+}
+
+// bandingkanSlip compares one karyawan's slips; either may be nil
+func (s *AnalisisGajiService) bandingkanSlip(lama, baru *slipRingkas) models.SelisihGaji {
+	ref := baru
+	if ref == nil {
+		ref = lama
+	}
+
+	selisih := models.SelisihGaji{
+		KaryawanID:   ref.KaryawanID,
+		NIK:          ref.NIK,
+		NamaKaryawan: ref.Nama,
+		Jabatan:      ref.Jabatan,
+		Status:       models.SelisihTetap,
+	}
+
+	var items []models.SelisihItem
+	index := make(map[string]int)
+	tambah := func(slip *slipRingkas, sekarang bool) {
+		for _, item := range slip.Items {
+			i, ok := index[item.Kode]
+			if !ok {
+				items = append(items, models.SelisihItem{Kode: item.Kode, Nama: item.Nama, Jenis: item.Jenis})
+				i = len(items) - 1
+				index[item.Kode] = i
+			}
+			if sekarang {
+				items[i].Sekarang += item.Jumlah
+			} else {
+				items[i].Sebelumnya += item.Jumlah
+			}
+		}
+	}
+
+	switch {
+	case lama == nil:
+		selisih.Status = models.SelisihBaru
+	case baru == nil:
+		selisih.Status = models.SelisihKeluar
+	}
+	if lama != nil {
+		selisih.TotalSebelumnya = lama.TotalGaji
+		tambah(lama, false)
+	}
+	if baru != nil {
+		selisih.TotalSekarang = baru.TotalGaji
+		tambah(baru, true)
+	}
+
+	for i := range items {
+		items[i].Selisih = items[i].Sekarang - items[i].Sebelumnya
+	}
+	selisih.Selisih = selisih.TotalSekarang - selisih.TotalSebelumnya
+	selisih.Items = items
+	return selisih
+}
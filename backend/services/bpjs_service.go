@@ -0,0 +1,51 @@
+package services
+
+import (
+	"math"
+	"pemdes-payroll/backend/config"
+	"pemdes-payroll/backend/models"
+)
+
+// BPJSService computes BPJS Kesehatan and BPJS Ketenagakerjaan contributions
+type BPJSService struct {
+	cfg *config.BPJSConfig
+}
+
+// NewBPJSService creates a new BPJS service
+func NewBPJSService(cfg *config.BPJSConfig) *BPJSService {
+	return &BPJSService{cfg: cfg}
+}
+
+// UpahBPJS returns the monthly wage used as the contribution base
+// (gaji pokok plus fixed allowances)
+func (s *BPJSService) UpahBPJS(gaji *models.Gaji) float64 {
+	return gaji.GajiPokok + gaji.TunjanganJabatan
+}
+
+// HitungIuran fills in the employee and employer contribution fields of a gaji
+// and adds the employee parts as deduction line items
+func (s *BPJSService) HitungIuran(gaji *models.Gaji) {
+	upah := s.UpahBPJS(gaji)
+
+	upahKesehatan := upah
+	if s.cfg.KesehatanBatasUpah > 0 {
+		upahKesehatan = math.Min(upah, s.cfg.KesehatanBatasUpah)
+	}
+	upahJP := upah
+	if s.cfg.JPBatasUpah > 0 {
+		upahJP = math.Min(upah, s.cfg.JPBatasUpah)
+	}
+
+	gaji.BPJSKesehatanKaryawan = math.Round(upahKesehatan * s.cfg.KesehatanKaryawan)
+	gaji.BPJSKesehatanPerusahaan = math.Round(upahKesehatan * s.cfg.KesehatanPerusahaan)
+	gaji.JHTKaryawan = math.Round(upah * s.cfg.JHTKaryawan)
+	gaji.JHTPerusahaan = math.Round(upah * s.cfg.JHTPerusahaan)
+	gaji.JKKPerusahaan = math.Round(upah * s.cfg.JKKPerusahaan())
+	gaji.JKMPerusahaan = math.Round(upah * s.cfg.JKMPerusahaan)
+	gaji.JPKaryawan = math.Round(upahJP * s.cfg.JPKaryawan)
+	gaji.JPPerusahaan = math.Round(upahJP * s.cfg.JPPerusahaan)
+
+	gaji.AddItem(models.KodeBPJSKesehatan, "BPJS Kesehatan", models.JenisPotongan, gaji.BPJSKesehatanKaryawan)
+	gaji.AddItem(models.KodeBPJSJHT, "BPJS JHT", models.JenisPotongan, gaji.JHTKaryawan)
+	gaji.AddItem(models.KodeBPJSJP, "BPJS JP", models.JenisPotongan, gaji.JPKaryawan)
+}
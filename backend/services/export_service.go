@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"pemdes-payroll/backend/models"
+	"strings"
 	"time"
 
 	"github.com/jung-kurt/gofpdf"
@@ -35,8 +36,39 @@ func getMonthName(month int) string {
 	return ""
 }
 
+// kolomItem identifies one line item column in the salary report
+type kolomItem struct {
+	Kode string
+	Nama string
+}
+
+// kolomItems lists the distinct line item kodes in the report, split into earnings
+// and deductions, in the order they first appear on the slips
+func kolomItems(laporanList []models.LaporanGaji) ([]kolomItem, []kolomItem) {
+	var pendapatan, potongan []kolomItem
+	seen := make(map[string]bool)
+
+	for _, l := range laporanList {
+		for _, gi := range l.Items {
+			key := string(gi.Jenis) + ":" + gi.Kode
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			if gi.Jenis == models.JenisPendapatan {
+				pendapatan = append(pendapatan, kolomItem{Kode: gi.Kode, Nama: gi.Nama})
+			} else {
+				potongan = append(potongan, kolomItem{Kode: gi.Kode, Nama: gi.Nama})
+			}
+		}
+	}
+
+	return pendapatan, potongan
+}
+
 // ExportToExcel exports salary report to Excel format for all employees in a period
-func (s *ExportService) ExportToExcel(laporanList []models.LaporanGaji, bulan, tahun int) ([]byte, error) {
+func (s *ExportService) ExportToExcel(laporanList []models.LaporanGaji, bulan, tahun int, jenis models.JenisGaji) ([]byte, error) {
 	f := excelize.NewFile()
 	sheetName := "Laporan Gaji"
 	index, err := f.NewSheet(sheetName)
@@ -47,10 +79,15 @@ func (s *ExportService) ExportToExcel(laporanList []models.LaporanGaji, bulan, t
 	// Set active sheet
 	f.SetActiveSheet(index)
 
+	// One column per line item kode found in the period, earnings first
+	pendapatan, potongan := kolomItems(laporanList)
+	kolomData := 4 + len(pendapatan) + 1 + len(potongan) + 1 + 1
+	lastCol, _ := excelize.ColumnNumberToName(kolomData + 1)
+
 	// Set column widths
 	f.SetColWidth(sheetName, "A", "A", 8)
 	f.SetColWidth(sheetName, "B", "C", 20)
-	f.SetColWidth(sheetName, "D", "L", 18)
+	f.SetColWidth(sheetName, "D", lastCol, 18)
 
 	// Header styles
 	headerStyle, err := f.NewStyle(&excelize.Style{
@@ -102,124 +139,89 @@ func (s *ExportService) ExportToExcel(laporanList []models.LaporanGaji, bulan, t
 
 	// Company title
 	f.SetCellValue(sheetName, "A1", "SISTEM PAYROLL PEMERINTAH DESA")
-	f.SetCellStyle(sheetName, "A1", "L1", titleStyle)
-	f.MergeCell(sheetName, "A1", "L1")
+	f.SetCellStyle(sheetName, "A1", lastCol+"1", titleStyle)
+	f.MergeCell(sheetName, "A1", lastCol+"1")
 
 	// Report title
-	periodeText := fmt.Sprintf("LAPORAN GAJI KARYAWAN - %s %d", getMonthName(bulan), tahun)
+	judul := "LAPORAN GAJI KARYAWAN"
+	if jenis != models.JenisGajiReguler {
+		judul = "LAPORAN " + strings.ToUpper(jenis.Label())
+	}
+	periodeText := fmt.Sprintf("%s - %s %d", judul, getMonthName(bulan), tahun)
 	f.SetCellValue(sheetName, "A2", periodeText)
-	f.SetCellStyle(sheetName, "A2", "L2", titleStyle)
-	f.MergeCell(sheetName, "A2", "L2")
+	f.SetCellStyle(sheetName, "A2", lastCol+"2", titleStyle)
+	f.MergeCell(sheetName, "A2", lastCol+"2")
 
 	// Table headers
 	row := 4
-	headers := []string{"No", "NIK", "Nama Karyawan", "Jabatan", "Gaji Pokok", "Tunj. Jabatan", "Transport", "Makan", "Lembur", "Potongan", "Total Gaji", "Status"}
+	headers := []string{"No", "NIK", "Nama Karyawan", "Jabatan"}
+	for _, k := range pendapatan {
+		headers = append(headers, k.Nama)
+	}
+	headers = append(headers, "Total Pendapatan")
+	for _, k := range potongan {
+		headers = append(headers, k.Nama)
+	}
+	headers = append(headers, "Total Potongan", "Total Gaji", "Status")
 	for i, header := range headers {
-		cell := fmt.Sprintf("%s%d", string(rune('A'+i)), row)
+		cell, _ := excelize.CoordinatesToCellName(i+1, row)
 		f.SetCellValue(sheetName, cell, header)
 		f.SetCellStyle(sheetName, cell, cell, headerStyle)
 	}
 	row++
 
+	// Totals per numeric column, indexed by column number
+	totals := make(map[int]float64)
+	setNum := func(col int, value float64) {
+		cell, _ := excelize.CoordinatesToCellName(col, row)
+		f.SetCellValue(sheetName, cell, value)
+		f.SetCellStyle(sheetName, cell, cell, numStyle)
+		totals[col] += value
+	}
+
 	// Data rows
 	for i, item := range laporanList {
-		col := 'A'
-		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), i+1)
-		col++
-
-		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), item.NIK)
-		col++
-		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), item.NamaKaryawan)
-		col++
-		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), item.Jabatan)
-		col++
-
-		// Numeric values
-		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), item.GajiPokok)
-		f.SetCellStyle(sheetName, fmt.Sprintf("%c%d", col, row), fmt.Sprintf("%c%d", col, row), numStyle)
-		col++
-
-		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), item.TunjanganJabatan)
-		f.SetCellStyle(sheetName, fmt.Sprintf("%c%d", col, row), fmt.Sprintf("%c%d", col, row), numStyle)
-		col++
-
-		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), item.TunjanganTransport)
-		f.SetCellStyle(sheetName, fmt.Sprintf("%c%d", col, row), fmt.Sprintf("%c%d", col, row), numStyle)
-		col++
+		jumlah := make(map[string]float64)
+		for _, gi := range item.Items {
+			jumlah[string(gi.Jenis)+":"+gi.Kode] += gi.Jumlah
+		}
 
-		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), item.TunjanganMakan)
-		f.SetCellStyle(sheetName, fmt.Sprintf("%c%d", col, row), fmt.Sprintf("%c%d", col, row), numStyle)
-		col++
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), i+1)
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), item.NIK)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), item.NamaKaryawan)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), item.Jabatan)
 
-		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), item.Lembur)
-		f.SetCellStyle(sheetName, fmt.Sprintf("%c%d", col, row), fmt.Sprintf("%c%d", col, row), numStyle)
+		col := 5
+		for _, k := range pendapatan {
+			setNum(col, jumlah[string(models.JenisPendapatan)+":"+k.Kode])
+			col++
+		}
+		setNum(col, item.TotalPendapatan)
 		col++
-
-		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), item.Potongan)
-		f.SetCellStyle(sheetName, fmt.Sprintf("%c%d", col, row), fmt.Sprintf("%c%d", col, row), numStyle)
+		for _, k := range potongan {
+			setNum(col, jumlah[string(models.JenisPotongan)+":"+k.Kode])
+			col++
+		}
+		setNum(col, item.TotalPotongan)
 		col++
-
-		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), item.TotalGaji)
-		f.SetCellStyle(sheetName, fmt.Sprintf("%c%d", col, row), fmt.Sprintf("%c%d", col, row), numStyle)
+		setNum(col, item.TotalGaji)
 		col++
 
-		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), string(item.Status))
-		col++
+		cell, _ := excelize.CoordinatesToCellName(col, row)
+		f.SetCellValue(sheetName, cell, item.Status)
 
 		row++
 	}
 
 	// Total row
 	f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), "TOTAL:")
-	f.SetCellStyle(sheetName, fmt.Sprintf("A%d", row), fmt.Sprintf("I%d", row), headerStyle)
-
-	// Calculate totals
-	totalGajiPokok := 0.0
-	totalTunjanganJabatan := 0.0
-	totalTunjanganTransport := 0.0
-	totalTunjanganMakan := 0.0
-	totalLembur := 0.0
-	totalPotongan := 0.0
-	totalGaji := 0.0
-
-	for _, item := range laporanList {
-		totalGajiPokok += item.GajiPokok
-		totalTunjanganJabatan += item.TunjanganJabatan
-		totalTunjanganTransport += item.TunjanganTransport
-		totalTunjanganMakan += item.TunjanganMakan
-		totalLembur += item.Lembur
-		totalPotongan += item.Potongan
-		totalGaji += item.TotalGaji
+	f.SetCellStyle(sheetName, fmt.Sprintf("A%d", row), fmt.Sprintf("D%d", row), headerStyle)
+	for col := 5; col <= kolomData; col++ {
+		cell, _ := excelize.CoordinatesToCellName(col, row)
+		f.SetCellValue(sheetName, cell, totals[col])
+		f.SetCellStyle(sheetName, cell, cell, headerStyle)
 	}
 
-	col := 'E'
-	f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), totalGajiPokok)
-	f.SetCellStyle(sheetName, fmt.Sprintf("%c%d", col, row), fmt.Sprintf("%c%d", col, row), headerStyle)
-	col++
-
-	f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), totalTunjanganJabatan)
-	f.SetCellStyle(sheetName, fmt.Sprintf("%c%d", col, row), fmt.Sprintf("%c%d", col, row), headerStyle)
-	col++
-
-	f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), totalTunjanganTransport)
-	f.SetCellStyle(sheetName, fmt.Sprintf("%c%d", col, row), fmt.Sprintf("%c%d", col, row), headerStyle)
-	col++
-
-	f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), totalTunjanganMakan)
-	f.SetCellStyle(sheetName, fmt.Sprintf("%c%d", col, row), fmt.Sprintf("%c%d", col, row), headerStyle)
-	col++
-
-	f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), totalLembur)
-	f.SetCellStyle(sheetName, fmt.Sprintf("%c%d", col, row), fmt.Sprintf("%c%d", col, row), headerStyle)
-	col++
-
-	f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), totalPotongan)
-	f.SetCellStyle(sheetName, fmt.Sprintf("%c%d", col, row), fmt.Sprintf("%c%d", col, row), headerStyle)
-	col++
-
-	f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), totalGaji)
-	f.SetCellStyle(sheetName, fmt.Sprintf("%c%d", col, row), fmt.Sprintf("%c%d", col, row), headerStyle)
-
 	// Delete default Sheet1
 	f.DeleteSheet("Sheet1")
 
@@ -289,14 +291,19 @@ func (s *ExportService) ExportToPDF(karyawan *models.Karyawan, gajiList []models
 		}
 
 		periode := fmt.Sprintf("%s %d", getMonthName(g.PeriodeBulan), g.PeriodeTahun)
-		totalTunjangan := g.TunjanganJabatan + g.TunjanganTransport + g.TunjanganMakan
+		if g.Jenis.IsTidakTeratur() {
+			periode += " " + strings.ToUpper(string(g.Jenis))
+		}
+		gajiPokok := g.JumlahItem(models.KodeGajiPokok)
+		lembur := g.JumlahItem(models.KodeLembur)
+		totalTunjangan := g.TotalPendapatan() - gajiPokok - lembur
 
 		pdf.CellFormat(colWidths[0], 6, fmt.Sprintf("%d", i+1), "1", 0, "C", true, 0, "")
 		pdf.CellFormat(colWidths[1], 6, periode, "1", 0, "L", true, 0, "")
-		pdf.CellFormat(colWidths[2], 6, formatCurrency(g.GajiPokok), "1", 0, "R", true, 0, "")
+		pdf.CellFormat(colWidths[2], 6, formatCurrency(gajiPokok), "1", 0, "R", true, 0, "")
 		pdf.CellFormat(colWidths[3], 6, formatCurrency(totalTunjangan), "1", 0, "R", true, 0, "")
-		pdf.CellFormat(colWidths[4], 6, formatCurrency(g.Lembur), "1", 0, "R", true, 0, "")
-		pdf.CellFormat(colWidths[5], 6, formatCurrency(g.Potongan), "1", 0, "R", true, 0, "")
+		pdf.CellFormat(colWidths[4], 6, formatCurrency(lembur), "1", 0, "R", true, 0, "")
+		pdf.CellFormat(colWidths[5], 6, formatCurrency(g.TotalPotongan()), "1", 0, "R", true, 0, "")
 		pdf.CellFormat(colWidths[6], 6, formatCurrency(g.TotalGaji), "1", 0, "R", true, 0, "")
 		pdf.CellFormat(colWidths[7], 6, string(g.Status), "1", 0, "C", true, 0, "")
 		pdf.Ln(6)
@@ -312,6 +319,15 @@ func (s *ExportService) ExportToPDF(karyawan *models.Karyawan, gajiList []models
 	pdf.CellFormat(20, 7, "", "1", 0, "C", true, 0, "")
 	pdf.Ln(10)
 
+	// Pro-rated periods
+	pdf.SetFont("Arial", "", 8)
+	for _, g := range gajiList {
+		if ket := g.KeteranganProrata(); ket != "" {
+			pdf.Cell(190, 5, fmt.Sprintf("%s %d: %s", getMonthName(g.PeriodeBulan), g.PeriodeTahun, ket))
+			pdf.Ln(5)
+		}
+	}
+
 	// Footer
 	pdf.SetFont("Arial", "I", 8)
 	pdf.Cell(190, 5, "Dicetak pada: "+time.Now().Format("02-01-2006 15:04:05"))
@@ -343,7 +359,7 @@ func (s *ExportService) ExportAbsensiToExcel(absensiList []models.Absensi) ([]by
 	// Set column widths
 	f.SetColWidth(sheetName, "A", "A", 8)
 	f.SetColWidth(sheetName, "B", "C", 20)
-	f.SetColWidth(sheetName, "D", "H", 15)
+	f.SetColWidth(sheetName, "D", "K", 15)
 
 	// Header styles
 	headerStyle, err := f.NewStyle(&excelize.Style{
@@ -381,17 +397,18 @@ func (s *ExportService) ExportAbsensiToExcel(absensiList []models.Absensi) ([]by
 
 	// Company title
 	f.SetCellValue(sheetName, "A1", "SISTEM PAYROLL PEMERINTAH DESA")
-	f.SetCellStyle(sheetName, "A1", "H1", titleStyle)
-	f.MergeCell(sheetName, "A1", "H1")
+	f.SetCellStyle(sheetName, "A1", "K1", titleStyle)
+	f.MergeCell(sheetName, "A1", "K1")
 
 	// Report title
 	f.SetCellValue(sheetName, "A2", "LAPORAN ABSENSI KARYAWAN")
-	f.SetCellStyle(sheetName, "A2", "H2", titleStyle)
-	f.MergeCell(sheetName, "A2", "H2")
+	f.SetCellStyle(sheetName, "A2", "K2", titleStyle)
+	f.MergeCell(sheetName, "A2", "K2")
 
 	// Table headers
 	row := 4
-	headers := []string{"No", "Tanggal", "NIK", "Nama Karyawan", "Jam Masuk", "Jam Keluar", "Status", "Keterangan"}
+	headers := []string{"No", "Tanggal", "NIK", "Nama Karyawan", "Jam Masuk", "Jam Keluar", "Status", "Keterangan",
+		"Terlambat (menit)", "Pulang Cepat (menit)", "Jam Kerja"}
 	for i, header := range headers {
 		cell := fmt.Sprintf("%s%d", string(rune('A'+i)), row)
 		f.SetCellValue(sheetName, cell, header)
@@ -421,6 +438,12 @@ func (s *ExportService) ExportAbsensiToExcel(absensiList []models.Absensi) ([]by
 		col++
 		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), item.Keterangan)
 		col++
+		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), item.MenitTerlambat)
+		col++
+		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), item.MenitPulangCepat)
+		col++
+		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col, row), item.JamKerja)
+		col++
 
 		row++
 	}
@@ -435,6 +458,14 @@ func (s *ExportService) ExportAbsensiToExcel(absensiList []models.Absensi) ([]by
 	return buffer.Bytes(), nil
 }
 
+// menitAtauStrip formats a number of minutes for the attendance PDF, or "-" for none
+func menitAtauStrip(menit int) string {
+	if menit == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d mnt", menit)
+}
+
 // ExportAbsensiToPDF exports attendance recap to PDF format per employee
 func (s *ExportService) ExportAbsensiToPDF(karyawan *models.Karyawan, absensiList []models.Absensi, rekap map[string]int, bulan, tahun int) ([]byte, error) {
 	pdf := gofpdf.New("P", "mm", "A4", "")
@@ -475,21 +506,26 @@ func (s *ExportService) ExportAbsensiToPDF(karyawan *models.Karyawan, absensiLis
 	// Summary boxes
 	pdf.SetFont("Arial", "B", 10)
 	pdf.SetFillColor(200, 230, 200)
-	pdf.CellFormat(40, 10, fmt.Sprintf("Hadir: %d", rekap["hadir"]), "1", 0, "C", true, 0, "")
+	pdf.CellFormat(40, 10, fmt.Sprintf("Hadir: %d/%d hari kerja", rekap["hadir"], rekap["hari_kerja"]), "1", 0, "C", true, 0, "")
 	pdf.SetFillColor(200, 200, 230)
 	pdf.CellFormat(40, 10, fmt.Sprintf("Izin: %d", rekap["izin"]), "1", 0, "C", true, 0, "")
 	pdf.SetFillColor(230, 230, 200)
 	pdf.CellFormat(40, 10, fmt.Sprintf("Sakit: %d", rekap["sakit"]), "1", 0, "C", true, 0, "")
 	pdf.SetFillColor(230, 200, 200)
 	pdf.CellFormat(40, 10, fmt.Sprintf("Alpha: %d", rekap["alpha"]), "1", 0, "C", true, 0, "")
+	pdf.Ln(10)
+	pdf.SetFillColor(240, 240, 240)
+	pdf.CellFormat(60, 8, fmt.Sprintf("Terlambat: %d hari (%d menit)", rekap["terlambat"], rekap["menit_terlambat"]), "1", 0, "C", true, 0, "")
+	pdf.CellFormat(60, 8, fmt.Sprintf("Pulang cepat: %d hari (%d menit)", rekap["pulang_cepat"], rekap["menit_pulang_cepat"]), "1", 0, "C", true, 0, "")
+	pdf.CellFormat(40, 8, fmt.Sprintf("Jam kerja: %.2f", float64(rekap["menit_kerja"])/60), "1", 0, "C", true, 0, "")
 	pdf.Ln(12)
 
 	// Table header
 	pdf.SetFont("Arial", "B", 9)
 	pdf.SetFillColor(200, 200, 200)
 
-	headers := []string{"No", "Tanggal", "Jam Masuk", "Jam Keluar", "Status", "Keterangan"}
-	colWidths := []float64{10, 30, 25, 25, 25, 60}
+	headers := []string{"No", "Tanggal", "Jam Masuk", "Jam Keluar", "Status", "Terlambat", "Plg Cepat", "Jam Kerja", "Keterangan"}
+	colWidths := []float64{10, 22, 18, 18, 18, 18, 18, 18, 50}
 
 	// Draw header row
 	for i, header := range headers {
@@ -516,7 +552,10 @@ func (s *ExportService) ExportAbsensiToPDF(karyawan *models.Karyawan, absensiLis
 		pdf.CellFormat(colWidths[2], 6, a.JamMasuk, "1", 0, "C", true, 0, "")
 		pdf.CellFormat(colWidths[3], 6, a.JamKeluar, "1", 0, "C", true, 0, "")
 		pdf.CellFormat(colWidths[4], 6, string(a.Status), "1", 0, "C", true, 0, "")
-		pdf.CellFormat(colWidths[5], 6, a.Keterangan, "1", 0, "L", true, 0, "")
+		pdf.CellFormat(colWidths[5], 6, menitAtauStrip(a.MenitTerlambat), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(colWidths[6], 6, menitAtauStrip(a.MenitPulangCepat), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(colWidths[7], 6, fmt.Sprintf("%.2f", a.JamKerja), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(colWidths[8], 6, a.Keterangan, "1", 0, "L", true, 0, "")
 		pdf.Ln(6)
 	}
 
@@ -536,3 +575,209 @@ func (s *ExportService) ExportAbsensiToPDF(karyawan *models.Karyawan, absensiLis
 
 	return buf.Bytes(), nil
 }
+
+// ExportVariansToExcel exports the period-over-period variance report to Excel, with a
+// summary sheet per karyawan and a detail sheet per changed line item
+func (s *ExportService) ExportVariansToExcel(laporan *models.LaporanVarians) ([]byte, error) {
+	f := excelize.NewFile()
+
+	headerStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{
+			Bold: true,
+			Size: 11,
+		},
+		Fill: excelize.Fill{
+			Type:    "pattern",
+			Color:   []string{"#4472C4"},
+			Pattern: 1,
+		},
+		Alignment: &excelize.Alignment{
+			Horizontal: "center",
+			Vertical:   "center",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	titleStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{
+			Bold: true,
+			Size: 14,
+		},
+		Alignment: &excelize.Alignment{
+			Horizontal: "center",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	numStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{
+			Size: 10,
+		},
+		Alignment: &excelize.Alignment{
+			Horizontal: "right",
+		},
+		NumFmt: 3,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows with changes at or above the threshold are highlighted
+	flagStyle, err := f.NewStyle(&excelize.Style{
+		Fill: excelize.Fill{
+			Type:    "pattern",
+			Color:   []string{"#FFF2CC"},
+			Pattern: 1,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	flagNumStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{
+			Size: 10,
+		},
+		Fill: excelize.Fill{
+			Type:    "pattern",
+			Color:   []string{"#FFF2CC"},
+			Pattern: 1,
+		},
+		Alignment: &excelize.Alignment{
+			Horizontal: "right",
+		},
+		NumFmt: 3,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	periodeText := fmt.Sprintf("%s %d DIBANDINGKAN %s %d",
+		getMonthName(laporan.PeriodeBulan), laporan.PeriodeTahun,
+		getMonthName(laporan.PembandingBulan), laporan.PembandingTahun)
+
+	writeTitle := func(sheet, lastCol string) {
+		f.SetCellValue(sheet, "A1", "SISTEM PAYROLL PEMERINTAH DESA")
+		f.SetCellStyle(sheet, "A1", lastCol+"1", titleStyle)
+		f.MergeCell(sheet, "A1", lastCol+"1")
+
+		f.SetCellValue(sheet, "A2", "LAPORAN VARIANS GAJI - "+periodeText)
+		f.SetCellStyle(sheet, "A2", lastCol+"2", titleStyle)
+		f.MergeCell(sheet, "A2", lastCol+"2")
+	}
+	writeHeaders := func(sheet string, headers []string) {
+		for i, header := range headers {
+			cell, _ := excelize.CoordinatesToCellName(i+1, 4)
+			f.SetCellValue(sheet, cell, header)
+			f.SetCellStyle(sheet, cell, cell, headerStyle)
+		}
+	}
+
+	// Summary sheet, one row per karyawan
+	ringkasan := "Ringkasan"
+	index, err := f.NewSheet(ringkasan)
+	if err != nil {
+		return nil, err
+	}
+	f.SetActiveSheet(index)
+	f.SetColWidth(ringkasan, "A", "A", 6)
+	f.SetColWidth(ringkasan, "B", "D", 20)
+	f.SetColWidth(ringkasan, "E", "H", 18)
+	f.SetColWidth(ringkasan, "I", "I", 40)
+	writeTitle(ringkasan, "I")
+	writeHeaders(ringkasan, []string{"No", "NIK", "Nama Karyawan", "Jabatan", "Status",
+		"Total Sebelumnya", "Total Sekarang", "Selisih", "Catatan"})
+
+	row := 5
+	for i, d := range laporan.Data {
+		var catatan []string
+		switch d.Status {
+		case models.SelisihBaru:
+			catatan = append(catatan, "Karyawan baru")
+		case models.SelisihKeluar:
+			catatan = append(catatan, "Tidak ada gaji periode ini")
+		}
+		if d.MelebihiAmbang {
+			catatan = append(catatan, "Melebihi ambang")
+		}
+		if d.LemburBerubah {
+			catatan = append(catatan, "Lembur berubah")
+		}
+
+		f.SetCellValue(ringkasan, fmt.Sprintf("A%d", row), i+1)
+		f.SetCellValue(ringkasan, fmt.Sprintf("B%d", row), d.NIK)
+		f.SetCellValue(ringkasan, fmt.Sprintf("C%d", row), d.NamaKaryawan)
+		f.SetCellValue(ringkasan, fmt.Sprintf("D%d", row), d.Jabatan)
+		f.SetCellValue(ringkasan, fmt.Sprintf("E%d", row), string(d.Status))
+		f.SetCellValue(ringkasan, fmt.Sprintf("F%d", row), d.TotalSebelumnya)
+		f.SetCellValue(ringkasan, fmt.Sprintf("G%d", row), d.TotalSekarang)
+		f.SetCellValue(ringkasan, fmt.Sprintf("H%d", row), d.Selisih)
+		f.SetCellValue(ringkasan, fmt.Sprintf("I%d", row), strings.Join(catatan, ", "))
+		if len(catatan) > 0 {
+			f.SetCellStyle(ringkasan, fmt.Sprintf("A%d", row), fmt.Sprintf("E%d", row), flagStyle)
+			f.SetCellStyle(ringkasan, fmt.Sprintf("I%d", row), fmt.Sprintf("I%d", row), flagStyle)
+			f.SetCellStyle(ringkasan, fmt.Sprintf("F%d", row), fmt.Sprintf("H%d", row), flagNumStyle)
+		} else {
+			f.SetCellStyle(ringkasan, fmt.Sprintf("F%d", row), fmt.Sprintf("H%d", row), numStyle)
+		}
+		row++
+	}
+
+	f.SetCellValue(ringkasan, fmt.Sprintf("A%d", row), "TOTAL:")
+	f.SetCellValue(ringkasan, fmt.Sprintf("F%d", row), laporan.TotalSebelumnya)
+	f.SetCellValue(ringkasan, fmt.Sprintf("G%d", row), laporan.TotalSekarang)
+	f.SetCellValue(ringkasan, fmt.Sprintf("H%d", row), laporan.Selisih)
+	f.SetCellStyle(ringkasan, fmt.Sprintf("A%d", row), fmt.Sprintf("I%d", row), headerStyle)
+
+	// Detail sheet, one row per changed line item
+	detail := "Detail Komponen"
+	if _, err := f.NewSheet(detail); err != nil {
+		return nil, err
+	}
+	f.SetColWidth(detail, "A", "C", 20)
+	f.SetColWidth(detail, "D", "D", 25)
+	f.SetColWidth(detail, "E", "I", 16)
+	writeTitle(detail, "I")
+	writeHeaders(detail, []string{"NIK", "Nama Karyawan", "Kode", "Komponen", "Jenis",
+		"Sebelumnya", "Sekarang", "Selisih", "Selisih (%)"})
+
+	row = 5
+	for _, d := range laporan.Data {
+		for _, item := range d.Items {
+			if item.Selisih == 0 {
+				continue
+			}
+
+			f.SetCellValue(detail, fmt.Sprintf("A%d", row), d.NIK)
+			f.SetCellValue(detail, fmt.Sprintf("B%d", row), d.NamaKaryawan)
+			f.SetCellValue(detail, fmt.Sprintf("C%d", row), item.Kode)
+			f.SetCellValue(detail, fmt.Sprintf("D%d", row), item.Nama)
+			f.SetCellValue(detail, fmt.Sprintf("E%d", row), string(item.Jenis))
+			f.SetCellValue(detail, fmt.Sprintf("F%d", row), item.Sebelumnya)
+			f.SetCellValue(detail, fmt.Sprintf("G%d", row), item.Sekarang)
+			f.SetCellValue(detail, fmt.Sprintf("H%d", row), item.Selisih)
+			if item.Sebelumnya != 0 {
+				f.SetCellValue(detail, fmt.Sprintf("I%d", row), item.Selisih/item.Sebelumnya*100)
+			}
+			if item.MelebihiAmbang {
+				f.SetCellStyle(detail, fmt.Sprintf("A%d", row), fmt.Sprintf("E%d", row), flagStyle)
+				f.SetCellStyle(detail, fmt.Sprintf("F%d", row), fmt.Sprintf("I%d", row), flagNumStyle)
+			} else {
+				f.SetCellStyle(detail, fmt.Sprintf("F%d", row), fmt.Sprintf("I%d", row), numStyle)
+			}
+			row++
+		}
+	}
+
+	// Delete default Sheet1
+	f.DeleteSheet("Sheet1")
+
+	buffer, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
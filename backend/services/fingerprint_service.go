@@ -0,0 +1,133 @@
+package services
+
+import (
+	"fmt"
+	"pemdes-payroll/backend/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+// jedaPunchMinimal is the time after the first punch of a day before another punch counts
+// as leaving; closer punches are repeated scans of the same arrival
+const jedaPunchMinimal = 5 * time.Minute
+
+var (
+	formatWaktuFingerprint   = []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006/01/02 15:04:05", "2006/01/02 15:04", "02/01/2006 15:04:05", "02/01/2006 15:04"}
+	formatTanggalFingerprint = []string{"2006-01-02", "2006/01/02", "02/01/2006"}
+	formatJamFingerprint     = []string{"15:04:05", "15:04"}
+)
+
+// FingerprintService reads the attendance logs exported by the fingerprint terminal
+type FingerprintService struct{}
+
+// NewFingerprintService creates a new fingerprint log service
+func NewFingerprintService() *FingerprintService {
+	return &FingerprintService{}
+}
+
+// ParseLog reads a fingerprint log exported as CSV or TXT. Each line holds the user PIN,
+// the punch time and optionally the verify mode, separated by tabs, commas or semicolons.
+// The date and time of the punch may be one column or two. A header line is skipped. It
+// also returns a message for each line that could not be read.
+func (s *FingerprintService) ParseLog(data []byte) ([]models.PunchFingerprint, []string) {
+	teks := strings.TrimPrefix(string(data), "\ufeff")
+	var punch []models.PunchFingerprint
+	var gagal []string
+
+	for i, line := range strings.Split(strings.ReplaceAll(teks, "\r\n", "\n"), "\n") {
+		baris := i + 1
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		pemisah := ","
+		switch {
+		case strings.Contains(line, "\t"):
+			pemisah = "\t"
+		case strings.Contains(line, ";"):
+			pemisah = ";"
+		}
+		kolom := strings.Split(line, pemisah)
+		for j := range kolom {
+			kolom[j] = strings.Trim(strings.TrimSpace(kolom[j]), `"`)
+		}
+
+		if len(kolom) < 2 || kolom[0] == "" {
+			gagal = append(gagal, fmt.Sprintf("Baris %d: expected PIN and waktu", baris))
+			continue
+		}
+		waktu, sisa, ok := parseWaktuFingerprint(kolom[1:])
+		if !ok {
+			if baris == 1 {
+				continue // header
+			}
+			gagal = append(gagal, fmt.Sprintf("Baris %d: invalid waktu %q", baris, kolom[1]))
+			continue
+		}
+
+		p := models.PunchFingerprint{Baris: baris, PIN: kolom[0], Waktu: waktu}
+		if len(sisa) > 0 {
+			p.VerifyMode = sisa[0]
+		}
+		punch = append(punch, p)
+	}
+	return punch, gagal
+}
+
+// parseWaktuFingerprint reads the punch time from the first column, or from the first
+// two when date and time are separate, and returns the remaining columns
+func parseWaktuFingerprint(kolom []string) (time.Time, []string, bool) {
+	for _, layout := range formatWaktuFingerprint {
+		if t, err := time.Parse(layout, kolom[0]); err == nil {
+			return t, kolom[1:], true
+		}
+	}
+	if len(kolom) < 2 {
+		return time.Time{}, nil, false
+	}
+	for _, layoutTanggal := range formatTanggalFingerprint {
+		for _, layoutJam := range formatJamFingerprint {
+			if t, err := time.Parse(layoutTanggal+" "+layoutJam, kolom[0]+" "+kolom[1]); err == nil {
+				return t, kolom[2:], true
+			}
+		}
+	}
+	return time.Time{}, nil, false
+}
+
+// Rekap collapses the punches of each PIN and day into jam masuk and jam keluar, ordered
+// by PIN and date
+func (s *FingerprintService) Rekap(punch []models.PunchFingerprint) []models.RekapFingerprint {
+	sort.SliceStable(punch, func(i, j int) bool {
+		if punch[i].PIN != punch[j].PIN {
+			return punch[i].PIN < punch[j].PIN
+		}
+		return punch[i].Waktu.Before(punch[j].Waktu)
+	})
+
+	var rekap []models.RekapFingerprint
+	var awal, akhir time.Time
+	for _, p := range punch {
+		tanggal := time.Date(p.Waktu.Year(), p.Waktu.Month(), p.Waktu.Day(), 0, 0, 0, 0, time.UTC)
+		n := len(rekap)
+		if n == 0 || rekap[n-1].PIN != p.PIN || !rekap[n-1].Tanggal.Equal(tanggal) {
+			rekap = append(rekap, models.RekapFingerprint{
+				PIN:      p.PIN,
+				Tanggal:  tanggal,
+				JamMasuk: p.Waktu.Format("15:04"),
+			})
+			n++
+			awal = p.Waktu
+		}
+		akhir = p.Waktu
+
+		r := &rekap[n-1]
+		r.Baris = append(r.Baris, p.Baris)
+		r.JumlahPunch++
+		if akhir.Sub(awal) >= jedaPunchMinimal {
+			r.JamKeluar = akhir.Format("15:04")
+		}
+	}
+	return rekap
+}
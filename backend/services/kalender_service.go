@@ -0,0 +1,193 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"pemdes-payroll/backend/models"
+	"strings"
+	"time"
+)
+
+// maksHariAcara limits how many days one calendar event may cover
+const maksHariAcara = 31
+
+// KalenderService reads holiday calendars from ICS and CSV files
+type KalenderService struct{}
+
+// NewKalenderService creates a new calendar service
+func NewKalenderService() *KalenderService {
+	return &KalenderService{}
+}
+
+// jenisDariNama returns cuti bersama for entries named as such and jenis otherwise
+func jenisDariNama(nama string, jenis models.JenisHariLibur) models.JenisHariLibur {
+	if strings.Contains(strings.ToLower(nama), "cuti bersama") {
+		return models.LiburCutiBersama
+	}
+	return jenis
+}
+
+// ParseICS reads the all-day events of an iCalendar file as calendar entries of jenis.
+// Events spanning several days give one entry per day. It also returns a message for
+// each event that could not be read.
+func (s *KalenderService) ParseICS(data []byte, jenis models.JenisHariLibur) ([]models.HariLibur, []string) {
+	// Unfold continuation lines, which start with a space or tab
+	var baris []string
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(baris) > 0 {
+			baris[len(baris)-1] += line[1:]
+			continue
+		}
+		baris = append(baris, line)
+	}
+
+	var hasil []models.HariLibur
+	var gagal []string
+	var dalamEvent bool
+	var nama, mulai, selesai string
+	event := 0
+
+	for _, line := range baris {
+		kunci, nilai, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		properti, _, _ := strings.Cut(kunci, ";")
+
+		switch {
+		case properti == "BEGIN" && nilai == "VEVENT":
+			dalamEvent = true
+			nama, mulai, selesai = "", "", ""
+			event++
+		case !dalamEvent:
+			continue
+		case properti == "SUMMARY":
+			nama = unescapeICS(nilai)
+		case properti == "DTSTART":
+			mulai = nilai
+		case properti == "DTEND":
+			selesai = nilai
+		case properti == "END" && nilai == "VEVENT":
+			dalamEvent = false
+			libur, err := hariAcara(nama, mulai, selesai, jenis)
+			if err != nil {
+				gagal = append(gagal, fmt.Sprintf("Event %d (%s): %s", event, nama, err.Error()))
+				continue
+			}
+			hasil = append(hasil, libur...)
+		}
+	}
+	return hasil, gagal
+}
+
+// hariAcara expands an event to one entry per day. DTEND of an all-day event is the day
+// after it ends.
+func hariAcara(nama, mulai, selesai string, jenis models.JenisHariLibur) ([]models.HariLibur, error) {
+	if nama == "" {
+		return nil, fmt.Errorf("missing SUMMARY")
+	}
+	awal, err := parseTanggalICS(mulai)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DTSTART")
+	}
+	akhir := awal
+	if selesai != "" {
+		if akhir, err = parseTanggalICS(selesai); err != nil {
+			return nil, fmt.Errorf("invalid DTEND")
+		}
+		akhir = akhir.AddDate(0, 0, -1)
+		if akhir.Before(awal) {
+			akhir = awal
+		}
+	}
+	if akhir.Sub(awal) >= maksHariAcara*24*time.Hour {
+		return nil, fmt.Errorf("event longer than %d days", maksHariAcara)
+	}
+
+	var libur []models.HariLibur
+	for d := awal; !d.After(akhir); d = d.AddDate(0, 0, 1) {
+		libur = append(libur, models.HariLibur{
+			Tanggal: d,
+			Nama:    nama,
+			Jenis:   jenisDariNama(nama, jenis),
+			Sumber:  "ics",
+		})
+	}
+	return libur, nil
+}
+
+// parseTanggalICS reads the date of a DATE or DATE-TIME value
+func parseTanggalICS(nilai string) (time.Time, error) {
+	if len(nilai) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date %q", nilai)
+	}
+	return time.Parse("20060102", nilai[:8])
+}
+
+// unescapeICS undoes the escaping of iCalendar text values
+func unescapeICS(nilai string) string {
+	return strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\N`, " ", `\\`, `\`).Replace(strings.TrimSpace(nilai))
+}
+
+// ParseCSV reads calendar entries from a CSV file with the columns tanggal, nama and an
+// optional jenis; a header row is skipped. Tanggal may be YYYY-MM-DD or DD/MM/YYYY and
+// entries without jenis get the given one. It also returns a message for each row that
+// could not be read.
+func (s *KalenderService) ParseCSV(data []byte, jenis models.JenisHariLibur) ([]models.HariLibur, []string) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var hasil []models.HariLibur
+	var gagal []string
+	for baris := 1; ; baris++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			gagal = append(gagal, fmt.Sprintf("Baris %d: %s", baris, err.Error()))
+			continue
+		}
+		if len(record) == 0 || (len(record) == 1 && strings.TrimSpace(record[0]) == "") {
+			continue
+		}
+
+		tanggal, err := parseTanggalCSV(record[0])
+		if err != nil {
+			if baris == 1 {
+				continue // header
+			}
+			gagal = append(gagal, fmt.Sprintf("Baris %d: invalid tanggal %q", baris, record[0]))
+			continue
+		}
+		if len(record) < 2 || strings.TrimSpace(record[1]) == "" {
+			gagal = append(gagal, fmt.Sprintf("Baris %d: nama is required", baris))
+			continue
+		}
+
+		nama := strings.TrimSpace(record[1])
+		j := jenisDariNama(nama, jenis)
+		if len(record) > 2 && strings.TrimSpace(record[2]) != "" {
+			j = models.JenisHariLibur(strings.ToLower(strings.TrimSpace(record[2])))
+			if !j.IsValid() {
+				gagal = append(gagal, fmt.Sprintf("Baris %d: invalid jenis %q", baris, record[2]))
+				continue
+			}
+		}
+
+		hasil = append(hasil, models.HariLibur{Tanggal: tanggal, Nama: nama, Jenis: j, Sumber: "csv"})
+	}
+	return hasil, gagal
+}
+
+// parseTanggalCSV reads a date written as YYYY-MM-DD or DD/MM/YYYY
+func parseTanggalCSV(nilai string) (time.Time, error) {
+	nilai = strings.TrimSpace(nilai)
+	if t, err := time.Parse("2006-01-02", nilai); err == nil {
+		return t, nil
+	}
+	return time.Parse("02/01/2006", nilai)
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"pemdes-payroll/backend/models"
+)
+
+// KomponenService computes admin-defined salary components for a slip
+type KomponenService struct{}
+
+// NewKomponenService creates a new komponen service
+func NewKomponenService() *KomponenService {
+	return &KomponenService{}
+}
+
+// KomponenInput holds the per-karyawan figures that component formulas depend on
+type KomponenInput struct {
+	KaryawanID  uint
+	JabatanID   *uint
+	GajiPokok   float64
+	JumlahHadir int
+}
+
+// HitungKomponen adds a line item to gaji for every active component that applies to the karyawan
+func (s *KomponenService) HitungKomponen(gaji *models.Gaji, komponenList []models.KomponenGaji, in KomponenInput) {
+	for _, k := range komponenList {
+		if !k.Aktif {
+			continue
+		}
+
+		nilai, berlaku := s.nilaiUntukKaryawan(k, in.KaryawanID)
+		if !berlaku {
+			continue
+		}
+
+		jumlah, keterangan := s.hitungJumlah(k, nilai, in)
+		if jumlah == 0 {
+			continue
+		}
+
+		komponenID := k.ID
+		gaji.Items = append(gaji.Items, models.GajiItem{
+			KomponenGajiID: &komponenID,
+			Kode:           k.Kode,
+			Nama:           k.Nama,
+			Jenis:          k.Jenis,
+			Jumlah:         jumlah,
+			Keterangan:     keterangan,
+		})
+	}
+}
+
+// SelisihHadir returns what counting ke hadir days instead of dari changes in the per_hadir
+// komponen of a slip, pendapatan positive and potongan negative. Komponen the slip paid
+// keep the rate per day it paid them at; when the slip had no hadir day, the komponen
+// that apply to the karyawan are taken at their current nilai.
+func (s *KomponenService) SelisihHadir(gaji *models.Gaji, komponenList []models.KomponenGaji, dari, ke int) float64 {
+	tarif := make(map[uint]float64)
+	if gaji.HariHadir > 0 {
+		for _, item := range gaji.Items {
+			if item.KomponenGajiID != nil {
+				tarif[*item.KomponenGajiID] = item.Jumlah / float64(gaji.HariHadir)
+			}
+		}
+	}
+
+	selisih := 0.0
+	for _, k := range komponenList {
+		if k.TipeFormula != models.FormulaPerHadir {
+			continue
+		}
+		nilai, dibayar := tarif[k.ID]
+		if !dibayar {
+			if gaji.HariHadir > 0 || !k.Aktif {
+				continue
+			}
+			var berlaku bool
+			if nilai, berlaku = s.nilaiUntukKaryawan(k, gaji.KaryawanID); !berlaku {
+				continue
+			}
+		}
+
+		jumlahKe, _ := s.hitungJumlah(k, nilai, KomponenInput{JumlahHadir: ke})
+		jumlahDari, _ := s.hitungJumlah(k, nilai, KomponenInput{JumlahHadir: dari})
+		if k.Jenis == models.JenisPotongan {
+			selisih -= jumlahKe - jumlahDari
+		} else {
+			selisih += jumlahKe - jumlahDari
+		}
+	}
+	return selisih
+}
+
+// nilaiUntukKaryawan returns the component nilai for a karyawan and whether the component applies
+func (s *KomponenService) nilaiUntukKaryawan(k models.KomponenGaji, karyawanID uint) (float64, bool) {
+	for _, kk := range k.Karyawan {
+		if kk.KaryawanID == karyawanID {
+			if kk.Nilai != nil {
+				return *kk.Nilai, true
+			}
+			return k.Nilai, true
+		}
+	}
+	return k.Nilai, k.BerlakuSemua
+}
+
+func (s *KomponenService) hitungJumlah(k models.KomponenGaji, nilai float64, in KomponenInput) (float64, string) {
+	switch k.TipeFormula {
+	case models.FormulaPersenPokok:
+		return math.Round(in.GajiPokok * nilai / 100), fmt.Sprintf("%.2f%% x gaji pokok", nilai)
+	case models.FormulaPerHadir:
+		return nilai * float64(in.JumlahHadir), fmt.Sprintf("%d hari hadir x %s", in.JumlahHadir, formatCurrency(nilai))
+	case models.FormulaPerJabatan:
+		if in.JabatanID == nil {
+			return 0, ""
+		}
+		for _, nj := range k.NilaiJabatan {
+			if nj.JabatanID == *in.JabatanID {
+				return nj.Nilai, ""
+			}
+		}
+		return 0, ""
+	}
+	return nilai, ""
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"pemdes-payroll/backend/config"
+	"pemdes-payroll/backend/models"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// LemburService applies the overtime multipliers: each record is paid per tier of hours
+// at the multipliers of its kind of day
+type LemburService struct {
+	cfg *config.LemburConfig
+}
+
+// NewLemburService creates a new overtime multiplier service
+func NewLemburService(cfg *config.LemburConfig) *LemburService {
+	return &LemburService{cfg: cfg}
+}
+
+// JenisHari classifies a date as a working day, a rest day or a public holiday using the
+// kalender kerja. Public holidays are the hari libur entries of the configured jenis.
+func (s *LemburService) JenisHari(tanggal time.Time, kalender *models.KalenderKerja) models.JenisHariLembur {
+	if l, ok := kalender.Libur[tanggal.Format("2006-01-02")]; ok && slices.Contains(s.cfg.JenisLiburResmi, string(l.Jenis)) {
+		return models.HariLemburLibur
+	}
+	if kalender.IsHariKerja(tanggal) {
+		return models.HariLemburKerja
+	}
+	return models.HariLemburIstirahat
+}
+
+// tingkat returns the multiplier tiers of a kind of day
+func (s *LemburService) tingkat(jenis models.JenisHariLembur) []config.TingkatLembur {
+	switch jenis {
+	case models.HariLemburLibur:
+		return s.cfg.HariLibur
+	case models.HariLemburIstirahat:
+		return s.cfg.HariIstirahat
+	}
+	return s.cfg.HariKerja
+}
+
+// Hitung classifies the overtime's date and splits its TotalJam into multiplier tiers at
+// its TarifPerJam, filling JenisHari, Rincian and TotalNominal. Hours beyond the last
+// tier with a limit are paid at that tier's multiplier.
+func (s *LemburService) Hitung(l *models.Lembur, kalender *models.KalenderKerja) {
+	l.JenisHari = s.JenisHari(l.Tanggal, kalender)
+	l.Rincian = nil
+
+	sisa := l.TotalJam
+	tingkat := s.tingkat(l.JenisHari)
+	for i, t := range tingkat {
+		if sisa < 0.005 {
+			break
+		}
+		jam := sisa
+		if t.Jam > 0 && t.Jam < sisa && i < len(tingkat)-1 {
+			jam = t.Jam
+		}
+		jam = math.Round(jam*100) / 100
+		l.Rincian = append(l.Rincian, models.RincianLembur{
+			Urutan:  i + 1,
+			Jam:     jam,
+			Pengali: t.Pengali,
+			Nominal: math.Round(jam*t.Pengali*l.TarifPerJam*100) / 100,
+		})
+		sisa -= jam
+	}
+	l.TotalNominal = l.HitungNominal()
+}
+
+// PemakaianBatas is the overtime already counted against the caps when checking a record:
+// the karyawan's hours in its week and month, and the desa's nominal in its month
+// against the budget, 0 when none is set
+type PemakaianBatas struct {
+	JamMinggu   float64
+	JamBulan    float64
+	NominalDesa float64
+	Anggaran    float64
+}
+
+// AwalMinggu returns the Senin starting the week of t
+func AwalMinggu(t time.Time) time.Time {
+	mundur := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-mundur, 0, 0, 0, 0, time.UTC)
+}
+
+// BlokirBatas reports whether overtime beyond a cap or the budget is rejected rather
+// than only warned about
+func (s *LemburService) BlokirBatas() bool {
+	return s.cfg.BlokirBatas
+}
+
+// WajibSuratPerintah reports whether new overtime must be reported against an approved
+// surat perintah lembur
+func (s *LemburService) WajibSuratPerintah() bool {
+	return s.cfg.WajibSuratPerintah
+}
+
+// CekBatas returns a message for each cap or budget the overtime record would exceed on
+// top of the usage so far
+func (s *LemburService) CekBatas(l *models.Lembur, p PemakaianBatas) []string {
+	var pelanggaran []string
+	if s.cfg.MaksJamMingguan > 0 && p.JamMinggu+l.TotalJam > s.cfg.MaksJamMingguan {
+		pelanggaran = append(pelanggaran, fmt.Sprintf("Weekly overtime would be %s hours, the cap is %s",
+			formatJam(p.JamMinggu+l.TotalJam), formatJam(s.cfg.MaksJamMingguan)))
+	}
+	if s.cfg.MaksJamBulanan > 0 && p.JamBulan+l.TotalJam > s.cfg.MaksJamBulanan {
+		pelanggaran = append(pelanggaran, fmt.Sprintf("Monthly overtime would be %s hours, the cap is %s",
+			formatJam(p.JamBulan+l.TotalJam), formatJam(s.cfg.MaksJamBulanan)))
+	}
+	if p.Anggaran > 0 && p.NominalDesa+l.TotalNominal > p.Anggaran {
+		pelanggaran = append(pelanggaran, fmt.Sprintf("Desa overtime would be Rp %.0f, the budget is Rp %.0f",
+			p.NominalDesa+l.TotalNominal, p.Anggaran))
+	}
+	return pelanggaran
+}
+
+// formatJam writes hours without trailing zeros
+func formatJam(jam float64) string {
+	return strconv.FormatFloat(math.Round(jam*100)/100, 'f', -1, 64)
+}
+
+// Dashboard summarizes a period's overtime against the caps and the budget. Weeks are
+// counted only over the days of the period. Rejected overtime is left out.
+func (s *LemburService) Dashboard(bulan, tahun int, lembur []models.Lembur, anggaran float64) models.DashboardLembur {
+	d := models.DashboardLembur{Bulan: bulan, Tahun: tahun, Anggaran: anggaran, Karyawan: []models.PemakaianLembur{}}
+
+	indeks := make(map[uint]int)
+	perMinggu := make(map[uint]map[time.Time]float64)
+	for _, l := range lembur {
+		if l.Status == "ditolak" {
+			continue
+		}
+		i, ok := indeks[l.KaryawanID]
+		if !ok {
+			i = len(d.Karyawan)
+			indeks[l.KaryawanID] = i
+			perMinggu[l.KaryawanID] = make(map[time.Time]float64)
+			d.Karyawan = append(d.Karyawan, models.PemakaianLembur{
+				KaryawanID:      l.KaryawanID,
+				NamaKaryawan:    l.Karyawan.Nama,
+				MaksJamBulanan:  s.cfg.MaksJamBulanan,
+				MaksJamMingguan: s.cfg.MaksJamMingguan,
+			})
+		}
+		p := &d.Karyawan[i]
+		if l.Status == "disetujui" {
+			p.JamBulan += l.TotalJam
+			p.Nominal += l.TotalNominal
+			d.Nominal += l.TotalNominal
+		} else {
+			p.JamPending += l.TotalJam
+			p.NominalPending += l.TotalNominal
+			d.NominalPending += l.TotalNominal
+		}
+		d.TotalJam += l.TotalJam
+
+		minggu := AwalMinggu(l.Tanggal)
+		perMinggu[l.KaryawanID][minggu] += l.TotalJam
+		p.JamMingguTerbanyak = max(p.JamMingguTerbanyak, perMinggu[l.KaryawanID][minggu])
+	}
+
+	for i := range d.Karyawan {
+		p := &d.Karyawan[i]
+		p.MelebihiBatas = (s.cfg.MaksJamBulanan > 0 && p.JamBulan+p.JamPending > s.cfg.MaksJamBulanan) ||
+			(s.cfg.MaksJamMingguan > 0 && p.JamMingguTerbanyak > s.cfg.MaksJamMingguan)
+		if p.MelebihiBatas {
+			d.JumlahMelebihi++
+		}
+	}
+	sort.Slice(d.Karyawan, func(i, j int) bool {
+		return d.Karyawan[i].JamBulan+d.Karyawan[i].JamPending > d.Karyawan[j].JamBulan+d.Karyawan[j].JamPending
+	})
+
+	if anggaran > 0 {
+		d.SisaAnggaran = anggaran - d.Nominal - d.NominalPending
+		d.PersenAnggaran = math.Round((d.Nominal+d.NominalPending)/anggaran*10000) / 100
+	}
+	return d
+}
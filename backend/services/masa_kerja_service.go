@@ -0,0 +1,82 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"pemdes-payroll/backend/config"
+	"time"
+)
+
+// MasaKerjaService computes a karyawan's share of THR, gaji ke-13 and bonus from the
+// length of service
+type MasaKerjaService struct {
+	cfg *config.MasaKerjaConfig
+}
+
+// NewMasaKerjaService creates a new service-length service
+func NewMasaKerjaService(cfg *config.MasaKerjaConfig) *MasaKerjaService {
+	return &MasaKerjaService{cfg: cfg}
+}
+
+// HakMasaKerja is a karyawan's entitlement on the reference date
+type HakMasaKerja struct {
+	Bulan        int     // full months of service
+	Faktor       float64 // share of the full amount, 0 to 1
+	TanpaTanggal bool    // tanggal bergabung is not recorded, so the full amount is given
+}
+
+// Keterangan describes the entitlement for the slip line item
+func (h HakMasaKerja) Keterangan() string {
+	if h.TanpaTanggal {
+		return "Masa kerja penuh (tanggal bergabung tidak tercatat)"
+	}
+	if h.Faktor >= 1 {
+		return fmt.Sprintf("Masa kerja %d bulan (penuh)", h.Bulan)
+	}
+	return fmt.Sprintf("Masa kerja %d bulan (%.4f)", h.Bulan, h.Faktor)
+}
+
+// Hitung computes the entitlement of a karyawan who joined on bergabung, counted up to
+// acuan. minimalBulan overrides the configured minimum when not nil.
+func (s *MasaKerjaService) Hitung(bergabung *time.Time, acuan time.Time, minimalBulan *int) HakMasaKerja {
+	if bergabung == nil {
+		return HakMasaKerja{Faktor: 1, TanpaTanggal: true}
+	}
+
+	minimal := s.cfg.MinimalBulan
+	if minimalBulan != nil {
+		minimal = *minimalBulan
+	}
+
+	hak := HakMasaKerja{Bulan: jumlahBulan(*bergabung, acuan)}
+	switch {
+	case hak.Bulan < minimal || hak.Bulan <= 0:
+		hak.Faktor = 0
+	case s.cfg.BulanPenuh <= 0 || hak.Bulan >= s.cfg.BulanPenuh:
+		hak.Faktor = 1
+	default:
+		hak.Faktor = math.Round(float64(hak.Bulan)/float64(s.cfg.BulanPenuh)*10000) / 10000
+	}
+	return hak
+}
+
+// Terapkan applies the entitlement to the full amount
+func (h HakMasaKerja) Terapkan(jumlah float64) float64 {
+	if h.Faktor >= 1 {
+		return jumlah
+	}
+	return math.Round(jumlah * h.Faktor)
+}
+
+// jumlahBulan counts the full months from mulai up to sampai
+func jumlahBulan(mulai, sampai time.Time) int {
+	mulai, sampai = tanggalSaja(mulai), tanggalSaja(sampai)
+	if sampai.Before(mulai) {
+		return 0
+	}
+	bulan := (sampai.Year()-mulai.Year())*12 + int(sampai.Month()) - int(mulai.Month())
+	if sampai.Day() < mulai.Day() {
+		bulan--
+	}
+	return bulan
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"math"
+	"pemdes-payroll/backend/models"
+)
+
+// PPh21Service computes monthly PPh 21 withholding using the TER
+// (tarif efektif rata-rata) scheme from PP 58/2023
+type PPh21Service struct{}
+
+// NewPPh21Service creates a new PPh 21 service
+func NewPPh21Service() *PPh21Service {
+	return &PPh21Service{}
+}
+
+// PPh21Input holds the figures needed to compute one month of withholding
+type PPh21Input struct {
+	StatusPTKP      models.StatusPTKP
+	Bulan           int
+	Bruto           float64 // penghasilan bruto bulan berjalan
+	IuranPensiun    float64 // iuran JHT dan JP pegawai bulan berjalan
+	BrutoSebelumnya float64 // akumulasi bruto Januari s.d. bulan sebelumnya
+	IuranSebelumnya float64 // akumulasi iuran JHT dan JP pegawai tahun berjalan
+	PPh21Sebelumnya float64 // akumulasi PPh 21 yang sudah dipotong tahun berjalan
+	BulanSebelumnya int     // jumlah bulan berpenghasilan Januari s.d. bulan sebelumnya
+}
+
+// terBracket is one row of a TER table: bruto up to Batas is taxed at Tarif
+type terBracket struct {
+	Batas float64
+	Tarif float64
+}
+
+// TER kategori A: TK/0, TK/1, K/0
+var terKategoriA = []terBracket{
+	{5400000, 0}, {5650000, 0.0025}, {5950000, 0.005}, {6300000, 0.0075},
+	{6750000, 0.01}, {7500000, 0.0125}, {8550000, 0.015}, {9650000, 0.0175},
+	{10050000, 0.02}, {10350000, 0.0225}, {10700000, 0.025}, {11050000, 0.03},
+	{11600000, 0.035}, {12500000, 0.04}, {13750000, 0.05}, {15100000, 0.06},
+	{16950000, 0.07}, {19750000, 0.08}, {24150000, 0.09}, {26450000, 0.10},
+	{28000000, 0.11}, {30050000, 0.12}, {32400000, 0.13}, {35400000, 0.14},
+	{39100000, 0.15}, {43850000, 0.16}, {47800000, 0.17}, {51400000, 0.18},
+	{56300000, 0.19}, {62200000, 0.20}, {68600000, 0.21}, {77500000, 0.22},
+	{89000000, 0.23}, {103000000, 0.24}, {125000000, 0.25}, {157000000, 0.26},
+	{206000000, 0.27}, {337000000, 0.28}, {454000000, 0.29}, {550000000, 0.30},
+	{695000000, 0.31}, {910000000, 0.32}, {1400000000, 0.33}, {math.MaxFloat64, 0.34},
+}
+
+// TER kategori B: TK/2, TK/3, K/1, K/2
+var terKategoriB = []terBracket{
+	{6200000, 0}, {6500000, 0.0025}, {6850000, 0.005}, {7300000, 0.0075},
+	{9200000, 0.01}, {10750000, 0.015}, {11250000, 0.02}, {11600000, 0.025},
+	{12600000, 0.03}, {13600000, 0.04}, {14950000, 0.05}, {16400000, 0.06},
+	{18450000, 0.07}, {21850000, 0.08}, {26000000, 0.09}, {27700000, 0.10},
+	{29350000, 0.11}, {31450000, 0.12}, {33950000, 0.13}, {37100000, 0.14},
+	{41100000, 0.15}, {45800000, 0.16}, {49500000, 0.17}, {53800000, 0.18},
+	{58500000, 0.19}, {64000000, 0.20}, {71000000, 0.21}, {80000000, 0.22},
+	{93000000, 0.23}, {109000000, 0.24}, {129000000, 0.25}, {163000000, 0.26},
+	{211000000, 0.27}, {374000000, 0.28}, {459000000, 0.29}, {555000000, 0.30},
+	{704000000, 0.31}, {957000000, 0.32}, {1405000000, 0.33}, {math.MaxFloat64, 0.34},
+}
+
+// TER kategori C: K/3
+var terKategoriC = []terBracket{
+	{6600000, 0}, {6950000, 0.0025}, {7350000, 0.005}, {7800000, 0.0075},
+	{8850000, 0.01}, {9800000, 0.0125}, {10950000, 0.015}, {11200000, 0.0175},
+	{12050000, 0.02}, {12950000, 0.03}, {14150000, 0.04}, {15550000, 0.05},
+	{17050000, 0.06}, {19500000, 0.07}, {22700000, 0.08}, {26600000, 0.09},
+	{28100000, 0.10}, {30100000, 0.11}, {32600000, 0.12}, {35400000, 0.13},
+	{38900000, 0.14}, {43000000, 0.15}, {47400000, 0.16}, {51200000, 0.17},
+	{55800000, 0.18}, {60400000, 0.19}, {66700000, 0.20}, {74500000, 0.21},
+	{83200000, 0.22}, {95600000, 0.23}, {110000000, 0.24}, {134000000, 0.25},
+	{169000000, 0.26}, {221000000, 0.27}, {390000000, 0.28}, {463000000, 0.29},
+	{561000000, 0.30}, {709000000, 0.31}, {965000000, 0.32}, {1419000000, 0.33},
+	{math.MaxFloat64, 0.34},
+}
+
+// tarifPasal17 holds the annual progressive rates from UU HPP
+var tarifPasal17 = []terBracket{
+	{60000000, 0.05},
+	{250000000, 0.15},
+	{500000000, 0.25},
+	{5000000000, 0.30},
+	{math.MaxFloat64, 0.35},
+}
+
+const (
+	ptkpDasar          = 54000000.0
+	ptkpTambahan       = 4500000.0
+	biayaJabatanTarif  = 0.05
+	biayaJabatanMaxBln = 500000.0
+)
+
+// GetPTKP returns the annual PTKP amount for a status
+func (s *PPh21Service) GetPTKP(status models.StatusPTKP) float64 {
+	switch status {
+	case models.PTKPTK1:
+		return ptkpDasar + ptkpTambahan
+	case models.PTKPTK2:
+		return ptkpDasar + 2*ptkpTambahan
+	case models.PTKPTK3:
+		return ptkpDasar + 3*ptkpTambahan
+	case models.PTKPK0:
+		return ptkpDasar + ptkpTambahan
+	case models.PTKPK1:
+		return ptkpDasar + 2*ptkpTambahan
+	case models.PTKPK2:
+		return ptkpDasar + 3*ptkpTambahan
+	case models.PTKPK3:
+		return ptkpDasar + 4*ptkpTambahan
+	}
+	return ptkpDasar
+}
+
+// GetTarifTER returns the monthly TER rate for a PTKP status and gross income
+func (s *PPh21Service) GetTarifTER(status models.StatusPTKP, bruto float64) float64 {
+	table := terKategoriA
+	switch status {
+	case models.PTKPTK2, models.PTKPTK3, models.PTKPK1, models.PTKPK2:
+		table = terKategoriB
+	case models.PTKPK3:
+		table = terKategoriC
+	}
+
+	for _, b := range table {
+		if bruto <= b.Batas {
+			return b.Tarif
+		}
+	}
+	return table[len(table)-1].Tarif
+}
+
+// HitungPPh21 computes the PPh 21 to withhold for the month in the input.
+// January to November use the TER rate on the monthly gross; December
+// computes the annual tax under Pasal 17 and withholds the difference with
+// what has already been withheld. A negative December result means the
+// year was over-withheld and the excess is returned on the slip.
+func (s *PPh21Service) HitungPPh21(in PPh21Input) float64 {
+	if in.Bulan != 12 {
+		return math.Floor(in.Bruto * s.GetTarifTER(in.StatusPTKP, in.Bruto))
+	}
+
+	brutoSetahun := in.BrutoSebelumnya + in.Bruto
+	iuranSetahun := in.IuranSebelumnya + in.IuranPensiun
+	return s.HitungPPh21Tahunan(in.StatusPTKP, brutoSetahun, iuranSetahun, in.BulanSebelumnya+1) - in.PPh21Sebelumnya
+}
+
+// HitungPPh21Tahunan computes the annual PPh 21 owed on a yearly gross income earned over
+// jumlahBulan months, after deducting biaya jabatan and the employee's pension
+// contributions. Biaya jabatan is capped at 500.000 per month worked, so a karyawan who
+// joined or left mid-year gets a pro-rated cap.
+func (s *PPh21Service) HitungPPh21Tahunan(status models.StatusPTKP, brutoSetahun, iuranPensiun float64, jumlahBulan int) float64 {
+	jumlahBulan = min(max(jumlahBulan, 1), 12)
+	biayaJabatan := math.Min(brutoSetahun*biayaJabatanTarif, biayaJabatanMaxBln*float64(jumlahBulan))
+	neto := brutoSetahun - biayaJabatan - iuranPensiun
+
+	// PKP is rounded down to whole thousands
+	pkp := math.Floor((neto-s.GetPTKP(status))/1000) * 1000
+	if pkp <= 0 {
+		return 0
+	}
+
+	pajak := 0.0
+	batasBawah := 0.0
+	for _, b := range tarifPasal17 {
+		if pkp <= batasBawah {
+			break
+		}
+		lapisan := math.Min(pkp, b.Batas) - batasBawah
+		pajak += lapisan * b.Tarif
+		batasBawah = b.Batas
+	}
+
+	return math.Floor(pajak)
+}
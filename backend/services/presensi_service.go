@@ -0,0 +1,117 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"pemdes-payroll/backend/config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// toleransiJamServer allows for tokens issued by a server instance whose clock is ahead
+const toleransiJamServer = 5 * time.Second
+
+// radiusBumiMeter is the mean radius of the earth used for distances
+const radiusBumiMeter = 6371000
+
+// PresensiService applies the clock-in rules: the server time in the desa's time zone,
+// the kantor desa geofence and the signed QR tokens of the kiosk
+type PresensiService struct {
+	cfg *config.PresensiConfig
+}
+
+// NewPresensiService creates a new clock-in service
+func NewPresensiService(cfg *config.PresensiConfig) *PresensiService {
+	return &PresensiService{cfg: cfg}
+}
+
+// Sekarang returns the server time in the desa's time zone, and its date as stored in
+// absensi
+func (s *PresensiService) Sekarang() (time.Time, time.Time) {
+	now := time.Now().In(s.cfg.ZonaWaktu)
+	return now, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// CekLokasi checks a reported location against the kantor desa geofence. It returns the
+// distance to the kantor desa when both are known, and why the location is rejected, or
+// "" if accepted.
+func (s *PresensiService) CekLokasi(latitude, longitude *float64) (*float64, string) {
+	if latitude == nil || longitude == nil {
+		if s.cfg.WajibLokasi {
+			return nil, "Location is required"
+		}
+		return nil, ""
+	}
+	if math.Abs(*latitude) > 90 || math.Abs(*longitude) > 180 {
+		return nil, "Invalid location"
+	}
+	if !s.cfg.AdaGeofence() {
+		return nil, ""
+	}
+
+	jarak := math.Round(JarakMeter(*latitude, *longitude, s.cfg.KantorLatitude, s.cfg.KantorLongitude))
+	if jarak > s.cfg.RadiusMeter {
+		return &jarak, fmt.Sprintf("Outside the kantor desa area (%.0f m away, allowed %.0f m)", jarak, s.cfg.RadiusMeter)
+	}
+	return &jarak, ""
+}
+
+// JarakMeter returns the great-circle distance in meters between two coordinates
+func JarakMeter(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * radiusBumiMeter * math.Asin(math.Sqrt(a))
+}
+
+// MasaBerlakuQR returns how long a kiosk QR token stays valid
+func (s *PresensiService) MasaBerlakuQR() time.Duration {
+	return s.cfg.QRMasaBerlaku
+}
+
+// BuatTokenQR returns a signed token naming the kiosk and the time it was issued
+func (s *PresensiService) BuatTokenQR(kiosk string, waktu time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(kiosk + "|" + strconv.FormatInt(waktu.UnixMilli(), 10)))
+	return payload + "." + s.tandaTangan(payload)
+}
+
+// CekTokenQR checks the signature and age of a scanned token at waktu. It returns the
+// kiosk that showed it, and why the token is rejected, or "" if accepted.
+func (s *PresensiService) CekTokenQR(token string, waktu time.Time) (string, string) {
+	payload, tanda, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok || !hmac.Equal([]byte(tanda), []byte(s.tandaTangan(payload))) {
+		return "", "Invalid QR token"
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", "Invalid QR token"
+	}
+	i := strings.LastIndex(string(data), "|")
+	if i < 0 {
+		return "", "Invalid QR token"
+	}
+	kiosk := string(data[:i])
+	terbit, err := strconv.ParseInt(string(data[i+1:]), 10, 64)
+	if err != nil {
+		return kiosk, "Invalid QR token"
+	}
+
+	umur := waktu.Sub(time.UnixMilli(terbit))
+	if umur < -toleransiJamServer || umur > s.cfg.QRMasaBerlaku {
+		return kiosk, "QR token has expired. Scan the code on the screen again"
+	}
+	return kiosk, ""
+}
+
+// tandaTangan returns the HMAC-SHA256 signature of a token payload
+func (s *PresensiService) tandaTangan(payload string) string {
+	mac := hmac.New(sha256.New, s.cfg.QRSecret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
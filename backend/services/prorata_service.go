@@ -0,0 +1,77 @@
+package services
+
+import (
+	"math"
+	"pemdes-payroll/backend/models"
+	"time"
+)
+
+// ProrataService computes the share of a month's fixed pay owed to karyawan who join or
+// leave during the month
+type ProrataService struct{}
+
+// NewProrataService creates a new pro-rating service
+func NewProrataService() *ProrataService {
+	return &ProrataService{}
+}
+
+// HasilProrata is the pro-rate factor of one karyawan for a period
+type HasilProrata struct {
+	Faktor      float64
+	HariDibayar int
+	HariPeriode int
+	Metode      models.MetodeProrata
+}
+
+// Hitung counts the days of the period from tanggal bergabung up to tanggal berhenti,
+// both inclusive, against all days of the period. Either date may be nil. Working days
+// are taken from kalender, or are Monday to Friday when kalender is nil.
+func (s *ProrataService) Hitung(bulan, tahun int, bergabung, berhenti *time.Time, metode models.MetodeProrata, kalender *models.KalenderKerja) HasilProrata {
+	hasil := HasilProrata{Faktor: 1, Metode: metode}
+
+	awal := time.Date(tahun, time.Month(bulan), 1, 0, 0, 0, 0, time.UTC)
+	akhir := awal.AddDate(0, 1, -1)
+
+	mulai, selesai := awal, akhir
+	if bergabung != nil && tanggalSaja(*bergabung).After(mulai) {
+		mulai = tanggalSaja(*bergabung)
+	}
+	if berhenti != nil && tanggalSaja(*berhenti).Before(selesai) {
+		selesai = tanggalSaja(*berhenti)
+	}
+
+	for d := awal; !d.After(akhir); d = d.AddDate(0, 0, 1) {
+		if metode == models.ProrataHariKerja && !isHariKerja(kalender, d) {
+			continue
+		}
+		hasil.HariPeriode++
+		if !d.Before(mulai) && !d.After(selesai) {
+			hasil.HariDibayar++
+		}
+	}
+
+	if hasil.HariPeriode > 0 && hasil.HariDibayar < hasil.HariPeriode {
+		hasil.Faktor = math.Round(float64(hasil.HariDibayar)/float64(hasil.HariPeriode)*10000) / 10000
+	}
+	return hasil
+}
+
+// Terapkan pro-rates a monthly amount
+func (h HasilProrata) Terapkan(jumlah float64) float64 {
+	if h.Faktor >= 1 {
+		return jumlah
+	}
+	return math.Round(jumlah * h.Faktor)
+}
+
+func isHariKerja(kalender *models.KalenderKerja, d time.Time) bool {
+	if kalender != nil {
+		return kalender.IsHariKerja(d)
+	}
+	return d.Weekday() != time.Saturday && d.Weekday() != time.Sunday
+}
+
+// tanggalSaja drops the time of day, keeping the calendar date as stored
+func tanggalSaja(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
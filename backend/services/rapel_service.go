@@ -0,0 +1,113 @@
+package services
+
+import (
+	"math"
+	"pemdes-payroll/backend/models"
+	"sort"
+)
+
+// RapelService computes back pay owed on past slips after a retroactive rate change
+type RapelService struct{}
+
+// NewRapelService creates a new rapel service
+func NewRapelService() *RapelService {
+	return &RapelService{}
+}
+
+// RapelInput holds a past slip and the rates now valid for its period
+type RapelInput struct {
+	Gaji             *models.Gaji        // the past slip, with line items
+	GajiPokok        float64             // gaji pokok now valid for the period
+	TunjanganJabatan float64             // tunjangan jabatan now valid for the period
+	SelisihLembur    float64             // approved overtime at the rates now valid minus what was paid
+	KomponenPersen   map[uint]bool       // komponen gaji computed as a percentage of gaji pokok
+	Sebelumnya       *models.RapelDetail // latest earlier rapel of the slip, if any
+}
+
+// HitungSelisih compares what a past slip paid, including earlier rapel, with what it
+// pays under the current rates. The new rates are pro-rated like the slip was. Components
+// computed as a percentage of gaji pokok are scaled with the new gaji pokok; other
+// components and deductions are unchanged.
+func (s *RapelService) HitungSelisih(in RapelInput) models.RapelDetail {
+	g := in.Gaji
+
+	gajiPokok, tunjanganJabatan := in.GajiPokok, in.TunjanganJabatan
+	if g.IsProrata() {
+		gajiPokok = math.Round(gajiPokok * g.FaktorProrata)
+		tunjanganJabatan = math.Round(tunjanganJabatan * g.FaktorProrata)
+	}
+
+	komponenSlip := 0.0
+	komponenBaru := 0.0
+	for _, item := range g.Items {
+		if item.KomponenGajiID == nil || !in.KomponenPersen[*item.KomponenGajiID] {
+			continue
+		}
+		jumlah := item.Jumlah
+		if item.Jenis == models.JenisPotongan {
+			jumlah = -jumlah
+		}
+		komponenSlip += jumlah
+		if g.GajiPokok > 0 {
+			komponenBaru += math.Round(jumlah * gajiPokok / g.GajiPokok)
+		}
+	}
+
+	detail := models.RapelDetail{
+		KaryawanID:           g.KaryawanID,
+		GajiID:               g.ID,
+		PeriodeBulan:         g.PeriodeBulan,
+		PeriodeTahun:         g.PeriodeTahun,
+		GajiPokokLama:        g.GajiPokok,
+		GajiPokokBaru:        gajiPokok,
+		TunjanganJabatanLama: g.TunjanganJabatan,
+		TunjanganJabatanBaru: tunjanganJabatan,
+		LemburLama:           g.Lembur,
+		LemburBaru:           g.Lembur + in.SelisihLembur,
+		KomponenLama:         komponenSlip,
+		KomponenBaru:         komponenBaru,
+	}
+	if p := in.Sebelumnya; p != nil {
+		detail.GajiPokokLama = p.GajiPokokBaru
+		detail.TunjanganJabatanLama = p.TunjanganJabatanBaru
+		detail.LemburLama = p.LemburBaru
+		detail.KomponenLama = p.KomponenBaru
+	}
+
+	detail.Selisih = (detail.GajiPokokBaru - detail.GajiPokokLama) +
+		(detail.TunjanganJabatanBaru - detail.TunjanganJabatanLama) +
+		(detail.LemburBaru - detail.LemburLama) +
+		(detail.KomponenBaru - detail.KomponenLama)
+	return detail
+}
+
+// TotalPerKaryawan sums the differences per karyawan. Details must have Karyawan loaded
+// for the name columns to be filled.
+func (s *RapelService) TotalPerKaryawan(detail []models.RapelDetail) []models.RapelKaryawan {
+	var totals []models.RapelKaryawan
+	index := make(map[uint]int)
+
+	for _, d := range detail {
+		i, ok := index[d.KaryawanID]
+		if !ok {
+			t := models.RapelKaryawan{
+				KaryawanID:   d.KaryawanID,
+				NIK:          d.Karyawan.NIK,
+				NamaKaryawan: d.Karyawan.Nama,
+			}
+			if d.Karyawan.Jabatan != nil {
+				t.Jabatan = d.Karyawan.Jabatan.NamaJabatan
+			}
+			totals = append(totals, t)
+			i = len(totals) - 1
+			index[d.KaryawanID] = i
+		}
+		totals[i].JumlahBulan++
+		totals[i].TotalRapel += d.Selisih
+	}
+
+	sort.SliceStable(totals, func(a, b int) bool {
+		return totals[a].NamaKaryawan < totals[b].NamaKaryawan
+	})
+	return totals
+}
@@ -61,6 +61,8 @@ export const AuthProvider = ({ children }) => {
     const roleHierarchy = {
       admin: 4,
       finance: 3,
+      kades: 3,
+      sekdes: 3,
       hr: 2,
       karyawan: 1,
     };
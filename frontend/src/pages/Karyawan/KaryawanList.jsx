@@ -19,6 +19,7 @@ const KaryawanList = () => {
     alamat: '',
     jabatan_id: '',
     tanggal_bergabung: '',
+    tanggal_berhenti: '',
     status: 'aktif',
   });
 
@@ -65,6 +66,7 @@ const KaryawanList = () => {
         alamat: karyawan.alamat || '',
         jabatan_id: karyawan.jabatan_id?.toString() || '',
         tanggal_bergabung: karyawan.tanggal_bergabung?.split('T')[0] || '',
+        tanggal_berhenti: karyawan.tanggal_berhenti?.split('T')[0] || '',
         status: karyawan.status || 'aktif',
       });
     } else {
@@ -77,6 +79,7 @@ const KaryawanList = () => {
         alamat: '',
         jabatan_id: '',
         tanggal_bergabung: '',
+        tanggal_berhenti: '',
         status: 'aktif',
       });
     }
@@ -265,6 +268,12 @@ const KaryawanList = () => {
               value={formData.tanggal_bergabung}
               onChange={(e) => setFormData({ ...formData, tanggal_bergabung: e.target.value })}
             />
+            <Input
+              label="Tanggal Berhenti"
+              type="date"
+              value={formData.tanggal_berhenti}
+              onChange={(e) => setFormData({ ...formData, tanggal_berhenti: e.target.value })}
+            />
             <div className="mb-4">
               <label className="block text-sm font-medium text-gray-700 mb-1">Status</label>
               <select
@@ -1,6 +1,6 @@
 import React, { useEffect, useState } from 'react';
 import axios from 'axios';
-import { lemburAPI, karyawanAPI } from '../../services/api';
+import { lemburAPI, karyawanAPI, suratPerintahLemburAPI } from '../../services/api';
 import Button from '../../components/ui/Button';
 import Modal from '../../components/ui/Modal';
 import Input from '../../components/ui/Input';
@@ -8,6 +8,7 @@ import Input from '../../components/ui/Input';
 const LemburList = () => {
   const [lembur, setLembur] = useState([]);
   const [karyawan, setKaryawan] = useState([]);
+  const [suratPerintah, setSuratPerintah] = useState([]);
   const [loading, setLoading] = useState(false);
   const [isModalOpen, setIsModalOpen] = useState(false);
   const [isApproveModalOpen, setIsApproveModalOpen] = useState(false);
@@ -18,16 +19,18 @@ const LemburList = () => {
   });
   const [editingLembur, setEditingLembur] = useState(null);
   const [formData, setFormData] = useState({
+    surat_perintah_id: '',
     karyawan_id: '',
     tanggal: '',
     jam_mulai: '',
     jam_selesai: '',
-    total_jam: '',
+    istirahat_menit: '',
     keterangan: '',
   });
 
   useEffect(() => {
     fetchKaryawan();
+    fetchSuratPerintah();
   }, []);
 
   useEffect(() => {
@@ -43,6 +46,15 @@ const LemburList = () => {
     }
   };
 
+  const fetchSuratPerintah = async () => {
+    try {
+      const response = await suratPerintahLemburAPI.getAll('disetujui');
+      setSuratPerintah(response.data);
+    } catch (error) {
+      console.error('Error fetching surat perintah lembur:', error);
+    }
+  };
+
   const fetchLembur = async () => {
     setLoading(true);
     try {
@@ -59,21 +71,23 @@ const LemburList = () => {
     if (lembur) {
       setEditingLembur(lembur);
       setFormData({
+        surat_perintah_id: lembur.surat_perintah_id ? lembur.surat_perintah_id.toString() : '',
         karyawan_id: lembur.karyawan_id.toString(),
         tanggal: lembur.tanggal ? lembur.tanggal.split('T')[0] : '',
         jam_mulai: lembur.jam_mulai || '',
         jam_selesai: lembur.jam_selesai || '',
-        total_jam: lembur.total_jam.toString(),
+        istirahat_menit: lembur.istirahat_menit ? lembur.istirahat_menit.toString() : '',
         keterangan: lembur.keterangan || '',
       });
     } else {
       setEditingLembur(null);
       setFormData({
+        surat_perintah_id: '',
         karyawan_id: '',
         tanggal: new Date().toISOString().split('T')[0],
         jam_mulai: '',
         jam_selesai: '',
-        total_jam: '',
+        istirahat_menit: '',
         keterangan: '',
       });
     }
@@ -90,8 +104,9 @@ const LemburList = () => {
     try {
       const data = {
         ...formData,
+        surat_perintah_id: formData.surat_perintah_id ? parseInt(formData.surat_perintah_id) : null,
         karyawan_id: parseInt(formData.karyawan_id),
-        total_jam: parseFloat(formData.total_jam),
+        istirahat_menit: parseInt(formData.istirahat_menit || '0'),
       };
 
       if (editingLembur) {
@@ -134,7 +149,7 @@ const LemburList = () => {
     if (!selectedLembur) return;
 
     try {
-      await lemburAPI.approve(selectedLembur.id, status, null);
+      await lemburAPI.approve(selectedLembur.id, status);
       await fetchLembur();
       setIsApproveModalOpen(false);
       setSelectedLembur(null);
@@ -263,7 +278,15 @@ const LemburList = () => {
                     </td>
                     <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-900">{row.total_jam}</td>
                     <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-900">{formatCurrency(row.tarif_per_jam)}</td>
-                    <td className="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{formatCurrency(row.total_nominal)}</td>
+                    <td className="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">
+                      {formatCurrency(row.total_nominal)}
+                      {row.rincian?.length > 0 && (
+                        <div className="text-xs font-normal text-gray-500">
+                          {row.rincian.map((r) => `${r.jam} jam x${r.pengali}`).join(', ')}
+                          {row.jenis_hari && row.jenis_hari !== 'hari_kerja' && ` (${row.jenis_hari === 'hari_libur' ? 'hari libur' : 'hari istirahat'})`}
+                        </div>
+                      )}
+                    </td>
                     <td className="px-6 py-4 text-sm text-gray-500">{row.keterangan}</td>
                     <td className="px-6 py-4 whitespace-nowrap text-sm">{getStatusBadge(row.status)}</td>
                     <td className="px-6 py-4 whitespace-nowrap text-sm">
@@ -290,6 +313,26 @@ const LemburList = () => {
 
       <Modal isOpen={isModalOpen} onClose={handleCloseModal} title={editingLembur ? 'Edit Lembur' : 'Tambah Lembur'}>
         <form onSubmit={handleSubmit}>
+          <Input
+            label="Surat Perintah Lembur"
+            type="select"
+            value={formData.surat_perintah_id}
+            onChange={(e) => {
+              const spl = suratPerintah.find(sp => sp.id === parseInt(e.target.value));
+              setFormData({
+                ...formData,
+                surat_perintah_id: e.target.value,
+                tanggal: spl ? spl.tanggal.split('T')[0] : formData.tanggal,
+                jam_mulai: formData.jam_mulai || spl?.jam_mulai || '',
+                jam_selesai: formData.jam_selesai || spl?.jam_selesai || '',
+              });
+            }}
+            disabled={!!editingLembur}
+            options={suratPerintah.map(sp => ({
+              value: sp.id,
+              label: `${sp.tanggal.split('T')[0]} - ${sp.tugas} (${sp.rencana_jam} jam)`,
+            }))}
+          />
           <Input
             label="Karyawan"
             type="select"
@@ -297,7 +340,12 @@ const LemburList = () => {
             onChange={(e) => setFormData({ ...formData, karyawan_id: e.target.value })}
             required
             disabled={!!editingLembur}
-            options={karyawan.map(k => ({ value: k.id, label: `${k.nama} - ${k.jabatan?.nama_jabatan || 'Tanpa Jabatan'}` }))}
+            options={karyawan
+              .filter(k => {
+                const spl = suratPerintah.find(sp => sp.id === parseInt(formData.surat_perintah_id));
+                return !spl || spl.karyawan.some(sk => sk.id === k.id);
+              })
+              .map(k => ({ value: k.id, label: `${k.nama} - ${k.jabatan?.nama_jabatan || 'Tanpa Jabatan'}` }))}
           />
           <Input
             label="Tanggal"
@@ -305,7 +353,7 @@ const LemburList = () => {
             value={formData.tanggal}
             onChange={(e) => setFormData({ ...formData, tanggal: e.target.value })}
             required
-            disabled={!!editingLembur}
+            disabled={!!editingLembur || !!formData.surat_perintah_id}
           />
           <div className="grid grid-cols-2 gap-4">
             <Input
@@ -313,26 +361,28 @@ const LemburList = () => {
               value={formData.jam_mulai}
               onChange={(e) => setFormData({ ...formData, jam_mulai: e.target.value })}
               placeholder="17:00"
-              disabled={!!editingLembur}
+              required
             />
             <Input
               label="Jam Selesai"
               value={formData.jam_selesai}
               onChange={(e) => setFormData({ ...formData, jam_selesai: e.target.value })}
               placeholder="20:00"
-              disabled={!!editingLembur}
+              required
             />
           </div>
           <Input
-            label="Total Jam"
+            label="Istirahat (menit)"
             type="number"
-            value={formData.total_jam}
-            onChange={(e) => setFormData({ ...formData, total_jam: e.target.value })}
-            placeholder="Contoh: 2.5"
-            required
-            min="0.5"
-            step="0.5"
+            value={formData.istirahat_menit}
+            onChange={(e) => setFormData({ ...formData, istirahat_menit: e.target.value })}
+            placeholder="0"
+            min="0"
+            step="5"
           />
+          <p className="text-xs text-gray-500">
+            Total jam dihitung otomatis dari jam mulai dan jam selesai dikurangi istirahat.
+          </p>
           <Input
             label="Keterangan"
             value={formData.keterangan}
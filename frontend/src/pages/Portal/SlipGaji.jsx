@@ -58,6 +58,13 @@ const SlipGaji = () => {
     { value: 12, label: 'Desember' },
   ];
 
+  const jenisLabel = {
+    rapel: 'Rapel Gaji',
+    thr: 'Tunjangan Hari Raya',
+    ke13: 'Gaji ke-13',
+    bonus: 'Bonus',
+  };
+
   const getStatusBadge = (status) => {
     return (
       <span className={`px-2 py-1 rounded-full text-xs font-medium ${
@@ -129,7 +136,9 @@ const SlipGaji = () => {
 
           <div id="slip-content" className="border-2 border-gray-300 p-8">
             <div className="text-center mb-8 pb-4 border-b-2 border-gray-300">
-              <h1 className="text-2xl font-bold text-gray-800">SLIP GAJI</h1>
+              <h1 className="text-2xl font-bold text-gray-800">
+                {jenisLabel[selectedSlip.jenis] ? `SLIP ${jenisLabel[selectedSlip.jenis].toUpperCase()}` : 'SLIP GAJI'}
+              </h1>
               <p className="text-gray-600">Pemerintah Desa</p>
             </div>
 
@@ -151,7 +160,24 @@ const SlipGaji = () => {
                 <p className="font-medium text-gray-800">
                   {bulanOptions.find(b => b.value === selectedSlip.periode_bulan)?.label} {selectedSlip.periode_tahun}
                 </p>
+                {selectedSlip.faktor_prorata > 0 && selectedSlip.faktor_prorata < 1 && (
+                  <p className="text-sm text-gray-500">
+                    Prorata {selectedSlip.hari_dibayar}/{selectedSlip.hari_periode}{' '}
+                    {selectedSlip.metode_prorata === 'hari_kerja' ? 'hari kerja' : 'hari kalender'} ({selectedSlip.faktor_prorata})
+                  </p>
+                )}
               </div>
+              {(selectedSlip.hari_hadir > 0 || selectedSlip.hari_alpha > 0) && (
+                <div className="col-span-2">
+                  <p className="text-sm text-gray-500">Kehadiran</p>
+                  <p className="font-medium text-gray-800">
+                    Hadir {selectedSlip.hari_hadir} hari, Izin {selectedSlip.hari_izin}, Sakit {selectedSlip.hari_sakit},
+                    Alpha {selectedSlip.hari_alpha}
+                    {selectedSlip.hari_terlambat > 0 &&
+                      `, Terlambat ${selectedSlip.hari_terlambat} hari (${selectedSlip.menit_terlambat} menit)`}
+                  </p>
+                </div>
+              )}
             </div>
 
             <table className="w-full mb-6">
@@ -175,13 +201,31 @@ const SlipGaji = () => {
                   <td className="border border-gray-300 px-4 py-2 text-right">{formatCurrency(selectedSlip.tunjangan_transport)}</td>
                 </tr>
                 <tr>
-                  <td className="border border-gray-300 px-4 py-2">Tunjangan Makan</td>
+                  <td className="border border-gray-300 px-4 py-2">
+                    Tunjangan Makan
+                    {selectedSlip.makan_per_hadir > 0 && (
+                      <span className="block text-xs text-gray-500">
+                        {selectedSlip.hari_hadir} hari hadir x {formatCurrency(selectedSlip.makan_per_hadir)}
+                      </span>
+                    )}
+                  </td>
                   <td className="border border-gray-300 px-4 py-2 text-right">{formatCurrency(selectedSlip.tunjangan_makan)}</td>
                 </tr>
                 <tr>
                   <td className="border border-gray-300 px-4 py-2">Lembur</td>
                   <td className="border border-gray-300 px-4 py-2 text-right">{formatCurrency(selectedSlip.lembur)}</td>
                 </tr>
+                {(selectedSlip.items || [])
+                  .filter((item) => ['POT_ALPHA', 'DENDA_TERLAMBAT'].includes(item.kode))
+                  .map((item) => (
+                    <tr key={item.kode} className="bg-red-50">
+                      <td className="border border-gray-300 px-4 py-2">
+                        {item.nama}
+                        <span className="block text-xs text-gray-500">{item.keterangan}</span>
+                      </td>
+                      <td className="border border-gray-300 px-4 py-2 text-right text-red-600">{formatCurrency(item.jumlah)}</td>
+                    </tr>
+                  ))}
                 <tr className="bg-red-50">
                   <td className="border border-gray-300 px-4 py-2 font-medium">Potongan</td>
                   <td className="border border-gray-300 px-4 py-2 text-right text-red-600">{formatCurrency(selectedSlip.potongan)}</td>
@@ -231,6 +275,9 @@ const SlipGaji = () => {
                   <tr key={slip.id} className="hover:bg-gray-50">
                     <td className="px-6 py-4 text-sm text-gray-900">
                       {bulanOptions.find(b => b.value === slip.periode_bulan)?.label} {slip.periode_tahun}
+                      {jenisLabel[slip.jenis] && (
+                        <span className="block text-xs text-gray-500">{jenisLabel[slip.jenis]}</span>
+                      )}
                     </td>
                     <td className="px-6 py-4 text-sm text-gray-900">{formatCurrency(slip.gaji_pokok)}</td>
                     <td className="px-6 py-4 text-sm text-gray-900">
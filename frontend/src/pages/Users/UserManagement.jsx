@@ -118,12 +118,16 @@ const UserManagement = () => {
       admin: 'bg-purple-100 text-purple-800',
       hr: 'bg-blue-100 text-blue-800',
       finance: 'bg-green-100 text-green-800',
+      kades: 'bg-yellow-100 text-yellow-800',
+      sekdes: 'bg-orange-100 text-orange-800',
       karyawan: 'bg-gray-100 text-gray-800',
     };
     const labels = {
       admin: 'Admin',
       hr: 'HR',
       finance: 'Finance',
+      kades: 'Kepala Desa',
+      sekdes: 'Sekretaris Desa',
       karyawan: 'Karyawan',
     };
     return (
@@ -242,6 +246,8 @@ const UserManagement = () => {
               <option value="admin">Admin</option>
               <option value="hr">HR</option>
               <option value="finance">Finance</option>
+              <option value="kades">Kepala Desa</option>
+              <option value="sekdes">Sekretaris Desa</option>
               <option value="karyawan">Karyawan</option>
             </select>
           </div>
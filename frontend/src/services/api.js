@@ -85,9 +85,21 @@ export const absensiAPI = {
   getById: (id) => api.get(`/api/absensi/${id}`),
   getByKaryawan: (id, startDate, endDate) => api.get(`/api/absensi/karyawan/${id}?start_date=${startDate}&end_date=${endDate}`),
   getRekap: (karyawanId, bulan, tahun) => api.get(`/api/absensi/rekap/${karyawanId}?bulan=${bulan}&tahun=${tahun}`),
+  getKeterlambatan: (bulan, tahun) => api.get(`/api/absensi/keterlambatan?bulan=${bulan}&tahun=${tahun}`),
   create: (data) => api.post('/api/absensi', data),
   update: (id, data) => api.put(`/api/absensi/${id}`, data),
   delete: (id) => api.delete(`/api/absensi/${id}`),
+  // Corrects a row already paid in a slip gaji through a koreksi gaji of the next period
+  koreksiGaji: (id, data) => api.post(`/api/absensi/${id}/koreksi-gaji`, data),
+  // Same for a day of a paid period without an absensi row; data holds karyawan_id and tanggal
+  koreksiGajiHari: (data) => api.post('/api/absensi/koreksi-gaji', data),
+  // formData holds the terminal log as "file"; simulasi reports without saving
+  importFingerprint: (formData, simulasi) => {
+    formData.set('simulasi', simulasi ? 'true' : 'false');
+    return api.post('/api/absensi/import/fingerprint', formData, {
+      headers: { 'Content-Type': 'multipart/form-data' },
+    });
+  },
   exportExcel: () => {
     window.location.href = `${API_BASE_URL}/api/absensi/export/excel`;
   },
@@ -96,6 +108,76 @@ export const absensiAPI = {
   },
 };
 
+// Jadwal Kerja API
+export const jadwalKerjaAPI = {
+  getAll: () => api.get('/api/jadwal-kerja'),
+  update: (data) => api.put('/api/jadwal-kerja', data),
+};
+
+// Kalender Kerja API
+export const kalenderAPI = {
+  getHariLibur: (tahun) => api.get(`/api/hari-libur?tahun=${tahun}`),
+  createHariLibur: (data) => api.post('/api/hari-libur', data),
+  importHariLibur: (formData) => api.post('/api/hari-libur/import', formData, {
+    headers: { 'Content-Type': 'multipart/form-data' },
+  }),
+  deleteHariLibur: (id) => api.delete(`/api/hari-libur/${id}`),
+  getHariKerja: (bulan, tahun, karyawanId) =>
+    api.get(`/api/kalender/hari-kerja?bulan=${bulan}&tahun=${tahun}${karyawanId ? `&karyawan_id=${karyawanId}` : ''}`),
+};
+
+// Auto alpha API
+export const autoAlphaAPI = {
+  getAll: (status) => api.get(`/api/absensi/auto-alpha${status ? `?status=${status}` : ''}`),
+  getById: (id) => api.get(`/api/absensi/auto-alpha/${id}`),
+  create: (data) => api.post('/api/absensi/auto-alpha', data),
+  terapkan: (id, kecualikanDetailIds = []) =>
+    api.post(`/api/absensi/auto-alpha/${id}/terapkan`, { kecualikan_detail_ids: kecualikanDetailIds }),
+  batal: (id) => api.post(`/api/absensi/auto-alpha/${id}/batal`),
+};
+
+// Presensi API - self-service clock-in/out
+export const presensiAPI = {
+  getHariIni: () => api.get('/api/presensi/hari-ini'),
+  getLog: (mulai, selesai, karyawanId, diterima) =>
+    api.get(`/api/presensi/log?mulai=${mulai}&selesai=${selesai}&karyawan_id=${karyawanId || ''}&diterima=${diterima ?? ''}`),
+  masuk: (data = {}) => api.post('/api/presensi/masuk', data),
+  keluar: (data = {}) => api.post('/api/presensi/keluar', data),
+  getTokenQR: (kiosk) => api.get(`/api/presensi/qr/token?kiosk=${encodeURIComponent(kiosk || 'kantor-desa')}`),
+  scanQR: (token, perangkat) => api.post('/api/presensi/qr', { token, perangkat }),
+};
+
+// Koreksi absensi API
+export const koreksiAbsensiAPI = {
+  getAll: (status, karyawanId) =>
+    api.get(`/api/absensi/koreksi?status=${status || ''}&karyawan_id=${karyawanId || ''}`),
+  getById: (id) => api.get(`/api/absensi/koreksi/${id}`),
+  // data is a FormData when a lampiran is attached
+  create: (data) => api.post('/api/absensi/koreksi', data, data instanceof FormData ? {
+    headers: { 'Content-Type': 'multipart/form-data' },
+  } : undefined),
+  getLampiran: (id) => api.get(`/api/absensi/koreksi/${id}/lampiran`, { responseType: 'blob' }),
+  setujui: (id, catatan) => api.post(`/api/absensi/koreksi/${id}/setujui`, { catatan }),
+  tolak: (id, catatan) => api.post(`/api/absensi/koreksi/${id}/tolak`, { catatan }),
+  batal: (id, catatan) => api.post(`/api/absensi/koreksi/${id}/batal`, { catatan }),
+};
+
+// Cuti API
+export const cutiAPI = {
+  getAll: (status, karyawanId, tahun) =>
+    api.get(`/api/cuti?status=${status || ''}&karyawan_id=${karyawanId || ''}&tahun=${tahun || ''}`),
+  getById: (id) => api.get(`/api/cuti/${id}`),
+  getSaldo: (karyawanId, tahun) => api.get(`/api/cuti/saldo?karyawan_id=${karyawanId || ''}&tahun=${tahun}`),
+  // data is a FormData when a lampiran (e.g. surat dokter) is attached
+  create: (data) => api.post('/api/cuti', data, data instanceof FormData ? {
+    headers: { 'Content-Type': 'multipart/form-data' },
+  } : undefined),
+  getLampiran: (id) => api.get(`/api/cuti/${id}/lampiran`, { responseType: 'blob' }),
+  setujui: (id, catatan) => api.post(`/api/cuti/${id}/setujui`, { catatan }),
+  tolak: (id, catatan) => api.post(`/api/cuti/${id}/tolak`, { catatan }),
+  batal: (id, catatan) => api.post(`/api/cuti/${id}/batal`, { catatan }),
+};
+
 // Lembur API
 export const lemburAPI = {
   getAll: () => api.get('/api/lembur'),
@@ -105,7 +187,22 @@ export const lemburAPI = {
   create: (data) => api.post('/api/lembur', data),
   update: (id, data) => api.put(`/api/lembur/${id}`, data),
   delete: (id) => api.delete(`/api/lembur/${id}`),
-  approve: (id, status, approverId = null) => api.patch(`/api/lembur/${id}/approve`, { status, approver_id: approverId }),
+  approve: (id, status) => api.patch(`/api/lembur/${id}/approve`, { status }),
+  // Corrects overtime already paid in a slip gaji through a koreksi gaji of the next period
+  koreksi: (id, data) => api.post(`/api/lembur/${id}/koreksi`, data),
+  getDashboard: (bulan, tahun) => api.get(`/api/lembur/dashboard?bulan=${bulan}&tahun=${tahun}`),
+  setAnggaran: (data) => api.put('/api/lembur/anggaran', data),
+};
+
+// Surat perintah lembur: overtime orders issued before the work
+export const suratPerintahLemburAPI = {
+  getAll: (status, karyawanId, bulan, tahun) =>
+    api.get(`/api/lembur/surat-perintah?status=${status || ''}&karyawan_id=${karyawanId || ''}&bulan=${bulan || ''}&tahun=${tahun || ''}`),
+  getById: (id) => api.get(`/api/lembur/surat-perintah/${id}`),
+  create: (data) => api.post('/api/lembur/surat-perintah', data),
+  setujui: (id, catatan) => api.post(`/api/lembur/surat-perintah/${id}/setujui`, { catatan }),
+  tolak: (id, catatan) => api.post(`/api/lembur/surat-perintah/${id}/tolak`, { catatan }),
+  batal: (id, catatan) => api.post(`/api/lembur/surat-perintah/${id}/batal`, { catatan }),
 };
 
 // Health check
@@ -30,6 +30,25 @@ func main() {
 		&models.Absensi{},
 		&models.Lembur{},
 		&models.User{},
+		&models.KomponenGaji{},
+		&models.KomponenJabatan{},
+		&models.KomponenKaryawan{},
+		&models.GajiItem{},
+		&models.PayrollRun{},
+		&models.KoreksiGaji{},
+		&models.TarifJabatan{},
+		&models.Rapel{},
+		&models.RapelDetail{},
+		&models.Kasbon{},
+		&models.AngsuranKasbon{},
+		&models.JadwalKerja{},
+		&models.HariLibur{},
+		&models.UsulanAlpha{},
+		&models.DetailUsulanAlpha{},
+		&models.PengajuanCuti{},
+		&models.SaldoCuti{},
+		&models.LogPresensi{}, &models.PengajuanKoreksiAbsensi{}, &models.RiwayatAbsensi{}, &models.RincianLembur{}, &models.AnggaranLembur{},
+		&models.SuratPerintahLembur{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
@@ -43,15 +62,77 @@ func main() {
 	absensiRepo := repositories.NewAbsensiRepository(db)
 	lemburRepo := repositories.NewLemburRepository(db)
 	userRepo := repositories.NewUserRepository(db)
+	komponenGajiRepo := repositories.NewKomponenGajiRepository(db)
+	payrollRunRepo := repositories.NewPayrollRunRepository(db)
+	koreksiGajiRepo := repositories.NewKoreksiGajiRepository(db)
+	tarifJabatanRepo := repositories.NewTarifJabatanRepository(db)
+	rapelRepo := repositories.NewRapelRepository(db)
+	kasbonRepo := repositories.NewKasbonRepository(db)
+	jadwalKerjaRepo := repositories.NewJadwalKerjaRepository(db)
+	hariLiburRepo := repositories.NewHariLiburRepository(db)
+	usulanAlphaRepo := repositories.NewUsulanAlphaRepository(db)
+	cutiRepo := repositories.NewCutiRepository(db)
+	presensiRepo := repositories.NewPresensiRepository(db)
+	koreksiAbsensiRepo := repositories.NewKoreksiAbsensiRepository(db)
+	suratPerintahLemburRepo := repositories.NewSuratPerintahLemburRepository(db)
+
+	// Itemize gaji rows saved before slips carried line items
+	if migrated, err := gajiRepo.MigrateLegacyItems(); err != nil {
+		log.Printf("Warning: Failed to migrate gaji line items: %v", err)
+	} else if migrated > 0 {
+		log.Printf("Migrated line items for %d gaji records", migrated)
+	}
+
+	// Attach gaji rows saved before payroll runs existed to a run for their period
+	if migrated, err := payrollRunRepo.MigrateLegacyRuns(); err != nil {
+		log.Printf("Warning: Failed to migrate payroll runs: %v", err)
+	} else if migrated > 0 {
+		log.Printf("Created payroll runs for %d legacy periods", migrated)
+	}
+
+	// Link the lembur and absensi paid by runs approved before slips recorded their sources
+	if migrated, err := payrollRunRepo.MigrateSumberGaji(); err != nil {
+		log.Printf("Warning: Failed to link lembur and absensi to gaji: %v", err)
+	} else if migrated > 0 {
+		log.Printf("Linked %d lembur and absensi records to their gaji", migrated)
+	}
+
+	// Record the current rates of jabatan created before rate history existed
+	if migrated, err := tarifJabatanRepo.MigrateTarifAwal(); err != nil {
+		log.Printf("Warning: Failed to migrate tarif jabatan: %v", err)
+	} else if migrated > 0 {
+		log.Printf("Created initial tarif for %d jabatan", migrated)
+	}
+
+	// Save the default working schedule on first start
+	if created, err := jadwalKerjaRepo.InitDefault(); err != nil {
+		log.Printf("Warning: Failed to create default jadwal kerja: %v", err)
+	} else if created > 0 {
+		log.Printf("Created default jadwal kerja for %d days", created)
+	}
 
 	// Initialize handlers
-	jabatanHandler := handlers.NewJabatanHandler(jabatanRepo)
+	jabatanHandler := handlers.NewJabatanHandler(jabatanRepo, tarifJabatanRepo)
 	karyawanHandler := handlers.NewKaryawanHandler(karyawanRepo)
-	gajiHandler := handlers.NewGajiHandler(gajiRepo, karyawanRepo, lemburRepo)
+	gajiHandler := handlers.NewGajiHandler(gajiRepo, karyawanRepo, lemburRepo, absensiRepo, komponenGajiRepo, payrollRunRepo, koreksiGajiRepo, tarifJabatanRepo, rapelRepo, kasbonRepo, hariLiburRepo)
 	laporanHandler := handlers.NewLaporanHandler(laporanRepo, karyawanRepo, gajiRepo)
-	absensiHandler := handlers.NewAbsensiHandler(absensiRepo, karyawanRepo)
-	lemburHandler := handlers.NewLemburHandler(lemburRepo, karyawanRepo)
+	absensiHandler := handlers.NewAbsensiHandler(absensiRepo, karyawanRepo, payrollRunRepo, gajiRepo, koreksiGajiRepo, komponenGajiRepo, hariLiburRepo)
+	lemburHandler := handlers.NewLemburHandler(lemburRepo, karyawanRepo, payrollRunRepo, tarifJabatanRepo, hariLiburRepo, suratPerintahLemburRepo, userRepo, koreksiGajiRepo)
 	authHandler := handlers.NewAuthHandler(userRepo)
+	komponenGajiHandler := handlers.NewKomponenGajiHandler(komponenGajiRepo)
+	payrollRunHandler := handlers.NewPayrollRunHandler(payrollRunRepo, gajiHandler)
+	koreksiGajiHandler := handlers.NewKoreksiGajiHandler(koreksiGajiRepo, gajiRepo)
+	rapelHandler := handlers.NewRapelHandler(db, rapelRepo, gajiHandler)
+	runKhususHandler := handlers.NewRunKhususHandler(gajiHandler)
+	kasbonHandler := handlers.NewKasbonHandler(kasbonRepo, karyawanRepo)
+	jadwalKerjaHandler := handlers.NewJadwalKerjaHandler(jadwalKerjaRepo)
+	hariLiburHandler := handlers.NewHariLiburHandler(hariLiburRepo, absensiRepo)
+	autoAlphaHandler := handlers.NewAutoAlphaHandler(usulanAlphaRepo, absensiRepo, karyawanRepo, payrollRunRepo)
+	cutiHandler := handlers.NewCutiHandler(cutiRepo, karyawanRepo, userRepo, hariLiburRepo, payrollRunRepo)
+	presensiHandler := handlers.NewPresensiHandler(absensiRepo, presensiRepo, karyawanRepo, userRepo, payrollRunRepo)
+	fingerprintHandler := handlers.NewFingerprintHandler(absensiRepo, karyawanRepo, payrollRunRepo)
+	koreksiAbsensiHandler := handlers.NewKoreksiAbsensiHandler(koreksiAbsensiRepo, absensiRepo, karyawanRepo, userRepo, payrollRunRepo)
+	suratPerintahLemburHandler := handlers.NewSuratPerintahLemburHandler(suratPerintahLemburRepo, karyawanRepo, userRepo, payrollRunRepo)
 
 	// Initialize default admin user
 	if err := authHandler.InitAdmin(); err != nil {
@@ -60,6 +141,9 @@ func main() {
 		log.Println("Default admin user created: username=admin, password=admin123")
 	}
 
+	// Daily auto-alpha proposals, when enabled
+	autoAlphaHandler.MulaiJadwal()
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
 		AppName:      "Pemdes Payroll API",
@@ -89,7 +173,7 @@ func main() {
 	})
 
 	// Setup routes
-	routes.SetupRoutes(app, jabatanHandler, karyawanHandler, gajiHandler, laporanHandler, absensiHandler, lemburHandler, authHandler)
+	routes.SetupRoutes(app, jabatanHandler, karyawanHandler, gajiHandler, laporanHandler, absensiHandler, lemburHandler, authHandler, komponenGajiHandler, payrollRunHandler, koreksiGajiHandler, rapelHandler, runKhususHandler, kasbonHandler, jadwalKerjaHandler, hariLiburHandler, autoAlphaHandler, cutiHandler, presensiHandler, fingerprintHandler, koreksiAbsensiHandler, suratPerintahLemburHandler)
 
 	// Start server
 	port := ":3000"